@@ -0,0 +1,104 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/razziel89/mealie-addons/assign"
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/render"
+)
+
+// Set up endpoints below /admin that modify the library. They are only set up if a token is
+// given, which requests have to present as a bearer token.
+func setUpAdminEndpoints(
+	router *gin.Engine, token string, timeout time.Duration, mealie assign.RenameClient,
+) {
+	if token == "" || mealie == nil {
+		return
+	}
+	slog.Info("setting up admin endpoints")
+
+	admin := router.Group("/admin", bearerAuth(token))
+
+	admin.POST("/organisers/:kind/:organiser/rename", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		kind := c.Param("kind")
+		if kind != "categories" && kind != "tags" {
+			msg := fmt.Sprintf("unknown kind of organiser %s, use categories or tags", kind)
+			logFailure(c, http.StatusNotFound, msg)
+			c.String(http.StatusNotFound, msg)
+			return
+		}
+		dryRun := false
+		if value := c.Query("dry-run"); value != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(value); err != nil {
+				msg := fmt.Sprintf("failed to parse dry-run: %s", err.Error())
+				logFailure(c, http.StatusBadRequest, msg)
+				c.String(http.StatusBadRequest, msg)
+				return
+			}
+		}
+
+		renaming, err := assign.Rename(
+			ctx, mealie, kind, c.Param("organiser"), c.Query("to"), dryRun,
+		)
+		if timedOut(ctx, c, "while renaming") {
+			return
+		}
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, renaming)
+		case errors.Is(err, assign.ErrUnknownOrganiser):
+			logFailure(c, http.StatusNotFound, err.Error())
+			c.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, mealieclient.ErrReadOnly), errors.Is(err, mealieclient.ErrNotPermitted):
+			logFailure(c, http.StatusForbidden, err.Error())
+			c.String(http.StatusForbidden, err.Error())
+		default:
+			msg := fmt.Sprintf("failed to rename: %s", err.Error())
+			logFailure(c, http.StatusInternalServerError, msg)
+			c.String(http.StatusInternalServerError, msg)
+		}
+	})
+
+	// Show what pandoc received for the most recent conversion to a format, which helps to debug
+	// documents that do not look as expected.
+	admin.GET("/pandoc/defaults/:format", func(c *gin.Context) {
+		defaults, found := render.RecentPandocDefaults(c.Param("format"))
+		if !found {
+			msg := fmt.Sprintf("pandoc has not converted to %s yet", c.Param("format"))
+			logFailure(c, http.StatusNotFound, msg)
+			c.String(http.StatusNotFound, msg)
+			return
+		}
+		c.Data(http.StatusOK, "application/yaml", defaults)
+	})
+}
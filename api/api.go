@@ -0,0 +1,622 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package api contains the HTTP API.
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/razziel89/mealie-addons/assign"
+	"github.com/razziel89/mealie-addons/destination"
+	"github.com/razziel89/mealie-addons/mealieclient"
+	mediaprep "github.com/razziel89/mealie-addons/media"
+	"github.com/razziel89/mealie-addons/render"
+	"github.com/razziel89/mealie-addons/requestid"
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/tracing"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+const (
+	defaultTimeout    = 2 * time.Second
+	readHeaderTimeout = 5 * time.Second
+	// Seconds for which clients may reuse media without asking again.
+	mediaMaxAge = 3600
+)
+
+type healthResponse struct {
+	OK   bool   `json:"ok"`
+	UUID string `json:"uuid"`
+}
+
+// The reply of the readiness endpoint. The reason explains why the instance is not ready.
+type readyResponse struct {
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"`
+}
+
+var instanceUUID = uuid.New().String()
+
+// ResponseGenerator generates a document in a specific format from a list of recipes.
+type ResponseGenerator interface {
+	CommonName() string
+	Extension() string
+	MimeType() string
+	Response(context.Context, []mealieclient.Recipe, time.Time) ([]byte, error)
+}
+
+// RecipeSource provides recipes and their media.
+type RecipeSource interface {
+	GetRecipes(
+		ctx context.Context,
+		queryParams map[string][]string,
+	) ([]mealieclient.Recipe, []string, error)
+	GetSummaries(ctx context.Context, query *url.Values) ([]mealieclient.Recipe, error)
+	SelectSlugs(ctx context.Context, queryParams map[string][]string) ([]mealieclient.Slug, error)
+	GetRecipe(ctx context.Context, slug string) (mealieclient.Recipe, error)
+	ValidateQuery(ctx context.Context, queryParams map[string][]string) error
+	GetMedia(
+		ctx context.Context,
+		uuid string,
+		filename string,
+		middle string,
+	) (mealieclient.MediaDownload, error)
+}
+
+// Filename is the name of a file generated by gen at the given time.
+func Filename(gen ResponseGenerator, timestamp time.Time) string {
+	return fmt.Sprintf("recipes-%s.%s", timestamp.Format(time.RFC3339), gen.Extension())
+}
+
+func timedOut(ctx context.Context, c *gin.Context, msg string) bool {
+	select {
+	case <-ctx.Done():
+		err := ctx.Err()
+		msg := fmt.Sprintf("timeout %s: %s", msg, err.Error())
+		logFailure(c, http.StatusInternalServerError, msg)
+		c.String(http.StatusInternalServerError, msg)
+		return true
+	default:
+		return false
+	}
+}
+
+// SetUp sets up all endpoints. It returns a function that starts the server in the background and
+// a function that shuts it down within the given timeout. Recipe metadata provided via GraphQL and
+// the overview is cached for cacheTTL. Users may pass those pandoc flags on to the converter that
+// are in the pandocAllowlist. Users may put documents into the destinations instead of downloading
+// them. Holders of the admin token may modify the library via the organisers client. Presets
+// export documents with predefined query parameters and presentation. The readiness endpoint
+// replies that the instance is ready only if none of the readinessChecks fails.
+func SetUp(
+	iface string,
+	timeout time.Duration,
+	source RecipeSource,
+	generators []ResponseGenerator,
+	cacheTTL time.Duration,
+	pandocAllowlist []string,
+	debugToken string,
+	destinations []destination.Destination,
+	adminToken string,
+	organisers assign.RenameClient,
+	presets map[string]Preset,
+	auth Auth,
+	rateLimit RateLimit,
+	readinessChecks ReadinessChecks,
+) (func(), func(time.Duration) error) {
+	router := newRouter()
+	if auth.Enabled() {
+		slog.Info("requiring credentials for all endpoints")
+		router.Use(requireAuth(auth))
+	}
+	if rateLimit.Enabled() {
+		slog.Info(
+			"limiting exports per minute",
+			"perClient", rateLimit.PerClient, "global", rateLimit.Global, "burst", rateLimit.Burst,
+		)
+		router.Use(limitRate(rateLimit))
+	}
+	stats := newRenderStats()
+	destinationsByName := make(map[string]destination.Destination, len(destinations))
+	for _, dest := range destinations {
+		destinationsByName[dest.Name()] = dest
+	}
+
+	bookHandlers := make(map[string]gin.HandlerFunc, len(generators))
+	for _, generator := range generators {
+		slog.Info("setting up book endpoint", "format", generator.CommonName())
+		handler := func(c *gin.Context) {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			defer cancel()
+
+			export := summary.New()
+			ctx = summary.With(ctx, export)
+			ctx, cleanup := workdir.With(ctx)
+			defer cleanup()
+
+			// Whether to split the document is meant for us and not for mealie.
+			query := c.Request.URL.Query()
+			gen, err := ExtractSplit(generator, query)
+			if err != nil {
+				logFailure(c, http.StatusBadRequest, err.Error())
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			// So is where to put the document.
+			dest, err := extractDestination(destinationsByName, query)
+			if err != nil {
+				logFailure(c, http.StatusBadRequest, err.Error())
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+
+			now := time.Now()
+			// Set headers that trigger the download dialogue in the browser.
+			filename := Filename(gen, now)
+			c.Writer.Header().
+				Set("Content-Disposition", "attachment; filename="+filename)
+			c.Writer.Header().Set("Content-Type", gen.MimeType())
+
+			if timedOut(ctx, c, "before getting recipes") {
+				return
+			}
+
+			// So are pandoc flags.
+			ctx, ok := withPandocFlags(ctx, c, query, pandocAllowlist)
+			if !ok {
+				return
+			}
+
+			// So is metadata.
+			ctx = render.WithMetadata(ctx, render.ExtractMetadata(query))
+
+			// And so is what to do if no recipes match.
+			emptyResult, err := ExtractEmptyResult(query)
+			if err != nil {
+				logFailure(c, http.StatusBadRequest, err.Error())
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+
+			if !validQuery(ctx, c, source, query) {
+				return
+			}
+
+			// Books are generated anew only if the request or any matching recipe changed. Clients
+			// that already have the current version need not download it again.
+			cacheKey, modified := bookVersion(ctx, source, c.Request, query)
+			if cacheKey != "" && dest == nil && unmodifiedBook(c, cacheKey, modified) {
+				return
+			}
+			response, cached := books.get(cacheKey)
+			if cached {
+				slog.InfoContext(ctx, "serving cached book", "mimeType", gen.MimeType())
+			}
+
+			// TODO: merge with default query parameters taken from env var.
+			var recipes []mealieclient.Recipe
+			var warnings []string
+			if !cached {
+				recipes, warnings, err = GetRecipes(ctx, source, gen, query)
+			}
+
+			if timedOut(ctx, c, "while getting recipes") {
+				return
+			}
+
+			if err == nil && !cached {
+				slog.InfoContext(
+					ctx, "retrieved recipes", "recipes", len(recipes), "mimeType", gen.MimeType(),
+				)
+				export.SetRecipes(len(recipes))
+				render.OrderRecipes(recipes, query)
+				ctx = render.WithWarnings(ctx, warnings)
+			}
+
+			if err == nil && !cached && len(recipes) == 0 && emptyResult != EmptyResultDocument {
+				slog.InfoContext(ctx, "no recipes matched", "response", emptyResult)
+				c.Writer.Header().Del("Content-Disposition")
+				c.Writer.Header().Del("Content-Type")
+				if emptyResult == EmptyResultNoContent {
+					c.Status(http.StatusNoContent)
+				} else {
+					c.JSON(http.StatusNotFound, emptyResultResponse{
+						Error: "no recipes matched the query", Query: query,
+					})
+				}
+				return
+			}
+
+			// Generate the file that shall be downloaded. Downloads are written to a temporary file
+			// if possible so that large documents need not be kept in memory. Only those that are
+			// cached are read into memory.
+			var spooled *spooledDocument
+			fileGen, canSpool := gen.(FileGenerator)
+			if err == nil && !cached && canSpool && dest == nil {
+				spooled, err = spool(ctx, fileGen, recipes, now)
+				defer spooled.remove()
+				if err == nil && cacheKey != "" && books.fits(spooled.size) {
+					response, err = spooled.read()
+					books.put(cacheKey, response)
+				}
+			} else if err == nil && !cached {
+				response, err = gen.Response(ctx, recipes, now)
+				if err == nil && cacheKey != "" {
+					books.put(cacheKey, response)
+				}
+			}
+			var document io.Reader = bytes.NewReader(response)
+			size := int64(len(response))
+			if spooled != nil && response == nil {
+				document, size = spooled.file, spooled.size
+			}
+
+			if timedOut(ctx, c, "while generating the file") {
+				return
+			}
+
+			if err == nil && dest != nil {
+				slog.InfoContext(ctx,
+					"putting book into destination", "name", filename, "destination", dest.Name(),
+				)
+				err = dest.Put(ctx, filename, response)
+				if err == nil {
+					c.Writer.Header().Del("Content-Disposition")
+					c.Writer.Header().Del("Content-Type")
+					c.JSON(http.StatusOK, uploadResponse{
+						Destination: dest.Name(), Name: filename, Bytes: len(response),
+					})
+				}
+			} else if err == nil {
+				c.Writer.Header().Set("Content-Length", fmt.Sprint(size))
+
+				// Pass the file along.
+				var written int64
+				written, err = io.Copy(c.Writer, document)
+				slog.DebugContext(ctx, "sent book", "bytes", written, "expectedBytes", size)
+				if written != size && err == nil {
+					err = fmt.Errorf("failed to download everything")
+				}
+			}
+
+			export.Log(ctx, gen.CommonName(), int(size), err)
+			if err == nil {
+				if !cached {
+					elapsed := time.Since(now)
+					stats.recordRender(gen.CommonName(), len(recipes), int(size), elapsed)
+				}
+				slog.InfoContext(
+					ctx, "book endpoint accessed successfully", "mimeType", gen.MimeType(),
+				)
+				c.Status(http.StatusOK)
+			} else {
+				msg := fmt.Sprintf("unexpected error %s", err.Error())
+				logFailure(c, errorStatus(err), msg)
+				c.String(errorStatus(err), msg)
+			}
+		}
+		router.GET("/book/"+generator.CommonName(), handler)
+		bookHandlers[generator.CommonName()] = handler
+		setUpEstimateEndpoint(router, timeout, source, generator.CommonName(), stats)
+	}
+	setUpRecipeEndpoint(router, timeout, source, generators, pandocAllowlist)
+	setUpPresetEndpoint(router, presets, bookHandlers)
+	drainJobs := setUpJobEndpoints(
+		router, timeout, source, generators, pandocAllowlist, destinationsByName, stats,
+	)
+
+	slog.Info("setting up endpoint for media retrieval")
+	router.GET("/media/:uuid/:what/:filename", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		uuid, _ := splitMediaUUID(c.Param("uuid"))
+		what := c.Param("what")
+		filename := c.Param("filename")
+		// WebP images are converted only on request. See render.EnsureWebpImagesCanBeReplaced.
+		toJPEG := render.IsReplaceableImage(strings.TrimSuffix(filename, ".jpeg"))
+		if toJPEG {
+			filename = strings.TrimSuffix(filename, ".jpeg")
+		}
+		// SVG images are rasterized only on request. See render.RasterizeSvgImages.
+		rasterize := strings.HasSuffix(strings.ToLower(filename), ".svg.png")
+		if rasterize {
+			filename = strings.TrimSuffix(filename, ".png")
+		}
+
+		media, err := source.GetMedia(ctx, uuid, filename, what)
+
+		if err == nil {
+			slog.DebugContext(ctx, "preparing media", "uuid", uuid, "filename", filename)
+			prepare := mediaprep.PrepareKeepingWebP
+			if toJPEG {
+				prepare = mediaprep.Prepare
+			}
+			media.Content, media.Mime, err = prepare(ctx, media.Content, media.Mime)
+		}
+		if err == nil && rasterize {
+			slog.DebugContext(ctx, "rasterizing svg", "uuid", uuid, "filename", filename)
+			media.Content, err = mediaprep.RasterizeSVG(ctx, media.Content)
+			media.Mime = "image/png"
+		}
+
+		if err == nil {
+			stats.recordMedia(len(media.Content))
+			// Let clients revalidate instead of downloading the same media again.
+			hash := sha256.Sum256(media.Content)
+			etag := `"` + hex.EncodeToString(hash[:]) + `"`
+			c.Writer.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", mediaMaxAge))
+			c.Writer.Header().Set("ETag", etag)
+			if c.GetHeader("If-None-Match") == etag {
+				c.Status(http.StatusNotModified)
+				return
+			}
+			c.Writer.Header().Set("Content-Type", media.Mime)
+			c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+			if media.Mime == "image/svg+xml" {
+				// SVG images may contain scripts, which must not run with the origin of this
+				// instance when opened directly. Embedding them as images is unaffected.
+				c.Writer.Header().Set("Content-Security-Policy", "sandbox")
+				c.Writer.Header().Set("Content-Disposition", "attachment")
+			}
+			_, err = io.Copy(c.Writer, bytes.NewReader(media.Content))
+		}
+		if err == nil {
+			c.Status(http.StatusOK)
+		} else {
+			msg := fmt.Sprintf("unexpected error %s", err.Error())
+			logFailure(c, http.StatusInternalServerError, msg)
+			c.String(http.StatusInternalServerError, msg)
+		}
+	})
+
+	summaries := &recipeCache{source: source, ttl: cacheTTL}
+	if err := setUpGraphQLEndpoint(router, timeout, summaries); err != nil {
+		slog.Error("failed to set up graphql endpoint", "error", err)
+		os.Exit(1)
+	}
+	setUpOverviewEndpoint(router, timeout, summaries, generators)
+
+	setUpLivenessEndpoint(router)
+	setUpReadyEndpoint(router, readinessChecks)
+	setUpDebugEndpoints(router, debugToken)
+	setUpAdminEndpoints(router, adminToken, timeout, organisers)
+
+	return serve(iface, router, drainJobs)
+}
+
+// The liveness endpoint only tells that the process is alive, even if its dependencies cannot be
+// used. That way, orchestrators do not restart instances that wait for mealie. The health endpoint
+// is kept as an alias for existing setups.
+func setUpLivenessEndpoint(router *gin.Engine) {
+	slog.Info("setting up liveness endpoint")
+	alive := func(c *gin.Context) {
+		status := healthResponse{OK: true, UUID: instanceUUID}
+		c.JSON(http.StatusOK, status)
+	}
+	router.GET("/livez", alive)
+	router.GET("/health", alive)
+}
+
+// Addresses or networks of reverse proxies whose X-Forwarded-For and X-Real-IP headers are trusted
+// to name the client. Clients could otherwise claim any address.
+var trustedProxies []string
+
+// SetTrustedProxies sets the addresses or networks, e.g. "10.0.0.0/8", of the reverse proxies that
+// may name the clients they forward requests for. Without any, clients are identified by the
+// addresses they connect from.
+func SetTrustedProxies(proxies []string) error {
+	// Let gin validate them since it parses them the same way later on.
+	if err := gin.New().SetTrustedProxies(proxies); err != nil {
+		return fmt.Errorf("failed to parse trusted proxies: %s", err.Error())
+	}
+	trustedProxies = proxies
+	return nil
+}
+
+// Create a router that logs requests via the default logger. Gin's own request log cannot be
+// parsed by log aggregators and cannot be filtered by level.
+func newRouter() *gin.Engine {
+	router := gin.New()
+	// The proxies have been validated by SetTrustedProxies.
+	_ = router.SetTrustedProxies(trustedProxies)
+	router.Use(identifyRequest, logRequest, gin.Recovery())
+	return router
+}
+
+// Give every request an ID, or keep the one sent by the client, and attach it to the request's
+// context. That way, log messages of concurrent exports can be told apart. The ID is sent back.
+func identifyRequest(c *gin.Context) {
+	id := requestid.Use(c.GetHeader(requestid.Header))
+	c.Header(requestid.Header, id)
+	c.Request = c.Request.WithContext(requestid.With(c.Request.Context(), id))
+	c.Next()
+}
+
+// The endpoints that orchestrators probe.
+var probePaths = []string{"/livez", "/health", "/readyz"}
+
+// Log a request once it has been handled. Orchestrators probe liveness and readiness all the time,
+// which is why those requests are logged at debug level only.
+func logRequest(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	tracing.Route(c.Request.Context(), c.Request.Method, c.FullPath())
+	level := slog.LevelInfo
+	if slices.Contains(probePaths, c.Request.URL.Path) {
+		level = slog.LevelDebug
+	}
+	slog.Log(
+		c.Request.Context(), level, "handled request",
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"status", c.Writer.Status(),
+		"duration", time.Since(start).String(),
+		"client", c.ClientIP(),
+	)
+}
+
+// Create a server for the router that listens on iface. Return a function that starts the server in
+// the background and a function that shuts it down within the given timeout. Shutting down stops
+// accepting requests and waits for running ones, e.g. exports and their pandoc processes, and for
+// work that outlives requests, which the drain functions wait for.
+func serve(
+	iface string, router *gin.Engine, drains ...func(context.Context) error,
+) (func(), func(time.Duration) error) {
+	server := &http.Server{
+		Addr:              iface,
+		Handler:           tracing.Handler(router),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	if certificate != nil {
+		server.TLSConfig = &tls.Config{
+			GetCertificate: certificate.get,
+			MinVersion:     tls.VersionTLS12,
+		}
+	}
+
+	shutdownFn := func(timeout time.Duration) error {
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		slog.Info("shutting down the webserver", "timeout", timeout.String())
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to wait for running requests: %s", err.Error())
+		}
+		for _, drain := range drains {
+			if err := drain(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	runFn := func() {
+		// Listen right away so that clients can connect as soon as this returns.
+		listener, err := listen(iface)
+		if err != nil {
+			slog.Error("failed to listen", "interface", iface, "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			serveFn := server.Serve
+			if certificate != nil {
+				// The certificate is taken from the TLS config, not from files.
+				serveFn = func(listener net.Listener) error {
+					return server.ServeTLS(listener, "", "")
+				}
+			}
+			if err := serveFn(listener); err != nil && err != http.ErrServerClosed {
+				slog.Error("failed to serve", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	return runFn, shutdownFn
+}
+
+// HealthCheck verifies that this very instance can be reached at selfURL.
+func HealthCheck(selfURL string) error {
+	sleeptime := time.Second
+	retries := 30
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Duration(retries)*sleeptime)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, "GET", selfURL+"/livez", nil)
+	if err != nil {
+		return fmt.Errorf("failed to build health check request: %s", err.Error())
+	}
+
+	success := false
+	retry := 0
+	var response *http.Response
+	for !success {
+		response, err = healthCheckClient().Do(request)
+		if err == nil {
+			success = true
+		} else if retry >= retries {
+			return fmt.Errorf("failed to execute health check request: %s", err.Error())
+		} else {
+			retry++
+			time.Sleep(sleeptime)
+		}
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected reply for health check: %d", response.StatusCode)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read body for health check: %s", err.Error())
+	}
+
+	var status healthResponse
+	err = json.Unmarshal(body, &status)
+	if err != nil {
+		return fmt.Errorf(
+			"failed to parse body for health check: %s, body: %s",
+			err.Error(), string(body),
+		)
+	}
+
+	if status.UUID == instanceUUID {
+		slog.InfoContext(ctx, "health check successful")
+		return nil
+	}
+	return fmt.Errorf(
+		"instance UUID mismatch in health check, want '%s' but got '%s'",
+		instanceUUID, status.UUID,
+	)
+}
+
+// The client that the health check uses. The check identifies this instance by its UUID, which is
+// why it need not verify certificates, which are rarely valid for the addresses it uses. If the
+// server listens on a socket, the client connects to it.
+func healthCheckClient() *http.Client {
+	if certificate == nil && listenSocket == "" {
+		return http.DefaultClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if certificate != nil {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // #nosec:G402
+	}
+	dialListenSocket(transport)
+	return &http.Client{Transport: transport}
+}
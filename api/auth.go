@@ -0,0 +1,167 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+)
+
+// Requests to the OIDC provider time out after this long.
+const oidcTimeout = 30 * time.Second
+
+// Auth holds the credentials that clients have to present: a token, a username and a password, or
+// a JWT issued by an OIDC provider. Browsers ask for a username and a password on their own, which
+// is why they suit people downloading documents. Tokens suit scripts. JWTs suit deployments behind
+// the same single sign-on as mealie, e.g. via forward authentication of a reverse proxy.
+type Auth struct {
+	Token    string
+	Username string
+	Password string
+	// JWTs have to be issued by this issuer for this audience. Both are given together.
+	OIDCIssuer   string
+	OIDCAudience string
+	// The header that carries JWTs, with or without a "Bearer " prefix. Defaults to Authorization.
+	OIDCHeader string
+
+	// Set by Discover.
+	verifier *oidc.IDTokenVerifier
+}
+
+// Validate ensures that usernames and passwords as well as OIDC issuers and audiences are given
+// together.
+func (a Auth) Validate() error {
+	if (a.Username == "") != (a.Password == "") {
+		return fmt.Errorf("username and password have to be given together")
+	}
+	if (a.OIDCIssuer == "") != (a.OIDCAudience == "") {
+		return fmt.Errorf("oidc issuer and audience have to be given together")
+	}
+	return nil
+}
+
+// Discover retrieves the configuration of the OIDC provider, if any, which is needed to verify
+// JWTs. The provider's keys are retrieved whenever a JWT is signed with an unknown one, so keys may
+// be rotated.
+func (a *Auth) Discover() error {
+	if a.OIDCIssuer == "" {
+		return nil
+	}
+	// The provider keeps the context to retrieve keys later on, which is why it must not expire.
+	ctx := oidc.ClientContext(context.Background(), &http.Client{Timeout: oidcTimeout})
+	provider, err := oidc.NewProvider(ctx, a.OIDCIssuer)
+	if err != nil {
+		return fmt.Errorf("failed to discover oidc provider: %s", err.Error())
+	}
+	a.verifier = provider.Verifier(&oidc.Config{ClientID: a.OIDCAudience})
+	return nil
+}
+
+// Enabled determines whether clients have to present credentials.
+func (a Auth) Enabled() bool {
+	return a.Token != "" || a.Username != "" || a.OIDCIssuer != ""
+}
+
+// Allows determines whether a request presents valid credentials in its headers, which header
+// returns by name. The token has to be presented as a bearer token and the username and password
+// via basic authentication in the Authorization header.
+func (a Auth) Allows(ctx context.Context, header func(name string) string) bool {
+	if !a.Enabled() {
+		return true
+	}
+	authorization := header("Authorization")
+	if token, found := strings.CutPrefix(authorization, "Bearer "); found && a.Token != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) == 1 {
+		return true
+	}
+	if encoded, found := strings.CutPrefix(authorization, "Basic "); found && a.Username != "" {
+		return a.allowsBasic(encoded)
+	}
+	if a.verifier == nil {
+		return false
+	}
+	name := a.OIDCHeader
+	if name == "" {
+		name = "Authorization"
+	}
+	raw := strings.TrimPrefix(header(name), "Bearer ")
+	if raw == "" {
+		return false
+	}
+	if _, err := a.verifier.Verify(ctx, raw); err != nil {
+		slog.DebugContext(ctx, "rejected jwt", "error", err)
+		return false
+	}
+	return true
+}
+
+// Whether base64-encoded basic authentication credentials are valid.
+func (a Auth) allowsBasic(encoded string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	username, password, _ := strings.Cut(string(decoded), ":")
+	// Both are compared in any case so as not to reveal which one is wrong.
+	validUser := subtle.ConstantTimeCompare([]byte(username), []byte(a.Username))
+	validPassword := subtle.ConstantTimeCompare([]byte(password), []byte(a.Password))
+	return validUser&validPassword == 1
+}
+
+// Endpoints that never require credentials. Orchestrators probe liveness and readiness without any,
+// and debug and admin endpoints require tokens of their own in the same header.
+var publicPaths = append([]string{"/debug", "/admin"}, probePaths...)
+
+// Return a middleware that rejects requests that do not present valid credentials, except for
+// those to public endpoints. Browsers are asked for a username and a password if those are set.
+func requireAuth(auth Auth) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, public := range publicPaths {
+			path := c.Request.URL.Path
+			if path == public || strings.HasPrefix(path, public+"/") {
+				c.Next()
+				return
+			}
+		}
+		// Pandoc retrieves images without credentials. See SignMediaPath.
+		if isSignedMediaPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		if !auth.Allows(c.Request.Context(), c.GetHeader) {
+			if auth.Username != "" {
+				c.Header("WWW-Authenticate", `Basic realm="mealie-addons", charset="UTF-8"`)
+			} else {
+				c.Header("WWW-Authenticate", `Bearer realm="mealie-addons"`)
+			}
+			logFailure(c, http.StatusUnauthorized, "missing or invalid credentials")
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
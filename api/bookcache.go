@@ -0,0 +1,157 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Caches generated books. Nil means that nothing is cached.
+var books *bookCache
+
+// SetBookCache caches generated books in memory up to maxBytes, evicting the least recently used
+// ones first. A book is served from the cache as long as the request is the same and none of the
+// matching recipes has been added, removed, or updated since. A non-positive maxBytes disables
+// caching. Call this before setting up the endpoints.
+func SetBookCache(maxBytes int64) {
+	if maxBytes <= 0 {
+		books = nil
+		return
+	}
+	books = &bookCache{maxBytes: maxBytes, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+type bookEntry struct {
+	key      string
+	document []byte
+}
+
+type bookCache struct {
+	lock     sync.Mutex
+	maxBytes int64
+	bytes    int64
+	entries  map[string]*list.Element
+	// The most recently used entry is at the front.
+	order *list.List
+}
+
+// RecipesVersion identifies the matching recipes in the version they have. It changes whenever a
+// recipe is added, removed, or updated.
+func RecipesVersion(slugs []mealieclient.Slug) string {
+	versions := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		versions = append(versions, slug.ID+"@"+slug.UpdatedAt)
+	}
+	sort.Strings(versions)
+	hash := sha256.New()
+	for _, version := range versions {
+		hash.Write([]byte(version + "\x00"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Identify a book by the request and by the recipes that match it. The path distinguishes formats
+// and presets. Encoding the query sorts it, which makes the order of parameters irrelevant.
+func bookCacheKey(path string, query url.Values, slugs []mealieclient.Slug) string {
+	hash := sha256.New()
+	hash.Write([]byte(path + "\x00" + query.Encode() + "\x00" + RecipesVersion(slugs)))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// CacheBook stores a book as if it had been generated for a request to path with the query, e.g.
+// to have it ready before anybody asks for it. Nothing is stored if books are not cached.
+func CacheBook(path string, query url.Values, slugs []mealieclient.Slug, document []byte) {
+	books.put(bookCacheKey(path, query, slugs), document)
+}
+
+// CachesBooks determines whether generated books are cached.
+func CachesBooks() bool {
+	return books != nil
+}
+
+// Retrieve a book.
+func (c *bookCache) get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*bookEntry).document, true //nolint:forcetypeassert
+}
+
+// Determine whether a book of the given size would be kept.
+func (c *bookCache) fits(size int64) bool {
+	return c != nil && size <= c.maxBytes
+}
+
+// Store a book, evicting the least recently used ones if there is not enough space. Books larger
+// than the whole cache are not kept.
+func (c *bookCache) put(key string, document []byte) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	size := int64(len(document))
+	if _, found := c.entries[key]; found || size > c.maxBytes {
+		return
+	}
+	for c.bytes+size > c.maxBytes {
+		oldest := c.order.Back()
+		entry := c.order.Remove(oldest).(*bookEntry) //nolint:forcetypeassert
+		delete(c.entries, entry.key)
+		c.bytes -= int64(len(entry.document))
+	}
+	c.entries[key] = c.order.PushFront(&bookEntry{key: key, document: document})
+	c.bytes += size
+}
+
+// Determine the version of the book for a request, which is also the key to cache it under, and
+// when any of the matching recipes was modified last. The key is empty if the book shall not be
+// cached, e.g. because no recipe matched. Determining the version lists the matching recipes.
+func bookVersion(
+	ctx context.Context, source RecipeSource, req *http.Request, query map[string][]string,
+) (string, time.Time) {
+	slugs, err := source.SelectSlugs(ctx, query)
+	if err != nil {
+		slog.WarnContext(
+			ctx, "cannot determine book version, failed to select recipes", "error", err,
+		)
+		return "", time.Time{}
+	}
+	if len(slugs) == 0 {
+		return "", time.Time{}
+	}
+	return bookCacheKey(req.URL.Path, req.URL.Query(), slugs), lastModified(slugs)
+}
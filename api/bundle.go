@@ -0,0 +1,88 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Bundle returns a generator that generates a zip archive containing the documents of all the
+// given generators. Recipes are retrieved only once for all of them, which is much faster than
+// exporting each format separately.
+func Bundle(generators []ResponseGenerator) ResponseGenerator {
+	return &bundleGenerator{generators: append([]ResponseGenerator{}, generators...)}
+}
+
+type bundleGenerator struct {
+	generators []ResponseGenerator
+}
+
+// CommonName is the name of the format.
+func (g *bundleGenerator) CommonName() string {
+	return "all"
+}
+
+// Extension is the file extension of the format.
+func (g *bundleGenerator) Extension() string {
+	return "zip"
+}
+
+// MimeType is the mime type of the format.
+func (g *bundleGenerator) MimeType() string {
+	return "application/zip"
+}
+
+// Response generates one document per generator, one after the other to limit memory usage, and
+// adds them to a zip archive. The export fails if any of them fails.
+func (g *bundleGenerator) Response(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+) ([]byte, error) {
+	archive := bytes.Buffer{}
+	writer := zip.NewWriter(&archive)
+	for _, gen := range g.generators {
+		slog.InfoContext(ctx, "generating document for bundle", "format", gen.CommonName())
+		document, err := gen.Response(ctx, recipes, timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s: %s", gen.CommonName(), err.Error())
+		}
+		entry, err := writer.CreateHeader(&zip.FileHeader{
+			Name:     Filename(gen, timestamp),
+			Method:   zip.Deflate,
+			Modified: timestamp,
+		})
+		if err == nil {
+			_, err = entry.Write(document)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to bundle: %s", gen.CommonName(), err.Error())
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalise bundle: %s", err.Error())
+	}
+	return archive.Bytes(), nil
+}
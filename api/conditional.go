@@ -0,0 +1,83 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Formats of update times used by mealie. Times without a time zone are in UTC.
+var updateTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"}
+
+// Determine when any of the recipes was updated last. The result is zero if that is unknown.
+func lastModified(slugs []mealieclient.Slug) time.Time {
+	newest := time.Time{}
+	for _, slug := range slugs {
+		for _, layout := range updateTimeLayouts {
+			if updated, err := time.Parse(layout, slug.UpdatedAt); err == nil {
+				if updated.After(newest) {
+					newest = updated
+				}
+				break
+			}
+		}
+	}
+	return newest
+}
+
+// Set the ETag and Last-Modified headers of a book and reply with status 304 if the client's
+// version of the book, as stated via If-None-Match or If-Modified-Since, is still current. Return
+// whether the reply has been sent. The ETag also changes if recipes are removed or if the request
+// changes, which the time of the last modification does not reflect.
+func unmodifiedBook(c *gin.Context, etag string, modified time.Time) bool {
+	etag = `"` + etag + `"`
+	c.Header("ETag", etag)
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	unmodified := false
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		// If-Modified-Since is ignored if If-None-Match is present.
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				unmodified = true
+			}
+		}
+	} else if since := c.GetHeader("If-Modified-Since"); since != "" && !modified.IsZero() {
+		sinceTime, err := http.ParseTime(since)
+		unmodified = err == nil && !modified.Truncate(time.Second).After(sinceTime)
+	}
+	if !unmodified {
+		return false
+	}
+
+	slog.Info("client has the current version of the book already")
+	c.Writer.Header().Del("Content-Disposition")
+	c.Writer.Header().Del("Content-Type")
+	c.Status(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,68 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+	"slices"
+)
+
+// EmptyResultParam is the query parameter that determines the response if no recipes match.
+const EmptyResultParam = "onEmpty"
+
+// Possible responses if no recipes match a query.
+const (
+	// EmptyResultDocument generates a document explaining that no recipes matched.
+	EmptyResultDocument = "document"
+	// EmptyResultNotFound responds with status 404 and a JSON explanation.
+	EmptyResultNotFound = "not-found"
+	// EmptyResultNoContent responds with status 204 and no content.
+	EmptyResultNoContent = "no-content"
+)
+
+var emptyResults = []string{EmptyResultDocument, EmptyResultNotFound, EmptyResultNoContent}
+
+// DefaultEmptyResult is the response if no recipes match a query that does not specify the
+// response via EmptyResultParam.
+var DefaultEmptyResult = EmptyResultDocument
+
+type emptyResultResponse struct {
+	Error string              `json:"error"`
+	Query map[string][]string `json:"query"`
+}
+
+// ValidateEmptyResult verifies that a response for empty results is known.
+func ValidateEmptyResult(emptyResult string) error {
+	if !slices.Contains(emptyResults, emptyResult) {
+		return fmt.Errorf(
+			"unknown response %s for empty results, use one of %v", emptyResult, emptyResults,
+		)
+	}
+	return nil
+}
+
+// ExtractEmptyResult removes the query parameter that determines the response if no recipes match
+// from the query and returns that response. It defaults to DefaultEmptyResult.
+func ExtractEmptyResult(query map[string][]string) (string, error) {
+	emptyResult := DefaultEmptyResult
+	if values := query[EmptyResultParam]; len(values) != 0 {
+		emptyResult = values[0]
+	}
+	delete(query, EmptyResultParam)
+	return emptyResult, ValidateEmptyResult(emptyResult)
+}
@@ -0,0 +1,149 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/razziel89/mealie-addons/render"
+)
+
+// Statistics about past renders and media retrievals that are used to estimate future renders.
+type renderStats struct {
+	lock       sync.Mutex
+	formats    map[string]*formatStats
+	mediaCount int
+	mediaBytes int
+}
+
+type formatStats struct {
+	renders  int
+	recipes  int
+	bytes    int
+	duration time.Duration
+}
+
+func newRenderStats() *renderStats {
+	return &renderStats{formats: map[string]*formatStats{}}
+}
+
+func (s *renderStats) recordRender(format string, recipes int, bytes int, duration time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := s.formats[format]
+	if stats == nil {
+		stats = &formatStats{}
+		s.formats[format] = stats
+	}
+	stats.renders++
+	stats.recipes += recipes
+	stats.bytes += bytes
+	stats.duration += duration
+}
+
+func (s *renderStats) recordMedia(bytes int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.mediaCount++
+	s.mediaBytes += bytes
+}
+
+type estimateResponse struct {
+	Format      string  `json:"format"`
+	Recipes     int     `json:"recipes"`
+	Images      int     `json:"images"`
+	ImageBytes  int     `json:"imageBytes"`
+	Bytes       int     `json:"bytes"`
+	Seconds     float64 `json:"seconds"`
+	PastRenders int     `json:"pastRenders"`
+}
+
+// Estimate a render of the given format based on the average size and duration per recipe of past
+// renders. Sizes and durations are zero if there are no past renders to base the estimate on.
+func (s *renderStats) estimate(format string, recipes int, images int) estimateResponse {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := estimateResponse{Format: format, Recipes: recipes, Images: images}
+	if s.mediaCount > 0 {
+		result.ImageBytes = images * s.mediaBytes / s.mediaCount
+	}
+	if stats := s.formats[format]; stats != nil && stats.recipes > 0 {
+		result.PastRenders = stats.renders
+		result.Bytes = recipes * stats.bytes / stats.recipes
+		result.Seconds = float64(recipes) * stats.duration.Seconds() / float64(stats.recipes)
+	}
+	return result
+}
+
+// Set up an endpoint that estimates the effort of generating a document in the given format. Only
+// the slugs of matching recipes are retrieved, which is why only recipe images are counted.
+func setUpEstimateEndpoint(
+	router *gin.Engine,
+	timeout time.Duration,
+	source RecipeSource,
+	format string,
+	stats *renderStats,
+) {
+	slog.Info("setting up estimate endpoint", "format", format)
+	router.GET("/book/"+format+"/estimate", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		// Pandoc flags, metadata, splitting, uploading, and the response to empty results do not
+		// influence the estimate.
+		query := c.Request.URL.Query()
+		query.Del("pandoc")
+		query.Del(EmptyResultParam)
+		query.Del(SplitParam)
+		query.Del(UploadParam)
+		render.ExtractMetadata(query)
+
+		if !validQuery(ctx, c, source, query) {
+			return
+		}
+
+		slugs, err := source.SelectSlugs(ctx, query)
+		if timedOut(ctx, c, "while getting recipes") {
+			return
+		}
+		if err != nil {
+			msg := fmt.Sprintf("unexpected error %s", err.Error())
+			logFailure(c, errorStatus(err), msg)
+			c.String(errorStatus(err), msg)
+			return
+		}
+
+		images := 0
+		for _, slug := range slugs {
+			if slug.Image != "" {
+				images++
+			}
+		}
+		c.JSON(http.StatusOK, stats.estimate(format, len(slugs), images))
+	})
+}
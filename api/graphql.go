@@ -0,0 +1,249 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/render"
+)
+
+// Cache recipe summaries so that GraphQL queries do not cause a request to mealie each.
+type recipeCache struct {
+	lock    sync.Mutex
+	source  RecipeSource
+	ttl     time.Duration
+	fetched time.Time
+	recipes []mealieclient.Recipe
+}
+
+// Get all recipes, retrieving them from mealie if the cached ones are too old.
+func (c *recipeCache) get(ctx context.Context) ([]mealieclient.Recipe, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.recipes != nil && time.Since(c.fetched) < c.ttl {
+		return c.recipes, nil
+	}
+	slog.InfoContext(ctx, "refreshing recipe cache")
+	recipes, err := c.source.GetSummaries(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.recipes = recipes
+	c.fetched = time.Now()
+	return recipes, nil
+}
+
+// All organisers of the given kind used by any of the recipes, sorted by name.
+func usedOrganisers(
+	recipes []mealieclient.Recipe,
+	kind func(mealieclient.Recipe) []mealieclient.Organiser,
+) []mealieclient.Organiser {
+	organisers := map[string]mealieclient.Organiser{}
+	for _, recipe := range recipes {
+		for _, organiser := range kind(recipe) {
+			organisers[organiser.ID] = organiser
+		}
+	}
+	result := make([]mealieclient.Organiser, 0, len(organisers))
+	for _, organiser := range organisers {
+		result = append(result, organiser)
+	}
+	render.SortOrganisers(result)
+	return result
+}
+
+func tagsOf(recipe mealieclient.Recipe) []mealieclient.Organiser {
+	return recipe.Tags
+}
+
+func categoriesOf(recipe mealieclient.Recipe) []mealieclient.Organiser {
+	return recipe.Categories
+}
+
+func hasOrganiser(organisers []mealieclient.Organiser, name string) bool {
+	return slices.ContainsFunc(organisers, func(o mealieclient.Organiser) bool {
+		return o.Name == name
+	})
+}
+
+func newGraphQLSchema(cache *recipeCache) (graphql.Schema, error) {
+	// Fields are resolved by matching their names to those of the struct fields.
+	organiserType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Organiser",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphql.String},
+			"name": &graphql.Field{Type: graphql.String},
+			"slug": &graphql.Field{Type: graphql.String},
+		},
+	})
+	recipeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Recipe",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.String},
+			"slug":        &graphql.Field{Type: graphql.String},
+			"name":        &graphql.Field{Type: graphql.String},
+			"description": &graphql.Field{Type: graphql.String},
+			"servings":    &graphql.Field{Type: graphql.Float},
+			"totalTime":   &graphql.Field{Type: graphql.String},
+			"prepTime":    &graphql.Field{Type: graphql.String},
+			"performTime": &graphql.Field{Type: graphql.String},
+			"rating":      &graphql.Field{Type: graphql.Float},
+			"orgURL":      &graphql.Field{Type: graphql.String},
+			"categories":  &graphql.Field{Type: graphql.NewList(organiserType)},
+			"tags":        &graphql.Field{Type: graphql.NewList(organiserType)},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"recipes": &graphql.Field{
+				Type:        graphql.NewList(recipeType),
+				Description: "All recipes, optionally only those with a tag or category.",
+				Args: graphql.FieldConfigArgument{
+					"tag":      &graphql.ArgumentConfig{Type: graphql.String},
+					"category": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					recipes, err := cache.get(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					tag, filterTag := p.Args["tag"].(string)
+					category, filterCategory := p.Args["category"].(string)
+					result := make([]mealieclient.Recipe, 0, len(recipes))
+					for _, recipe := range recipes {
+						if filterTag && !hasOrganiser(recipe.Tags, tag) {
+							continue
+						}
+						if filterCategory && !hasOrganiser(recipe.Categories, category) {
+							continue
+						}
+						result = append(result, recipe)
+					}
+					return result, nil
+				},
+			},
+			"recipe": &graphql.Field{
+				Type:        recipeType,
+				Description: "The recipe with the given slug.",
+				Args: graphql.FieldConfigArgument{
+					"slug": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					recipes, err := cache.get(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					for _, recipe := range recipes {
+						if recipe.Slug == p.Args["slug"] {
+							return recipe, nil
+						}
+					}
+					return nil, nil
+				},
+			},
+			"tags": &graphql.Field{
+				Type:        graphql.NewList(organiserType),
+				Description: "All tags used by any recipe.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					recipes, err := cache.get(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					return usedOrganisers(recipes, tagsOf), nil
+				},
+			},
+			"categories": &graphql.Field{
+				Type:        graphql.NewList(organiserType),
+				Description: "All categories used by any recipe.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					recipes, err := cache.get(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					return usedOrganisers(recipes, categoriesOf), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Set up a read-only GraphQL endpoint for recipe metadata taken from the cache.
+func setUpGraphQLEndpoint(
+	router *gin.Engine,
+	timeout time.Duration,
+	recipes *recipeCache,
+) error {
+	schema, err := newGraphQLSchema(recipes)
+	if err != nil {
+		return fmt.Errorf("failed to build graphql schema: %s", err.Error())
+	}
+
+	slog.Info("setting up graphql endpoint")
+	handler := func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		var request graphQLRequest
+		if c.Request.Method == http.MethodGet {
+			request.Query = c.Query("query")
+			request.OperationName = c.Query("operationName")
+		} else if err := c.ShouldBindJSON(&request); err != nil {
+			msg := fmt.Sprintf("cannot parse graphql request: %s", err.Error())
+			logFailure(c, http.StatusBadRequest, msg)
+			c.String(http.StatusBadRequest, msg)
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  request.Query,
+			OperationName:  request.OperationName,
+			VariableValues: request.Variables,
+			Context:        ctx,
+		})
+		if result.HasErrors() {
+			slog.WarnContext(ctx, "graphql query failed", "errors", fmt.Sprint(result.Errors))
+		}
+		c.JSON(http.StatusOK, result)
+	}
+	router.GET("/graphql", handler)
+	router.POST("/graphql", handler)
+	return nil
+}
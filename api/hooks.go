@@ -0,0 +1,60 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// QueryHook modifies the query parameters used to retrieve recipes.
+type QueryHook func(context.Context, map[string][]string) (map[string][]string, error)
+
+// WithQueryHook returns a recipe source that lets the hook modify the query parameters before
+// recipes are selected or retrieved from the given source.
+func WithQueryHook(source RecipeSource, hook QueryHook) RecipeSource {
+	return &hookedSource{RecipeSource: source, hook: hook}
+}
+
+type hookedSource struct {
+	RecipeSource
+	hook QueryHook
+}
+
+func (s *hookedSource) GetRecipes(
+	ctx context.Context,
+	queryParams map[string][]string,
+) ([]mealieclient.Recipe, []string, error) {
+	queryParams, err := s.hook(ctx, queryParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.RecipeSource.GetRecipes(ctx, queryParams)
+}
+
+func (s *hookedSource) SelectSlugs(
+	ctx context.Context,
+	queryParams map[string][]string,
+) ([]mealieclient.Slug, error) {
+	queryParams, err := s.hook(ctx, queryParams)
+	if err != nil {
+		return nil, err
+	}
+	return s.RecipeSource.SelectSlugs(ctx, queryParams)
+}
@@ -0,0 +1,92 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/render"
+)
+
+// IndexPrefix precedes the names of formats to generate only an index of the library.
+const IndexPrefix = "index."
+
+// IndexOnly returns a generator that generates documents in the format of gen that list recipes
+// with their categories, tags, times, and links to mealie but without any further details. See
+// GetRecipes for how that avoids retrieving recipe details.
+func IndexOnly(gen ResponseGenerator) ResponseGenerator {
+	return &indexGenerator{generator: gen}
+}
+
+type indexGenerator struct {
+	generator ResponseGenerator
+}
+
+// CommonName is the name of the format, that of the wrapped generator with a prefix.
+func (g *indexGenerator) CommonName() string {
+	return IndexPrefix + g.generator.CommonName()
+}
+
+// Extension is the file extension of the format.
+func (g *indexGenerator) Extension() string {
+	return g.generator.Extension()
+}
+
+// MimeType is the mime type of the format.
+func (g *indexGenerator) MimeType() string {
+	return g.generator.MimeType()
+}
+
+// Response generates an index of the recipes.
+func (g *indexGenerator) Response(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+) ([]byte, error) {
+	return g.generator.Response(render.WithIndexOnly(ctx), recipes, timestamp)
+}
+
+// GetRecipes retrieves the recipes that gen needs to generate a document for the query. Indices
+// need only what mealie reports when listing recipes, which takes a single request per page of
+// recipes instead of one request per recipe. Recipe details are retrieved anyway if recipes are
+// selected or ordered by difficulty.
+func GetRecipes(
+	ctx context.Context,
+	source RecipeSource,
+	gen ResponseGenerator,
+	query map[string][]string,
+) ([]mealieclient.Recipe, []string, error) {
+	_, index := gen.(*indexGenerator)
+	if !index || len(query[mealieclient.MinDifficultyParam]) != 0 ||
+		len(query[mealieclient.MaxDifficultyParam]) != 0 ||
+		slices.Contains(query["orderBy"], mealieclient.OrderByDifficulty) {
+		return source.GetRecipes(ctx, query)
+	}
+	slugs, err := source.SelectSlugs(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	recipes := make([]mealieclient.Recipe, 0, len(slugs))
+	for _, slug := range slugs {
+		recipes = append(recipes, slug.Summary())
+	}
+	return recipes, nil, nil
+}
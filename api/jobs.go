@@ -0,0 +1,429 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/razziel89/mealie-addons/destination"
+	"github.com/razziel89/mealie-addons/render"
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+const (
+	// Finished jobs and their documents are forgotten after this time.
+	jobRetention = time.Hour
+	// At most this many jobs are kept track of. The jobs that finished first are forgotten early
+	// to make room for new ones. Further jobs are rejected if this many are queued or running.
+	maxJobs = 32
+)
+
+// States of export jobs.
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+// An export running in the background. Jobs run one after the other since exports are expensive.
+type exportJob struct {
+	ID       string     `json:"id"`
+	Format   string     `json:"format"`
+	State    string     `json:"state"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Recipes  int        `json:"recipes"`
+	Filename string     `json:"filename,omitempty"`
+	Bytes    int        `json:"bytes"`
+	// What a running job is doing at the moment, e.g. "retrieved 120/300 recipes".
+	Stage string `json:"stage,omitempty"`
+	// Set if the document was put into a destination, in which case it cannot be downloaded.
+	Destination string `json:"destination,omitempty"`
+	// Issues with the content of the document found while linting it, if enabled.
+	Lint []string `json:"lint,omitempty"`
+
+	mimeType string
+	document []byte
+	run      func(job *exportJob) error
+	// The context of the request that queued the job, which carries values for log messages.
+	ctx context.Context
+}
+
+// Keep track of export jobs and run them in the background.
+type exportJobs struct {
+	lock  sync.Mutex
+	jobs  map[string]*exportJob
+	queue chan *exportJob
+	// Closed and replaced whenever any job changes.
+	changed chan struct{}
+	// Set once the server shuts down. Queued jobs are not started anymore.
+	stopping bool
+}
+
+func newExportJobs() *exportJobs {
+	jobs := &exportJobs{
+		jobs:    map[string]*exportJob{},
+		queue:   make(chan *exportJob, maxJobs),
+		changed: make(chan struct{}),
+	}
+	go jobs.work()
+	return jobs
+}
+
+// Wake up everybody waiting for jobs to change. The lock has to be held.
+func (j *exportJobs) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// Forget jobs that finished long ago. The lock has to be held.
+func (j *exportJobs) prune() {
+	for id, job := range j.jobs {
+		if job.Finished != nil && time.Since(*job.Finished) > jobRetention {
+			delete(j.jobs, id)
+		}
+	}
+}
+
+// Forget the job that finished first. The lock has to be held.
+func (j *exportJobs) forgetOldestFinished() {
+	oldest := ""
+	var oldestFinished time.Time
+	for id, job := range j.jobs {
+		if job.Finished != nil && (oldest == "" || job.Finished.Before(oldestFinished)) {
+			oldest, oldestFinished = id, *job.Finished
+		}
+	}
+	delete(j.jobs, oldest)
+}
+
+// Queue a job that runs fn. Return a copy of the job or an error if there are too many jobs.
+func (j *exportJobs) enqueue(
+	ctx context.Context, format string, run func(job *exportJob) error,
+) (exportJob, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.prune()
+	if j.stopping {
+		return exportJob{}, fmt.Errorf("shutting down, try again later")
+	}
+	active := 0
+	for _, job := range j.jobs {
+		if job.Finished == nil {
+			active++
+		}
+	}
+	if active >= maxJobs {
+		return exportJob{}, fmt.Errorf("too many jobs, try again later")
+	}
+	// There is a finished job to forget since not all of them are active.
+	for len(j.jobs) >= maxJobs {
+		j.forgetOldestFinished()
+	}
+	job := &exportJob{
+		ID: uuid.New().String(), Format: format, State: jobQueued, Created: time.Now(),
+		run: run, ctx: ctx,
+	}
+	j.jobs[job.ID] = job
+	j.queue <- job
+	slog.InfoContext(ctx, "queued export job", "format", format, "job", job.ID)
+	return *job, nil
+}
+
+// Get a copy of a job.
+func (j *exportJobs) get(id string) (exportJob, bool) {
+	job, _, found := j.watch(id)
+	return job, found
+}
+
+// Get a copy of a job and a channel that is closed once it might have changed.
+func (j *exportJobs) watch(id string) (exportJob, <-chan struct{}, bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.prune()
+	job, found := j.jobs[id]
+	if !found {
+		return exportJob{}, nil, false
+	}
+	return *job, j.changed, true
+}
+
+// Record the stage that a running job has entered.
+func (j *exportJobs) setStage(id string, stage string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if job, found := j.jobs[id]; found {
+		job.Stage = stage
+		j.notify()
+	}
+}
+
+// Run queued jobs one after the other.
+func (j *exportJobs) work() {
+	for job := range j.queue {
+		j.lock.Lock()
+		if j.stopping {
+			finished := time.Now()
+			job.State, job.Error, job.Finished = jobFailed, "shut down before it started", &finished
+			job.run, job.ctx = nil, nil
+			j.notify()
+			j.lock.Unlock()
+			continue
+		}
+		job.State = jobRunning
+		j.notify()
+		// The job runs on a copy so that status requests need not wait for it.
+		running := *job
+		j.lock.Unlock()
+
+		slog.InfoContext(job.ctx, "running export job", "format", job.Format, "job", job.ID)
+		err := job.run(&running)
+		finished := time.Now()
+
+		j.lock.Lock()
+		running.Finished = &finished
+		running.State = jobSucceeded
+		if err != nil {
+			slog.ErrorContext(
+				job.ctx, "export job failed", "format", job.Format, "job", job.ID, "error", err,
+			)
+			running.State = jobFailed
+			running.Error = err.Error()
+			running.document = nil
+		} else {
+			slog.InfoContext(job.ctx, "export job succeeded", "format", job.Format, "job", job.ID)
+		}
+		running.run = nil
+		running.ctx = nil
+		running.Stage = ""
+		*job = running
+		j.notify()
+		j.lock.Unlock()
+	}
+}
+
+// Stop starting queued jobs and wait for the running one to finish, unless ctx is done first.
+func (j *exportJobs) drain(ctx context.Context) error {
+	j.lock.Lock()
+	j.stopping = true
+	j.notify()
+	j.lock.Unlock()
+	for logged := false; ; logged = true {
+		j.lock.Lock()
+		busy := 0
+		for _, job := range j.jobs {
+			if job.State == jobQueued || job.State == jobRunning {
+				busy++
+			}
+		}
+		changed := j.changed
+		j.lock.Unlock()
+		if busy == 0 {
+			return nil
+		}
+		if !logged {
+			slog.InfoContext(ctx, "waiting for export jobs to finish", "jobs", busy)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for %d export jobs: %s", busy, ctx.Err().Error())
+		case <-changed:
+		}
+	}
+}
+
+// Set up endpoints that export recipes in the background. A job is queued with the same query
+// parameters as the book endpoints. Its status and, once it succeeded, its document can be
+// retrieved later. That way, large exports do not run into timeouts of reverse proxies. The
+// returned function waits for running jobs when shutting down.
+func setUpJobEndpoints(
+	router *gin.Engine,
+	timeout time.Duration,
+	source RecipeSource,
+	generators []ResponseGenerator,
+	pandocAllowlist []string,
+	destinationsByName map[string]destination.Destination,
+	stats *renderStats,
+) func(context.Context) error {
+	slog.Info("setting up endpoints for export jobs")
+	jobs := newExportJobs()
+	byName := make(map[string]ResponseGenerator, len(generators))
+	for _, gen := range generators {
+		byName[gen.CommonName()] = gen
+	}
+
+	router.POST("/jobs/book/:format", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		generator, found := byName[c.Param("format")]
+		if !found {
+			msg := fmt.Sprintf("unknown format %s", c.Param("format"))
+			logFailure(c, http.StatusNotFound, msg)
+			c.String(http.StatusNotFound, msg)
+			return
+		}
+
+		// Special query parameters are handled just like for the book endpoints.
+		query := c.Request.URL.Query()
+		gen, err := ExtractSplit(generator, query)
+		var dest destination.Destination
+		if err == nil {
+			dest, err = extractDestination(destinationsByName, query)
+		}
+		var emptyResult string
+		if err == nil {
+			emptyResult, err = ExtractEmptyResult(query)
+		}
+		if err != nil {
+			logFailure(c, http.StatusBadRequest, err.Error())
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		ctx, ok := withPandocFlags(ctx, c, query, pandocAllowlist)
+		if !ok {
+			return
+		}
+		ctx = render.WithMetadata(ctx, render.ExtractMetadata(query))
+		if !validQuery(ctx, c, source, query) {
+			return
+		}
+		// The job keeps the values of the request's context but must outlive the request.
+		jobCtx := context.WithoutCancel(ctx)
+
+		job, err := jobs.enqueue(jobCtx, gen.CommonName(), func(job *exportJob) error {
+			ctx, cancel := context.WithTimeout(jobCtx, timeout)
+			defer cancel()
+			export := summary.New()
+			export.Observe(func(stage string) { jobs.setStage(job.ID, stage) })
+			ctx = summary.With(ctx, export)
+			ctx, cleanup := workdir.With(ctx)
+			defer cleanup()
+
+			now := time.Now()
+			recipes, warnings, err := GetRecipes(ctx, source, gen, query)
+			if err != nil {
+				return fmt.Errorf("failed to get recipes: %s", err.Error())
+			}
+			job.Recipes = len(recipes)
+			export.SetRecipes(len(recipes))
+			if len(recipes) == 0 && emptyResult != EmptyResultDocument {
+				return fmt.Errorf("no recipes matched the query")
+			}
+			render.OrderRecipes(recipes, query)
+			ctx = render.WithWarnings(ctx, warnings)
+
+			export.Stage("generating %s from %d recipes", gen.CommonName(), len(recipes))
+			response, err := gen.Response(ctx, recipes, now)
+			job.Filename, job.mimeType = Filename(gen, now), gen.MimeType()
+			job.Bytes = len(response)
+			job.Lint = export.LintWarnings()
+			if err == nil && dest != nil {
+				slog.InfoContext(ctx,
+					"putting document into destination",
+					"name", job.Filename, "destination", dest.Name(),
+				)
+				export.Stage("putting %s into %s", job.Filename, dest.Name())
+				err = dest.Put(ctx, job.Filename, response)
+				job.Destination = dest.Name()
+			} else {
+				job.document = response
+			}
+			export.Log(ctx, gen.CommonName(), len(response), err)
+			if err == nil {
+				stats.recordRender(gen.CommonName(), len(recipes), len(response), time.Since(now))
+			}
+			return err
+		})
+		if err != nil {
+			logFailure(c, http.StatusTooManyRequests, err.Error())
+			c.String(http.StatusTooManyRequests, err.Error())
+			return
+		}
+		c.Header("Location", "/jobs/"+job.ID)
+		c.JSON(http.StatusAccepted, job)
+	})
+
+	router.GET("/jobs/:id", func(c *gin.Context) {
+		job, found := jobs.get(c.Param("id"))
+		if !found {
+			c.String(http.StatusNotFound, fmt.Sprintf("unknown job %s", c.Param("id")))
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+
+	// Report every change of a job as a server-sent event until it has finished. That way, users
+	// see that long exports make progress.
+	router.GET("/jobs/:id/events", func(c *gin.Context) {
+		job, changed, found := jobs.watch(c.Param("id"))
+		if !found {
+			c.String(http.StatusNotFound, fmt.Sprintf("unknown job %s", c.Param("id")))
+			return
+		}
+		c.Stream(func(io.Writer) bool {
+			c.SSEvent(job.State, job)
+			if job.Finished != nil {
+				return false
+			}
+			// Several stages may pass while waiting. Only the latest one is reported.
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-changed:
+			}
+			job, changed, found = jobs.watch(job.ID)
+			return found
+		})
+	})
+
+	router.GET("/jobs/:id/result", func(c *gin.Context) {
+		job, found := jobs.get(c.Param("id"))
+		switch {
+		case !found:
+			c.String(http.StatusNotFound, fmt.Sprintf("unknown job %s", c.Param("id")))
+		case job.State != jobSucceeded:
+			c.JSON(http.StatusConflict, job)
+		case job.Destination != "":
+			msg := fmt.Sprintf("document was put into %s", job.Destination)
+			c.String(http.StatusNotFound, msg)
+		default:
+			c.Writer.Header().Set("Content-Disposition", "attachment; filename="+job.Filename)
+			c.Writer.Header().Set("Content-Type", job.mimeType)
+			c.Writer.Header().Set("Content-Length", fmt.Sprint(len(job.document)))
+			if _, err := io.Copy(c.Writer, bytes.NewReader(job.document)); err != nil {
+				slog.Error("failed to send result of job", "job", job.ID, "error", err)
+			}
+		}
+	})
+
+	return jobs.drain
+}
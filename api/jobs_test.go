@@ -0,0 +1,93 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Wait until the job with the given ID has finished.
+func waitForJob(t *testing.T, jobs *exportJobs, id string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for {
+		job, changed, found := jobs.watch(id)
+		if !found {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Finished != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("job %s did not finish", id)
+		case <-changed:
+		}
+	}
+}
+
+func TestEnqueueCapsActiveJobs(t *testing.T) {
+	jobs := newExportJobs()
+	done := func(*exportJob) error { return nil }
+
+	first, err := jobs.enqueue(context.Background(), "pdf", done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, jobs, first.ID)
+	// Finished jobs do not count towards the limit but make room for new ones.
+	ids := []string{first.ID}
+	for range maxJobs {
+		job, err := jobs.enqueue(context.Background(), "pdf", done)
+		if err != nil {
+			t.Fatalf("expected finished jobs not to count: %s", err.Error())
+		}
+		waitForJob(t, jobs, job.ID)
+		ids = append(ids, job.ID)
+	}
+	if _, found := jobs.get(first.ID); found {
+		t.Error("expected the oldest finished job to be forgotten")
+	}
+	if _, found := jobs.get(ids[len(ids)-1]); !found {
+		t.Error("expected the latest finished job to be kept")
+	}
+
+	// Queued and running jobs do count.
+	release := make(chan struct{})
+	defer close(release)
+	blocked := func(*exportJob) error {
+		<-release
+		return nil
+	}
+	for range maxJobs {
+		if _, err := jobs.enqueue(context.Background(), "pdf", blocked); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := jobs.enqueue(context.Background(), "pdf", blocked); err == nil {
+		t.Error("expected too many active jobs to be rejected")
+	}
+	jobs.lock.Lock()
+	defer jobs.lock.Unlock()
+	if len(jobs.jobs) != maxJobs {
+		t.Errorf("expected %d jobs to be kept track of, got %d", maxJobs, len(jobs.jobs))
+	}
+}
@@ -0,0 +1,82 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Media URLs carry a signature for the recipe after this separator, e.g.
+// "/media/<uuid>.<signature>/images/original.webp". Pandoc retrieves images without credentials,
+// and the signature lets it do so even if the API requires authentication. It only grants access
+// to the media of the recipe whose document refers to them.
+const mediaSignatureSeparator = "."
+
+// Length of media signatures in bytes before hex encoding.
+const mediaSignatureLength = 16
+
+// The key to sign media URLs with. It is random unless set via SetMediaKey.
+var mediaKey = rand.Text()
+
+// SetMediaKey sets the secret to sign media URLs with. Instances that produce documents with the
+// media of other instances, e.g. workers, need the same secret.
+func SetMediaKey(secret string) {
+	if secret != "" {
+		mediaKey = secret
+	}
+}
+
+func mediaSignature(uuid string) string {
+	mac := hmac.New(sha256.New, []byte(mediaKey))
+	_, _ = mac.Write([]byte("media:" + uuid))
+	return hex.EncodeToString(mac.Sum(nil)[:mediaSignatureLength])
+}
+
+// SignMediaPath adds a signature to a media path relative to the media endpoint, e.g.
+// "<uuid>/images/original.webp".
+func SignMediaPath(path string) string {
+	uuid, rest, found := strings.Cut(path, "/")
+	if !found || uuid == "" {
+		return path
+	}
+	return uuid + mediaSignatureSeparator + mediaSignature(uuid) + "/" + rest
+}
+
+// Split the uuid segment of a media path into the uuid and its signature, if any.
+func splitMediaUUID(segment string) (string, string) {
+	uuid, signature, _ := strings.Cut(segment, mediaSignatureSeparator)
+	return uuid, signature
+}
+
+// Determine whether path is that of the media endpoint with a valid signature.
+func isSignedMediaPath(path string) bool {
+	rest, found := strings.CutPrefix(path, "/media/")
+	if !found {
+		return false
+	}
+	segment, _, _ := strings.Cut(rest, "/")
+	uuid, signature := splitMediaUUID(segment)
+	if uuid == "" || signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(mediaSignature(uuid)))
+}
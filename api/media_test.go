@@ -0,0 +1,106 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/html"
+
+	"github.com/razziel89/mealie-addons/render"
+)
+
+// Find the source of the first image in the document.
+func firstImgSrc(node *html.Node) string {
+	if node.Type == html.ElementNode && node.Data == "img" {
+		for _, attr := range node.Attr {
+			if attr.Key == "src" {
+				return attr.Val
+			}
+		}
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if src := firstImgSrc(child); src != "" {
+			return src
+		}
+	}
+	return ""
+}
+
+func TestSignedMediaWithAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(requireAuth(Auth{Token: "secret"}))
+	router.GET("/media/:uuid/:what/:filename", func(c *gin.Context) {
+		uuid, _ := splitMediaUUID(c.Param("uuid"))
+		c.String(http.StatusOK, uuid+"/"+c.Param("what")+"/"+c.Param("filename"))
+	})
+
+	// Prepare the document of a recipe with an image the way exports do.
+	recipe := `<html><body><h1>Soup</h1>` +
+		`<img src="/api/media/recipes/abc-123/images/original.webp"></body></html>`
+	root, err := html.Parse(strings.NewReader(recipe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaURL := "http://127.0.0.1:9926/media/"
+	root, err = render.RewriteImgSources(root, "/api/media/recipes/", func(path string) string {
+		return mediaURL + SignMediaPath(path)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err = render.EnsureWebpImagesCanBeReplaced(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := firstImgSrc(root)
+	if !strings.HasPrefix(signed, mediaURL+"abc-123.") {
+		t.Fatalf("unexpected image source %s", signed)
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"signed", signed, http.StatusOK},
+		{"unsigned", mediaURL + "abc-123/images/original.webp", http.StatusUnauthorized},
+		{
+			"signature of other recipe",
+			strings.Replace(signed, "abc-123", "abc-124", 1),
+			http.StatusUnauthorized,
+		},
+		{"other endpoint", "http://127.0.0.1:9926/book/pdf", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Pandoc does not present any credentials.
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			if recorder.Code != test.status {
+				t.Fatalf("expected status %d, got %d", test.status, recorder.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// The range of font sizes in the tag cloud in units of em.
+const (
+	minTagSize = 0.8
+	maxTagSize = 2.5
+)
+
+var overviewTemplate = template.Must(template.New("overview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Recipe Overview</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.cloud { line-height: 2.2; text-align: center; }
+.cloud a { margin: 0 0.4em; text-decoration: none; white-space: nowrap; }
+.treemap { display: flex; flex-wrap: wrap; gap: 4px; }
+.treemap a {
+  background: #4a7ab0; color: white; min-height: 4em; min-width: 6em; padding: 0.5em;
+  box-sizing: border-box; text-decoration: none;
+}
+.treemap a:nth-child(even) { background: #6a9a5b; }
+.treemap a:nth-child(3n) { background: #b07a4a; }
+</style>
+</head>
+<body>
+<h1>Recipe Overview</h1>
+<p>{{.Recipes}} recipes. Follow a link to export the recipes as {{.Format}}.</p>
+<h2>Categories</h2>
+<div class="treemap">
+{{- range .Categories}}
+<a href="{{.Link}}" style="flex: {{.Count}} 1 {{.Share}}%;">{{.Name}}<br>{{.Count}}</a>
+{{- end}}
+</div>
+<h2>Tags</h2>
+<div class="cloud">
+{{- range .Tags}}
+<a href="{{.Link}}" style="font-size: {{.Size}}em;" title="{{.Count}} recipes">{{.Name}}</a>
+{{- end}}
+</div>
+</body>
+</html>
+`))
+
+type overviewOrganiser struct {
+	Name  string
+	Link  string
+	Count int
+	// The share of all recipes in percent, used for the area in the treemap.
+	Share string
+	// The font size in the tag cloud.
+	Size string
+}
+
+type overviewPage struct {
+	Recipes    int
+	Format     string
+	Categories []overviewOrganiser
+	Tags       []overviewOrganiser
+}
+
+// Build the entries for all organisers of the given kind used by any of the recipes. Each links to
+// the book endpoint of the format with the given query parameter selecting the organiser.
+func overviewOrganisers(
+	recipes []mealieclient.Recipe,
+	kind func(mealieclient.Recipe) []mealieclient.Organiser,
+	param string,
+	format string,
+) []overviewOrganiser {
+	counts := map[string]int{}
+	for _, recipe := range recipes {
+		for _, organiser := range kind(recipe) {
+			counts[organiser.ID]++
+		}
+	}
+	most := 1
+	for _, count := range counts {
+		most = max(most, count)
+	}
+
+	organisers := usedOrganisers(recipes, kind)
+	result := make([]overviewOrganiser, 0, len(organisers))
+	for _, organiser := range organisers {
+		count := counts[organiser.ID]
+		query := url.Values{param: {organiser.Slug}}
+		size := minTagSize + (maxTagSize-minTagSize)*float64(count)/float64(most)
+		share := 100 * float64(count) / float64(max(len(recipes), 1)) //nolint:mnd
+		result = append(result, overviewOrganiser{
+			Name:  organiser.Name,
+			Link:  fmt.Sprintf("book/%s?%s", format, query.Encode()),
+			Count: count,
+			Share: fmt.Sprintf("%.1f", share),
+			Size:  fmt.Sprintf("%.2f", size),
+		})
+	}
+	return result
+}
+
+// Set up an endpoint that shows how the library is organised. Categories are shown as a treemap and
+// tags as a cloud, the sizes of both depending on the number of recipes. Each links to an export of
+// its recipes in the format given via the query parameter format, PDF by default.
+func setUpOverviewEndpoint(
+	router *gin.Engine,
+	timeout time.Duration,
+	recipes *recipeCache,
+	generators []ResponseGenerator,
+) {
+	formats := map[string]bool{}
+	for _, gen := range generators {
+		formats[gen.CommonName()] = true
+	}
+
+	slog.Info("setting up overview endpoint")
+	router.GET("/overview", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		format := c.DefaultQuery("format", "pdf")
+		if !formats[format] {
+			msg := fmt.Sprintf("unknown format %s", format)
+			logFailure(c, http.StatusBadRequest, msg)
+			c.String(http.StatusBadRequest, msg)
+			return
+		}
+
+		summaries, err := recipes.get(ctx)
+		if err != nil {
+			msg := fmt.Sprintf("unexpected error %s", err.Error())
+			logFailure(c, errorStatus(err), msg)
+			c.String(errorStatus(err), msg)
+			return
+		}
+		page := overviewPage{
+			Recipes: len(summaries),
+			Format:  format,
+			Categories: overviewOrganisers(
+				summaries, func(r mealieclient.Recipe) []mealieclient.Organiser {
+					return r.Categories
+				}, "category", format,
+			),
+			Tags: overviewOrganisers(
+				summaries, func(r mealieclient.Recipe) []mealieclient.Organiser {
+					return r.Tags
+				}, "tag", format,
+			),
+		}
+
+		content := bytes.Buffer{}
+		if err := overviewTemplate.Execute(&content, page); err != nil {
+			msg := fmt.Sprintf("failed to render overview: %s", err.Error())
+			logFailure(c, http.StatusInternalServerError, msg)
+			c.String(http.StatusInternalServerError, msg)
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", content.Bytes())
+	})
+}
@@ -0,0 +1,78 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/razziel89/mealie-addons/render"
+)
+
+// Preset exports documents in a format with predefined query parameters and presentation, e.g. a
+// cookbook that is given as a gift. Query parameters of requests override those of the preset.
+type Preset struct {
+	Format       string              `json:"format"`
+	Params       map[string]string   `json:"params"`
+	Presentation render.Presentation `json:"presentation"`
+}
+
+// Set up an endpoint for each preset that behaves like the book endpoint of the preset's format.
+func setUpPresetEndpoint(
+	router *gin.Engine,
+	presets map[string]Preset,
+	bookHandlers map[string]gin.HandlerFunc,
+) {
+	if len(presets) == 0 {
+		return
+	}
+	for name, preset := range presets {
+		if _, found := bookHandlers[preset.Format]; !found {
+			slog.Error("unknown format for preset", "format", preset.Format, "preset", name)
+			os.Exit(1)
+		}
+	}
+	slog.Info("setting up endpoint for presets")
+
+	router.GET("/preset/:name", func(c *gin.Context) {
+		preset, found := presets[c.Param("name")]
+		if !found {
+			msg := fmt.Sprintf("unknown preset %s", c.Param("name"))
+			logFailure(c, http.StatusNotFound, msg)
+			c.String(http.StatusNotFound, msg)
+			return
+		}
+		query := c.Request.URL.Query()
+		for key, value := range preset.Params {
+			if _, set := query[key]; !set {
+				query[key] = []string{value}
+			}
+		}
+		c.Request.URL.RawQuery = query.Encode()
+		ctx := render.WithPresentation(c.Request.Context(), preset.Presentation)
+		c.Request = c.Request.WithContext(ctx)
+		slog.InfoContext(
+			ctx, "exporting preset", "preset", c.Param("name"), "format", preset.Format,
+		)
+		bookHandlers[preset.Format](c)
+	})
+}
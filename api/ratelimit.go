@@ -0,0 +1,177 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// Limiters of clients that sent no request for this long are forgotten.
+const clientLimiterRetention = time.Hour
+
+// At most this many client limiters are kept. The least recently seen client is forgotten first.
+const maxClientLimiters = 10000
+
+// Exports are expensive: each one retrieves hundreds of recipes from mealie and runs pandoc twice.
+// These endpoints start exports and are rate limited. Estimates are not since they are meant to be
+// asked for before exports.
+var rateLimitedPaths = []string{"/book/", "/recipe/", "/preset/", "/jobs/book/"}
+
+// RateLimit limits how many exports may be started per minute, both by every client as identified
+// by its IP address, see SetTrustedProxies, and by all clients together. Zero disables the
+// respective limit. Burst is the number of exports that may be started at once before limits kick
+// in.
+type RateLimit struct {
+	PerClient float64
+	Global    float64
+	Burst     int
+}
+
+// Validate ensures that limits are not negative and that bursts are possible if limits are set.
+func (r RateLimit) Validate() error {
+	if r.PerClient < 0 || r.Global < 0 {
+		return fmt.Errorf("rate limits must not be negative")
+	}
+	if r.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	return nil
+}
+
+// Enabled determines whether any limit is set.
+func (r RateLimit) Enabled() bool {
+	return r.PerClient > 0 || r.Global > 0
+}
+
+// The limiter of a single client and when it was last used.
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Keep track of the rate limiters of all clients and of the global one.
+type rateLimiters struct {
+	limit   RateLimit
+	lock    sync.Mutex
+	global  *rate.Limiter
+	clients map[string]*clientLimiter
+}
+
+func newRateLimiters(limit RateLimit) *rateLimiters {
+	limiters := &rateLimiters{limit: limit, clients: map[string]*clientLimiter{}}
+	if limit.Global > 0 {
+		limiters.global = newLimiter(limit.Global, limit.Burst)
+	}
+	return limiters
+}
+
+// Create a limiter that allows the given number of events per minute, at least one at once.
+func newLimiter(perMinute float64, burst int) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(perMinute/time.Minute.Seconds()), max(burst, 1))
+}
+
+// Reserve an export for a client. Return how long the client has to wait if it may not start one
+// now, in which case nothing is reserved.
+func (r *rateLimiters) reserve(client string) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := time.Now()
+
+	reservations := []*rate.Reservation{}
+	if r.limit.PerClient > 0 {
+		for name, limiter := range r.clients {
+			if now.Sub(limiter.lastSeen) > clientLimiterRetention {
+				delete(r.clients, name)
+			}
+		}
+		limiter, found := r.clients[client]
+		if !found && len(r.clients) >= maxClientLimiters {
+			r.evictOldest()
+		}
+		if !found {
+			limiter = &clientLimiter{limiter: newLimiter(r.limit.PerClient, r.limit.Burst)}
+			r.clients[client] = limiter
+		}
+		limiter.lastSeen = now
+		reservations = append(reservations, limiter.limiter.ReserveN(now, 1))
+	}
+	if r.global != nil {
+		reservations = append(reservations, r.global.ReserveN(now, 1))
+	}
+
+	wait := time.Duration(0)
+	for _, reservation := range reservations {
+		wait = max(wait, reservation.DelayFrom(now))
+	}
+	if wait > 0 {
+		// Clients that are turned away do not use up what other clients may use.
+		for _, reservation := range reservations {
+			reservation.CancelAt(now)
+		}
+	}
+	return wait
+}
+
+// Forget the client that was seen least recently.
+func (r *rateLimiters) evictOldest() {
+	oldest := ""
+	var oldestSeen time.Time
+	for name, limiter := range r.clients {
+		if oldest == "" || limiter.lastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = name, limiter.lastSeen
+		}
+	}
+	delete(r.clients, oldest)
+}
+
+// Return a middleware that rejects requests that would start an export if the client or all clients
+// together started too many of them recently. Rejected clients are told when to try again.
+func limitRate(limit RateLimit) gin.HandlerFunc {
+	limiters := newRateLimiters(limit)
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		limited := false
+		for _, prefix := range rateLimitedPaths {
+			limited = limited || strings.HasPrefix(path, prefix)
+		}
+		limited = limited && !strings.HasSuffix(path, "/estimate")
+		if !limited {
+			c.Next()
+			return
+		}
+		if wait := limiters.reserve(c.ClientIP()); wait > 0 {
+			seconds := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			msg := fmt.Sprintf("too many exports, try again in %d seconds", seconds)
+			logFailure(c, http.StatusTooManyRequests, msg)
+			c.String(http.StatusTooManyRequests, msg)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
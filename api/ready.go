@@ -0,0 +1,87 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// For how long the outcome of the readiness checks is reused. Orchestrators probe readiness every
+// few seconds, which would otherwise put load on mealie all the time.
+const readyCacheTTL = 10 * time.Second
+
+// ReadinessChecks maps the names of the dependencies of an instance to functions that verify that
+// the dependency can be used, e.g. that mealie can be reached. An instance is ready only if none
+// of them returns an error.
+type ReadinessChecks map[string]func() error
+
+// Run the readiness checks and remember their outcome for readyCacheTTL. Probes that arrive while
+// checks are running wait for them instead of running them again.
+type readiness struct {
+	lock    sync.Mutex
+	checks  ReadinessChecks
+	checked time.Time
+	reason  string
+}
+
+// Return the reason why the instance is not ready, which is empty if it is ready.
+func (r *readiness) check() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.checked.IsZero() && time.Since(r.checked) < readyCacheTTL {
+		return r.reason
+	}
+
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	failures := []string{}
+	for _, name := range names {
+		if err := r.checks[name](); err != nil {
+			slog.Warn("readiness check failed", "check", name, "error", err)
+			failures = append(failures, fmt.Sprintf("%s: %s", name, err.Error()))
+		}
+	}
+	r.reason = strings.Join(failures, "; ")
+	r.checked = time.Now()
+	return r.reason
+}
+
+// The readiness endpoint lets orchestrators know that requests may be sent, i.e. that all
+// dependencies of this instance can be used.
+func setUpReadyEndpoint(router *gin.Engine, checks ReadinessChecks) {
+	slog.Info("setting up readiness endpoint")
+	ready := &readiness{checks: checks}
+	router.GET("/readyz", func(c *gin.Context) {
+		if reason := ready.check(); reason != "" {
+			c.JSON(http.StatusServiceUnavailable, readyResponse{Ready: false, Reason: reason})
+			return
+		}
+		c.JSON(http.StatusOK, readyResponse{Ready: true})
+	})
+}
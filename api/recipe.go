@@ -0,0 +1,145 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/render"
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// Set up an endpoint that exports a single recipe, identified by its slug or ID, in the format of
+// any of the generators. It supports the same query parameters as the book endpoints except for
+// those that select recipes.
+func setUpRecipeEndpoint(
+	router *gin.Engine,
+	timeout time.Duration,
+	source RecipeSource,
+	generators []ResponseGenerator,
+	pandocAllowlist []string,
+) {
+	byName := make(map[string]ResponseGenerator, len(generators))
+	for _, gen := range generators {
+		byName[gen.CommonName()] = gen
+	}
+
+	slog.Info("setting up endpoint for single recipes")
+	router.GET("/recipe/:slug/:format", func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		slug := c.Param("slug")
+		gen, found := byName[c.Param("format")]
+		if !found {
+			msg := fmt.Sprintf("unknown format %s", c.Param("format"))
+			logFailure(c, http.StatusNotFound, msg)
+			c.String(http.StatusNotFound, msg)
+			return
+		}
+
+		export := summary.New()
+		ctx = summary.With(ctx, export)
+		ctx, cleanup := workdir.With(ctx)
+		defer cleanup()
+
+		query := c.Request.URL.Query()
+		ctx, ok := withPandocFlags(ctx, c, query, pandocAllowlist)
+		if !ok {
+			return
+		}
+		ctx = render.WithMetadata(ctx, render.ExtractMetadata(query))
+
+		now := time.Now()
+		recipe, err := source.GetRecipe(ctx, slug)
+		if timedOut(ctx, c, "while getting the recipe") {
+			return
+		}
+		if errors.Is(err, mealieclient.ErrRecipeNotFound) {
+			msg := fmt.Sprintf("unknown recipe %s", slug)
+			logFailure(c, http.StatusNotFound, msg)
+			c.String(http.StatusNotFound, msg)
+			return
+		}
+
+		var response []byte
+		if err == nil {
+			slog.InfoContext(ctx, "retrieved recipe", "slug", slug, "mimeType", gen.MimeType())
+			export.SetRecipes(1)
+			ctx = render.WithSingleRecipe(ctx, recipe.Name)
+			response, err = gen.Response(ctx, []mealieclient.Recipe{recipe}, now)
+		}
+		if timedOut(ctx, c, "while generating the file") {
+			return
+		}
+
+		if err == nil {
+			filename := fmt.Sprintf("%s.%s", recipe.Slug, gen.Extension())
+			c.Writer.Header().Set("Content-Disposition", "attachment; filename="+filename)
+			c.Writer.Header().Set("Content-Type", gen.MimeType())
+			c.Writer.Header().Set("Content-Length", fmt.Sprint(len(response)))
+			_, err = io.Copy(c.Writer, bytes.NewReader(response))
+		}
+
+		export.Log(ctx, gen.CommonName(), len(response), err)
+		if err == nil {
+			slog.InfoContext(
+				ctx, "recipe endpoint accessed successfully", "mimeType", gen.MimeType(),
+			)
+			c.Status(http.StatusOK)
+		} else {
+			msg := fmt.Sprintf("unexpected error %s", err.Error())
+			logFailure(c, http.StatusInternalServerError, msg)
+			c.String(http.StatusInternalServerError, msg)
+		}
+	})
+}
+
+// Pandoc flags are meant for us and not for mealie. Remove them from the query and validate them.
+// If they are invalid, reply with status 400. Return a context that makes converters use the flags
+// and whether they are valid.
+func withPandocFlags(
+	ctx context.Context,
+	c *gin.Context,
+	query map[string][]string,
+	pandocAllowlist []string,
+) (context.Context, bool) {
+	flags := query["pandoc"]
+	if len(flags) == 0 {
+		return ctx, true
+	}
+	if err := render.ValidatePandocFlags(flags, pandocAllowlist); err != nil {
+		msg := fmt.Sprintf("bad pandoc flags: %s", err.Error())
+		logFailure(c, http.StatusBadRequest, msg)
+		c.String(http.StatusBadRequest, msg)
+		return ctx, false
+	}
+	delete(query, "pandoc")
+	return render.WithPandocFlags(ctx, flags), true
+}
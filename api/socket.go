@@ -0,0 +1,84 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// Listen interfaces with this prefix are paths to Unix domain sockets.
+	socketPrefix = "unix:"
+	// Reverse proxies usually run as a different user, which has to be in the same group.
+	socketPermissions = 0o660
+)
+
+// The path of the socket that the server listens on, empty if it listens on a TCP port.
+var listenSocket string
+
+// SocketPath determines whether a listen interface such as unix:/run/mealie-addons.sock is a Unix
+// domain socket and returns its path if so.
+func SocketPath(iface string) (string, bool) {
+	path, found := strings.CutPrefix(iface, socketPrefix)
+	return path, found
+}
+
+// Listen on iface, which is either a TCP address or a Unix domain socket, see SocketPath. A socket
+// left behind by a previous instance that did not shut down cleanly is replaced. The socket is
+// removed once the listener is closed.
+func listen(iface string) (net.Listener, error) {
+	path, isSocket := SocketPath(iface)
+	if !isSocket {
+		return net.Listen("tcp", iface)
+	}
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("cannot listen on %s, file exists and is no socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %s", path, err.Error())
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketPermissions); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set permissions of socket %s: %s", path, err.Error())
+	}
+	listenSocket = path
+	return listener, nil
+}
+
+// Make a client connect to the socket that the server listens on, if any, whatever the host of the
+// URLs it requests.
+func dialListenSocket(transport *http.Transport) {
+	if listenSocket == "" {
+		return
+	}
+	path := listenSocket
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", path)
+	}
+}
@@ -0,0 +1,120 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/render"
+)
+
+// SplitParam is the query parameter that determines whether a single document containing all
+// recipes or an archive with one document per recipe is generated.
+const SplitParam = "split"
+
+// SplitPerRecipe generates a zip archive with one document per recipe.
+const SplitPerRecipe = "per-recipe"
+
+// ExtractSplit removes the query parameter that determines how to split documents from the query.
+// Return a generator that generates one document per recipe if requested, or gen otherwise.
+func ExtractSplit(
+	gen ResponseGenerator,
+	query map[string][]string,
+) (ResponseGenerator, error) {
+	split := ""
+	if values := query[SplitParam]; len(values) != 0 {
+		split = values[0]
+	}
+	delete(query, SplitParam)
+	switch split {
+	case "":
+		return gen, nil
+	case SplitPerRecipe:
+		return &splitGenerator{generator: gen}, nil
+	default:
+		return nil, fmt.Errorf("unknown split %s, use %s", split, SplitPerRecipe)
+	}
+}
+
+type splitGenerator struct {
+	generator ResponseGenerator
+}
+
+// CommonName is the name of the format. It differs from that of the wrapped generator to keep
+// statistics about past renders apart.
+func (g *splitGenerator) CommonName() string {
+	return g.generator.CommonName() + "-" + SplitPerRecipe
+}
+
+// Extension is the file extension of the format.
+func (g *splitGenerator) Extension() string {
+	return "zip"
+}
+
+// MimeType is the mime type of the format.
+func (g *splitGenerator) MimeType() string {
+	return "application/zip"
+}
+
+// Response generates one document per recipe, one after the other to limit memory usage, and adds
+// them to a zip archive. Each document is named after the slug of its recipe and laid out for a
+// single recipe. The export fails if any document cannot be generated.
+func (g *splitGenerator) Response(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+) ([]byte, error) {
+	archive := bytes.Buffer{}
+	writer := zip.NewWriter(&archive)
+	for _, recipe := range recipes {
+		name := recipe.Slug
+		if name == "" {
+			name = recipe.ID
+		}
+		slog.InfoContext(ctx,
+			"generating document for recipe", "format", g.generator.CommonName(), "recipe", name,
+		)
+		document, err := g.generator.Response(
+			render.WithSingleRecipe(ctx, recipe.Name), []mealieclient.Recipe{recipe}, timestamp,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate document for %s: %s", name, err.Error())
+		}
+		entry, err := writer.CreateHeader(&zip.FileHeader{
+			Name:     fmt.Sprintf("%s.%s", name, g.generator.Extension()),
+			Method:   zip.Deflate,
+			Modified: timestamp,
+		})
+		if err == nil {
+			_, err = entry.Write(document)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to archive: %s", name, err.Error())
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalise archive: %s", err.Error())
+	}
+	return archive.Bytes(), nil
+}
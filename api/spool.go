@@ -0,0 +1,103 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// FileGenerator is a ResponseGenerator that can also write documents to files directly. Downloads
+// of such documents are streamed from the file instead of being kept in memory as a whole.
+type FileGenerator interface {
+	ResponseGenerator
+	ResponseFile(
+		ctx context.Context,
+		recipes []mealieclient.Recipe,
+		timestamp time.Time,
+		path string,
+	) error
+}
+
+// A document that has been written to a temporary file.
+type spooledDocument struct {
+	file *os.File
+	size int64
+}
+
+// Generate a document and write it to a temporary file. Remove the file once it is no longer
+// needed, even if there was an error.
+func spool(
+	ctx context.Context,
+	gen FileGenerator,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+) (*spooledDocument, error) {
+	file, err := os.CreateTemp(workdir.Dir(ctx), workdir.Prefix+"download-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %s", err.Error())
+	}
+	spooled := &spooledDocument{file: file}
+	if err := gen.ResponseFile(ctx, recipes, timestamp, file.Name()); err != nil {
+		return spooled, err
+	}
+	// The generator may have replaced the file, so it is opened anew.
+	reopened, err := os.Open(file.Name())
+	if err == nil {
+		err = file.Close()
+		spooled.file = reopened
+	}
+	var info os.FileInfo
+	if err == nil {
+		info, err = reopened.Stat()
+	}
+	if err != nil {
+		return spooled, fmt.Errorf("failed to open generated document: %s", err.Error())
+	}
+	spooled.size = info.Size()
+	return spooled, nil
+}
+
+// Read the whole document into memory.
+func (d *spooledDocument) read() ([]byte, error) {
+	content, err := io.ReadAll(d.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generated document: %s", err.Error())
+	}
+	return content, nil
+}
+
+// Remove the temporary file. Nothing happens for nil documents.
+func (d *spooledDocument) remove() {
+	if d == nil {
+		return
+	}
+	if err := d.file.Close(); err != nil {
+		slog.Warn("failed to close spooled document", "path", d.file.Name(), "error", err)
+	}
+	if err := os.Remove(d.file.Name()); err != nil && !os.IsNotExist(err) {
+		slog.Warn("failed to remove spooled document", "path", d.file.Name(), "error", err)
+	}
+}
@@ -0,0 +1,81 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// The certificate that servers present if they serve HTTPS, nil if they serve plain HTTP.
+var certificate *reloadableCertificate
+
+// A certificate and key pair read from files that can be read again, e.g. once it was renewed.
+type reloadableCertificate struct {
+	certFile string
+	keyFile  string
+	lock     sync.RWMutex
+	current  *tls.Certificate
+}
+
+// Read the certificate from its files again. Keep the previous one on error.
+func (r *reloadableCertificate) reload() error {
+	loaded, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load tls certificate: %s", err.Error())
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.current = &loaded
+	return nil
+}
+
+func (r *reloadableCertificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.current, nil
+}
+
+// EnableTLS makes all servers that are set up afterwards serve HTTPS with the certificate and key
+// in the given PEM files instead of plain HTTP. The certificate file may contain intermediate
+// certificates after the leaf certificate.
+func EnableTLS(certFile string, keyFile string) error {
+	loaded := &reloadableCertificate{certFile: certFile, keyFile: keyFile}
+	if err := loaded.reload(); err != nil {
+		return err
+	}
+	certificate = loaded
+	slog.Info("serving https", "certFile", certFile, "keyFile", keyFile)
+	return nil
+}
+
+// ReloadTLS reads the certificate and key passed to EnableTLS again, e.g. after they have been
+// renewed. Connections established afterwards use the new certificate. On error, the previous
+// certificate is kept. It does nothing if TLS is not enabled.
+func ReloadTLS() error {
+	if certificate == nil {
+		return nil
+	}
+	if err := certificate.reload(); err != nil {
+		return err
+	}
+	slog.Info("reloaded tls certificate", "certFile", certificate.certFile)
+	return nil
+}
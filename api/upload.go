@@ -0,0 +1,52 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"fmt"
+
+	"github.com/razziel89/mealie-addons/destination"
+)
+
+// UploadParam is the query parameter that names the destination that a document is put into
+// instead of being downloaded.
+const UploadParam = "upload"
+
+type uploadResponse struct {
+	Destination string `json:"destination"`
+	Name        string `json:"name"`
+	Bytes       int    `json:"bytes"`
+}
+
+// Remove the query parameter that names the destination from the query and return the destination.
+// It is nil if the document shall be downloaded.
+func extractDestination(
+	destinations map[string]destination.Destination,
+	query map[string][]string,
+) (destination.Destination, error) {
+	values := query[UploadParam]
+	delete(query, UploadParam)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	dest, found := destinations[values[0]]
+	if !found {
+		return nil, fmt.Errorf("unknown or unconfigured upload destination %s", values[0])
+	}
+	return dest, nil
+}
@@ -0,0 +1,70 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Validate the query before anything is retrieved. Invalid queries cannot succeed, which is why we
+// reply with status 400 naming the offending parameter right away. Return whether the query is
+// valid.
+func validQuery(
+	ctx context.Context,
+	c *gin.Context,
+	source RecipeSource,
+	query map[string][]string,
+) bool {
+	err := source.ValidateQuery(ctx, query)
+	if err == nil {
+		return true
+	}
+	msg := fmt.Sprintf("unexpected error %s", err.Error())
+	if errorStatus(err) == http.StatusBadRequest {
+		msg = err.Error()
+	}
+	logFailure(c, errorStatus(err), msg)
+	c.String(errorStatus(err), msg)
+	return false
+}
+
+// Log why a request failed with the given status. Failures caused by clients are only warnings.
+func logFailure(c *gin.Context, status int, msg string) {
+	if status < http.StatusInternalServerError {
+		slog.WarnContext(c.Request.Context(), msg)
+	} else {
+		slog.ErrorContext(c.Request.Context(), msg)
+	}
+}
+
+// The status to reply with for an error.
+func errorStatus(err error) int {
+	var queryErr *mealieclient.QueryError
+	if errors.As(err, &queryErr) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,124 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/razziel89/mealie-addons/render"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// Formats that a worker is willing to convert to.
+var workerFormats = []string{"markdown_github", "epub", "pdf", "html", "docx", "odt"}
+
+// SetUpWorker sets up the endpoint that lets other instances offload conversions to this one. It
+// accepts a render.ConversionRequest via POST and replies with the converted document. Requests
+// may contain only those pandoc flags that are in the pandocAllowlist. Requests have to present
+// the token as a bearer token since conversions may be expensive. The readinessChecks and the
+// return values are identical to those of SetUp.
+func SetUpWorker(
+	iface string,
+	timeout time.Duration,
+	converter render.Converter,
+	pandocAllowlist []string,
+	token string,
+	debugToken string,
+	readinessChecks ReadinessChecks,
+) (func(), func(time.Duration) error) {
+	router := newRouter()
+
+	slog.Info("setting up endpoint for conversions")
+	router.POST("/convert", requireWorkerToken(token), func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		ctx, cleanup := workdir.With(ctx)
+		defer cleanup()
+
+		var request render.ConversionRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			msg := fmt.Sprintf("cannot parse conversion request: %s", err.Error())
+			logFailure(c, http.StatusBadRequest, msg)
+			c.String(http.StatusBadRequest, msg)
+			return
+		}
+		if !slices.Contains(workerFormats, request.Format) {
+			msg := fmt.Sprintf("cannot convert to unknown format %s", request.Format)
+			logFailure(c, http.StatusBadRequest, msg)
+			c.String(http.StatusBadRequest, msg)
+			return
+		}
+
+		if len(request.Flags) != 0 {
+			if err := render.ValidatePandocFlags(request.Flags, pandocAllowlist); err != nil {
+				msg := fmt.Sprintf("bad pandoc flags: %s", err.Error())
+				logFailure(c, http.StatusBadRequest, msg)
+				c.String(http.StatusBadRequest, msg)
+				return
+			}
+			ctx = render.WithPandocFlags(ctx, request.Flags)
+		}
+
+		ctx = render.WithMetadata(ctx, request.Metadata)
+
+		converted, err := converter.Convert(ctx, request.Markdown, request.Format, request.Title)
+
+		if timedOut(ctx, c, "while converting") {
+			return
+		}
+
+		if err == nil {
+			slog.InfoContext(ctx,
+				"converted markdown", "bytes", len(request.Markdown), "format", request.Format,
+			)
+			c.Data(http.StatusOK, "application/octet-stream", converted)
+		} else {
+			msg := fmt.Sprintf("unexpected error %s", err.Error())
+			logFailure(c, http.StatusInternalServerError, msg)
+			c.String(http.StatusInternalServerError, msg)
+		}
+	})
+
+	setUpLivenessEndpoint(router)
+	setUpReadyEndpoint(router, readinessChecks)
+	setUpDebugEndpoints(router, debugToken)
+
+	return serve(iface, router)
+}
+
+// Return a middleware that rejects requests that do not present the token as a bearer token.
+func requireWorkerToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			slog.Warn("rejecting conversion request without valid token")
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,396 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package assign assigns categories and tags to recipes based on queries.
+package assign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Data lists the names of organisers to set and unset.
+type Data struct {
+	Set   []string `json:"set"`
+	Unset []string `json:"unset"`
+}
+
+// Query is a query for recipes. The mode determines whether matching recipes are added to or
+// removed from the set of recipes that an assignment applies to. If NotCookedForDays is positive,
+// only recipes that have not been cooked for at least that many days match, e.g. to archive them.
+type Query struct {
+	Params           map[string]string `json:"params"`
+	Mode             string            `json:"mode"`
+	NotCookedForDays int               `json:"not-cooked-for-days"`
+}
+
+// The query parameters for mealie at the given time. Restrictions on when recipes were last cooked
+// are turned into a query filter since mealie only supports absolute dates.
+func (q Query) values(now time.Time) url.Values {
+	values := url.Values{}
+	for key, value := range q.Params {
+		if key != "queryFilter" {
+			values.Add(key, value)
+		}
+	}
+	filter := q.Params["queryFilter"]
+	if q.NotCookedForDays > 0 {
+		cutoff := now.AddDate(0, 0, -q.NotCookedForDays).Format(time.DateOnly)
+		notCooked := fmt.Sprintf(`(lastMade IS NULL OR lastMade < "%s")`, cutoff)
+		if filter == "" {
+			filter = notCooked
+		} else {
+			filter = fmt.Sprintf("(%s) AND %s", filter, notCooked)
+		}
+	}
+	if filter != "" {
+		values.Add("queryFilter", filter)
+	}
+	return values
+}
+
+// Assignment assigns categories and tags to all recipes matched by its queries. Disabled
+// assignments are skipped.
+type Assignment struct {
+	Queries    []Query `json:"queries"`
+	Categories Data    `json:"categories"`
+	Tags       Data    `json:"tags"`
+	Disabled   bool    `json:"disabled"`
+}
+
+// Assignments is the full configuration of the assignment loop. Additional assignments may be put
+// into the rules directory, one per file.
+type Assignments struct {
+	RepeatSecs  int          `json:"repeat-secs"`
+	TimeoutSecs int          `json:"timeout-secs"`
+	Assignments []Assignment `json:"assignments"`
+	RulesDir    string       `json:"rules-dir"`
+}
+
+// Client is what the assignment loop needs from a mealie client.
+type Client interface {
+	GetOrganisers(ctx context.Context, kind string) ([]mealieclient.Organiser, error)
+	GetSlugs(ctx context.Context, query *url.Values) ([]mealieclient.Slug, error)
+	GetRecipe(ctx context.Context, slug string) (mealieclient.Recipe, error)
+	SetOrganisers(ctx context.Context, recipe mealieclient.Recipe) error
+	Exclusive(ctx context.Context) (func(), error)
+}
+
+func updateSlice[T comparable](original []T, add []T, remove []T) ([]T, bool) {
+	wasChanged := false
+
+	asMap := make(map[T]bool, len(original))
+	for _, org := range original {
+		asMap[org] = true
+	}
+
+	length := len(asMap)
+	for _, addThis := range add {
+		asMap[addThis] = true
+	}
+	wasChanged = length != len(asMap)
+
+	length = len(asMap)
+	for _, rmThis := range remove {
+		delete(asMap, rmThis)
+	}
+	wasChanged = wasChanged || length != len(asMap)
+
+	asSlice := make([]T, 0, len(asMap))
+	for key := range asMap {
+		asSlice = append(asSlice, key)
+	}
+	return asSlice, wasChanged
+}
+
+func indexedSlice[T comparable](myMap map[string]T, indices []string) []T {
+	result := make([]T, 0, len(indices))
+	for _, index := range indices {
+		if value, found := myMap[index]; found {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// LaunchLoop starts the assignment loop in the background. Send to the returned channel to stop
+// it. If there are neither assignments nor a rules directory, no loop is started and the channel
+// is nil.
+func LaunchLoop(assignments Assignments, mealie Client) (chan<- bool, error) {
+	// Perform sanity checks first.
+	if !assignments.Configured() {
+		return nil, nil
+	}
+
+	repeatTime := time.Duration(assignments.RepeatSecs) * time.Second
+	nextWaitTime := time.Duration(0)
+
+	quit := make(chan bool)
+
+	go func() {
+		for {
+			select {
+			case <-quit:
+				return
+			case <-time.After(nextWaitTime):
+				startTime := time.Now()
+				err := RunOnce(assignments, mealie)
+				if err != nil {
+					slog.Error("round of assignments failed", "error", err)
+				}
+				timePassed := time.Since(startTime)
+				nextWaitTime = max(repeatTime-timePassed, 0)
+			}
+		}
+	}()
+
+	return quit, nil
+}
+
+// Rounds of assignments must not run concurrently, e.g. when triggered manually while the loop is
+// running, since they would otherwise work against each other.
+var roundLock sync.Mutex
+
+// RunOnce performs a single round of assignments. Failures to handle single queries or recipes do
+// not stop the round. They are joined into the returned error.
+func RunOnce(assignments Assignments, mealie Client) error {
+	roundLock.Lock()
+	defer roundLock.Unlock()
+
+	background := context.Background()
+	timeout := time.Duration(assignments.TimeoutSecs) * time.Second
+
+	// Exports shall not see a partially completed round.
+	ctx, cancel := context.WithTimeout(background, timeout)
+	release, err := mealie.Exclusive(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	skipAll := false
+
+	// Handle categories. First retrieval.
+	ctx, cancel = context.WithTimeout(background, timeout)
+	categoriesRaw, err := mealie.GetOrganisers(ctx, "categories")
+	if err != nil {
+		skipAll = true
+		slog.ErrorContext(ctx, "failed to retrieve categories", "error", err)
+	}
+	cancel()
+	// Then conversion to a nicer data structure.
+	categories := make([]string, 0, len(categoriesRaw))
+	categoriesMap := make(map[string]mealieclient.Organiser, len(categoriesRaw))
+	for _, category := range categoriesRaw {
+		categories = append(categories, category.Name)
+		categoriesMap[category.Name] = category
+	}
+	// Then logging.
+	slog.DebugContext(ctx, "known categories", "categories", strings.Join(categories, ", "))
+
+	// Handle tags. First retrieval.
+	ctx, cancel = context.WithTimeout(background, timeout)
+	tagsRaw, err := mealie.GetOrganisers(ctx, "tags")
+	if err != nil {
+		skipAll = true
+		slog.ErrorContext(ctx, "failed to retrieve tags", "error", err)
+	}
+	cancel()
+	// Then conversion to a nicer data structure.
+	tags := make([]string, 0, len(tagsRaw))
+	tagsMap := make(map[string]mealieclient.Organiser, len(categoriesRaw))
+	for _, tag := range tagsRaw {
+		tags = append(tags, tag.Name)
+		tagsMap[tag.Name] = tag
+	}
+	// Then logging.
+	slog.DebugContext(ctx, "known tags", "tags", strings.Join(tags, ", "))
+
+	if skipAll {
+		return fmt.Errorf("failed to retrieve categories or tags")
+	}
+
+	// Perform actions for each assignment. Failures do not stop the round but are reported at the
+	// end.
+	failures := []error{}
+	enabled := assignments.enabled()
+	numAssignments := len(enabled)
+	for assignmentIdx, assignment := range enabled {
+		skipThis := false
+		// Check whether all referenced tags and categories are known.
+		for _, category := range assignment.Categories.Set {
+			if !slices.Contains(categories, category) {
+				slog.WarnContext(ctx,
+					"skipping assignment, category not known",
+					"assignment", assignmentIdx+1, "category", category,
+				)
+				skipThis = true
+			}
+		}
+		for _, category := range assignment.Categories.Unset {
+			if !slices.Contains(categories, category) {
+				slog.WarnContext(ctx,
+					"skipping assignment, category not known",
+					"assignment", assignmentIdx+1, "category", category,
+				)
+				skipThis = true
+			}
+		}
+		for _, tag := range assignment.Tags.Set {
+			if !slices.Contains(tags, tag) {
+				slog.WarnContext(ctx,
+					"skipping assignment, tag not known",
+					"assignment", assignmentIdx+1, "tag", tag,
+				)
+				skipThis = true
+			}
+		}
+		for _, tag := range assignment.Tags.Unset {
+			if !slices.Contains(tags, tag) {
+				slog.WarnContext(ctx,
+					"skipping assignment, tag not known",
+					"assignment", assignmentIdx+1, "tag", tag,
+				)
+				skipThis = true
+			}
+		}
+		if skipThis {
+			continue
+		}
+
+		recipeSlugsRetention := map[string]bool{}
+		ctx, cancel = context.WithTimeout(background, timeout)
+		for queryIdx, query := range assignment.Queries {
+			// Check whether this query's mode is known.
+			switch query.Mode {
+			case "add", "remove":
+				// Retrieve recipe slugs that match this query.
+				queryVals := query.values(time.Now())
+				slog.DebugContext(ctx,
+					"built query string", "assignment", assignmentIdx+1, "query", queryIdx+1,
+					"values", queryVals.Encode(),
+				)
+				querySlugs, err := mealie.GetSlugs(ctx, &queryVals)
+				if err != nil {
+					slog.ErrorContext(ctx, "failed to retrieve recipes", "error", err)
+					failures = append(failures, fmt.Errorf(
+						"failed to retrieve recipes for query %d of assignment %d: %s",
+						queryIdx+1, assignmentIdx+1, err.Error(),
+					))
+					continue
+				}
+				slog.InfoContext(ctx,
+					"recipes matched query",
+					"assignment", assignmentIdx+1, "query", queryIdx+1,
+					"mode", query.Mode, "recipes", len(querySlugs),
+				)
+				if query.Mode == "add" {
+					for _, slug := range querySlugs {
+						recipeSlugsRetention[slug.Slug] = true
+					}
+				} else {
+					for _, slug := range querySlugs {
+						recipeSlugsRetention[slug.Slug] = false
+					}
+				}
+			case "skip":
+				slog.InfoContext(ctx,
+					"skipping query due to mode setting",
+					"assignment", assignmentIdx+1, "query", queryIdx+1,
+				)
+				continue
+			default:
+				slog.WarnContext(ctx,
+					"skipping query with unknown mode",
+					"assignment", assignmentIdx+1, "query", queryIdx+1, "mode", query.Mode,
+				)
+				continue
+			}
+		}
+		cancel()
+
+		recipeSlugs := make([]string, 0, len(recipeSlugsRetention))
+		for slug, keep := range recipeSlugsRetention {
+			if keep {
+				recipeSlugs = append(recipeSlugs, slug)
+			}
+		}
+
+		// Assign everything for each matched recipe.
+		numSlugs := len(recipeSlugs)
+		if numSlugs == 0 {
+			slog.InfoContext(ctx,
+				"no recipes to process for assignment",
+				"assignment", assignmentIdx+1, "assignments", numAssignments,
+			)
+		}
+		for slugIdx, slug := range recipeSlugs {
+			slog.InfoContext(ctx,
+				"processing recipe for assignment",
+				"recipe", slugIdx+1, "recipes", numSlugs,
+				"assignment", assignmentIdx+1, "assignments", numAssignments,
+			)
+			ctx, cancel = context.WithTimeout(background, timeout)
+			recipe, err := mealie.GetRecipe(ctx, slug)
+			cancel()
+			if err != nil {
+				slog.WarnContext(ctx,
+					"skipping recipe that failed to yield details", "slug", slug, "error", err,
+				)
+				failures = append(failures, fmt.Errorf(
+					"failed to retrieve recipe %s for assignment %d: %s",
+					slug, assignmentIdx+1, err.Error(),
+				))
+				continue
+			}
+			var categoriesChanged, tagsChanged bool
+			recipe.Categories, categoriesChanged = updateSlice(
+				recipe.Categories,
+				indexedSlice(categoriesMap, assignment.Categories.Set),
+				indexedSlice(categoriesMap, assignment.Categories.Unset),
+			)
+			recipe.Tags, tagsChanged = updateSlice(
+				recipe.Tags,
+				indexedSlice(tagsMap, assignment.Tags.Set),
+				indexedSlice(tagsMap, assignment.Tags.Unset),
+			)
+			if categoriesChanged || tagsChanged {
+				ctx, cancel = context.WithTimeout(background, timeout)
+				err = mealie.SetOrganisers(ctx, recipe)
+				cancel()
+				if err != nil {
+					slog.ErrorContext(ctx, "failed to update organisers", "error", err)
+					failures = append(failures, fmt.Errorf(
+						"failed to update organisers of recipe %s for assignment %d: %s",
+						slug, assignmentIdx+1, err.Error(),
+					))
+				}
+			}
+		}
+	}
+	return errors.Join(failures...)
+}
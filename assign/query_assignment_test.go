@@ -0,0 +1,101 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package assign
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// A mealie client that knows a single tag and fails to update some recipes.
+type fakeClient struct {
+	slugs   []string
+	failing map[string]bool
+	updated []string
+}
+
+func (f *fakeClient) GetOrganisers(
+	_ context.Context, kind string,
+) ([]mealieclient.Organiser, error) {
+	if kind == "tags" {
+		return []mealieclient.Organiser{{ID: "1", Name: "quick", Slug: "quick"}}, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeClient) GetSlugs(_ context.Context, _ *url.Values) ([]mealieclient.Slug, error) {
+	slugs := []mealieclient.Slug{}
+	for _, slug := range f.slugs {
+		slugs = append(slugs, mealieclient.Slug{Slug: slug})
+	}
+	return slugs, nil
+}
+
+func (f *fakeClient) GetRecipe(_ context.Context, slug string) (mealieclient.Recipe, error) {
+	return mealieclient.Recipe{Slug: slug}, nil
+}
+
+func (f *fakeClient) SetOrganisers(_ context.Context, recipe mealieclient.Recipe) error {
+	if f.failing[recipe.Slug] {
+		return fmt.Errorf("mealie refused %s", recipe.Slug)
+	}
+	f.updated = append(f.updated, recipe.Slug)
+	return nil
+}
+
+func (f *fakeClient) Exclusive(_ context.Context) (func(), error) {
+	return func() {}, nil
+}
+
+func TestRunOnceReportsFailures(t *testing.T) {
+	assignments := Assignments{
+		TimeoutSecs: 10,
+		Assignments: []Assignment{{
+			Queries: []Query{{Mode: "add"}},
+			Tags:    Data{Set: []string{"quick"}},
+		}},
+	}
+
+	client := &fakeClient{slugs: []string{"soup", "salad"}}
+	if err := RunOnce(assignments, client); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+
+	client = &fakeClient{
+		slugs:   []string{"soup", "salad", "stew"},
+		failing: map[string]bool{"soup": true, "stew": true},
+	}
+	err := RunOnce(assignments, client)
+	if err == nil {
+		t.Fatal("expected failures to be reported")
+	}
+	for _, slug := range []string{"soup", "stew"} {
+		if !strings.Contains(err.Error(), "mealie refused "+slug) {
+			t.Errorf("failure for %s missing from error: %s", slug, err.Error())
+		}
+	}
+	// Failures do not stop the round.
+	if len(client.updated) != 1 || client.updated[0] != "salad" {
+		t.Errorf("expected the remaining recipe to be updated, got %v", client.updated)
+	}
+}
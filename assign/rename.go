@@ -0,0 +1,165 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package assign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// ErrUnknownOrganiser is returned when renaming an organiser that does not exist.
+var ErrUnknownOrganiser = errors.New("unknown organiser")
+
+// RenameClient is what renaming organisers needs from a mealie client.
+type RenameClient interface {
+	Client
+	CreateOrganiser(ctx context.Context, kind string, name string) (mealieclient.Organiser, error)
+	DeleteOrganiser(ctx context.Context, kind string, id string) error
+}
+
+// Renaming describes what renaming an organiser changes. The new organiser exists already if it is
+// merged into. It has no ID if it would be created by a dry run.
+type Renaming struct {
+	Kind    string                 `json:"kind"`
+	From    mealieclient.Organiser `json:"from"`
+	To      mealieclient.Organiser `json:"to"`
+	Merged  bool                   `json:"merged"`
+	Recipes []string               `json:"recipes"`
+	DryRun  bool                   `json:"dry-run"`
+}
+
+// Find an organiser by its ID, slug, or name. Names are compared case-insensitively.
+func findOrganiser(organisers []mealieclient.Organiser, key string) (mealieclient.Organiser, bool) {
+	key = strings.TrimSpace(key)
+	for _, org := range organisers {
+		if org.ID == key || org.Slug == key || strings.EqualFold(org.Name, key) {
+			return org, true
+		}
+	}
+	return mealieclient.Organiser{}, false
+}
+
+// Replace the organiser "from" by "to" in a list of organisers. Return whether anything changed.
+func replaceOrganiser(
+	organisers []mealieclient.Organiser, from mealieclient.Organiser, to mealieclient.Organiser,
+) ([]mealieclient.Organiser, bool) {
+	result := make([]mealieclient.Organiser, 0, len(organisers))
+	changed := false
+	hasTarget := false
+	for _, org := range organisers {
+		if org.ID == from.ID {
+			changed = true
+			continue
+		}
+		hasTarget = hasTarget || org.ID == to.ID
+		result = append(result, org)
+	}
+	if changed && !hasTarget {
+		result = append(result, to)
+	}
+	return result, changed
+}
+
+// Rename renames the category or tag "from", identified by its ID, slug, or name, to "to". Instead
+// of renaming it in place, which can leave a stale slug behind, a new organiser is created, all
+// recipes are reassigned to it, and the old organiser is deleted. If an organiser with the new
+// name exists already, the old one is merged into it. A dry run only reports what would change.
+// The old organiser is kept if any recipe could not be reassigned.
+func Rename(
+	ctx context.Context, mealie RenameClient, kind string, from string, to string, dryRun bool,
+) (Renaming, error) {
+	renaming := Renaming{Kind: kind, DryRun: dryRun, Recipes: []string{}}
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return renaming, fmt.Errorf("the new name must not be empty")
+	}
+
+	// Rounds of assignments would otherwise assign the old organiser again.
+	roundLock.Lock()
+	defer roundLock.Unlock()
+	release, err := mealie.Exclusive(ctx)
+	if err != nil {
+		return renaming, err
+	}
+	defer release()
+
+	organisers, err := mealie.GetOrganisers(ctx, kind)
+	if err != nil {
+		return renaming, fmt.Errorf("failed to retrieve %s: %s", kind, err.Error())
+	}
+	var found bool
+	if renaming.From, found = findOrganiser(organisers, from); !found {
+		return renaming, fmt.Errorf("%w: %s", ErrUnknownOrganiser, from)
+	}
+	renaming.To, renaming.Merged = findOrganiser(organisers, to)
+	if !renaming.Merged {
+		renaming.To = mealieclient.Organiser{Name: to}
+	} else if renaming.To.ID == renaming.From.ID {
+		return renaming, fmt.Errorf("%s is already named %s", from, renaming.From.Name)
+	}
+
+	slugs, err := mealie.GetSlugs(ctx, &url.Values{kind: []string{renaming.From.ID}})
+	if err != nil {
+		return renaming, fmt.Errorf("failed to retrieve recipes: %s", err.Error())
+	}
+	for _, slug := range slugs {
+		renaming.Recipes = append(renaming.Recipes, slug.Slug)
+	}
+	slog.InfoContext(ctx,
+		"renaming organiser", "kind", kind, "from", renaming.From.Name, "to", to,
+		"recipes", len(renaming.Recipes), "dryRun", dryRun,
+	)
+	if dryRun {
+		return renaming, nil
+	}
+
+	if !renaming.Merged {
+		if renaming.To, err = mealie.CreateOrganiser(ctx, kind, to); err != nil {
+			return renaming, err
+		}
+	}
+	for _, slug := range renaming.Recipes {
+		recipe, err := mealie.GetRecipe(ctx, slug)
+		if err != nil {
+			return renaming, fmt.Errorf("failed to retrieve recipe %s: %s", slug, err.Error())
+		}
+		organisers := &recipe.Tags
+		if kind == "categories" {
+			organisers = &recipe.Categories
+		}
+		var changed bool
+		*organisers, changed = replaceOrganiser(*organisers, renaming.From, renaming.To)
+		if !changed {
+			continue
+		}
+		if err := mealie.SetOrganisers(ctx, recipe); err != nil {
+			return renaming, fmt.Errorf("failed to reassign recipe %s: %w", slug, err)
+		}
+	}
+	if err := mealie.DeleteOrganiser(ctx, kind, renaming.From.ID); err != nil {
+		return renaming, err
+	}
+	slog.InfoContext(ctx, "renamed organiser", "kind", kind, "from", renaming.From.Name, "to", to)
+	return renaming, nil
+}
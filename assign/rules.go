@@ -0,0 +1,94 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package assign
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Extensions of files in the rules directory that contain assignments.
+var ruleExtensions = []string{".json", ".yaml", ".yml"}
+
+// Configured returns whether there is anything to assign, i.e. assignments or a rules directory.
+func (a Assignments) Configured() bool {
+	return len(a.Assignments) != 0 || a.RulesDir != ""
+}
+
+// Load all assignments that shall be performed in a round, i.e. the enabled ones given directly
+// followed by the enabled ones in the rules directory. The directory is read anew for every round
+// so that rules can be added, changed, and toggled without a restart. Files that cannot be parsed
+// are skipped.
+func (a Assignments) enabled() []Assignment {
+	result := []Assignment{}
+	for _, assignment := range a.Assignments {
+		if !assignment.Disabled {
+			result = append(result, assignment)
+		}
+	}
+	if a.RulesDir == "" {
+		return result
+	}
+
+	entries, err := os.ReadDir(a.RulesDir)
+	if err != nil {
+		slog.Warn("failed to read rules directory, skipping it", "error", err)
+		return result
+	}
+	// Entries are sorted by file name, which determines the order of assignments.
+	for _, entry := range entries {
+		extension := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || !slices.Contains(ruleExtensions, extension) {
+			continue
+		}
+		path := filepath.Join(a.RulesDir, entry.Name())
+		assignment, err := loadRule(path)
+		if err != nil {
+			slog.Warn("skipping rule file", "path", path, "error", err)
+			continue
+		}
+		if assignment.Disabled {
+			slog.Info("skipping disabled rule file", "path", path)
+			continue
+		}
+		result = append(result, assignment)
+	}
+	return result
+}
+
+// Load a single assignment from a JSON or YAML file.
+func loadRule(path string) (Assignment, error) {
+	var assignment Assignment
+	content, err := os.ReadFile(path) // #nosec:G304
+	if err != nil {
+		return assignment, fmt.Errorf("failed to read file: %s", err.Error())
+	}
+	err = yaml.UnmarshalWithOptions(content, &assignment, yaml.Strict())
+	if err != nil {
+		return assignment, fmt.Errorf(
+			"failed to parse as the expected JSON or YAML:\n%s", yaml.FormatError(err, false, true),
+		)
+	}
+	return assignment, nil
+}
@@ -0,0 +1,217 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/razziel89/mealie-addons/api"
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/media"
+	"github.com/razziel89/mealie-addons/migrate"
+	"github.com/razziel89/mealie-addons/render"
+)
+
+// Statuses of the checks performed via --check-config.
+const (
+	checkOK      = "ok"
+	checkWarning = "warning"
+	checkFailed  = "FAILED"
+)
+
+// The result of a single check of the configuration.
+type configCheck struct {
+	name   string
+	status string
+	detail string
+}
+
+// The results of all checks of the configuration, in the order they were performed.
+type configChecks []configCheck
+
+// Record a check that failed if err is not nil and passed otherwise.
+func (c *configChecks) add(name string, err error, detail string) {
+	if err != nil {
+		*c = append(*c, configCheck{name: name, status: checkFailed, detail: err.Error()})
+		return
+	}
+	*c = append(*c, configCheck{name: name, status: checkOK, detail: detail})
+}
+
+// Record a check that passed with a caveat.
+func (c *configChecks) warn(name string, detail string) {
+	*c = append(*c, configCheck{name: name, status: checkWarning, detail: detail})
+}
+
+// Write a human-readable report to w and return the exit code, 1 if any check failed and 0
+// otherwise.
+func (c configChecks) report(w io.Writer) int {
+	failed := 0
+	for _, check := range c {
+		if check.status == checkFailed {
+			failed++
+		}
+		line := fmt.Sprintf("%-7s %s", check.status, check.name)
+		if check.detail != "" {
+			line += ": " + check.detail
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+	if failed != 0 {
+		_, _ = fmt.Fprintf(
+			w, "configuration check failed, %d of %d checks failed\n", failed, len(c),
+		)
+		return 1
+	}
+	_, _ = fmt.Fprintf(w, "configuration check passed, %d checks performed\n", len(c))
+	return 0
+}
+
+// Load and validate the full configuration like a regular start-up would, including access to
+// mealie and all needed executables and fonts, but without changing anything or serving requests.
+// Write a report to w and return the exit code.
+func checkConfig(w io.Writer) int {
+	checks := configChecks{}
+
+	if configFile := os.Getenv("MA_CONFIG_FILE"); configFile != "" {
+		fromFile, err := loadConfigFile(configFile)
+		detail := fmt.Sprintf("loaded %d variables from %s", len(fromFile), configFile)
+		checks.add("config file", err, detail)
+	}
+	checks.add("logging", setUpLogging(), "")
+	cfg, err := initConfig()
+	checks.add("environment variables", err, "mode "+cfg.mode)
+	if err != nil {
+		// Nothing else can be checked reliably with an invalid configuration.
+		return checks.report(w)
+	}
+
+	checks.add("persisted state", migrate.Run(cfg.stores(), true), "")
+	checks.add("executables", checkExecutables(&cfg), "pdf engine "+cfg.pdfEngine)
+	if cfg.imageAction == "embed" {
+		if err := media.CheckForHeifConvert(); err != nil {
+			checks.warn("heic images", "cannot be embedded: "+err.Error())
+		}
+		if err := media.CheckForRsvgConvert(); err != nil {
+			checks.warn("svg images", "cannot be embedded in pdf documents: "+err.Error())
+		}
+	}
+	if cfg.needsPandoc() {
+		checkFonts(&checks, cfg.pandocFontsDir)
+	}
+	if cfg.tlsCertFile != "" {
+		checks.add(
+			"tls", api.EnableTLS(cfg.tlsCertFile, cfg.tlsKeyFile), "loaded "+cfg.tlsCertFile,
+		)
+	}
+	if cfg.auth.OIDCIssuer != "" {
+		checks.add("single sign-on", cfg.auth.Discover(), "discovered "+cfg.auth.OIDCIssuer)
+	}
+	if cfg.mode == modeServer {
+		mealie, group, err := connectToMealie(cfg)
+		detail := fmt.Sprintf("reached %s, group %s", cfg.mealieRetrievalURL, group)
+		checks.add("mealie", err, detail)
+		if err == nil {
+			checkPermissions(&checks, cfg, mealie)
+		}
+	}
+
+	return checks.report(w)
+}
+
+// Check that the mealie token may do what the configuration needs.
+func checkPermissions(checks *configChecks, cfg config, mealie *mealieclient.Client) {
+	permissions, err := mealie.CheckPermissions()
+	switch {
+	case err != nil:
+		checks.add("mealie permissions", err, "")
+	case !permissions.ReadRecipes:
+		checks.add("mealie permissions", errors.New("mealie token cannot read recipes"), "")
+	case !permissions.ManageOrganisers && cfg.adminToken != "":
+		checks.warn("mealie permissions", "cannot manage categories and tags, renaming them fails")
+	default:
+		checks.add("mealie permissions", nil, "")
+	}
+}
+
+// Check that the fonts in dir can be used, which are not needed if there are none.
+func checkFonts(checks *configChecks, dir string) {
+	content, err := os.ReadDir(dir)
+	if err != nil {
+		checks.add("fonts", fmt.Errorf("failed to list directory %s: %s", dir, err.Error()), "")
+		return
+	}
+	fonts := 0
+	for _, file := range content {
+		if strings.HasSuffix(file.Name(), ".ttf") {
+			fonts++
+		}
+	}
+	if fonts == 0 {
+		checks.warn("fonts", fmt.Sprintf("no fonts in %s, using the default fonts", dir))
+		return
+	}
+	checks.add("fonts", nil, fmt.Sprintf("found %d fonts in %s", fonts, dir))
+}
+
+// Whether any document is converted via pandoc. Workers always convert via pandoc.
+func (c config) needsPandoc() bool {
+	needsPandoc := c.mode == modeWorker
+	for _, format := range knownFormats {
+		needsPandoc = needsPandoc || c.converters[format].NeedsPandoc()
+	}
+	return needsPandoc
+}
+
+// Determine the PDF engine and check that all executables needed for the configuration are
+// available.
+func checkExecutables(cfg *config) error {
+	needsTypst := false
+	for _, format := range knownFormats {
+		needsTypst = needsTypst || cfg.converters[format].Backend == render.BackendTypst
+	}
+	// Pandoc calls the PDF engine itself. Typst is checked as part of the backends anyway.
+	pdfBackend := cfg.converters["pdf"].Backend
+	if cfg.mode == modeWorker || pdfBackend == "" || pdfBackend == render.BackendPandoc {
+		cfg.pdfEngine = render.ProbePDFEngine(cfg.pdfEngine)
+		needsTypst = needsTypst || cfg.pdfEngine == render.PDFEngineTypst
+	}
+	if cfg.pdfEngine == "" {
+		cfg.pdfEngine = render.DefaultPDFEngine
+	}
+	if cfg.needsPandoc() {
+		if err := render.CheckForPandoc(); err != nil {
+			return err
+		}
+	}
+	if needsTypst {
+		if err := render.CheckForTypst(); err != nil {
+			return err
+		}
+	}
+	if cfg.pdfSubsetFonts {
+		if err := render.CheckForGhostscript(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
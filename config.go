@@ -19,36 +19,172 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"golang.org/x/text/language"
+
+	"github.com/razziel89/mealie-addons/api"
+	"github.com/razziel89/mealie-addons/assign"
+	"github.com/razziel89/mealie-addons/destination"
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/media"
+	"github.com/razziel89/mealie-addons/migrate"
+	"github.com/razziel89/mealie-addons/render"
+	"github.com/razziel89/mealie-addons/schedule"
+)
+
+var knownFormats = []string{"markdown", "epub", "pdf", "html", "docx", "odt"}
+
+const defaultCacheSecs = 300
+
+// Converted images are cached in memory up to this size by default.
+const defaultMediaCacheSize = 64 << 20 //nolint:mnd
+
+// Converted images are cached on disk up to this size by default, if at all.
+const defaultMediaCacheDirSize = 1 << 30 //nolint:mnd
+
+const defaultBookCacheSize = 128 << 20 //nolint:mnd
+
+// Region used to sign requests to S3-compatible storage. Most services other than AWS ignore it.
+const defaultS3Region = "us-east-1"
+
+// Defaults for commits to git repositories.
+const (
+	defaultGitBranch      = "main"
+	defaultGitAuthorName  = "mealie-addons"
+	defaultGitAuthorEmail = "mealie-addons@localhost"
+)
+
+// Rough estimates of the memory needed to retrieve a single recipe and to process a single image.
+// Images are decoded completely, which takes four bytes per pixel for photos with many megapixels.
+const (
+	memoryPerRecipe = 16 << 20  //nolint:mnd
+	memoryPerImage  = 128 << 20 //nolint:mnd
+)
+
+// In server mode, we serve documents built from recipes retrieved from mealie. In worker mode, we
+// only convert documents on behalf of instances in server mode.
+const (
+	modeServer = "server"
+	modeWorker = "worker"
 )
 
 type config struct {
+	mode               string
+	workerToken        string
 	mealieRetrievalURL string
 	mealieBaseURL      string
 	mealieToken        string
+	debugToken         string
+	adminToken         string
+	auth               api.Auth
+	rateLimit          api.RateLimit
+	trustedProxies     []string
 	selfURL            string
+	tlsCertFile        string
+	tlsKeyFile         string
+	mediaURL           string
 	listenInterface    string
+	grpcInterface      string
+	grpcToken          string
 	retrievalLimit     int
+	memoryLimit        int64
+	imageLimit         int
+	emptyResult        string
+	mediaCacheSize     int64
+	bookCacheSize      int64
+	mediaCacheDir      string
+	mediaCacheDirSize  int64
+	pageSize           int
+	maxPages           int
+	mealieRetry        mealieclient.Retry
+	mealieTransport    mealieclient.Transport
 	timeoutSecs        int
+	drainTimeoutSecs   int
+	cacheSecs          int
 	startupGraceSecs   int
+	degradedStart      bool
 	pandocFlags        []string
+	pandocAllowlist    []string
 	pandocFontsDir     string
+	workDir            string
+	pdfSubsetFonts     bool
+	lint               bool
+	rejectGlyphs       bool
+	pdfLayout          render.PDFLayout
+	pdfEngine          string
 	imageAction        string
+	imageCaptions      bool
+	showDifficulty     bool
+	showNutrition      bool
+	allergens          render.Allergens
+	failOnBrokenLinks  bool
+	language           language.Tag
+	metadata           render.Metadata
+	categoryStyles     render.CategoryStyles
+	seasons            mealieclient.Seasons
+	diets              mealieclient.Diets
+	savedQueries       mealieclient.SavedQueries
+	archiveCategory    string
+	readOnly           bool
+	presets            map[string]api.Preset
+	watch              schedule.Watch
+	execHooks          render.ExecHooks
 	htmlAttrsMod       map[string]map[string]string
 	htmlAttrsRm        map[string]map[string]string
-	queryAssignments   queryAssignments
+	queryAssignments   assign.Assignments
+	scheduledExports   schedule.Exports
+	webdav             *destination.WebDAV
+	git                *destination.Git
+	s3                 *destination.S3
+	dropbox            *destination.Dropbox
+	googleDrive        *destination.GoogleDrive
+	sftp               *destination.SFTP
+	ftp                *destination.FTP
+	remarkable         *destination.ReMarkable
+	paperless          *destination.Paperless
+	discord            *destination.Discord
+	slack              *destination.Slack
+	matrix             *destination.Matrix
 	fixes              fixes
+	converters         map[string]render.ConverterSpec
 }
 
 func initConfig() (cfg config, err error) {
-	for _, env := range []string{
-		"MEALIE_BASE_URL", "MEALIE_RETRIEVAL_URL", "MEALIE_TOKEN", "MA_LISTEN_INTERFACE",
-		"MA_RETRIEVAL_LIMIT", "MA_STARTUP_GRACE_SECS", "MA_TIMEOUT_SECS",
-	} {
+	mode := strings.ToLower(os.Getenv("MA_MODE"))
+	switch mode {
+	case "":
+		mode = modeServer
+	case modeServer, modeWorker:
+	default:
+		err = fmt.Errorf("unknown mode, must be 'server' or 'worker': %s", mode)
+		return cfg, err
+	}
+
+	required := []string{"MA_LISTEN_INTERFACE", "MA_TIMEOUT_SECS"}
+	if mode == modeWorker {
+		required = append(required, "MA_WORKER_TOKEN")
+	}
+	if os.Getenv("MA_GRPC_LISTEN_INTERFACE") != "" {
+		required = append(required, "MA_GRPC_TOKEN")
+	}
+	if mode == modeServer {
+		required = append(
+			required,
+			"MEALIE_BASE_URL", "MEALIE_RETRIEVAL_URL", "MEALIE_TOKEN",
+			"MA_RETRIEVAL_LIMIT", "MA_STARTUP_GRACE_SECS",
+		)
+	}
+	for _, env := range required {
 		val := os.Getenv(env)
 		if val == "" {
 			err = fmt.Errorf("environment variable %s not defined or empty", env)
@@ -56,44 +192,239 @@ func initConfig() (cfg config, err error) {
 		}
 	}
 
-	retrievalLimit, parseErr := strconv.Atoi(os.Getenv("MA_RETRIEVAL_LIMIT"))
-	if parseErr != nil {
-		err = parseErr
-		return cfg, err
+	// Workers never talk to mealie.
+	var retrievalLimit, startupGraceSecs int
+	degradedStart := false
+	if mode == modeServer {
+		var parseErr error
+		retrievalLimit, parseErr = strconv.Atoi(os.Getenv("MA_RETRIEVAL_LIMIT"))
+		if parseErr != nil {
+			err = parseErr
+			return cfg, err
+		}
+		startupGraceSecs, parseErr = strconv.Atoi(os.Getenv("MA_STARTUP_GRACE_SECS"))
+		if parseErr != nil {
+			err = parseErr
+			return cfg, err
+		}
+		if degradedStartStr := os.Getenv("MA_DEGRADED_START"); degradedStartStr != "" {
+			degradedStart, parseErr = strconv.ParseBool(degradedStartStr)
+			if parseErr != nil {
+				err = fmt.Errorf("failed to parse MA_DEGRADED_START: %s", parseErr.Error())
+				return cfg, err
+			}
+		}
 	}
-	startupGraceSecs, parseErr := strconv.Atoi(os.Getenv("MA_STARTUP_GRACE_SECS"))
+	timeoutSecs, parseErr := strconv.Atoi(os.Getenv("MA_TIMEOUT_SECS"))
 	if parseErr != nil {
 		err = parseErr
 		return cfg, err
 	}
-	timeoutSecs, parseErr := strconv.Atoi(os.Getenv("MA_TIMEOUT_SECS"))
-	if parseErr != nil {
-		err = parseErr
+	// No export takes longer than the timeout, so waiting any longer for them is pointless.
+	drainTimeoutSecs := timeoutSecs
+	if drainStr := os.Getenv("MA_DRAIN_TIMEOUT_SECS"); drainStr != "" {
+		drainTimeoutSecs, parseErr = strconv.Atoi(drainStr)
+		if parseErr != nil {
+			err = parseErr
+			return cfg, err
+		}
+		if drainTimeoutSecs <= 0 {
+			err = fmt.Errorf("MA_DRAIN_TIMEOUT_SECS must be positive but is %d", drainTimeoutSecs)
+			return cfg, err
+		}
+	}
+	pageSize := mealieclient.DefaultPerPage
+	if pageSizeStr := os.Getenv("MA_PAGE_SIZE"); pageSizeStr != "" {
+		pageSize, parseErr = strconv.Atoi(pageSizeStr)
+		if parseErr != nil {
+			err = parseErr
+			return cfg, err
+		}
+		if pageSize <= 0 {
+			err = fmt.Errorf("MA_PAGE_SIZE must be positive but is %d", pageSize)
+			return cfg, err
+		}
+	}
+	mealieRetry := mealieclient.DefaultRetry
+	if attemptsStr := os.Getenv("MA_MEALIE_RETRY_ATTEMPTS"); attemptsStr != "" {
+		mealieRetry.Attempts, parseErr = strconv.Atoi(attemptsStr)
+		if parseErr != nil {
+			err = parseErr
+			return cfg, err
+		}
+		if mealieRetry.Attempts <= 0 {
+			err = fmt.Errorf(
+				"MA_MEALIE_RETRY_ATTEMPTS must be positive but is %d", mealieRetry.Attempts,
+			)
+			return cfg, err
+		}
+	}
+	if backoffStr := os.Getenv("MA_MEALIE_RETRY_BACKOFF_MS"); backoffStr != "" {
+		var backoffMillis int
+		backoffMillis, parseErr = strconv.Atoi(backoffStr)
+		if parseErr != nil {
+			err = parseErr
+			return cfg, err
+		}
+		if backoffMillis < 0 {
+			err = fmt.Errorf(
+				"MA_MEALIE_RETRY_BACKOFF_MS must not be negative but is %d", backoffMillis,
+			)
+			return cfg, err
+		}
+		mealieRetry.Backoff = time.Duration(backoffMillis) * time.Millisecond
+	}
+	mealieTransport := mealieclient.Transport{
+		CAFile: os.Getenv("MA_MEALIE_CA_FILE"),
+		Proxy:  os.Getenv("MA_MEALIE_PROXY"),
+	}
+	if insecureStr := os.Getenv("MA_MEALIE_INSECURE_SKIP_VERIFY"); insecureStr != "" {
+		mealieTransport.InsecureSkipVerify, parseErr = strconv.ParseBool(insecureStr)
+		if parseErr != nil {
+			err = fmt.Errorf(
+				"failed to parse MA_MEALIE_INSECURE_SKIP_VERIFY: %s", parseErr.Error(),
+			)
+			return cfg, err
+		}
+	}
+	if _, transportErr := mealieTransport.HTTPClient(); transportErr != nil {
+		err = fmt.Errorf("failed to configure connection to mealie: %s", transportErr.Error())
 		return cfg, err
 	}
+	maxPages := 0
+	if maxPagesStr := os.Getenv("MA_MAX_PAGES"); maxPagesStr != "" {
+		maxPages, parseErr = strconv.Atoi(maxPagesStr)
+		if parseErr != nil {
+			err = parseErr
+			return cfg, err
+		}
+	}
+	mediaCacheSize := int64(defaultMediaCacheSize)
+	if mediaCacheSizeStr := os.Getenv("MA_MEDIA_CACHE_SIZE"); mediaCacheSizeStr == "0" {
+		mediaCacheSize = 0
+	} else if mediaCacheSizeStr != "" {
+		mediaCacheSize, parseErr = parseByteSize(mediaCacheSizeStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_MEDIA_CACHE_SIZE: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+	mediaCacheDirSize := int64(defaultMediaCacheDirSize)
+	if dirSizeStr := os.Getenv("MA_MEDIA_CACHE_DIR_SIZE"); dirSizeStr == "0" {
+		mediaCacheDirSize = 0
+	} else if dirSizeStr != "" {
+		mediaCacheDirSize, parseErr = parseByteSize(dirSizeStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_MEDIA_CACHE_DIR_SIZE: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+	bookCacheSize := int64(defaultBookCacheSize)
+	if bookCacheSizeStr := os.Getenv("MA_BOOK_CACHE_SIZE"); bookCacheSizeStr == "0" {
+		bookCacheSize = 0
+	} else if bookCacheSizeStr != "" {
+		bookCacheSize, parseErr = parseByteSize(bookCacheSizeStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_BOOK_CACHE_SIZE: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	emptyResult := api.EmptyResultDocument
+	if emptyResultStr := os.Getenv("MA_EMPTY_RESULT"); emptyResultStr != "" {
+		emptyResult = emptyResultStr
+		if err = api.ValidateEmptyResult(emptyResult); err != nil {
+			return cfg, err
+		}
+	}
+
+	var memoryLimit int64
+	var imageLimit int
+	if memoryLimitStr := os.Getenv("MA_MEMORY_LIMIT"); memoryLimitStr != "" {
+		memoryLimit, parseErr = parseByteSize(memoryLimitStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_MEMORY_LIMIT: %s", parseErr.Error())
+			return cfg, err
+		}
+		// Stay within the limit by not processing too much at the same time.
+		recipes := int(max(1, memoryLimit/memoryPerRecipe))
+		if mode == modeServer && (retrievalLimit <= 0 || retrievalLimit > recipes) {
+			slog.Info("limiting MA_RETRIEVAL_LIMIT due to MA_MEMORY_LIMIT", "recipes", recipes)
+			retrievalLimit = recipes
+		}
+		imageLimit = int(max(1, memoryLimit/memoryPerImage))
+	}
+	cacheSecs := defaultCacheSecs
+	if cacheSecsStr := os.Getenv("MA_CACHE_SECS"); cacheSecsStr != "" {
+		cacheSecs, parseErr = strconv.Atoi(cacheSecsStr)
+		if parseErr != nil {
+			err = parseErr
+			return cfg, err
+		}
+	}
 	interfaceEnv := os.Getenv("MA_LISTEN_INTERFACE")
-	_, portStr, found := strings.Cut(interfaceEnv, ":")
-	if !found {
-		err = fmt.Errorf("cannot find port in interface spec %s", interfaceEnv)
+	socketPath, isSocket := api.SocketPath(interfaceEnv)
+	listenPort := 0
+	if isSocket && socketPath == "" {
+		err = fmt.Errorf("cannot find socket path in interface spec %s", interfaceEnv)
 		return cfg, err
+	} else if !isSocket {
+		_, portStr, found := strings.Cut(interfaceEnv, ":")
+		if !found {
+			err = fmt.Errorf("cannot find port in interface spec %s", interfaceEnv)
+			return cfg, err
+		}
+		listenPort, parseErr = strconv.Atoi(portStr)
+		if parseErr != nil {
+			err = parseErr
+			return cfg, err
+		}
 	}
-	listenPort, parseErr := strconv.Atoi(portStr)
-	if parseErr != nil {
-		err = parseErr
+
+	token := secretEnv("MEALIE_TOKEN")
+	// Debug endpoints are enabled only if a token protecting them is set.
+	debugToken := secretEnv("MA_DEBUG_TOKEN")
+	// Admin endpoints are enabled only if a token protecting them is set.
+	adminToken := secretEnv("MA_ADMIN_TOKEN")
+	// Clients have to present credentials only if any are set.
+	auth := api.Auth{
+		Token:    secretEnv("MA_AUTH_TOKEN"),
+		Username: os.Getenv("MA_AUTH_USERNAME"),
+		Password: secretEnv("MA_AUTH_PASSWORD"),
+
+		OIDCIssuer:   os.Getenv("MA_OIDC_ISSUER"),
+		OIDCAudience: os.Getenv("MA_OIDC_AUDIENCE"),
+		OIDCHeader:   os.Getenv("MA_OIDC_HEADER"),
+	}
+	if authErr := auth.Validate(); authErr != nil {
+		err = fmt.Errorf("bad credentials: %s", authErr.Error())
 		return cfg, err
 	}
 
-	// Try to interpret the token as pointing to a file that exists. If so, we read the value from
-	// the file. If not, we use the value from the environment directly. This enables the use of
-	// docker-compose secrets.
-	var token string
-	tokenInput := os.Getenv("MEALIE_TOKEN")
-	maybeToken, readErr := os.ReadFile(tokenInput) // #nosec:G304
-	if readErr == nil {
-		// It does point to a file.
-		token = strings.TrimSpace(string(maybeToken))
-	} else {
-		token = strings.TrimSpace(tokenInput)
+	// Exports are rate limited only if any limit is set.
+	rateLimit := api.RateLimit{Burst: 1}
+	for env, target := range map[string]*float64{
+		"MA_RATE_LIMIT_PER_CLIENT": &rateLimit.PerClient,
+		"MA_RATE_LIMIT_GLOBAL":     &rateLimit.Global,
+	} {
+		if value := os.Getenv(env); value != "" {
+			*target, parseErr = strconv.ParseFloat(value, 64)
+			if parseErr != nil {
+				err = fmt.Errorf("failed to parse %s: %s", env, parseErr.Error())
+				return cfg, err
+			}
+		}
+	}
+	if burstStr := os.Getenv("MA_RATE_LIMIT_BURST"); burstStr != "" {
+		rateLimit.Burst, parseErr = strconv.Atoi(burstStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_RATE_LIMIT_BURST: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+	if rateErr := rateLimit.Validate(); rateErr != nil {
+		err = fmt.Errorf("bad rate limit: %s", rateErr.Error())
+		return cfg, err
 	}
 
 	mealieBaseURL := os.Getenv("MEALIE_BASE_URL")
@@ -103,6 +434,7 @@ func initConfig() (cfg config, err error) {
 	}
 
 	pandocFlags := strings.Fields(os.Getenv("PANDOC_FLAGS"))
+	pandocAllowlist := strings.Fields(os.Getenv("MA_PANDOC_ALLOWED_FLAGS"))
 
 	pandocFontsDir := os.Getenv("PANDOC_FONTS_DIR")
 	if pandocFontsDir == "" {
@@ -114,6 +446,60 @@ func initConfig() (cfg config, err error) {
 		pandocFontsDir = cwd
 	}
 
+	pdfSubsetFonts := false
+	if pdfSubsetFontsStr := os.Getenv("MA_PDF_SUBSET_FONTS"); pdfSubsetFontsStr != "" {
+		pdfSubsetFonts, parseErr = strconv.ParseBool(pdfSubsetFontsStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_PDF_SUBSET_FONTS: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	lint := false
+	if lintStr := os.Getenv("MA_LINT"); lintStr != "" {
+		lint, parseErr = strconv.ParseBool(lintStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_LINT: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	rejectGlyphs := false
+	if rejectStr := os.Getenv("MA_REJECT_MISSING_GLYPHS"); rejectStr != "" {
+		rejectGlyphs, parseErr = strconv.ParseBool(rejectStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_REJECT_MISSING_GLYPHS: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	var pdfLayout render.PDFLayout
+	if chapterNumbersStr := os.Getenv("MA_PDF_CHAPTER_NUMBERS"); chapterNumbersStr != "" {
+		pdfLayout.ChapterNumbers, parseErr = strconv.ParseBool(chapterNumbersStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_PDF_CHAPTER_NUMBERS: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+	if runningHeadersStr := os.Getenv("MA_PDF_RUNNING_HEADERS"); runningHeadersStr != "" {
+		pdfLayout.RunningHeaders, parseErr = strconv.ParseBool(runningHeadersStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_PDF_RUNNING_HEADERS: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	// The PDF engine is determined at startup unless set explicitly.
+	pdfEngine := strings.ToLower(os.Getenv("MA_PDF_ENGINE"))
+	if pdfEngine != "" {
+		if !slices.Contains(render.PDFEngines, pdfEngine) {
+			err = fmt.Errorf(
+				"unknown MA_PDF_ENGINE %s, use one of %v", pdfEngine, render.PDFEngines,
+			)
+			return cfg, err
+		}
+	}
+
 	imageAction := strings.ToLower(os.Getenv("MA_IMAGE_ACTION"))
 	switch imageAction {
 	case "":
@@ -125,34 +511,101 @@ func initConfig() (cfg config, err error) {
 		return cfg, err
 	}
 
-	htmlAttrsMod, parseErr := parseHTMLAttrs(os.Getenv("MA_HTML_ATTRS_MOD"))
+	imageCaptions := false
+	if imageCaptionsStr := os.Getenv("MA_IMAGE_CAPTIONS"); imageCaptionsStr != "" {
+		imageCaptions, parseErr = strconv.ParseBool(imageCaptionsStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_IMAGE_CAPTIONS: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	showDifficulty := false
+	if showDifficultyStr := os.Getenv("MA_SHOW_DIFFICULTY"); showDifficultyStr != "" {
+		showDifficulty, parseErr = strconv.ParseBool(showDifficultyStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_SHOW_DIFFICULTY: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	showNutrition := false
+	if showNutritionStr := os.Getenv("MA_SHOW_NUTRITION"); showNutritionStr != "" {
+		showNutrition, parseErr = strconv.ParseBool(showNutritionStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_SHOW_NUTRITION: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	failOnBrokenLinks := false
+	if failOnBrokenLinksStr := os.Getenv("MA_FAIL_ON_BROKEN_LINKS"); failOnBrokenLinksStr != "" {
+		failOnBrokenLinks, parseErr = strconv.ParseBool(failOnBrokenLinksStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_FAIL_ON_BROKEN_LINKS: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	lang := language.Und
+	if langStr := os.Getenv("MA_LANGUAGE"); langStr != "" {
+		lang, parseErr = language.Parse(langStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_LANGUAGE: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	htmlAttrsMod, parseErr := parseHTMLAttrsEnv("MA_HTML_ATTRS_MOD")
 	if parseErr != nil {
 		err = parseErr
 		return cfg, err
 	}
 
-	htmlAttrsRm, parseErr := parseHTMLAttrs(os.Getenv("MA_HTML_ATTRS_RM"))
+	htmlAttrsRm, parseErr := parseHTMLAttrsEnv("MA_HTML_ATTRS_RM")
 	if parseErr != nil {
 		err = parseErr
 		return cfg, err
 	}
 
-	selfURL := os.Getenv("MA_SELF_URL")
-	if selfURL == "" {
-		selfURL = fmt.Sprintf("http://127.0.0.1:%d", listenPort)
+	// Temporary files are kept apart from those of other programs so that leftovers can be removed.
+	workDir := os.Getenv("MA_WORK_DIR")
+	if workDir == "" {
+		workDir = filepath.Join(os.TempDir(), "mealie-addons")
 	}
 
-	var queryAssignments queryAssignments
-	queryAssignmentsStr := os.Getenv("MA_QUERY_ASSIGNMENTS")
-	if queryAssignmentsStr != "" {
-		parseErr := json.Unmarshal([]byte(queryAssignmentsStr), &queryAssignments)
-		if parseErr != nil {
-			err = fmt.Errorf(
-				"failed to parse MA_QUERY_ASSIGNMENTS as the expected JSON: %s",
-				parseErr.Error(),
-			)
-			return cfg, err
+	// HTTPS is served only if a certificate is given.
+	tlsCertFile := os.Getenv("MA_TLS_CERT_FILE")
+	tlsKeyFile := os.Getenv("MA_TLS_KEY_FILE")
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		err = fmt.Errorf("MA_TLS_CERT_FILE and MA_TLS_KEY_FILE have to be given together")
+		return cfg, err
+	}
+
+	selfURL := os.Getenv("MA_SELF_URL")
+	if selfURL == "" && isSocket {
+		err = fmt.Errorf("MA_SELF_URL has to be set when listening on a unix socket")
+		return cfg, err
+	} else if selfURL == "" {
+		scheme := "http"
+		if tlsCertFile != "" {
+			scheme = "https"
 		}
+		selfURL = fmt.Sprintf("%s://127.0.0.1:%d", scheme, listenPort)
+	}
+
+	// Workers have to retrieve media via an instance in server mode.
+	mediaURL := os.Getenv("MA_MEDIA_URL")
+	if mediaURL == "" {
+		mediaURL = selfURL + "/media/"
+	}
+
+	var queryAssignments assign.Assignments
+	if parseErr := parseStructuredEnv("MA_QUERY_ASSIGNMENTS", &queryAssignments); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+	if os.Getenv("MA_QUERY_ASSIGNMENTS") != "" {
 		if queryAssignments.TimeoutSecs == 0 {
 			err = fmt.Errorf("timeout-secs for query assignment must not be 0")
 			return cfg, err
@@ -161,6 +614,222 @@ func initConfig() (cfg config, err error) {
 			err = fmt.Errorf("repeat-secs for query assignment must not be 0")
 			return cfg, err
 		}
+		if rulesDir := queryAssignments.RulesDir; rulesDir != "" {
+			if info, statErr := os.Stat(rulesDir); statErr != nil || !info.IsDir() {
+				err = fmt.Errorf("rules-dir for query assignment is no directory: %s", rulesDir)
+				return cfg, err
+			}
+		}
+	}
+
+	var scheduledExports schedule.Exports
+	if parseErr := parseStructuredEnv("MA_SCHEDULED_EXPORTS", &scheduledExports); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+	if os.Getenv("MA_SCHEDULED_EXPORTS") != "" {
+		if scheduledExports.TimeoutSecs == 0 {
+			err = fmt.Errorf("timeout-secs for scheduled exports must not be 0")
+			return cfg, err
+		}
+		dir := scheduledExports.Dir
+		if info, statErr := os.Stat(dir); dir != "" && (statErr != nil || !info.IsDir()) {
+			err = fmt.Errorf("dir for scheduled exports is no directory: %s", dir)
+			return cfg, err
+		}
+	}
+
+	var webdav *destination.WebDAV
+	if webdavURL := os.Getenv("MA_WEBDAV_URL"); webdavURL != "" {
+		webdav = &destination.WebDAV{
+			URL:      webdavURL,
+			User:     os.Getenv("MA_WEBDAV_USER"),
+			Password: secretEnv("MA_WEBDAV_PASSWORD"),
+			Path:     os.Getenv("MA_WEBDAV_PATH"),
+		}
+	}
+
+	var s3 *destination.S3
+	if s3Endpoint := os.Getenv("MA_S3_ENDPOINT"); s3Endpoint != "" {
+		s3 = &destination.S3{
+			Endpoint:  s3Endpoint,
+			Region:    os.Getenv("MA_S3_REGION"),
+			Bucket:    os.Getenv("MA_S3_BUCKET"),
+			Prefix:    os.Getenv("MA_S3_PREFIX"),
+			AccessKey: os.Getenv("MA_S3_ACCESS_KEY"),
+			SecretKey: secretEnv("MA_S3_SECRET_KEY"),
+		}
+		if s3.Region == "" {
+			s3.Region = defaultS3Region
+		}
+		if s3.Bucket == "" {
+			err = fmt.Errorf("environment variable MA_S3_BUCKET is required with MA_S3_ENDPOINT")
+			return cfg, err
+		}
+	}
+
+	var dropbox *destination.Dropbox
+	if refreshToken := secretEnv("MA_DROPBOX_REFRESH_TOKEN"); refreshToken != "" {
+		dropbox = &destination.Dropbox{
+			AppKey:       os.Getenv("MA_DROPBOX_APP_KEY"),
+			AppSecret:    secretEnv("MA_DROPBOX_APP_SECRET"),
+			RefreshToken: refreshToken,
+			Path:         os.Getenv("MA_DROPBOX_PATH"),
+		}
+		if dropbox.AppKey == "" {
+			err = fmt.Errorf(
+				"environment variable MA_DROPBOX_APP_KEY is required with MA_DROPBOX_REFRESH_TOKEN",
+			)
+			return cfg, err
+		}
+	}
+
+	var googleDrive *destination.GoogleDrive
+	if credentials := secretEnv("MA_GDRIVE_CREDENTIALS"); credentials != "" {
+		googleDrive = &destination.GoogleDrive{
+			Credentials: credentials,
+			FolderID:    os.Getenv("MA_GDRIVE_FOLDER_ID"),
+		}
+		if googleDrive.FolderID == "" {
+			err = fmt.Errorf(
+				"environment variable MA_GDRIVE_FOLDER_ID is required with MA_GDRIVE_CREDENTIALS",
+			)
+			return cfg, err
+		}
+		if validateErr := googleDrive.Validate(); validateErr != nil {
+			err = fmt.Errorf("failed to parse MA_GDRIVE_CREDENTIALS: %s", validateErr.Error())
+			return cfg, err
+		}
+	}
+
+	var sftp *destination.SFTP
+	if sftpAddress := os.Getenv("MA_SFTP_ADDRESS"); sftpAddress != "" {
+		sftp = &destination.SFTP{
+			Address:    sftpAddress,
+			User:       os.Getenv("MA_SFTP_USER"),
+			Password:   secretEnv("MA_SFTP_PASSWORD"),
+			PrivateKey: secretEnv("MA_SFTP_PRIVATE_KEY"),
+			Passphrase: secretEnv("MA_SFTP_PRIVATE_KEY_PASSPHRASE"),
+			HostKeys:   secretEnv("MA_SFTP_HOST_KEYS"),
+			Path:       os.Getenv("MA_SFTP_PATH"),
+		}
+		if validateErr := sftp.Validate(); validateErr != nil {
+			err = fmt.Errorf("failed to configure sftp: %s", validateErr.Error())
+			return cfg, err
+		}
+	}
+
+	var ftp *destination.FTP
+	if ftpAddress := os.Getenv("MA_FTP_ADDRESS"); ftpAddress != "" {
+		ftp = &destination.FTP{
+			Address:  ftpAddress,
+			User:     os.Getenv("MA_FTP_USER"),
+			Password: secretEnv("MA_FTP_PASSWORD"),
+			Path:     os.Getenv("MA_FTP_PATH"),
+		}
+	}
+
+	var remarkable *destination.ReMarkable
+	if deviceToken := secretEnv("MA_REMARKABLE_DEVICE_TOKEN"); deviceToken != "" {
+		remarkable = &destination.ReMarkable{DeviceToken: deviceToken}
+	}
+
+	var paperless *destination.Paperless
+	if paperlessURL := os.Getenv("MA_PAPERLESS_URL"); paperlessURL != "" {
+		paperless = &destination.Paperless{
+			URL:           paperlessURL,
+			Token:         secretEnv("MA_PAPERLESS_TOKEN"),
+			Correspondent: strings.TrimSpace(os.Getenv("MA_PAPERLESS_CORRESPONDENT")),
+		}
+		for tag := range strings.SplitSeq(os.Getenv("MA_PAPERLESS_TAGS"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				paperless.Tags = append(paperless.Tags, tag)
+			}
+		}
+		if paperless.Token == "" {
+			err = fmt.Errorf(
+				"environment variable MA_PAPERLESS_TOKEN is required with MA_PAPERLESS_URL",
+			)
+			return cfg, err
+		}
+	}
+
+	announcement := destination.Announcement{
+		Message:       "New cookbook edition:",
+		MaxAttachment: destination.DefaultMaxAttachment,
+		LinkURL:       os.Getenv("MA_CHAT_LINK_URL"),
+	}
+	if message, found := os.LookupEnv("MA_CHAT_MESSAGE"); found {
+		announcement.Message = message
+	}
+	if maxAttachmentStr := os.Getenv("MA_CHAT_MAX_ATTACHMENT_SIZE"); maxAttachmentStr == "0" {
+		announcement.MaxAttachment = 0
+	} else if maxAttachmentStr != "" {
+		announcement.MaxAttachment, parseErr = parseByteSize(maxAttachmentStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_CHAT_MAX_ATTACHMENT_SIZE: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	var discord *destination.Discord
+	if webhookURL := secretEnv("MA_DISCORD_WEBHOOK_URL"); webhookURL != "" {
+		discord = &destination.Discord{WebhookURL: webhookURL, Announcement: announcement}
+	}
+
+	var slack *destination.Slack
+	if slackToken := secretEnv("MA_SLACK_TOKEN"); slackToken != "" {
+		slack = &destination.Slack{
+			Token:        slackToken,
+			Channel:      os.Getenv("MA_SLACK_CHANNEL"),
+			Announcement: announcement,
+		}
+		if slack.Channel == "" {
+			err = fmt.Errorf(
+				"environment variable MA_SLACK_CHANNEL is required with MA_SLACK_TOKEN",
+			)
+			return cfg, err
+		}
+	}
+
+	var matrix *destination.Matrix
+	if homeserver := os.Getenv("MA_MATRIX_HOMESERVER"); homeserver != "" {
+		matrix = &destination.Matrix{
+			Homeserver:   homeserver,
+			Token:        secretEnv("MA_MATRIX_TOKEN"),
+			Room:         os.Getenv("MA_MATRIX_ROOM"),
+			Announcement: announcement,
+		}
+		if matrix.Token == "" || matrix.Room == "" {
+			err = fmt.Errorf(
+				"environment variables MA_MATRIX_TOKEN and MA_MATRIX_ROOM are required with " +
+					"MA_MATRIX_HOMESERVER",
+			)
+			return cfg, err
+		}
+	}
+
+	var git *destination.Git
+	if gitRemote := os.Getenv("MA_GIT_REMOTE"); gitRemote != "" {
+		git = &destination.Git{
+			Remote:      gitRemote,
+			Branch:      os.Getenv("MA_GIT_BRANCH"),
+			Dir:         os.Getenv("MA_GIT_DIR"),
+			AuthorName:  os.Getenv("MA_GIT_AUTHOR_NAME"),
+			AuthorEmail: os.Getenv("MA_GIT_AUTHOR_EMAIL"),
+		}
+		if git.Branch == "" {
+			git.Branch = defaultGitBranch
+		}
+		if git.Dir == "" {
+			git.Dir = filepath.Join(os.TempDir(), "mealie-addons-git")
+		}
+		if git.AuthorName == "" {
+			git.AuthorName = defaultGitAuthorName
+		}
+		if git.AuthorEmail == "" {
+			git.AuthorEmail = defaultGitAuthorEmail
+		}
 	}
 
 	fixes, fixErr := fixesFromString(os.Getenv("MA_MEALIE_FIXES"))
@@ -168,23 +837,293 @@ func initConfig() (cfg config, err error) {
 		err = fmt.Errorf("failed to parse fixes: %s", fixErr.Error())
 		return cfg, err
 	}
+	fixes.resultTarget = os.Getenv("MA_FIX_RESULT")
+	fixes.stateFile = os.Getenv("MA_FIX_STATE_FILE")
+	fixes.parallel = retrievalLimit
+	if oneShotStr := os.Getenv("MA_FIX_ONE_SHOT"); oneShotStr != "" {
+		fixes.oneShot, parseErr = strconv.ParseBool(oneShotStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_FIX_ONE_SHOT: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+
+	// Refuse to modify data in mealie, e.g. if the token only grants read access.
+	readOnly := false
+	if readOnlyStr := os.Getenv("MA_READ_ONLY"); readOnlyStr != "" {
+		readOnly, parseErr = strconv.ParseBool(readOnlyStr)
+		if parseErr != nil {
+			err = fmt.Errorf("failed to parse MA_READ_ONLY: %s", parseErr.Error())
+			return cfg, err
+		}
+	}
+	if readOnly && fixes.oneShot {
+		err = fmt.Errorf("MA_FIX_ONE_SHOT cannot be used together with MA_READ_ONLY")
+		return cfg, err
+	}
+
+	converters := map[string]render.ConverterSpec{}
+	if parseErr := parseStructuredEnv("MA_CONVERTERS", &converters); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+	for format, spec := range converters {
+		if !slices.Contains(knownFormats, format) {
+			err = fmt.Errorf("unknown format %s in MA_CONVERTERS", format)
+			return cfg, err
+		}
+		if specErr := spec.Validate(format); specErr != nil {
+			err = fmt.Errorf("bad converter for %s: %s", format, specErr.Error())
+			return cfg, err
+		}
+	}
+
+	categoryStyles := render.CategoryStyles{}
+	if parseErr := parseStructuredEnv("MA_CATEGORY_STYLES", &categoryStyles); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+	for category, style := range categoryStyles {
+		if styleErr := style.Validate(); styleErr != nil {
+			err = fmt.Errorf("bad style for category %s: %s", category, styleErr.Error())
+			return cfg, err
+		}
+	}
+
+	allergens := render.Allergens{}
+	if parseErr := parseStructuredEnv("MA_ALLERGENS", &allergens); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+	if allergenErr := allergens.Validate(); allergenErr != nil {
+		err = fmt.Errorf("bad allergens: %s", allergenErr.Error())
+		return cfg, err
+	}
+
+	seasons := mealieclient.Seasons{}
+	if parseErr := parseStructuredEnv("MA_SEASONS", &seasons); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+	if seasonErr := seasons.Validate(); seasonErr != nil {
+		err = fmt.Errorf("bad seasons: %s", seasonErr.Error())
+		return cfg, err
+	}
+
+	diets := mealieclient.Diets{}
+	if parseErr := parseStructuredEnv("MA_DIETS", &diets); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+	if dietErr := diets.Validate(); dietErr != nil {
+		err = fmt.Errorf("bad diets: %s", dietErr.Error())
+		return cfg, err
+	}
+
+	savedQueries := mealieclient.SavedQueries{}
+	if parseErr := parseStructuredEnv("MA_SAVED_QUERIES", &savedQueries); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+	if queryErr := savedQueries.Validate(); queryErr != nil {
+		err = fmt.Errorf("bad saved queries: %s", queryErr.Error())
+		return cfg, err
+	}
+
+	presets := map[string]api.Preset{}
+	if parseErr := parseStructuredEnv("MA_PRESETS", &presets); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+
+	var watch schedule.Watch
+	if parseErr := parseStructuredEnv("MA_WATCH", &watch); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
+	if watch.Configured() && (watch.IntervalSecs <= 0 || watch.TimeoutSecs <= 0) {
+		err = fmt.Errorf("interval-secs and timeout-secs for watching presets must be positive")
+		return cfg, err
+	}
+
+	// Archived recipes are excluded from exports unless requested explicitly.
+	archiveCategory := strings.TrimSpace(os.Getenv("MA_ARCHIVE_CATEGORY"))
+
+	var execHooks render.ExecHooks
+	if parseErr := parseStructuredEnv("MA_EXEC_HOOKS", &execHooks); parseErr != nil {
+		err = parseErr
+		return cfg, err
+	}
 
 	cfg = config{
+		mode:               mode,
+		workerToken:        secretEnv("MA_WORKER_TOKEN"),
 		mealieRetrievalURL: os.Getenv("MEALIE_RETRIEVAL_URL"),
 		mealieBaseURL:      mealieBaseURL,
 		mealieToken:        token,
+		debugToken:         debugToken,
+		adminToken:         adminToken,
+		auth:               auth,
+		rateLimit:          rateLimit,
+		trustedProxies:     strings.Fields(os.Getenv("MA_TRUSTED_PROXIES")),
 		selfURL:            selfURL,
+		tlsCertFile:        tlsCertFile,
+		tlsKeyFile:         tlsKeyFile,
+		mediaURL:           mediaURL,
 		listenInterface:    interfaceEnv,
+		grpcInterface:      os.Getenv("MA_GRPC_LISTEN_INTERFACE"),
+		grpcToken:          secretEnv("MA_GRPC_TOKEN"),
 		retrievalLimit:     retrievalLimit,
+		memoryLimit:        memoryLimit,
+		imageLimit:         imageLimit,
+		emptyResult:        emptyResult,
+		mediaCacheSize:     mediaCacheSize,
+		bookCacheSize:      bookCacheSize,
+		mediaCacheDir:      os.Getenv("MA_MEDIA_CACHE_DIR"),
+		mediaCacheDirSize:  mediaCacheDirSize,
+		pageSize:           pageSize,
+		maxPages:           maxPages,
+		mealieRetry:        mealieRetry,
+		mealieTransport:    mealieTransport,
 		timeoutSecs:        timeoutSecs,
+		drainTimeoutSecs:   drainTimeoutSecs,
+		cacheSecs:          cacheSecs,
 		startupGraceSecs:   startupGraceSecs,
+		degradedStart:      degradedStart,
 		pandocFlags:        pandocFlags,
+		pandocAllowlist:    pandocAllowlist,
 		pandocFontsDir:     pandocFontsDir,
+		workDir:            workDir,
+		pdfSubsetFonts:     pdfSubsetFonts,
+		lint:               lint,
+		rejectGlyphs:       rejectGlyphs,
+		pdfLayout:          pdfLayout,
+		pdfEngine:          pdfEngine,
 		imageAction:        imageAction,
-		htmlAttrsMod:       htmlAttrsMod,
-		htmlAttrsRm:        htmlAttrsRm,
-		queryAssignments:   queryAssignments,
-		fixes:              fixes,
+		imageCaptions:      imageCaptions,
+		showDifficulty:     showDifficulty,
+		showNutrition:      showNutrition,
+		allergens:          allergens,
+		failOnBrokenLinks:  failOnBrokenLinks,
+		language:           lang,
+		metadata: render.Metadata{
+			Author:  os.Getenv("MA_METADATA_AUTHOR"),
+			Subject: os.Getenv("MA_METADATA_SUBJECT"),
+		},
+		htmlAttrsMod:     htmlAttrsMod,
+		htmlAttrsRm:      htmlAttrsRm,
+		queryAssignments: queryAssignments,
+		scheduledExports: scheduledExports,
+		webdav:           webdav,
+		git:              git,
+		s3:               s3,
+		dropbox:          dropbox,
+		googleDrive:      googleDrive,
+		sftp:             sftp,
+		ftp:              ftp,
+		remarkable:       remarkable,
+		paperless:        paperless,
+		discord:          discord,
+		slack:            slack,
+		matrix:           matrix,
+		fixes:            fixes,
+		converters:       converters,
+		seasons:          seasons,
+		diets:            diets,
+		savedQueries:     savedQueries,
+		archiveCategory:  archiveCategory,
+		readOnly:         readOnly,
+		presets:          presets,
+		watch:            watch,
 	}
 	return cfg, err
 }
+
+// Parse a number of bytes with an optional unit suffix, i.e. B, KiB, MiB, GiB, or TiB. This is the
+// format of the GOMEMLIMIT environment variable understood by the Go runtime.
+func parseByteSize(value string) (int64, error) {
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40}, {"B", 1},
+	}
+	value = strings.TrimSpace(value)
+	factor := int64(1)
+	for _, unit := range units {
+		if number, found := strings.CutSuffix(value, unit.suffix); found {
+			value, factor = number, unit.factor
+			break
+		}
+	}
+	number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if number <= 0 {
+		return 0, fmt.Errorf("size must be positive but is %d", number)
+	}
+	if number > math.MaxInt64/factor {
+		return 0, fmt.Errorf("size is too large")
+	}
+	return number * factor, nil
+}
+
+// Get the value of an environment variable that contains a secret. Try to interpret the value as
+// pointing to a file that exists. If so, we read the value from the file. If not, we use the value
+// from the environment directly. This enables the use of docker-compose secrets.
+func secretEnv(env string) string {
+	value := os.Getenv(env)
+	content, readErr := os.ReadFile(value) // #nosec:G304
+	if readErr == nil {
+		// It does point to a file.
+		return strings.TrimSpace(string(content))
+	}
+	return strings.TrimSpace(value)
+}
+
+// Parse the value of an environment variable that contains a complex structure into target. The
+// value is either the structure itself or the path to a file containing it, e.g. a mounted config
+// file. The structure may be given as JSON or as YAML, which supports anchors and aliases to reuse
+// parts of it. Unknown fields are rejected and errors point to the offending position.
+func parseStructuredEnv(env string, target any) error {
+	value := os.Getenv(env)
+	if value == "" {
+		return nil
+	}
+	// Like for the token, we first try to interpret the value as pointing to a file that exists.
+	if content, readErr := os.ReadFile(value); readErr == nil { // #nosec:G304
+		slog.Info("reading environment variable from file", "env", env, "path", value)
+		value = string(content)
+	}
+	if err := yaml.UnmarshalWithOptions([]byte(value), target, yaml.Strict()); err != nil {
+		return fmt.Errorf(
+			"failed to parse %s as the expected JSON or YAML:\n%s",
+			env, yaml.FormatError(err, false, true),
+		)
+	}
+	return nil
+}
+
+// All persisted state that is configured.
+func (c config) stores() []migrate.Store {
+	stores := []migrate.Store{}
+	if c.mediaCacheDir != "" {
+		stores = append(stores, media.CacheStore(c.mediaCacheDir))
+	}
+	if c.fixes.stateFile != "" {
+		stores = append(stores, fixStateStore(c.fixes.stateFile))
+	}
+	return stores
+}
+
+// Parse HTML attributes given as an HTML snippet or, e.g. in config files, as a mapping from
+// element names to attributes.
+func parseHTMLAttrsEnv(env string) (map[string]map[string]string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(os.Getenv(env)), "{") {
+		return render.ParseHTMLAttrs(os.Getenv(env))
+	}
+	attrs := map[string]map[string]string{}
+	err := parseStructuredEnv(env, &attrs)
+	return attrs, err
+}
@@ -0,0 +1,86 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Keys of configuration files are names of environment variables.
+var configKeyRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Load the YAML or TOML file at path, if any, whose keys are names of environment variables, and
+// set every environment variable in it that is not set already. That way, environment variables
+// override the file. Structured values such as those of MA_QUERY_ASSIGNMENTS may be given as YAML
+// or TOML directly instead of as JSON strings. Return the names of the variables taken from the
+// file, even on error.
+func loadConfigFile(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path) // #nosec:G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %s", err.Error())
+	}
+	values := map[string]any{}
+	if strings.ToLower(filepath.Ext(path)) == ".toml" {
+		err = toml.Unmarshal(content, &values)
+	} else {
+		err = yaml.Unmarshal(content, &values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %s", path, err.Error())
+	}
+
+	applied := []string{}
+	for key, value := range values {
+		if !configKeyRegex.MatchString(key) {
+			return applied, fmt.Errorf("config file key %s is no environment variable", key)
+		}
+		if _, isSet := os.LookupEnv(key); isSet || value == nil {
+			continue
+		}
+		var str string
+		switch value.(type) {
+		case map[string]any, []any:
+			// Structured environment variables are parsed as JSON or YAML.
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return applied, fmt.Errorf("failed to encode value of %s: %s", key, err.Error())
+			}
+			str = string(encoded)
+		default:
+			str = fmt.Sprint(value)
+		}
+		if err := os.Setenv(key, str); err != nil {
+			return applied, fmt.Errorf("failed to set %s: %s", key, err.Error())
+		}
+		applied = append(applied, key)
+	}
+	slices.Sort(applied)
+	return applied, nil
+}
@@ -0,0 +1,89 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultMaxAttachment is the size in bytes up to which chats attach documents by default. Chat
+// services limit the size of files, e.g. Discord to 10MB for servers without boosts.
+const DefaultMaxAttachment = 8 << 20
+
+// Announcement determines how chats announce documents. Documents no larger than MaxAttachment
+// bytes are attached to the message. Larger ones are linked if LinkURL is set, which is where
+// documents can be downloaded by name, e.g. the public URL of a directory that another destination
+// uploads them to. Otherwise, only their names are posted.
+type Announcement struct {
+	// Message precedes the name of the document, e.g. "New cookbook edition:".
+	Message       string
+	MaxAttachment int64
+	LinkURL       string
+}
+
+// Whether the document is attached to the message.
+func (a Announcement) attach(content []byte) bool {
+	return int64(len(content)) <= a.MaxAttachment
+}
+
+// The text announcing the document, which includes a link unless the document is attached.
+func (a Announcement) text(name string, content []byte) string {
+	text := strings.TrimSpace(a.Message + " " + name)
+	switch {
+	case a.attach(content):
+		return text
+	case a.LinkURL != "":
+		return text + "\n" + strings.TrimSuffix(a.LinkURL, "/") + "/" + url.PathEscape(name)
+	default:
+		return fmt.Sprintf("%s (%d bytes, too large to attach)", text, len(content))
+	}
+}
+
+// The MIME type of an attached document.
+func attachmentType(name string) string {
+	if mimeType := mime.TypeByExtension(path.Ext(name)); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// Send a request to a chat service and return the body of the response. Also report whether it
+// makes sense to retry after a failure.
+func sendChat(req *http.Request) ([]byte, bool, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf(
+			"unexpected status code %d: %s", resp.StatusCode, string(body),
+		)
+	}
+	return body, false, resp.Body.Close()
+}
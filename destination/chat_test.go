@@ -0,0 +1,47 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import "testing"
+
+func TestAnnouncementAttachesOrLinks(t *testing.T) {
+	tests := []struct {
+		announcement Announcement
+		content      string
+		want         string
+	}{
+		{
+			Announcement{Message: "New cookbook edition:", MaxAttachment: 5},
+			"small", "New cookbook edition: My Recipes.pdf",
+		},
+		{
+			Announcement{Message: "New:", MaxAttachment: 4, LinkURL: "https://example.com/books/"},
+			"large", "New: My Recipes.pdf\nhttps://example.com/books/My%20Recipes.pdf",
+		},
+		{
+			Announcement{MaxAttachment: 4},
+			"large", "My Recipes.pdf (5 bytes, too large to attach)",
+		},
+	}
+	for _, test := range tests {
+		got := test.announcement.text("My Recipes.pdf", []byte(test.content))
+		if got != test.want {
+			t.Errorf("text() = %q, want %q", got, test.want)
+		}
+	}
+}
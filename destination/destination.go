@@ -0,0 +1,60 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package destination stores exported documents somewhere other than the requester's machine.
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrUnsupported is returned by destinations that cannot store a type of document at all.
+var ErrUnsupported = errors.New("unsupported type of document")
+
+// Destination stores documents under a name.
+type Destination interface {
+	// Name identifies the destination, e.g. in query parameters.
+	Name() string
+	// Put stores content under the name, replacing anything stored under that name before.
+	Put(ctx context.Context, name string, content []byte) error
+}
+
+// Directory stores documents in a local directory.
+type Directory struct {
+	Path string
+}
+
+// Name identifies the destination.
+func (d *Directory) Name() string {
+	return "dir"
+}
+
+// Put writes to a temporary file first so that nobody sees partially written documents.
+func (d *Directory) Put(_ context.Context, name string, content []byte) error {
+	path := filepath.Join(d.Path, name)
+	if err := os.WriteFile(path+".tmp", content, 0o600); err != nil { //nolint:mnd
+		return fmt.Errorf("failed to write document: %s", err.Error())
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to move document into place: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+)
+
+// Discord announces documents in a Discord channel via a webhook, which is created in the settings
+// of the channel. Every upload posts a new message.
+type Discord struct {
+	WebhookURL   string
+	Announcement Announcement
+}
+
+// Name identifies the destination.
+func (d *Discord) Name() string {
+	return "discord"
+}
+
+// Put posts a message announcing the document. Posts that fail due to network or server-side
+// errors or rate limits are retried.
+func (d *Discord) Put(ctx context.Context, name string, content []byte) error {
+	return retryUpload(ctx, d.Name(), name, func() (bool, error) {
+		return d.put(ctx, name, content)
+	})
+}
+
+// Perform a single post. Also report whether it makes sense to retry it.
+func (d *Discord) put(ctx context.Context, name string, content []byte) (bool, error) {
+	// Without waiting, Discord does not report whether the message could be created.
+	target, err := url.Parse(d.WebhookURL)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse webhook url: %s", err.Error())
+	}
+	query := target.Query()
+	query.Set("wait", "true")
+	target.RawQuery = query.Encode()
+
+	payload, err := json.Marshal(map[string]string{"content": d.Announcement.text(name, content)})
+	if err != nil {
+		return false, fmt.Errorf("failed to convert to json: %s", err.Error())
+	}
+	contentType := "application/json"
+	body := payload
+	if d.Announcement.attach(content) {
+		contentType, body, err = discordForm(payload, name, content)
+		if err != nil {
+			return false, fmt.Errorf("failed to build form: %s", err.Error())
+		}
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, target.String(), bytes.NewReader(body),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", contentType)
+	_, retry, err := sendChat(req)
+	return retry, err
+}
+
+// Build a form that posts the message with the document attached. Return its content type, too.
+func discordForm(payload []byte, name string, content []byte) (string, []byte, error) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	if err := writer.WriteField("payload_json", string(payload)); err != nil {
+		return "", nil, err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[0]"; filename=%q`, name))
+	header.Set("Content-Type", attachmentType(name))
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", nil, err
+	}
+	_, err = part.Write(content)
+	if err = errors.Join(err, writer.Close()); err != nil {
+		return "", nil, err
+	}
+	return writer.FormDataContentType(), form.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiscordAttachesSmallDocuments(t *testing.T) {
+	posted := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/webhooks/1/token" || r.URL.Query().Get("wait") != "true" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var payload map[string]string
+		_ = json.Unmarshal([]byte(r.FormValue("payload_json")), &payload)
+		posted["text"] = payload["content"]
+		file, header, err := r.FormFile("files[0]")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		posted["name"], posted["content"] = header.Filename, string(content)
+		_, _ = io.WriteString(w, `{"id":"1"}`)
+	}))
+	defer server.Close()
+
+	discord := &Discord{
+		WebhookURL:   server.URL + "/api/webhooks/1/token",
+		Announcement: Announcement{Message: "New cookbook edition:", MaxAttachment: 100},
+	}
+	if err := discord.Put(context.Background(), "recipes.pdf", []byte("content")); err != nil {
+		t.Fatalf("Put() failed: %s", err.Error())
+	}
+	want := map[string]string{
+		"text": "New cookbook edition: recipes.pdf", "name": "recipes.pdf", "content": "content",
+	}
+	for key, value := range want {
+		if posted[key] != value {
+			t.Errorf("%s = %q, want %q", key, posted[key], value)
+		}
+	}
+}
+
+func TestDiscordLinksLargeDocuments(t *testing.T) {
+	posted := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var payload map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		posted = payload["content"]
+		_, _ = io.WriteString(w, `{"id":"1"}`)
+	}))
+	defer server.Close()
+
+	discord := &Discord{
+		WebhookURL:   server.URL,
+		Announcement: Announcement{MaxAttachment: 1, LinkURL: "https://example.com"},
+	}
+	if err := discord.Put(context.Background(), "recipes.pdf", []byte("content")); err != nil {
+		t.Fatalf("Put() failed: %s", err.Error())
+	}
+	if want := "recipes.pdf\nhttps://example.com/recipes.pdf"; posted != want {
+		t.Errorf("posted %q, want %q", posted, want)
+	}
+}
@@ -0,0 +1,140 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/oauth2"
+)
+
+// Endpoints of the Dropbox API, which tests replace.
+var (
+	dropboxTokenURL  = "https://api.dropboxapi.com/oauth2/token"
+	dropboxUploadURL = "https://content.dropboxapi.com/2/files/upload"
+)
+
+// Dropbox uploads documents to a folder in Dropbox. It authenticates as a Dropbox app with a
+// refresh token, which is exchanged for short-lived access tokens whenever they expire. The folder
+// at Path, e.g. /recipes, is relative to the app folder for apps with that access type.
+type Dropbox struct {
+	AppKey       string
+	AppSecret    string
+	RefreshToken string
+	Path         string
+	tokens       tokenCache
+}
+
+// Name identifies the destination.
+func (d *Dropbox) Name() string {
+	return "dropbox"
+}
+
+// Put uploads content as a file, overwriting any file of the same name. Uploads that fail due to
+// network errors, server-side errors, or expired access tokens are retried.
+func (d *Dropbox) Put(ctx context.Context, name string, content []byte) error {
+	return retryUpload(ctx, d.Name(), name, func() (bool, error) {
+		return d.put(ctx, name, content)
+	})
+}
+
+func (d *Dropbox) tokenSource(ctx context.Context) oauth2.TokenSource {
+	config := oauth2.Config{
+		ClientID:     d.AppKey,
+		ClientSecret: d.AppSecret,
+		Endpoint: oauth2.Endpoint{
+			TokenURL: dropboxTokenURL, AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+	return config.TokenSource(ctx, &oauth2.Token{RefreshToken: d.RefreshToken})
+}
+
+// Perform a single upload. Also report whether it makes sense to retry it.
+func (d *Dropbox) put(ctx context.Context, name string, content []byte) (bool, error) {
+	arg, err := asciiJSON(map[string]any{
+		"path":       path.Join("/", d.Path, name),
+		"mode":       "overwrite",
+		"autorename": false,
+		"mute":       true,
+	})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, dropboxUploadURL, bytes.NewReader(content),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Dropbox-API-Arg", arg)
+	if retry, err := d.tokens.authorize(req, d.tokenSource); err != nil {
+		return retry, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return false, resp.Body.Close()
+	case resp.StatusCode == http.StatusUnauthorized:
+		// The access token expired early or was revoked. Try again with a new one.
+		d.tokens.reset()
+		return true, fmt.Errorf("access token rejected: %s", string(body))
+	default:
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+}
+
+// Encode a value as JSON that contains only ASCII characters, which Dropbox requires for arguments
+// passed as headers.
+func asciiJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert to json: %s", err.Error())
+	}
+	result := strings.Builder{}
+	for _, char := range string(encoded) {
+		switch {
+		case char < 0x80: //nolint:mnd
+			result.WriteRune(char)
+		case utf16.RuneLen(char) == 2: //nolint:mnd
+			// Characters outside the basic multilingual plane are escaped as surrogate pairs.
+			first, second := utf16.EncodeRune(char)
+			fmt.Fprintf(&result, `\u%04x\u%04x`, first, second)
+		default:
+			fmt.Fprintf(&result, `\u%04x`, char)
+		}
+	}
+	return result.String(), nil
+}
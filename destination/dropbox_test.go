@@ -0,0 +1,100 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDropboxRenewsRejectedAccessTokens(t *testing.T) {
+	tokens, uploaded, args := 0, "", []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.FormValue("refresh_token") != "refresh" || r.FormValue("client_id") != "key" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			tokens++
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":14400}`, tokens)
+		case "/upload":
+			args = append(args, r.Header.Get("Dropbox-API-Arg"))
+			// The first access token has been revoked.
+			if r.Header.Get("Authorization") != "Bearer token-2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			content, _ := io.ReadAll(r.Body)
+			uploaded = string(content)
+		}
+	}))
+	defer server.Close()
+	dropboxTokenURL, dropboxUploadURL = server.URL+"/token", server.URL+"/upload"
+
+	dropbox := &Dropbox{
+		AppKey: "key", AppSecret: "secret", RefreshToken: "refresh", Path: "/Rezepte/",
+	}
+	if err := dropbox.Put(context.Background(), "Käse.pdf", []byte("content")); err != nil {
+		t.Fatalf("Put() failed: %s", err.Error())
+	}
+
+	if tokens != 2 || uploaded != "content" {
+		t.Errorf("obtained %d tokens and uploaded %q, want 2 and %q", tokens, uploaded, "content")
+	}
+	if len(args) != 2 || args[0] != args[1] || !isASCII(args[1]) {
+		t.Fatalf("Dropbox-API-Arg = %v, want the same ascii value twice", args)
+	}
+	arg := map[string]any{}
+	if err := json.Unmarshal([]byte(args[1]), &arg); err != nil {
+		t.Fatal(err)
+	}
+	if arg["path"] != "/Rezepte/K\u00e4se.pdf" || arg["mode"] != "overwrite" {
+		t.Errorf("Dropbox-API-Arg = %v, want overwriting /Rezepte/K\u00e4se.pdf", arg)
+	}
+}
+
+func isASCII(s string) bool {
+	for _, char := range s {
+		if char >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestASCIIJSON(t *testing.T) {
+	value := "K\u00e4sekuchen \U0001f370"
+	got, err := asciiJSON(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded string
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !isASCII(got) || decoded != value {
+		t.Errorf("asciiJSON(%q) = %s, which is not ascii or decodes to %q", value, got, decoded)
+	}
+}
@@ -0,0 +1,224 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// FTP uploads documents to the directory at Path on an FTP server. Address is the host name of
+// the server, optionally followed by a port. Without a user, the anonymous account is used. Plain
+// FTP sends credentials and documents in the clear, so it should only be used in trusted networks.
+type FTP struct {
+	Address  string
+	User     string
+	Password string
+	Path     string
+}
+
+// Name identifies the destination.
+func (f *FTP) Name() string {
+	return "ftp"
+}
+
+// Put uploads content to the target directory. It is written to a temporary file first so that
+// nobody sees partially written documents. Uploads that fail due to network errors or transient
+// errors reported by the server are retried.
+func (f *FTP) Put(ctx context.Context, name string, content []byte) error {
+	return retryUpload(ctx, f.Name(), name, func() (bool, error) {
+		return f.put(ctx, name, content)
+	})
+}
+
+// Perform a single upload. Also report whether it makes sense to retry it.
+func (f *FTP) put(ctx context.Context, name string, content []byte) (bool, error) {
+	address := f.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "21")
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return true, fmt.Errorf("failed to connect to %s: %s", address, err.Error())
+	}
+	// Closing the connection aborts whatever is in progress once the context is done.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	control := textproto.NewConn(conn)
+	defer func() { _ = control.Close() }()
+
+	err = f.upload(ctx, control, conn.RemoteAddr(), name, content)
+	if err != nil {
+		// Replies with codes 4xx signal transient errors, those with codes 5xx permanent ones.
+		var protoErr *textproto.Error
+		retry := !errors.As(err, &protoErr) || protoErr.Code < 500
+		return retry, err
+	}
+	return false, nil
+}
+
+// Send a command and wait for a reply whose code starts with expected.
+func ftpCommand(control *textproto.Conn, expected int, format string, args ...any) (string, error) {
+	if err := control.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	_, message, err := control.ReadResponse(expected)
+	return message, err
+}
+
+func (f *FTP) upload(
+	ctx context.Context, control *textproto.Conn, server net.Addr, name string, content []byte,
+) error {
+	if _, _, err := control.ReadResponse(2); err != nil { //nolint:mnd
+		return err
+	}
+	user, password := f.User, f.Password
+	if user == "" {
+		user, password = "anonymous", "anonymous"
+	}
+	// Servers that need no password accept the user right away.
+	_, err := ftpCommand(control, 2, "USER %s", user) //nolint:mnd
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code == 331 { //nolint:mnd
+		_, err = ftpCommand(control, 2, "PASS %s", password) //nolint:mnd
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := ftpCommand(control, 2, "TYPE I"); err != nil { //nolint:mnd
+		return err
+	}
+
+	dir := strings.TrimSuffix(f.Path, "/")
+	if err := ftpMkdirAll(ctx, control, dir); err != nil {
+		return err
+	}
+	target := path.Join(dir, name)
+
+	data, err := ftpDataConn(ctx, control, server)
+	if err != nil {
+		return err
+	}
+	// The server confirms that the transfer starts and, once the data connection is closed, that
+	// it is complete.
+	if _, err := ftpCommand(control, 1, "STOR %s", target+".tmp"); err != nil {
+		_ = data.Close()
+		return err
+	}
+	_, writeErr := data.Write(content)
+	closeErr := data.Close()
+	if _, _, err := control.ReadResponse(2); err != nil { //nolint:mnd
+		return err
+	}
+	if writeErr != nil || closeErr != nil {
+		return fmt.Errorf("failed to transfer document: %v", errors.Join(writeErr, closeErr))
+	}
+
+	if _, err := ftpCommand(control, 3, "RNFR %s", target+".tmp"); err != nil { //nolint:mnd
+		return err
+	}
+	if _, err := ftpCommand(control, 2, "RNTO %s", target); err != nil { //nolint:mnd
+		return err
+	}
+	_, _ = ftpCommand(control, 2, "QUIT") //nolint:mnd
+	return nil
+}
+
+// Create the directory at dir and all of its parents, one after the other since servers do not
+// create parents on their own. Servers reply to directories that exist already with permanent
+// errors, which are ignored. Should a directory be missing after all, storing the document fails.
+func ftpMkdirAll(ctx context.Context, control *textproto.Conn, dir string) error {
+	current := ""
+	if strings.HasPrefix(dir, "/") {
+		current = "/"
+	}
+	for component := range strings.SplitSeq(dir, "/") {
+		if component == "" {
+			continue
+		}
+		current = path.Join(current, component)
+		_, err := ftpCommand(control, 2, "MKD %s", current) //nolint:mnd
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+			slog.DebugContext(
+				ctx, "failed to create ftp directory", "path", current, "error", err,
+			)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open a connection for a transfer in passive mode, which works behind NAT on the client side.
+// Servers are connected to at the address of the control connection even if they reply with
+// another one, since servers behind NAT often reply with their internal address.
+func ftpDataConn(ctx context.Context, control *textproto.Conn, server net.Addr) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(server.String())
+	if err != nil {
+		return nil, err
+	}
+	var port int
+	message, err := ftpCommand(control, 2, "EPSV") //nolint:mnd
+	if err == nil {
+		// The reply looks like "Entering Extended Passive Mode (|||6446|)".
+		fields := strings.Split(message, "|")
+		if len(fields) < 5 { //nolint:mnd
+			return nil, fmt.Errorf("failed to parse reply to EPSV: %s", message)
+		}
+		port, err = strconv.Atoi(fields[3])
+	} else {
+		// The reply looks like "Entering Passive Mode (h1,h2,h3,h4,p1,p2)".
+		message, err = ftpCommand(control, 2, "PASV") //nolint:mnd
+		if err != nil {
+			return nil, err
+		}
+		start, end := strings.Index(message, "("), strings.Index(message, ")")
+		fields := []string{}
+		if start >= 0 && end > start {
+			fields = strings.Split(message[start+1:end], ",")
+		}
+		if len(fields) != 6 { //nolint:mnd
+			return nil, fmt.Errorf("failed to parse reply to PASV: %s", message)
+		}
+		var high, low int
+		high, err = strconv.Atoi(strings.TrimSpace(fields[4]))
+		if err == nil {
+			low, err = strconv.Atoi(strings.TrimSpace(fields[5]))
+		}
+		port = high*256 + low //nolint:mnd
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse port for transfer: %s", err.Error())
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect for transfer: %s", err.Error())
+	}
+	return conn, nil
+}
@@ -0,0 +1,191 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// An FTP server that keeps files in memory and understands just enough of the protocol for
+// uploads. Logging in fails unless the password is "secret". Directories are created only if their
+// parents exist.
+type fakeFTPServer struct {
+	lock        sync.Mutex
+	files       map[string]string
+	dirs        map[string]bool
+	commands    []string
+	connections int
+	address     string
+}
+
+func newFakeFTPServer(t *testing.T) *fakeFTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	server := &fakeFTPServer{
+		files: map[string]string{}, dirs: map[string]bool{"/": true},
+		address: listener.Addr().String(),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.lock.Lock()
+			server.connections++
+			server.lock.Unlock()
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (f *fakeFTPServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reply := func(line string) { _, _ = fmt.Fprintf(conn, "%s\r\n", line) }
+	reader := bufio.NewReader(conn)
+	var data net.Listener
+	renameFrom := ""
+	reply("220 welcome")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		f.lock.Lock()
+		f.commands = append(f.commands, strings.TrimSpace(line))
+		f.lock.Unlock()
+		switch command {
+		case "USER":
+			reply("331 password please")
+		case "PASS":
+			if arg != "secret" {
+				reply("530 login incorrect")
+			} else {
+				reply("230 logged in")
+			}
+		case "TYPE":
+			reply("200 binary")
+		case "MKD":
+			f.lock.Lock()
+			switch {
+			case f.dirs[arg]:
+				reply("550 exists")
+			case !f.dirs[path.Dir(arg)]:
+				reply("550 no such directory")
+			default:
+				f.dirs[arg] = true
+				reply("257 created")
+			}
+			f.lock.Unlock()
+		case "EPSV":
+			data, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				return
+			}
+			reply(fmt.Sprintf("229 passive (|||%d|)", data.Addr().(*net.TCPAddr).Port))
+		case "STOR":
+			f.lock.Lock()
+			exists := f.dirs[path.Dir(arg)]
+			f.lock.Unlock()
+			if !exists {
+				reply("553 no such directory")
+				continue
+			}
+			reply("150 go ahead")
+			dataConn, err := data.Accept()
+			if err != nil {
+				return
+			}
+			content, _ := io.ReadAll(dataConn)
+			_ = dataConn.Close()
+			_ = data.Close()
+			f.lock.Lock()
+			f.files[arg] = string(content)
+			f.lock.Unlock()
+			reply("226 done")
+		case "RNFR":
+			renameFrom = arg
+			reply("350 ready")
+		case "RNTO":
+			f.lock.Lock()
+			f.files[arg] = f.files[renameFrom]
+			delete(f.files, renameFrom)
+			f.lock.Unlock()
+			reply("250 renamed")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestFTPUploadsViaTemporaryFile(t *testing.T) {
+	server := newFakeFTPServer(t)
+	ftp := &FTP{
+		Address: server.address, User: "user", Password: "secret", Path: "/recipes/2025/",
+	}
+	for range 2 {
+		if err := ftp.Put(context.Background(), "recipes.pdf", []byte("content")); err != nil {
+			t.Fatalf("Put() failed: %s", err.Error())
+		}
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	commands := strings.Join(server.commands, "\n")
+	// Directories are created one after the other on every upload since they might have been
+	// removed in the meantime.
+	want := "USER user\nPASS secret\nTYPE I\nMKD /recipes\nMKD /recipes/2025\nEPSV\n" +
+		"STOR /recipes/2025/recipes.pdf.tmp\nRNFR /recipes/2025/recipes.pdf.tmp\n" +
+		"RNTO /recipes/2025/recipes.pdf\nQUIT"
+	if commands != want+"\n"+want {
+		t.Errorf("unexpected commands:\n%s\nwant twice:\n%s", commands, want)
+	}
+	if len(server.files) != 1 || server.files["/recipes/2025/recipes.pdf"] != "content" {
+		t.Errorf("unexpected files: %v", server.files)
+	}
+}
+
+func TestFTPDoesNotRetryRejectedLogin(t *testing.T) {
+	server := newFakeFTPServer(t)
+	ftp := &FTP{Address: server.address, User: "user", Password: "wrong"}
+	err := ftp.Put(context.Background(), "recipes.pdf", []byte("content"))
+	if err == nil || !strings.Contains(err.Error(), "login incorrect") {
+		t.Errorf("expected the login to be rejected, got %v", err)
+	}
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if server.connections != 1 {
+		t.Errorf("expected no retries, got %d connections", server.connections)
+	}
+}
@@ -0,0 +1,222 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+// The Google Drive API, which tests replace.
+var googleDriveURL = "https://www.googleapis.com"
+
+// The token endpoint used if the key of a service account does not name one.
+const googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// Access to files in Google Drive.
+const googleDriveScope = "https://www.googleapis.com/auth/drive"
+
+// The parts of the JSON key of a Google service account that are needed to obtain access tokens.
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// GoogleDrive uploads documents to a folder in Google Drive as a service account. Credentials is
+// the JSON key of the service account, which has to be permitted to add files to the folder with
+// the ID FolderID. Access tokens are obtained with the key whenever they expire.
+type GoogleDrive struct {
+	Credentials string
+	FolderID    string
+	tokens      tokenCache
+}
+
+// Name identifies the destination.
+func (g *GoogleDrive) Name() string {
+	return "gdrive"
+}
+
+// Validate checks that the credentials are the key of a service account.
+func (g *GoogleDrive) Validate() error {
+	_, err := g.key()
+	return err
+}
+
+func (g *GoogleDrive) key() (serviceAccountKey, error) {
+	var key serviceAccountKey
+	if err := json.Unmarshal([]byte(g.Credentials), &key); err != nil {
+		return key, fmt.Errorf("failed to parse service account key: %s", err.Error())
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return key, fmt.Errorf("service account key lacks client_email or private_key")
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return key, fmt.Errorf("private key of service account is no PEM block")
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if _, pkcs1Err := x509.ParsePKCS1PrivateKey(block.Bytes); pkcs1Err != nil {
+			return key, fmt.Errorf(
+				"failed to parse private key of service account: %s", err.Error(),
+			)
+		}
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = googleTokenURL
+	}
+	return key, nil
+}
+
+func (g *GoogleDrive) tokenSource(ctx context.Context) oauth2.TokenSource {
+	// Invalid keys are rejected by Validate. Should one slip through, obtaining tokens fails.
+	key, _ := g.key()
+	config := jwt.Config{
+		Email:      key.ClientEmail,
+		PrivateKey: []byte(key.PrivateKey),
+		Scopes:     []string{googleDriveScope},
+		TokenURL:   key.TokenURI,
+	}
+	return config.TokenSource(ctx)
+}
+
+// Put uploads content as a file into the folder, replacing the content of any file of the same
+// name. Uploads that fail due to network errors, server-side errors, or expired access tokens are
+// retried.
+func (g *GoogleDrive) Put(ctx context.Context, name string, content []byte) error {
+	return retryUpload(ctx, g.Name(), name, func() (bool, error) {
+		return g.put(ctx, name, content)
+	})
+}
+
+// Perform a single upload. Also report whether it makes sense to retry it.
+func (g *GoogleDrive) put(ctx context.Context, name string, content []byte) (bool, error) {
+	// Files in Drive are identified by IDs, not names, so an existing file has to be looked up.
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	query := url.Values{
+		"q": {fmt.Sprintf(
+			"name = '%s' and '%s' in parents and trashed = false",
+			escaped.Replace(name), escaped.Replace(g.FolderID),
+		)},
+		"fields":                    {"files(id)"},
+		"supportsAllDrives":         {"true"},
+		"includeItemsFromAllDrives": {"true"},
+	}
+	var found struct {
+		Files []struct {
+			ID string `json:"id"`
+		} `json:"files"`
+	}
+	target := googleDriveURL + "/drive/v3/files?" + query.Encode()
+	if retry, err := g.do(ctx, http.MethodGet, target, "", nil, &found); err != nil {
+		return retry, fmt.Errorf("failed to look up existing file: %s", err.Error())
+	}
+
+	if len(found.Files) != 0 {
+		target := googleDriveURL + "/upload/drive/v3/files/" + url.PathEscape(found.Files[0].ID) +
+			"?uploadType=media&supportsAllDrives=true"
+		return g.do(ctx, http.MethodPatch, target, "application/octet-stream", content, nil)
+	}
+
+	// New files are created with their metadata and content in a single multipart request.
+	body := bytes.Buffer{}
+	writer := multipart.NewWriter(&body)
+	metadata, err := json.Marshal(map[string]any{"name": name, "parents": []string{g.FolderID}})
+	if err != nil {
+		return false, fmt.Errorf("failed to convert metadata to json: %s", err.Error())
+	}
+	parts := []struct {
+		contentType string
+		content     []byte
+	}{{"application/json; charset=UTF-8", metadata}, {"application/octet-stream", content}}
+	for _, part := range parts {
+		partWriter, err := writer.CreatePart(
+			textproto.MIMEHeader{"Content-Type": {part.contentType}},
+		)
+		if err == nil {
+			_, err = partWriter.Write(part.content)
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to construct request body: %s", err.Error())
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return false, fmt.Errorf("failed to construct request body: %s", err.Error())
+	}
+	target = googleDriveURL + "/upload/drive/v3/files?uploadType=multipart&supportsAllDrives=true"
+	contentType := "multipart/related; boundary=" + writer.Boundary()
+	return g.do(ctx, http.MethodPost, target, contentType, body.Bytes(), nil)
+}
+
+// Send an authorized request with the given body unless it is nil and decode the JSON response
+// into result unless it is nil. Also report whether it makes sense to retry after a failure.
+func (g *GoogleDrive) do(
+	ctx context.Context, method string, target string, contentType string, body []byte, result any,
+) (bool, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if retry, err := g.tokens.authorize(req, g.tokenSource); err != nil {
+		return retry, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		// The access token expired early or was revoked. Try again with a new one.
+		g.tokens.reset()
+		return true, fmt.Errorf("access token rejected: %s", string(content))
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(content))
+	}
+	if result != nil {
+		if err := json.Unmarshal(content, result); err != nil {
+			return false, fmt.Errorf("failed to parse response: %s", err.Error())
+		}
+	}
+	return false, resp.Body.Close()
+}
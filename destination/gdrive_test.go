@@ -0,0 +1,138 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Create the JSON key of a service account whose tokens are obtained from tokenURL.
+func serviceAccountCredentials(t *testing.T, tokenURL string) string {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := json.Marshal(serviceAccountKey{
+		ClientEmail: "exporter@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encoded})),
+		TokenURI:    tokenURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(credentials)
+}
+
+func TestGoogleDriveCreatesAndReplacesFiles(t *testing.T) {
+	requests, created, uploaded := []string{}, "", ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"access_token":"token","expires_in":3600}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			want := "name = 'Oma\\'s Rezepte.pdf' and 'folder' in parents and trashed = false"
+			if got := r.URL.Query().Get("q"); got != want {
+				t.Errorf("q = %s, want %s", got, want)
+			}
+			if created == "" {
+				_, _ = io.WriteString(w, `{"files":[]}`)
+			} else {
+				_, _ = io.WriteString(w, `{"files":[{"id":"file-id"}]}`)
+			}
+		case http.MethodPost:
+			_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			reader := multipart.NewReader(r.Body, params["boundary"])
+			metadata, _ := reader.NextPart()
+			content, _ := io.ReadAll(metadata)
+			created = string(content)
+			file, _ := reader.NextPart()
+			content, _ = io.ReadAll(file)
+			uploaded = string(content)
+		case http.MethodPatch:
+			content, _ := io.ReadAll(r.Body)
+			uploaded = string(content)
+		}
+	}))
+	defer server.Close()
+	googleDriveURL = server.URL
+
+	drive := &GoogleDrive{
+		Credentials: serviceAccountCredentials(t, server.URL+"/token"), FolderID: "folder",
+	}
+	if err := drive.Validate(); err != nil {
+		t.Fatalf("Validate() failed: %s", err.Error())
+	}
+	for _, content := range []string{"first", "second"} {
+		err := drive.Put(context.Background(), "Oma's Rezepte.pdf", []byte(content))
+		if err != nil {
+			t.Fatalf("Put() failed: %s", err.Error())
+		}
+	}
+
+	want := []string{
+		"GET /drive/v3/files",
+		"POST /upload/drive/v3/files",
+		"GET /drive/v3/files",
+		"PATCH /upload/drive/v3/files/file-id",
+	}
+	if strings.Join(requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+	if !strings.Contains(created, `"parents":["folder"]`) || uploaded != "second" {
+		t.Errorf("created %s and uploaded %q last, want a file in folder and %q", created,
+			uploaded, "second")
+	}
+}
+
+func TestGoogleDriveRejectsInvalidCredentials(t *testing.T) {
+	for _, credentials := range []string{"", "{}", `{"client_email":"a","private_key":"b"}`} {
+		drive := &GoogleDrive{Credentials: credentials, FolderID: "folder"}
+		if err := drive.Validate(); err == nil {
+			t.Errorf("expected an error validating %q", credentials)
+		}
+	}
+}
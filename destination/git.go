@@ -0,0 +1,186 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Name of documents and directories in git repositories. It does not change between exports so
+// that git tracks the history of the recipes.
+const gitDocumentName = "recipes"
+
+// Git commits documents to a branch of a git repository and pushes them to the remote. A clone of
+// the remote is kept in Dir. Zip archives, e.g. with one document per recipe, are unpacked into a
+// directory whose content they replace. Other documents are stored under a name that depends only
+// on their extension. Credentials can be part of the remote URL or come from the environment, e.g.
+// an SSH key.
+type Git struct {
+	Remote      string
+	Branch      string
+	Dir         string
+	AuthorName  string
+	AuthorEmail string
+	// The repository may be used by only one export at a time.
+	lock sync.Mutex
+}
+
+// Name identifies the destination.
+func (g *Git) Name() string {
+	return "git"
+}
+
+// Run git in the local clone and return its output. Errors contain the output.
+func (g *Git) git(ctx context.Context, args ...string) (string, error) {
+	slog.DebugContext(ctx, "running git", "args", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", g.Dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %s: %s", args[0], err.Error(), string(output))
+	}
+	return string(output), nil
+}
+
+// Clone the remote if there is no local clone yet. Otherwise, discard anything that differs from
+// the remote branch. The branch is created if the remote does not have it yet.
+func (g *Git) sync(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(g.Dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(g.Dir, 0o700); err != nil { //nolint:mnd
+			return fmt.Errorf("failed to create directory for clone: %s", err.Error())
+		}
+		if _, err := g.git(ctx, "clone", "--no-checkout", g.Remote, "."); err != nil {
+			return err
+		}
+	}
+	if _, err := g.git(ctx, "fetch", "--prune", "origin"); err != nil {
+		return err
+	}
+	remoteBranch := "refs/remotes/origin/" + g.Branch
+	_, err := g.git(ctx, "rev-parse", "--verify", "--quiet", remoteBranch)
+	if err != nil {
+		// The remote is empty or does not have the branch yet.
+		slog.InfoContext(
+			ctx, "branch does not exist in git remote, creating it", "branch", g.Branch,
+		)
+		// Commits of earlier attempts that could not be pushed are discarded.
+		_, err = g.git(ctx, "update-ref", "-d", "refs/heads/"+g.Branch)
+		if err == nil {
+			_, err = g.git(ctx, "symbolic-ref", "HEAD", "refs/heads/"+g.Branch)
+		}
+		if err == nil {
+			_, err = g.git(ctx, "rm", "-r", "-q", "--cached", "--ignore-unmatch", ".")
+		}
+	} else {
+		_, err = g.git(ctx, "checkout", "-q", "-f", "-B", g.Branch, remoteBranch)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = g.git(ctx, "clean", "-q", "-f", "-d")
+	return err
+}
+
+// Write the document into the local clone.
+func (g *Git) write(name string, content []byte) error {
+	if path.Ext(name) != ".zip" {
+		target := filepath.Join(g.Dir, gitDocumentName+path.Ext(name))
+		return os.WriteFile(target, content, 0o600) //nolint:mnd
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %s", err.Error())
+	}
+	dir := filepath.Join(g.Dir, gitDocumentName)
+	// Recipes that no longer exist shall vanish from the repository.
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove previous documents: %s", err.Error())
+	}
+	for _, entry := range archive.File {
+		if !filepath.IsLocal(entry.Name) || strings.HasSuffix(entry.Name, "/") {
+			return fmt.Errorf("refusing to unpack archive entry %s", entry.Name)
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open archive entry %s: %s", entry.Name, err.Error())
+		}
+		buf := bytes.Buffer{}
+		_, err = buf.ReadFrom(reader)
+		if closeErr := reader.Close(); err == nil {
+			err = closeErr
+		}
+		target := filepath.Join(dir, entry.Name)
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(target), 0o700) //nolint:mnd
+		}
+		if err == nil {
+			err = os.WriteFile(target, buf.Bytes(), 0o600) //nolint:mnd
+		}
+		if err != nil {
+			return fmt.Errorf("failed to unpack archive entry %s: %s", entry.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// Put commits content and pushes the commit. Nothing is committed if nothing changed.
+func (g *Git) Put(ctx context.Context, name string, content []byte) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	err := g.sync(ctx)
+	if err == nil {
+		err = g.write(name, content)
+	}
+	if err == nil {
+		_, err = g.git(ctx, "add", "-A")
+	}
+	var status string
+	if err == nil {
+		status, err = g.git(ctx, "status", "--porcelain")
+	}
+	if err == nil && strings.TrimSpace(status) == "" {
+		slog.InfoContext(ctx, "no changes to commit to git", "name", name)
+		return nil
+	}
+	if err == nil {
+		_, err = g.git(
+			ctx,
+			"-c", "user.name="+g.AuthorName, "-c", "user.email="+g.AuthorEmail,
+			"commit", "-q", "-m", "Export "+name,
+		)
+	}
+	if err == nil {
+		_, err = g.git(ctx, "push", "origin", "HEAD:refs/heads/"+g.Branch)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to commit %s to git: %s", name, err.Error())
+	}
+	return nil
+}
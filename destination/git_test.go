@@ -0,0 +1,86 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitCommitsAndPushesDocuments(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	output, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to create remote: %s", output)
+	}
+	gitDest := &Git{
+		Remote: remote, Branch: "recipes", Dir: filepath.Join(t.TempDir(), "clone"),
+		AuthorName: "tester", AuthorEmail: "tester@localhost",
+	}
+	log := func() string {
+		output, err := exec.Command(
+			"git", "-C", remote, "log", "--format=%s", "--name-status", "recipes",
+		).CombinedOutput()
+		if err != nil {
+			t.Fatalf("failed to read log: %s", output)
+		}
+		return strings.Join(strings.Fields(string(output)), " ")
+	}
+
+	archive := func(names ...string) []byte {
+		buf := bytes.Buffer{}
+		writer := zip.NewWriter(&buf)
+		for _, name := range names {
+			entry, _ := writer.Create(name)
+			_, _ = entry.Write([]byte(name))
+		}
+		_ = writer.Close()
+		return buf.Bytes()
+	}
+
+	ctx := context.Background()
+	puts := []struct {
+		name    string
+		content []byte
+	}{
+		{"recipes-1.md", []byte("first")},
+		{"recipes-2.md", []byte("first")},
+		{"recipes-3.zip", archive("cake.md", "soup.md")},
+		{"recipes-4.zip", archive("cake.md")},
+	}
+	for _, put := range puts {
+		if err := gitDest.Put(ctx, put.name, put.content); err != nil {
+			t.Fatalf("Put(%s) failed: %s", put.name, err.Error())
+		}
+	}
+
+	want := "Export recipes-4.zip D recipes/soup.md " +
+		"Export recipes-3.zip A recipes/cake.md A recipes/soup.md " +
+		"Export recipes-1.md A recipes.md"
+	if got := log(); got != want {
+		t.Errorf("unexpected history %q, want %q", got, want)
+	}
+}
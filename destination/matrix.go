@@ -0,0 +1,110 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Matrix announces documents in a Matrix room. Token is the access token of a user who has joined
+// the room with the ID Room, e.g. !abc:example.org, on the Homeserver, e.g.
+// https://matrix.example.org. Every upload posts a new message.
+type Matrix struct {
+	Homeserver   string
+	Token        string
+	Room         string
+	Announcement Announcement
+}
+
+// Name identifies the destination.
+func (m *Matrix) Name() string {
+	return "matrix"
+}
+
+// Send a request to the homeserver and return the body of the response. Also report whether it
+// makes sense to retry after a failure.
+func (m *Matrix) do(
+	ctx context.Context, method string, endpoint string, contentType string, body []byte,
+) ([]byte, bool, error) {
+	target := strings.TrimSuffix(m.Homeserver, "/") + endpoint
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	req.Header.Set("Content-Type", contentType)
+	return sendChat(req)
+}
+
+// Put posts a message announcing the document. Posts that fail due to network or server-side
+// errors or rate limits are retried. Retries reuse the transaction ID of the message, which keeps
+// the homeserver from posting it twice.
+func (m *Matrix) Put(ctx context.Context, name string, content []byte) error {
+	transaction := uuid.New().String()
+	return retryUpload(ctx, m.Name(), name, func() (bool, error) {
+		return m.put(ctx, name, content, transaction)
+	})
+}
+
+// Perform a single post. Also report whether it makes sense to retry it. Attached documents are
+// uploaded to the homeserver first and then posted as a file whose caption is the announcement.
+func (m *Matrix) put(
+	ctx context.Context, name string, content []byte, transaction string,
+) (bool, error) {
+	event := map[string]any{"msgtype": "m.text", "body": m.Announcement.text(name, content)}
+	if m.Announcement.attach(content) {
+		mimeType := attachmentType(name)
+		endpoint := "/_matrix/media/v3/upload?" + url.Values{"filename": {name}}.Encode()
+		body, retry, err := m.do(ctx, http.MethodPost, endpoint, mimeType, content)
+		if err != nil {
+			return retry, fmt.Errorf("failed to upload file: %s", err.Error())
+		}
+		var upload struct {
+			ContentURI string `json:"content_uri"`
+		}
+		if err := json.Unmarshal(body, &upload); err != nil {
+			return false, fmt.Errorf("failed to parse upload: %s", err.Error())
+		}
+		event["msgtype"] = "m.file"
+		event["filename"] = name
+		event["url"] = upload.ContentURI
+		event["info"] = map[string]any{"mimetype": mimeType, "size": len(content)}
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return false, fmt.Errorf("failed to convert to json: %s", err.Error())
+	}
+	endpoint := fmt.Sprintf(
+		"/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		url.PathEscape(m.Room), url.PathEscape(transaction),
+	)
+	_, retry, err := m.do(ctx, http.MethodPut, endpoint, "application/json", payload)
+	if err != nil {
+		return retry, fmt.Errorf("failed to send message: %s", err.Error())
+	}
+	return false, nil
+}
@@ -0,0 +1,86 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMatrixUploadsAndPostsFiles(t *testing.T) {
+	uploaded := ""
+	events := map[string]map[string]any{}
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		prefix := "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/"
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/_matrix/media/v3/upload":
+			content, _ := io.ReadAll(r.Body)
+			uploaded = r.URL.Query().Get("filename") + ":" + string(content)
+			_, _ = io.WriteString(w, `{"content_uri":"mxc://example.org/abc"}`)
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, prefix):
+			// The first attempt fails after the event was stored.
+			var event map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&event)
+			events[strings.TrimPrefix(r.URL.Path, prefix)] = event
+			if attempts++; attempts == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = io.WriteString(w, `{"event_id":"$1"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	matrix := &Matrix{
+		Homeserver: server.URL + "/", Token: "secret", Room: "!room:example.org",
+		Announcement: Announcement{Message: "New cookbook edition:", MaxAttachment: 100},
+	}
+	if err := matrix.Put(context.Background(), "recipes.pdf", []byte("content")); err != nil {
+		t.Fatalf("Put() failed: %s", err.Error())
+	}
+
+	if uploaded != "recipes.pdf:content" {
+		t.Errorf("uploaded %q, want %q", uploaded, "recipes.pdf:content")
+	}
+	if len(events) != 1 {
+		t.Fatalf("retries used %d transaction ids, want 1", len(events))
+	}
+	for _, event := range events {
+		want := map[string]any{
+			"msgtype": "m.file", "body": "New cookbook edition: recipes.pdf",
+			"filename": "recipes.pdf", "url": "mxc://example.org/abc",
+		}
+		for key, value := range want {
+			if event[key] != value {
+				t.Errorf("%s = %v, want %v", key, event[key], value)
+			}
+		}
+	}
+}
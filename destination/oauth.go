@@ -0,0 +1,116 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Number of attempts to upload a document before giving up.
+const uploadAttempts = 3
+
+// Time to wait before the second attempt to upload a document. Later attempts wait longer.
+const uploadBackoff = time.Second
+
+// Timeout for obtaining an access token.
+const tokenTimeout = 30 * time.Second
+
+// Timeout for a single attempt to upload a document, including its transfer.
+const uploadTimeout = 5 * time.Minute
+
+// The client for uploads via HTTP. Unlike http.DefaultClient, it gives up on servers that stop
+// responding instead of blocking the export or scheduled run forever.
+var httpClient = &http.Client{Timeout: uploadTimeout}
+
+// Call upload until it succeeds, fails in a way that makes retrying pointless, or was attempted
+// uploadAttempts times. Upload reports whether it makes sense to retry it after a failure.
+func retryUpload(
+	ctx context.Context, destination string, name string, upload func() (bool, error),
+) error {
+	var err error
+	for attempt := 1; attempt <= uploadAttempts; attempt++ {
+		var retry bool
+		retry, err = upload()
+		if err == nil || !retry || attempt == uploadAttempts {
+			break
+		}
+		backoff := uploadBackoff * time.Duration(attempt)
+		slog.WarnContext(ctx,
+			"retrying upload", "destination", destination, "name", name,
+			"backoff", backoff.String(), "error", err,
+		)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to %s: %s", name, destination, err.Error())
+	}
+	return nil
+}
+
+// Access tokens of an OAuth 2.0 API. They are obtained when first needed and renewed once they
+// expire or are rejected.
+type tokenCache struct {
+	lock   sync.Mutex
+	source oauth2.TokenSource
+}
+
+// Set an access token on req, obtaining one from the source created by newSource if needed. Also
+// report whether it makes sense to retry after a failure.
+func (t *tokenCache) authorize(
+	req *http.Request, newSource func(context.Context) oauth2.TokenSource,
+) (bool, error) {
+	t.lock.Lock()
+	if t.source == nil {
+		// The source keeps the context to renew tokens later on, which is why it must not expire.
+		ctx := context.WithValue(
+			context.Background(), oauth2.HTTPClient, &http.Client{Timeout: tokenTimeout},
+		)
+		t.source = newSource(ctx)
+	}
+	source := t.source
+	t.lock.Unlock()
+
+	token, err := source.Token()
+	if err != nil {
+		// Credentials that were rejected stay rejected.
+		var retrieveErr *oauth2.RetrieveError
+		retry := !errors.As(err, &retrieveErr) || retrieveErr.Response.StatusCode >= 500
+		return retry, fmt.Errorf("failed to obtain access token: %s", err.Error())
+	}
+	token.SetAuthHeader(req)
+	return false, nil
+}
+
+// Forget the current access token, e.g. because it was rejected, so that a new one is obtained.
+func (t *tokenCache) reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.source = nil
+}
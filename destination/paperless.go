@@ -0,0 +1,203 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Paperless ingests PDF documents into paperless-ngx, which archives them and makes them
+// searchable. Documents are tagged with Tags and assigned to Correspondent, both given by name.
+// Those that do not exist yet are created on the first upload. Paperless-ngx consumes documents
+// asynchronously and refuses those whose content it archived before, which is why re-uploading an
+// unchanged document has no effect.
+type Paperless struct {
+	URL           string
+	Token         string
+	Tags          []string
+	Correspondent string
+	// The IDs of the tags and of the correspondent, which are looked up once.
+	lock            sync.Mutex
+	resolved        bool
+	tagIDs          []int
+	correspondentID int
+}
+
+// Name identifies the destination.
+func (p *Paperless) Name() string {
+	return "paperless"
+}
+
+// Send a request to the API and return the body of the response. Also report whether it makes
+// sense to retry after a failure.
+func (p *Paperless) do(
+	ctx context.Context, method string, endpoint string, contentType string, body []byte,
+) ([]byte, bool, error) {
+	target := strings.TrimSuffix(p.URL, "/") + "/api/" + endpoint
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	req.Header.Set("Authorization", "Token "+p.Token)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf(
+			"unexpected status code %d: %s", resp.StatusCode, string(content),
+		)
+	}
+	return content, false, resp.Body.Close()
+}
+
+// Look up the ID of the tag or correspondent with the name, creating it if it does not exist.
+// Kind is the endpoint, i.e. "tags" or "correspondents". Also report whether it makes sense to
+// retry after a failure.
+func (p *Paperless) resolve(ctx context.Context, kind string, name string) (int, bool, error) {
+	type object struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	query := url.Values{"name__iexact": {name}}.Encode()
+	body, retry, err := p.do(ctx, http.MethodGet, kind+"/?"+query, "", nil)
+	if err != nil {
+		return 0, retry, fmt.Errorf("failed to look up %s %s: %s", kind, name, err.Error())
+	}
+	var found struct {
+		Results []object `json:"results"`
+	}
+	if err := json.Unmarshal(body, &found); err != nil {
+		return 0, false, fmt.Errorf("failed to parse %s: %s", kind, err.Error())
+	}
+	if len(found.Results) > 0 {
+		return found.Results[0].ID, false, nil
+	}
+
+	payload, err := json.Marshal(object{Name: name})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to convert to json: %s", err.Error())
+	}
+	body, retry, err = p.do(ctx, http.MethodPost, kind+"/", "application/json", payload)
+	if err != nil {
+		return 0, retry, fmt.Errorf("failed to create %s %s: %s", kind, name, err.Error())
+	}
+	var created object
+	if err := json.Unmarshal(body, &created); err != nil {
+		return 0, false, fmt.Errorf("failed to parse %s: %s", kind, err.Error())
+	}
+	return created.ID, false, nil
+}
+
+// Look up the IDs of the tags and of the correspondent unless that succeeded before. Also report
+// whether it makes sense to retry after a failure.
+func (p *Paperless) resolveAll(ctx context.Context) (bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.resolved {
+		return false, nil
+	}
+	tagIDs := make([]int, 0, len(p.Tags))
+	for _, tag := range p.Tags {
+		id, retry, err := p.resolve(ctx, "tags", tag)
+		if err != nil {
+			return retry, err
+		}
+		tagIDs = append(tagIDs, id)
+	}
+	correspondentID := 0
+	if p.Correspondent != "" {
+		var retry bool
+		var err error
+		correspondentID, retry, err = p.resolve(ctx, "correspondents", p.Correspondent)
+		if err != nil {
+			return retry, err
+		}
+	}
+	p.tagIDs, p.correspondentID, p.resolved = tagIDs, correspondentID, true
+	return false, nil
+}
+
+// Put uploads content as a new document titled like the file without its extension. Only PDF
+// documents are accepted. Uploads that fail due to network or server-side errors are retried.
+func (p *Paperless) Put(ctx context.Context, name string, content []byte) error {
+	if strings.ToLower(path.Ext(name)) != ".pdf" {
+		return fmt.Errorf(
+			"failed to upload %s to %s: %w, only pdf is", name, p.Name(), ErrUnsupported,
+		)
+	}
+	return retryUpload(ctx, p.Name(), name, func() (bool, error) {
+		return p.put(ctx, name, content)
+	})
+}
+
+// Perform a single upload. Also report whether it makes sense to retry it.
+func (p *Paperless) put(ctx context.Context, name string, content []byte) (bool, error) {
+	if retry, err := p.resolveAll(ctx); err != nil {
+		return retry, err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := [][2]string{{"title", strings.TrimSuffix(name, path.Ext(name))}}
+	for _, id := range p.tagIDs {
+		fields = append(fields, [2]string{"tags", fmt.Sprint(id)})
+	}
+	if p.correspondentID != 0 {
+		fields = append(fields, [2]string{"correspondent", fmt.Sprint(p.correspondentID)})
+	}
+	for _, field := range fields {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return false, fmt.Errorf("failed to build form: %s", err.Error())
+		}
+	}
+	document, err := form.CreateFormFile("document", name)
+	if err != nil {
+		return false, fmt.Errorf("failed to build form: %s", err.Error())
+	}
+	_, err = document.Write(content)
+	err = errors.Join(err, form.Close())
+	if err != nil {
+		return false, fmt.Errorf("failed to build form: %s", err.Error())
+	}
+
+	_, retry, err := p.do(
+		ctx, http.MethodPost, "documents/post_document/", form.FormDataContentType(), body.Bytes(),
+	)
+	return retry, err
+}
@@ -0,0 +1,129 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestPaperlessResolvesNamesAndUploads(t *testing.T) {
+	requests := []string{}
+	forms := []map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("name__iexact"))
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/tags/":
+			if r.URL.Query().Get("name__iexact") == "recipes" {
+				_, _ = fmt.Fprint(w, `{"results":[{"id":3,"name":"Recipes"}]}`)
+			} else {
+				_, _ = fmt.Fprint(w, `{"results":[]}`)
+			}
+		case "POST /api/tags/":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprint(w, `{"id":7,"name":"mealie"}`)
+		case "GET /api/correspondents/":
+			_, _ = fmt.Fprint(w, `{"results":[{"id":5,"name":"Kitchen"}]}`)
+		case "POST /api/documents/post_document/":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			file, _, err := r.FormFile("document")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			content, _ := io.ReadAll(file)
+			form := r.MultipartForm.Value
+			form["document"] = []string{string(content)}
+			forms = append(forms, form)
+			_, _ = fmt.Fprint(w, `"b7d0e6a6-3c3e-4d6e-a5a4-0e1c1b5d2c11"`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	paperless := &Paperless{
+		URL: server.URL + "/", Token: "secret", Tags: []string{"recipes", "mealie"},
+		Correspondent: "kitchen",
+	}
+	for range 2 {
+		err := paperless.Put(context.Background(), "recipes.pdf", []byte("content"))
+		if err != nil {
+			t.Fatalf("Put() failed: %s", err.Error())
+		}
+	}
+
+	want := []string{
+		"GET /api/tags/ recipes",
+		"GET /api/tags/ mealie",
+		"POST /api/tags/ ",
+		"GET /api/correspondents/ kitchen",
+		"POST /api/documents/post_document/ ",
+		"POST /api/documents/post_document/ ",
+	}
+	if !slices.Equal(requests, want) {
+		t.Fatalf("requests = %v, want %v", requests, want)
+	}
+	for _, form := range forms {
+		if !slices.Equal(form["tags"], []string{"3", "7"}) {
+			t.Errorf("tags = %v, want [3 7]", form["tags"])
+		}
+		if !slices.Equal(form["correspondent"], []string{"5"}) {
+			t.Errorf("correspondent = %v, want [5]", form["correspondent"])
+		}
+		if !slices.Equal(form["title"], []string{"recipes"}) {
+			t.Errorf("title = %v, want [recipes]", form["title"])
+		}
+		if !slices.Equal(form["document"], []string{"content"}) {
+			t.Errorf("document = %v, want [content]", form["document"])
+		}
+	}
+}
+
+func TestPaperlessRejectsOtherFormats(t *testing.T) {
+	paperless := &Paperless{URL: "http://localhost:1", Token: "secret"}
+	err := paperless.Put(context.Background(), "recipes.epub", nil)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Put() = %v, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestPaperlessReportsFailedUploads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	paperless := &Paperless{URL: server.URL, Token: "secret"}
+	if err := paperless.Put(context.Background(), "recipes.pdf", nil); err == nil {
+		t.Error("expected an error for a forbidden upload")
+	}
+}
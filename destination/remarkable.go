@@ -0,0 +1,195 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+// Endpoints of the reMarkable cloud, which tests replace. There is no official API. These are the
+// endpoints that reMarkable's own browser extension and web app use.
+var (
+	remarkableDeviceURL = "https://webapp-prod.cloud.remarkable.engineering/token/json/2/device/new"
+	remarkableTokenURL  = "https://webapp-prod.cloud.remarkable.engineering/token/json/2/user/new"
+	remarkableUploadURL = "https://internal.cloud.remarkable.com/doc/v2/files"
+)
+
+// User tokens are renewed after this time even if the cloud did not reject them yet.
+const remarkableTokenLifetime = time.Hour
+
+// The types of documents that the reMarkable cloud accepts by extension.
+var remarkableTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".epub": "application/epub+zip",
+}
+
+// ReMarkable uploads PDF and EPUB documents to the reMarkable cloud, from where they are synced to
+// the tablets of the account. DeviceToken is obtained once via RegisterReMarkable and exchanged
+// for short-lived user tokens whenever they expire. The cloud does not replace documents by name,
+// so every upload adds a new document.
+type ReMarkable struct {
+	DeviceToken string
+	tokens      tokenCache
+}
+
+// Name identifies the destination.
+func (r *ReMarkable) Name() string {
+	return "remarkable"
+}
+
+// RegisterReMarkable exchanges a one-time code, which is shown at
+// https://my.remarkable.com/device/browser/connect, for a device token of a new browser device.
+func RegisterReMarkable(ctx context.Context, code string) (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"code": strings.TrimSpace(code), "deviceDesc": "browser-chrome",
+		"deviceID": uuid.New().String(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to convert to json: %s", err.Error())
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, remarkableDeviceURL, bytes.NewReader(body),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token, err := remarkableToken(&http.Client{Timeout: tokenTimeout}, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to register device: %s", err.Error())
+	}
+	return token.AccessToken, nil
+}
+
+// Send a request whose reply is a token in plain text.
+func remarkableToken(client *http.Client, req *http.Request) (*oauth2.Token, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &oauth2.RetrieveError{Response: resp, Body: body}
+	}
+	token := strings.TrimSpace(string(body))
+	if token == "" {
+		return nil, fmt.Errorf("received an empty token")
+	}
+	return &oauth2.Token{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		Expiry:      time.Now().Add(remarkableTokenLifetime),
+	}, resp.Body.Close()
+}
+
+// Obtains user tokens with the device token.
+type remarkableTokenSource struct {
+	ctx         context.Context
+	deviceToken string
+}
+
+func (s remarkableTokenSource) Token() (*oauth2.Token, error) {
+	client := httpClient
+	if configured, ok := s.ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		client = configured
+	}
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, remarkableTokenURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	req.Header.Set("Authorization", "Bearer "+s.deviceToken)
+	return remarkableToken(client, req)
+}
+
+func (r *ReMarkable) tokenSource(ctx context.Context) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, remarkableTokenSource{ctx: ctx, deviceToken: r.DeviceToken})
+}
+
+// Put uploads content as a new document named like the file without its extension. Only PDF and
+// EPUB documents are accepted. Uploads that fail due to network errors, server-side errors, or
+// expired user tokens are retried.
+func (r *ReMarkable) Put(ctx context.Context, name string, content []byte) error {
+	mimeType, ok := remarkableTypes[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return fmt.Errorf(
+			"failed to upload %s to %s: %w, only pdf and epub are", name, r.Name(), ErrUnsupported,
+		)
+	}
+	return retryUpload(ctx, r.Name(), name, func() (bool, error) {
+		return r.put(ctx, name, mimeType, content)
+	})
+}
+
+// Perform a single upload. Also report whether it makes sense to retry it.
+func (r *ReMarkable) put(
+	ctx context.Context, name string, mimeType string, content []byte,
+) (bool, error) {
+	// The name is what the tablet shows, which is why it has no extension.
+	visibleName := strings.TrimSuffix(name, path.Ext(name))
+	meta, err := json.Marshal(map[string]string{"file_name": visibleName})
+	if err != nil {
+		return false, fmt.Errorf("failed to convert to json: %s", err.Error())
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, remarkableUploadURL, bytes.NewReader(content),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", mimeType)
+	req.Header.Set("rm-meta", base64.StdEncoding.EncodeToString(meta))
+	req.Header.Set("rm-source", "RoR-Browser")
+	if retry, err := r.tokens.authorize(req, r.tokenSource); err != nil {
+		return retry, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, resp.Body.Close()
+	case resp.StatusCode == http.StatusUnauthorized:
+		// The user token expired early. Try again with a new one.
+		r.tokens.reset()
+		return true, fmt.Errorf("user token rejected: %s", string(body))
+	default:
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+}
@@ -0,0 +1,100 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReMarkableRegistersAndUploads(t *testing.T) {
+	tokens, uploads := 0, []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/device":
+			var request map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			if request["code"] != "abcdefgh" || request["deviceID"] == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = fmt.Fprint(w, "device-token\n")
+		case "/token":
+			if r.Header.Get("Authorization") != "Bearer device-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			tokens++
+			_, _ = fmt.Fprintf(w, "user-token-%d", tokens)
+		case "/upload":
+			// The first user token has expired early.
+			if r.Header.Get("Authorization") != "Bearer user-token-2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			meta, _ := base64.StdEncoding.DecodeString(r.Header.Get("rm-meta"))
+			content, _ := io.ReadAll(r.Body)
+			uploads = append(uploads, fmt.Sprintf(
+				"%s %s %s", r.Header.Get("Content-Type"), string(meta), string(content),
+			))
+		}
+	}))
+	defer server.Close()
+	remarkableDeviceURL = server.URL + "/device"
+	remarkableTokenURL = server.URL + "/token"
+	remarkableUploadURL = server.URL + "/upload"
+
+	deviceToken, err := RegisterReMarkable(context.Background(), " abcdefgh\n")
+	if err != nil {
+		t.Fatalf("RegisterReMarkable() failed: %s", err.Error())
+	}
+	remarkable := &ReMarkable{DeviceToken: deviceToken}
+	if err := remarkable.Put(context.Background(), "recipes.pdf", []byte("pdf")); err != nil {
+		t.Fatalf("Put() failed: %s", err.Error())
+	}
+	if err := remarkable.Put(context.Background(), "recipes.epub", []byte("epub")); err != nil {
+		t.Fatalf("Put() failed: %s", err.Error())
+	}
+
+	want := []string{
+		`application/pdf {"file_name":"recipes"} pdf`,
+		`application/epub+zip {"file_name":"recipes"} epub`,
+	}
+	if strings.Join(uploads, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected uploads:\n%s", strings.Join(uploads, "\n"))
+	}
+	if tokens != 2 {
+		t.Errorf("expected the user token to be renewed once, got %d tokens", tokens)
+	}
+
+	err = remarkable.Put(context.Background(), "recipes.html", []byte("html"))
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected html documents to be unsupported, got %v", err)
+	}
+	if _, err := RegisterReMarkable(context.Background(), "wrong"); err == nil {
+		t.Error("expected an invalid code to be rejected")
+	}
+}
@@ -0,0 +1,151 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// S3 uploads documents to a bucket of an S3-compatible object storage such as MinIO, Backblaze B2,
+// or AWS S3. Requests use path-style URLs, i.e., <Endpoint>/<Bucket>/<Prefix><name>, and are
+// signed with AWS signature version 4.
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+}
+
+// Name identifies the destination.
+func (s *S3) Name() string {
+	return "s3"
+}
+
+// Put uploads content as an object. Uploads that fail due to network errors or server-side errors
+// are retried.
+func (s *S3) Put(ctx context.Context, name string, content []byte) error {
+	target := strings.TrimSuffix(s.Endpoint, "/") + "/" + uriEncode(s.Bucket, true) + "/" +
+		uriEncode(s.Prefix+name, false)
+
+	return retryUpload(ctx, s.Name(), name, func() (bool, error) {
+		return s.put(ctx, target, content)
+	})
+}
+
+// Perform a single upload. Also report whether it makes sense to retry it.
+func (s *S3) put(ctx context.Context, target string, content []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(content))
+	if err != nil {
+		return false, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	s.sign(req, content, time.Now())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+	return false, resp.Body.Close()
+}
+
+// Sign a request with AWS signature version 4. The host and all headers already set are signed.
+func (s *S3) sign(req *http.Request, payload []byte, now time.Time) {
+	payloadHash := sha256.Sum256(payload)
+	payloadHex := hex.EncodeToString(payloadHash[:])
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHex)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHex,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" +
+		hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{date, s.Region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, hex.EncodeToString(key),
+	))
+}
+
+// Encode a string as required by S3. Slashes are retained unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	result := strings.Builder{}
+	for _, char := range []byte(s) {
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9',
+			char == '-', char == '_', char == '.', char == '~':
+			result.WriteByte(char)
+		case char == '/' && !encodeSlash:
+			result.WriteByte(char)
+		default:
+			fmt.Fprintf(&result, "%%%02X", char)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,483 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Packet types and flags of version 3 of the SFTP protocol, which is what OpenSSH implements.
+const (
+	sftpVersion      = 3
+	sftpInit         = 1
+	sftpVersionReply = 2
+	sftpOpen         = 3
+	sftpClose        = 4
+	sftpWrite        = 6
+	sftpRemove       = 13
+	sftpMkdir        = 14
+	sftpRename       = 18
+	sftpStatus       = 101
+	sftpHandle       = 102
+	sftpExtended     = 200
+	sftpStatusOK     = 0
+	sftpOpenWrite    = 0x02
+	sftpOpenCreate   = 0x08
+	sftpOpenTruncate = 0x10
+	// An extension of OpenSSH that replaces existing files, which plain renames refuse to do.
+	sftpPosixRename = "posix-rename@openssh.com"
+)
+
+// Servers have to accept writes of this many bytes. Several writes are sent without waiting for
+// replies, since waiting would make uploads as slow as the round trip time permits.
+const (
+	sftpChunkSize      = 32 * 1024
+	sftpPendingWrites  = 64
+	sftpMaxPacketBytes = 256 * 1024
+)
+
+// SFTP uploads documents to the directory at Path on an SSH server via SFTP. Address is the host
+// name of the server, optionally followed by a port. Users log in with a password, with a private
+// key in PEM format that may be protected by a passphrase, or with both. The server has to present
+// one of the HostKeys, which are given one per line either in known_hosts format, e.g. the output
+// of ssh-keyscan, or as public keys.
+type SFTP struct {
+	Address    string
+	User       string
+	Password   string
+	PrivateKey string
+	Passphrase string
+	HostKeys   string
+	Path       string
+	// Creating the target directory is attempted only once.
+	mkdir sync.Once
+}
+
+// Name identifies the destination.
+func (s *SFTP) Name() string {
+	return "sftp"
+}
+
+// Validate checks that the private key and host keys can be parsed and that there is a way to log
+// in.
+func (s *SFTP) Validate() error {
+	_, err := s.clientConfig()
+	return err
+}
+
+func (s *SFTP) clientConfig() (*ssh.ClientConfig, error) {
+	if s.User == "" {
+		return nil, fmt.Errorf("no user to log in as")
+	}
+	auth := []ssh.AuthMethod{}
+	if s.PrivateKey != "" {
+		var signer ssh.Signer
+		var err error
+		if s.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(
+				[]byte(s.PrivateKey), []byte(s.Passphrase),
+			)
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(s.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %s", err.Error())
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if s.Password != "" {
+		auth = append(auth, ssh.Password(s.Password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("neither a password nor a private key to log in with")
+	}
+	hostKeys, err := parseHostKeys(s.HostKeys)
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User: s.User,
+		Auth: auth,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			for _, hostKey := range hostKeys {
+				if string(hostKey.Marshal()) == string(key.Marshal()) {
+					return nil
+				}
+			}
+			return fmt.Errorf(
+				"host key %s is none of the accepted ones", ssh.FingerprintSHA256(key),
+			)
+		},
+	}, nil
+}
+
+// Parse host keys given one per line in known_hosts format or as public keys. Host names in
+// known_hosts lines are ignored, since the server to connect to is known.
+func parseHostKeys(hostKeys string) ([]ssh.PublicKey, error) {
+	keys := []ssh.PublicKey{}
+	for line := range strings.SplitSeq(hostKeys, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			var knownErr error
+			_, _, key, _, _, knownErr = ssh.ParseKnownHosts([]byte(line))
+			if knownErr != nil {
+				return nil, fmt.Errorf("failed to parse host key %s: %s", line, err.Error())
+			}
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no host keys to verify the server with")
+	}
+	return keys, nil
+}
+
+// Put uploads content to the target directory. It is written to a temporary file first so that
+// nobody, e.g. a sync client, sees partially written documents. Uploads that fail due to network
+// errors are retried.
+func (s *SFTP) Put(ctx context.Context, name string, content []byte) error {
+	return retryUpload(ctx, s.Name(), name, func() (bool, error) {
+		return s.put(ctx, name, content)
+	})
+}
+
+// Perform a single upload. Also report whether it makes sense to retry it.
+func (s *SFTP) put(ctx context.Context, name string, content []byte) (bool, error) {
+	config, err := s.clientConfig()
+	if err != nil {
+		return false, err
+	}
+	address := s.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "22")
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return true, fmt.Errorf("failed to connect to %s: %s", address, err.Error())
+	}
+	// Closing the connection aborts whatever is in progress once the context is done.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer func() { _ = conn.Close() }()
+
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		// Wrong credentials or host keys do not fix themselves.
+		var netErr net.Error
+		return errors.As(err, &netErr), fmt.Errorf("failed to log in: %s", err.Error())
+	}
+	client := ssh.NewClient(sshConn, channels, requests)
+	defer func() { _ = client.Close() }()
+
+	session, err := newSFTPSession(client)
+	if err != nil {
+		return true, err
+	}
+	defer session.close()
+
+	dir := strings.TrimSuffix(s.Path, "/")
+	if dir != "" {
+		s.mkdir.Do(func() {
+			// Failing to create the directory most likely means that it exists already.
+			// The directory gets no attributes, which is an empty set of flags.
+			mkdir := sftpPathRequest{Path: dir, Attrs: make([]byte, 4)} //nolint:mnd
+			if err := session.call(sftpMkdir, mkdir); err != nil {
+				slog.DebugContext(ctx, "failed to create sftp directory", "path", dir, "error", err)
+			}
+		})
+	}
+	// Errors reported by the server, e.g. due to missing permissions, do not fix themselves.
+	target := path.Join(dir, name)
+	if err := session.upload(target+".tmp", content); err != nil {
+		return session.broken, err
+	}
+	if err := session.rename(target+".tmp", target); err != nil {
+		return session.broken, err
+	}
+	return false, nil
+}
+
+// A connection to the SFTP subsystem of an SSH server.
+type sftpSession struct {
+	session    *ssh.Session
+	in         io.WriteCloser
+	out        *bufio.Reader
+	lastID     uint32
+	extensions map[string]string
+	// Whether sending or receiving failed, e.g. because the connection was lost.
+	broken bool
+}
+
+// Requests that only name a path, e.g. to create a directory. MKDIR has attributes, REMOVE not.
+type sftpPathRequest struct {
+	Path  string
+	Attrs []byte `ssh:"rest"`
+}
+
+type sftpOpenRequest struct {
+	Path  string
+	Flags uint32
+	Attrs uint32
+}
+
+type sftpHandleRequest struct {
+	Handle string
+}
+
+type sftpWriteRequest struct {
+	Handle string
+	Offset uint64
+	Data   []byte
+}
+
+type sftpRenameRequest struct {
+	Old string
+	New string
+}
+
+type sftpExtendedRequest struct {
+	Name string
+	Rest []byte `ssh:"rest"`
+}
+
+type sftpStatusReply struct {
+	ID      uint32
+	Code    uint32
+	Message string
+	Rest    []byte `ssh:"rest"`
+}
+
+type sftpHandleReply struct {
+	ID     uint32
+	Handle string
+}
+
+type sftpExtension struct {
+	Name string
+	Data string
+	Rest []byte `ssh:"rest"`
+}
+
+func newSFTPSession(client *ssh.Client) (*sftpSession, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ssh session: %s", err.Error())
+	}
+	in, err := session.StdinPipe()
+	if err != nil {
+		_ = session.Close()
+		return nil, fmt.Errorf("failed to connect to stdin of sftp: %s", err.Error())
+	}
+	out, err := session.StdoutPipe()
+	if err != nil {
+		_ = session.Close()
+		return nil, fmt.Errorf("failed to connect to stdout of sftp: %s", err.Error())
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		_ = session.Close()
+		return nil, fmt.Errorf("failed to start sftp: %s", err.Error())
+	}
+	s := &sftpSession{
+		session: session, in: in, out: bufio.NewReader(out), extensions: map[string]string{},
+	}
+
+	if err := s.send(sftpInit, binary.BigEndian.AppendUint32(nil, sftpVersion)); err != nil {
+		s.close()
+		return nil, err
+	}
+	kind, payload, err := s.receive()
+	if err == nil && (kind != sftpVersionReply || len(payload) < 4) {
+		err = fmt.Errorf("unexpected reply of type %d to sftp init", kind)
+	}
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	// Servers announce the extensions that they support after their version.
+	rest := payload[4:]
+	for len(rest) > 0 {
+		var extension sftpExtension
+		if err := ssh.Unmarshal(rest, &extension); err != nil {
+			break
+		}
+		s.extensions[extension.Name] = extension.Data
+		rest = extension.Rest
+	}
+	return s, nil
+}
+
+func (s *sftpSession) close() {
+	_ = s.in.Close()
+	_ = s.session.Close()
+}
+
+// Send a packet, which consists of its length, its type, and the payload.
+func (s *sftpSession) send(kind byte, payload []byte) error {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1)) // #nosec:G115
+	packet = append(packet, kind)
+	packet = append(packet, payload...)
+	if _, err := s.in.Write(packet); err != nil {
+		s.broken = true
+		return fmt.Errorf("failed to send sftp packet: %s", err.Error())
+	}
+	return nil
+}
+
+func (s *sftpSession) receive() (byte, []byte, error) {
+	header := make([]byte, 4) //nolint:mnd
+	if _, err := io.ReadFull(s.out, header); err != nil {
+		s.broken = true
+		return 0, nil, fmt.Errorf("failed to receive sftp packet: %s", err.Error())
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length == 0 || length > sftpMaxPacketBytes {
+		return 0, nil, fmt.Errorf("sftp packet has invalid length %d", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(s.out, packet); err != nil {
+		s.broken = true
+		return 0, nil, fmt.Errorf("failed to receive sftp packet: %s", err.Error())
+	}
+	return packet[0], packet[1:], nil
+}
+
+// Send a request, which starts with an ID that the reply refers to, and return that ID.
+func (s *sftpSession) request(kind byte, request any) (uint32, error) {
+	s.lastID++
+	payload := binary.BigEndian.AppendUint32(nil, s.lastID)
+	return s.lastID, s.send(kind, append(payload, ssh.Marshal(request)...))
+}
+
+// Wait for the status that a request was answered with and turn failures into errors.
+func (s *sftpSession) status() error {
+	kind, payload, err := s.receive()
+	if err != nil {
+		return err
+	}
+	if kind != sftpStatus {
+		return fmt.Errorf("unexpected reply of type %d instead of a status", kind)
+	}
+	var status sftpStatusReply
+	if err := ssh.Unmarshal(payload, &status); err != nil {
+		return fmt.Errorf("failed to parse sftp status: %s", err.Error())
+	}
+	if status.Code != sftpStatusOK {
+		return fmt.Errorf("sftp error %d: %s", status.Code, status.Message)
+	}
+	return nil
+}
+
+// Send a request that is answered with a status.
+func (s *sftpSession) call(kind byte, request any) error {
+	if _, err := s.request(kind, request); err != nil {
+		return err
+	}
+	return s.status()
+}
+
+// Write content to the file at target, replacing what was there before.
+func (s *sftpSession) upload(target string, content []byte) error {
+	_, err := s.request(sftpOpen, sftpOpenRequest{
+		Path: target, Flags: sftpOpenWrite | sftpOpenCreate | sftpOpenTruncate,
+	})
+	if err != nil {
+		return err
+	}
+	kind, payload, err := s.receive()
+	if err != nil {
+		return err
+	}
+	var handle sftpHandleReply
+	if kind == sftpStatus {
+		// Opening the file failed, e.g. due to missing permissions.
+		var status sftpStatusReply
+		if err := ssh.Unmarshal(payload, &status); err == nil {
+			return fmt.Errorf("failed to open %s: %s", target, status.Message)
+		}
+	}
+	if kind != sftpHandle || ssh.Unmarshal(payload, &handle) != nil {
+		return fmt.Errorf("failed to open %s: unexpected reply of type %d", target, kind)
+	}
+
+	pending := 0
+	var writeErr error
+	for offset := 0; offset < len(content) || pending > 0; {
+		if offset < len(content) && pending < sftpPendingWrites && writeErr == nil {
+			chunk := content[offset:min(offset+sftpChunkSize, len(content))]
+			_, err := s.request(sftpWrite, sftpWriteRequest{
+				Handle: handle.Handle, Offset: uint64(offset), Data: chunk, // #nosec:G115
+			})
+			if err != nil {
+				return err
+			}
+			offset += len(chunk)
+			pending++
+			continue
+		}
+		if pending == 0 {
+			break
+		}
+		// Replies to all writes have to be awaited, even after one failed.
+		if err := s.status(); err != nil && writeErr == nil {
+			writeErr = err
+		}
+		pending--
+	}
+	closeErr := s.call(sftpClose, sftpHandleRequest{Handle: handle.Handle})
+	if writeErr != nil {
+		return fmt.Errorf("failed to write %s: %s", target, writeErr.Error())
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close %s: %s", target, closeErr.Error())
+	}
+	return nil
+}
+
+// Move the file at source to target, replacing any file there.
+func (s *sftpSession) rename(source string, target string) error {
+	var err error
+	if _, ok := s.extensions[sftpPosixRename]; ok {
+		err = s.call(sftpExtended, sftpExtendedRequest{
+			Name: sftpPosixRename, Rest: ssh.Marshal(sftpRenameRequest{Old: source, New: target}),
+		})
+	} else {
+		// Plain renames fail if the target exists. Removing it fails if it does not exist.
+		_ = s.call(sftpRemove, sftpPathRequest{Path: target})
+		err = s.call(sftpRename, sftpRenameRequest{Old: source, New: target})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move %s into place: %s", target, err.Error())
+	}
+	return nil
+}
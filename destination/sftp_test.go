@@ -0,0 +1,251 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// An SSH server whose SFTP subsystem keeps files in memory and understands just enough of the
+// protocol for uploads.
+type fakeSFTPServer struct {
+	lock     sync.Mutex
+	files    map[string]string
+	requests []string
+	address  string
+	hostKey  ssh.PublicKey
+}
+
+func newFakeSFTPServer(t *testing.T) *fakeSFTPServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() != "user" || string(password) != "secret" {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	server := &fakeSFTPServer{
+		files: map[string]string{}, address: listener.Addr().String(), hostKey: signer.PublicKey(),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config)
+		}
+	}()
+	return server
+}
+
+func (f *fakeSFTPServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for request := range channelRequests {
+				_ = request.Reply(request.Type == "subsystem", nil)
+			}
+		}()
+		go f.handle(channel)
+	}
+}
+
+func (f *fakeSFTPServer) handle(channel ssh.Channel) {
+	defer func() { _ = channel.Close() }()
+	open := map[string][]byte{}
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(channel, header); err != nil {
+			return
+		}
+		packet := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(channel, packet); err != nil {
+			return
+		}
+		kind, payload := packet[0], packet[1:]
+		if kind == sftpInit {
+			reply := binary.BigEndian.AppendUint32(nil, sftpVersion)
+			reply = append(reply, ssh.Marshal(sftpExtension{Name: sftpPosixRename, Data: "1"})...)
+			writeFakeSFTPPacket(channel, sftpVersionReply, reply)
+			continue
+		}
+		id, payload := payload[:4], payload[4:]
+		f.lock.Lock()
+		switch kind {
+		case sftpOpen:
+			var request sftpOpenRequest
+			_ = ssh.Unmarshal(payload, &request)
+			f.requests = append(f.requests, "open "+request.Path)
+			open[request.Path] = []byte{}
+			writeFakeSFTPPacket(
+				channel, sftpHandle, append(id, ssh.Marshal(sftpHandleRequest{request.Path})...),
+			)
+			f.lock.Unlock()
+			continue
+		case sftpWrite:
+			var request sftpWriteRequest
+			_ = ssh.Unmarshal(payload, &request)
+			content := open[request.Handle]
+			end := int(request.Offset) + len(request.Data)
+			content = append(content, make([]byte, max(0, end-len(content)))...)
+			copy(content[request.Offset:], request.Data)
+			open[request.Handle] = content
+		case sftpClose:
+			var request sftpHandleRequest
+			_ = ssh.Unmarshal(payload, &request)
+			f.requests = append(f.requests, "close "+request.Handle)
+			f.files[request.Handle] = string(open[request.Handle])
+		case sftpMkdir:
+			var request sftpPathRequest
+			_ = ssh.Unmarshal(payload, &request)
+			f.requests = append(f.requests, "mkdir "+request.Path)
+		case sftpExtended:
+			var request sftpExtendedRequest
+			_ = ssh.Unmarshal(payload, &request)
+			var rename sftpRenameRequest
+			_ = ssh.Unmarshal(request.Rest, &rename)
+			f.requests = append(f.requests, "rename "+rename.Old+" "+rename.New)
+			f.files[rename.New] = f.files[rename.Old]
+			delete(f.files, rename.Old)
+		}
+		f.lock.Unlock()
+		status := ssh.Marshal(struct {
+			Code     uint32
+			Message  string
+			Language string
+		}{sftpStatusOK, "", ""})
+		writeFakeSFTPPacket(channel, sftpStatus, append(id, status...))
+	}
+}
+
+func writeFakeSFTPPacket(channel ssh.Channel, kind byte, payload []byte) {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	_, _ = channel.Write(append(append(packet, kind), payload...))
+}
+
+func TestSFTPUploadsViaTemporaryFile(t *testing.T) {
+	server := newFakeSFTPServer(t)
+	sftp := &SFTP{
+		Address:  server.address,
+		User:     "user",
+		Password: "secret",
+		// The host name is ignored, only the key matters.
+		HostKeys: "# comment\nexample.com " + string(ssh.MarshalAuthorizedKey(server.hostKey)),
+		Path:     "recipes/",
+	}
+	if err := sftp.Validate(); err != nil {
+		t.Fatalf("Validate() failed: %s", err.Error())
+	}
+	// More than one chunk is written.
+	content := strings.Repeat("recipe ", sftpChunkSize)
+	for range 2 {
+		if err := sftp.Put(context.Background(), "recipes.pdf", []byte(content)); err != nil {
+			t.Fatalf("Put() failed: %s", err.Error())
+		}
+	}
+
+	want := []string{
+		"mkdir recipes",
+		"open recipes/recipes.pdf.tmp",
+		"close recipes/recipes.pdf.tmp",
+		"rename recipes/recipes.pdf.tmp recipes/recipes.pdf",
+		"open recipes/recipes.pdf.tmp",
+		"close recipes/recipes.pdf.tmp",
+		"rename recipes/recipes.pdf.tmp recipes/recipes.pdf",
+	}
+	if strings.Join(server.requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected requests:\n%s", strings.Join(server.requests, "\n"))
+	}
+	if len(server.files) != 1 || server.files["recipes/recipes.pdf"] != content {
+		t.Errorf("unexpected files: %d", len(server.files))
+	}
+}
+
+func TestSFTPRejectsUnknownHostKey(t *testing.T) {
+	server := newFakeSFTPServer(t)
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ssh.NewPublicKey(other.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sftp := &SFTP{
+		Address:  server.address,
+		User:     "user",
+		Password: "secret",
+		HostKeys: string(ssh.MarshalAuthorizedKey(otherKey)),
+	}
+	err = sftp.Put(context.Background(), "recipes.pdf", []byte("content"))
+	if err == nil || !strings.Contains(err.Error(), "none of the accepted") {
+		t.Errorf("expected the host key to be rejected, got %v", err)
+	}
+	if len(server.requests) != 0 {
+		t.Errorf("unexpected requests: %v", server.requests)
+	}
+}
+
+func TestSFTPValidateRequiresCredentialsAndHostKeys(t *testing.T) {
+	for _, sftp := range []*SFTP{
+		{Address: "example.com", HostKeys: "ssh-ed25519 invalid"},
+		{Address: "example.com", User: "user", HostKeys: "ssh-ed25519 invalid"},
+		{Address: "example.com", User: "user", Password: "secret"},
+		{Address: "example.com", User: "user", Password: "secret", HostKeys: "garbage"},
+		{Address: "example.com", User: "user", PrivateKey: "garbage", HostKeys: "garbage"},
+	} {
+		if err := sftp.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", sftp)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// The base URL of the Slack Web API, which tests replace.
+var slackAPIURL = "https://slack.com/api/"
+
+// Slack announces documents in a Slack channel via a bot. Token is the bot token of a Slack app
+// with the scopes chat:write and files:write, which has to be a member of the channel with the ID
+// Channel. Every upload posts a new message.
+type Slack struct {
+	Token        string
+	Channel      string
+	Announcement Announcement
+}
+
+// Name identifies the destination.
+func (s *Slack) Name() string {
+	return "slack"
+}
+
+// Call a method of the Web API and decode its reply into result. Also report whether it makes
+// sense to retry after a failure. Slack reports most failures with status 200.
+func (s *Slack) call(
+	ctx context.Context, method string, contentType string, body []byte, result any,
+) (bool, error) {
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, slackAPIURL+method, bytes.NewReader(body),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+	req.Header.Set("Content-Type", contentType)
+	content, retry, err := sendChat(req)
+	if err != nil {
+		return retry, fmt.Errorf("failed to call %s: %s", method, err.Error())
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(content, &status); err != nil {
+		return false, fmt.Errorf("failed to parse reply to %s: %s", method, err.Error())
+	}
+	if !status.OK {
+		retry := status.Error == "ratelimited"
+		return retry, fmt.Errorf("failed to call %s: %s", method, status.Error)
+	}
+	if result == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(content, result); err != nil {
+		return false, fmt.Errorf("failed to parse reply to %s: %s", method, err.Error())
+	}
+	return false, nil
+}
+
+// Call a method of the Web API with a JSON payload.
+func (s *Slack) callJSON(
+	ctx context.Context, method string, payload any, result any,
+) (bool, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("failed to convert to json: %s", err.Error())
+	}
+	return s.call(ctx, method, "application/json; charset=utf-8", body, result)
+}
+
+// Put posts a message announcing the document. Posts that fail due to network or server-side
+// errors or rate limits are retried.
+func (s *Slack) Put(ctx context.Context, name string, content []byte) error {
+	return retryUpload(ctx, s.Name(), name, func() (bool, error) {
+		return s.put(ctx, name, content)
+	})
+}
+
+// Perform a single post. Also report whether it makes sense to retry it. Attaching a file takes
+// three steps: obtaining an upload URL, uploading the file there, and sharing it in the channel.
+func (s *Slack) put(ctx context.Context, name string, content []byte) (bool, error) {
+	text := s.Announcement.text(name, content)
+	if !s.Announcement.attach(content) {
+		payload := map[string]string{"channel": s.Channel, "text": text}
+		return s.callJSON(ctx, "chat.postMessage", payload, nil)
+	}
+
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	form := url.Values{"filename": {name}, "length": {strconv.Itoa(len(content))}}
+	retry, err := s.call(
+		ctx, "files.getUploadURLExternal", "application/x-www-form-urlencoded",
+		[]byte(form.Encode()), &upload,
+	)
+	if err != nil {
+		return retry, err
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, upload.UploadURL, bytes.NewReader(content),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", attachmentType(name))
+	if _, retry, err := sendChat(req); err != nil {
+		return retry, fmt.Errorf("failed to upload file: %s", err.Error())
+	}
+
+	payload := map[string]any{
+		"files":           []map[string]string{{"id": upload.FileID, "title": name}},
+		"channel_id":      s.Channel,
+		"initial_comment": text,
+	}
+	return s.callJSON(ctx, "files.completeUploadExternal", payload, nil)
+}
@@ -0,0 +1,101 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSlackUploadsAndSharesFiles(t *testing.T) {
+	requests := []string{}
+	uploaded := ""
+	completed := map[string]any{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path != "/upload" && r.Header.Get("Authorization") != "Bearer xoxb-secret" {
+			_, _ = io.WriteString(w, `{"ok":false,"error":"invalid_auth"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/files.getUploadURLExternal":
+			if r.FormValue("filename") != "recipes.pdf" || r.FormValue("length") != "7" {
+				_, _ = io.WriteString(w, `{"ok":false,"error":"invalid_arguments"}`)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"ok":true,"upload_url":"%s/upload","file_id":"F1"}`, server.URL)
+		case "/upload":
+			content, _ := io.ReadAll(r.Body)
+			uploaded = string(content)
+		case "/api/files.completeUploadExternal":
+			_ = json.NewDecoder(r.Body).Decode(&completed)
+			_, _ = io.WriteString(w, `{"ok":true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	slackAPIURL = server.URL + "/api/"
+
+	slack := &Slack{
+		Token: "xoxb-secret", Channel: "C1",
+		Announcement: Announcement{Message: "New cookbook edition:", MaxAttachment: 100},
+	}
+	if err := slack.Put(context.Background(), "recipes.pdf", []byte("content")); err != nil {
+		t.Fatalf("Put() failed: %s", err.Error())
+	}
+
+	want := []string{
+		"/api/files.getUploadURLExternal", "/upload", "/api/files.completeUploadExternal",
+	}
+	if !slices.Equal(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+	if uploaded != "content" {
+		t.Errorf("uploaded %q, want %q", uploaded, "content")
+	}
+	if completed["channel_id"] != "C1" ||
+		completed["initial_comment"] != "New cookbook edition: recipes.pdf" {
+		t.Errorf("completed upload with %v", completed)
+	}
+}
+
+func TestSlackReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `{"ok":false,"error":"channel_not_found"}`)
+	}))
+	defer server.Close()
+	slackAPIURL = server.URL + "/api/"
+
+	slack := &Slack{Token: "xoxb-secret", Channel: "C1"}
+	err := slack.Put(context.Background(), "recipes.pdf", []byte("content"))
+	if err == nil {
+		t.Fatal("expected an error for a missing channel")
+	}
+	if want := "channel_not_found"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not mention %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,106 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// WebDAV uploads documents to a WebDAV server such as Nextcloud. Documents are put into the
+// directory at Path below URL, which is created if it does not exist. For Nextcloud, the URL is
+// https://<host>/remote.php/dav/files/<user>.
+type WebDAV struct {
+	URL      string
+	User     string
+	Password string
+	Path     string
+	// Creating the target directory is attempted only once.
+	mkdir sync.Once
+}
+
+// Name identifies the destination.
+func (w *WebDAV) Name() string {
+	return "webdav"
+}
+
+// The URL of the directory that documents are put into, or of a document in it if name is given.
+func (w *WebDAV) target(name string) string {
+	target := strings.TrimSuffix(w.URL, "/")
+	for part := range strings.SplitSeq(strings.Trim(w.Path, "/")+"/"+name, "/") {
+		if part != "" {
+			target += "/" + url.PathEscape(part)
+		}
+	}
+	return target
+}
+
+// Send a request and return the status code and body of the response.
+func (w *WebDAV) do(
+	ctx context.Context, method string, target string, body []byte,
+) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to construct request: %s", err.Error())
+	}
+	if w.User != "" || w.Password != "" {
+		req.SetBasicAuth(w.User, w.Password)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	return resp.StatusCode, string(content), resp.Body.Close()
+}
+
+// Put uploads content to the target directory.
+func (w *WebDAV) Put(ctx context.Context, name string, content []byte) error {
+	if strings.Trim(w.Path, "/") != "" {
+		w.mkdir.Do(func() {
+			// Status 405 means that the directory exists already.
+			status, body, err := w.do(ctx, "MKCOL", w.target(""), nil)
+			if err == nil && status != http.StatusCreated && status != http.StatusMethodNotAllowed {
+				err = fmt.Errorf("unexpected status code %d: %s", status, body)
+			}
+			if err != nil {
+				slog.WarnContext(
+					ctx, "failed to create webdav directory", "path", w.Path, "error", err,
+				)
+			}
+		})
+	}
+	status, body, err := w.do(ctx, http.MethodPut, w.target(name), content)
+	if err == nil && (status < 200 || status >= 300) {
+		err = fmt.Errorf("unexpected status code %d: %s", status, body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload %s via webdav: %s", name, err.Error())
+	}
+	return nil
+}
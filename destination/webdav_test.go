@@ -0,0 +1,85 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package destination
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebDAVCreatesDirectoryAndUploads(t *testing.T) {
+	requests := []string{}
+	uploaded := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		if user, password, _ := r.BasicAuth(); user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case "MKCOL":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case http.MethodPut:
+			content, _ := io.ReadAll(r.Body)
+			uploaded = string(content)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	webdav := &WebDAV{
+		URL: server.URL + "/dav/", User: "user", Password: "secret", Path: "/My Recipes/",
+	}
+	for range 2 {
+		if err := webdav.Put(context.Background(), "recipes.pdf", []byte("content")); err != nil {
+			t.Fatalf("Put() failed: %s", err.Error())
+		}
+	}
+
+	want := []string{
+		"MKCOL /dav/My%20Recipes",
+		"PUT /dav/My%20Recipes/recipes.pdf",
+		"PUT /dav/My%20Recipes/recipes.pdf",
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("requests = %v, want %v", requests, want)
+	}
+	for idx := range want {
+		if requests[idx] != want[idx] {
+			t.Errorf("request %d = %s, want %s", idx, requests[idx], want[idx])
+		}
+	}
+	if uploaded != "content" {
+		t.Errorf("uploaded %q, want %q", uploaded, "content")
+	}
+}
+
+func TestWebDAVReportsFailedUploads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	webdav := &WebDAV{URL: server.URL}
+	if err := webdav.Put(context.Background(), "recipes.pdf", nil); err == nil {
+		t.Error("expected an error for a forbidden upload")
+	}
+}
@@ -19,15 +19,163 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/http"
 	"net/url"
+	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/migrate"
 )
 
 type fixes struct {
-	imageReupload bool
+	imageReupload         bool
+	thumbnailRegeneration bool
+	ingredientParsing     bool
+	// Where to report the result of fixes to, a file or an http(s) URL. Empty to not report it.
+	resultTarget string
+	// Whether to exit after running fixes instead of serving requests.
+	oneShot bool
+	// How many recipes to fix at the same time.
+	parallel int
+	// Where to keep track of recipes that have already been fixed. Empty to not keep track.
+	stateFile string
+}
+
+func (f fixes) requested() bool {
+	return f.imageReupload || f.thumbnailRegeneration || f.ingredientParsing
+}
+
+// Exit codes in one-shot mode. Any other error, e.g. a bad config, exits with code 1.
+const (
+	exitFixed        = 0
+	exitFixFailed    = 2
+	exitNothingToFix = 3
+)
+
+// Possible statuses of fixes.
+const (
+	fixStatusFixed        = "fixed"
+	fixStatusNothingToFix = "nothing-to-fix"
+	fixStatusFailed       = "failed"
+)
+
+// Timeout for reporting the result of fixes to a URL.
+const fixReportTimeout = 30 * time.Second
+
+type fixResult struct {
+	Fix    string `json:"fix"`
+	Status string `json:"status"`
+	Fixed  int    `json:"fixed"`
+	Error  string `json:"error,omitempty"`
+}
+
+type fixReport struct {
+	Status  string      `json:"status"`
+	Started time.Time   `json:"started"`
+	Seconds float64     `json:"seconds"`
+	Results []fixResult `json:"results"`
+}
+
+func newFixResult(fix string, fixed int, err error) fixResult {
+	result := fixResult{Fix: fix, Status: fixStatusNothingToFix, Fixed: fixed}
+	switch {
+	case err != nil:
+		result.Status = fixStatusFailed
+		result.Error = err.Error()
+	case fixed > 0:
+		result.Status = fixStatusFixed
+	}
+	return result
+}
+
+// Run all requested fixes. The overall status is failed if any fix failed and fixed if any fix
+// fixed anything.
+func runFixes(mealie *mealieclient.Client, f fixes) fixReport {
+	report := fixReport{Status: fixStatusNothingToFix, Started: time.Now()}
+	requested := []recipeFix{}
+	if f.imageReupload {
+		requested = append(requested, imageReuploadFix(mealie))
+	}
+	// Reuploaded images come with thumbnails, so this runs afterwards.
+	if f.thumbnailRegeneration {
+		requested = append(requested, thumbnailRegenerationFix(mealie))
+	}
+	if f.ingredientParsing {
+		requested = append(requested, ingredientParsingFix(mealie))
+	}
+	for _, fix := range requested {
+		fixed, err := fixRecipes(mealie, fix, f.parallel, f.stateFile)
+		report.Results = append(report.Results, newFixResult(fix.name, fixed, err))
+	}
+	for _, result := range report.Results {
+		switch {
+		case result.Status == fixStatusFailed:
+			report.Status = fixStatusFailed
+		case result.Status == fixStatusFixed && report.Status != fixStatusFailed:
+			report.Status = fixStatusFixed
+		}
+	}
+	report.Seconds = time.Since(report.Started).Seconds()
+	return report
+}
+
+func (r fixReport) exitCode() int {
+	switch r.Status {
+	case fixStatusFixed:
+		return exitFixed
+	case fixStatusNothingToFix:
+		return exitNothingToFix
+	default:
+		return exitFixFailed
+	}
+}
+
+// Report the result of fixes as JSON to target. A target starting with http:// or https:// is
+// sent the report via a POST request. Any other target is a file that is overwritten.
+func (r fixReport) send(target string) error {
+	content, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fix report: %s", err.Error())
+	}
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		if err := os.WriteFile(target, content, 0o600); err != nil { //nolint:mnd
+			return fmt.Errorf("failed to write fix report: %s", err.Error())
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fixReportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("failed to build fix report request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send fix report: %s", err.Error())
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read fix report response: %s", err.Error())
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+	return resp.Body.Close()
 }
 
 func fixesFromString(s string) (fixes, error) {
@@ -36,6 +184,10 @@ func fixesFromString(s string) (fixes, error) {
 		switch fix {
 		case "image-reupload":
 			fixes.imageReupload = true
+		case "thumbnail-regeneration":
+			fixes.thumbnailRegeneration = true
+		case "ingredient-parsing":
+			fixes.ingredientParsing = true
 		default:
 			return fixes, fmt.Errorf("unknown fix %s", fix)
 		}
@@ -43,29 +195,191 @@ func fixesFromString(s string) (fixes, error) {
 	return fixes, nil
 }
 
-func reuploadImages(mealie *mealie) error {
-	log.Printf("reuploading images")
+// Recipes are fixed this many at the same time unless MA_RETRIEVAL_LIMIT is set.
+const defaultFixParallelism = 4
+
+// Time that fixing a single recipe may take at most.
+const fixTimeout = 2 * time.Minute
+
+// Progress of all fixes, available via the debug endpoints.
+var fixProgress = expvar.NewMap("fixes")
+
+// A fix that is applied to every recipe matching a queryFilter, or to all recipes if it is empty.
+// It reports whether the recipe was fixed.
+type recipeFix struct {
+	name        string
+	queryFilter string
+	fix         func(ctx context.Context, slug string) (bool, error)
+}
+
+// Reupload images of recipes that mealie lost track of.
+func imageReuploadFix(mealie *mealieclient.Client) recipeFix {
+	return recipeFix{
+		name:        "image-reupload",
+		queryFilter: "image IS NULL",
+		fix:         mealie.ReuploadImage,
+	}
+}
+
+// Regenerate missing thumbnails of recipe images.
+func thumbnailRegenerationFix(mealie *mealieclient.Client) recipeFix {
+	return recipeFix{
+		name:        "thumbnail-regeneration",
+		queryFilter: "image IS NOT NULL",
+		fix:         mealie.RegenerateThumbnails,
+	}
+}
+
+// Store structured quantities, units, and foods for recipes whose ingredients are plain text.
+func ingredientParsingFix(mealie *mealieclient.Client) recipeFix {
+	return recipeFix{name: "ingredient-parsing", fix: mealie.ParseIngredients}
+}
+
+// Apply a fix to all matching recipes, processing several recipes in parallel. Recipes listed for
+// this fix in the state file are skipped, and recipes processed successfully are added to it,
+// which lets an interrupted run resume. An empty state file disables that. Return the number of
+// recipes that were fixed.
+func fixRecipes(
+	mealie *mealieclient.Client, fix recipeFix, parallel int, stateFile string,
+) (int, error) {
+	slog.Info("running fix", "fix", fix.name)
 
 	ctx := context.Background()
-	counter := 0
+	if parallel <= 0 {
+		parallel = defaultFixParallelism
+	}
+
+	state, err := openFixState(stateFile, fix.name)
+	if err != nil {
+		return 0, err
+	}
+	defer state.close()
 
 	query := url.Values{}
-	query.Add("queryFilter", "image IS NULL")
-	slugs, err := mealie.getSlugs(ctx, &query)
+	if fix.queryFilter != "" {
+		query.Add("queryFilter", fix.queryFilter)
+	}
+	// The fixes take exclusive access only while modifying a recipe, which is why exports and
+	// assignments keep running in between. Recipes are not modified while they are listed.
+	release, err := mealie.Shared(ctx)
+	if err != nil {
+		return 0, err
+	}
+	slugs, err := mealie.GetSlugs(ctx, &query)
+	release()
 	if err != nil {
-		return fmt.Errorf("failed to retrieve slugs for image-reupload: %s", err.Error())
+		return 0, fmt.Errorf("failed to retrieve slugs for %s: %s", fix.name, err.Error())
 	}
+	slugs = slices.DeleteFunc(slugs, func(slug mealieclient.Slug) bool {
+		return state.processed[slug.Slug]
+	})
+	slog.InfoContext(
+		ctx, "checking recipes", "fix", fix.name, "recipes", len(slugs), "parallel", parallel,
+	)
 
-	for _, slug := range slugs {
-		reuploaded, err := mealie.reuploadImage(ctx, slug.Slug)
-		if err != nil {
-			return fmt.Errorf("failed to reupload image for %s: %s", slug.Slug, err.Error())
-		}
-		if reuploaded {
-			counter++
+	progress := new(expvar.Map).Init()
+	fixProgress.Set(fix.name, progress)
+	progress.Add("total", int64(len(slugs)))
+	var processed, counter atomic.Int64
+	errs := make([]error, len(slugs))
+	wg := sync.WaitGroup{}
+	limiter := make(chan bool, parallel)
+	for idx, slug := range slugs {
+		wg.Add(1)
+		limiter <- true
+		go func() {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			recipeCtx, cancel := context.WithTimeout(ctx, fixTimeout)
+			defer cancel()
+
+			fixed, err := fix.fix(recipeCtx, slug.Slug)
+			if err != nil {
+				errs[idx] = fmt.Errorf("failed to fix %s: %s", slug.Slug, err.Error())
+				progress.Add("failed", 1)
+			} else {
+				state.add(slug.Slug)
+			}
+			if fixed {
+				counter.Add(1)
+				progress.Add("fixed", 1)
+			}
+			progress.Add("processed", 1)
+			slog.InfoContext(ctx,
+				"fix progress",
+				"fix", fix.name, "processed", processed.Add(1), "recipes", len(slugs),
+			)
+		}()
+	}
+	wg.Wait()
+
+	slog.InfoContext(ctx, "fix finished", "fix", fix.name, "fixed", counter.Load())
+	return int(counter.Load()), errors.Join(errs...)
+}
+
+// The recipes that previous runs of a fix have already processed.
+type fixState struct {
+	fix       string
+	processed map[string]bool
+	file      *os.File
+	lock      sync.Mutex
+}
+
+// The layout of fix state files. Increment it and add a migration to fixStateStore whenever the
+// layout changes.
+const fixStateVersion = 1
+
+// Describe a fix state file as persisted state so that it can be migrated.
+func fixStateStore(path string) migrate.Store {
+	return migrate.Store{Name: "fix state", Path: path, Version: fixStateVersion}
+}
+
+// Read the names of fixes and the slugs of recipes they processed, one pair per line, and open the
+// file to append to it.
+func openFixState(path string, fix string) (*fixState, error) {
+	state := &fixState{fix: fix, processed: map[string]bool{}}
+	if path == "" {
+		return state, nil
+	}
+	content, err := os.ReadFile(path) // #nosec:G304
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read fix state: %s", err.Error())
+	}
+	for line := range strings.Lines(string(content)) {
+		if name, slug, found := strings.Cut(strings.TrimSpace(line), " "); found && name == fix {
+			state.processed[slug] = true
 		}
 	}
+	slog.Info(
+		"skipping recipes processed by previous runs", "fix", fix, "recipes", len(state.processed),
+	)
+	state.file, err = os.OpenFile( // #nosec:G304
+		path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600, //nolint:mnd
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fix state: %s", err.Error())
+	}
+	return state, nil
+}
+
+func (s *fixState) add(slug string) {
+	if s.file == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.file.WriteString(s.fix + " " + slug + "\n"); err != nil {
+		slog.Error("failed to update fix state", "error", err)
+	}
+}
 
-	log.Printf("reuploaded images for %d recipes", counter)
-	return nil
+func (s *fixState) close() {
+	if s.file == nil {
+		return
+	}
+	if err := s.file.Close(); err != nil {
+		slog.Error("failed to close fix state", "error", err)
+	}
 }
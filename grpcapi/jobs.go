@@ -0,0 +1,126 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package grpcapi
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/razziel89/mealie-addons/grpcapi/pb"
+)
+
+const (
+	// Finished jobs are forgotten after this time, just like export jobs of the HTTP API.
+	jobRetention = time.Hour
+	// At most this many jobs are kept track of. The jobs that finished first are forgotten early
+	// to make room for new ones. Further jobs are rejected if this many are running.
+	maxJobs = 32
+)
+
+var errTooManyJobs = errors.New("too many jobs, try again later")
+
+// Keep track of jobs running in the background.
+type jobs struct {
+	lock sync.Mutex
+	jobs map[string]*pb.Job
+	// When jobs finished, by their IDs.
+	finished map[string]time.Time
+}
+
+// Forget jobs that finished long ago. The lock has to be held.
+func (j *jobs) prune() {
+	for id, finished := range j.finished {
+		if time.Since(finished) > jobRetention {
+			delete(j.jobs, id)
+			delete(j.finished, id)
+		}
+	}
+}
+
+// Forget the job that finished first. The lock has to be held.
+func (j *jobs) forgetOldestFinished() {
+	oldest := ""
+	var oldestFinished time.Time
+	for id, finished := range j.finished {
+		if oldest == "" || finished.Before(oldestFinished) {
+			oldest, oldestFinished = id, finished
+		}
+	}
+	delete(j.jobs, oldest)
+	delete(j.finished, oldest)
+}
+
+// Run fn in the background as a new job and return the job's initial state. Return an error if
+// there are too many jobs.
+func (j *jobs) start(name string, fn func() error) (*pb.Job, error) {
+	job := &pb.Job{Id: uuid.New().String(), State: pb.Job_STATE_RUNNING}
+
+	j.lock.Lock()
+	if j.jobs == nil {
+		j.jobs = map[string]*pb.Job{}
+		j.finished = map[string]time.Time{}
+	}
+	j.prune()
+	if len(j.jobs)-len(j.finished) >= maxJobs {
+		j.lock.Unlock()
+		return nil, errTooManyJobs
+	}
+	// There is a finished job to forget since not all of them are running.
+	for len(j.jobs) >= maxJobs {
+		j.forgetOldestFinished()
+	}
+	j.jobs[job.Id] = job
+	result := proto.CloneOf(job)
+	j.lock.Unlock()
+
+	slog.Info("starting grpc job", "name", name, "job", job.Id)
+	go func() {
+		err := fn()
+
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		j.finished[job.Id] = time.Now()
+		if err == nil {
+			slog.Info("grpc job succeeded", "name", name, "job", job.Id)
+			job.State = pb.Job_STATE_SUCCEEDED
+		} else {
+			slog.Error("grpc job failed", "name", name, "job", job.Id, "error", err)
+			job.State = pb.Job_STATE_FAILED
+			job.Error = err.Error()
+		}
+	}()
+
+	return result, nil
+}
+
+// Get the current state of a job.
+func (j *jobs) get(id string) (*pb.Job, bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.prune()
+	job, found := j.jobs[id]
+	if !found {
+		return nil, false
+	}
+	return proto.CloneOf(job), true
+}
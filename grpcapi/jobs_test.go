@@ -0,0 +1,90 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package grpcapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/razziel89/mealie-addons/grpcapi/pb"
+)
+
+// Wait until the job with the given ID has finished and return it.
+func waitForJob(t *testing.T, j *jobs, id string) *pb.Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, found := j.get(id)
+		if !found {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.GetState() != pb.Job_STATE_RUNNING {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestJobsCapRunningJobs(t *testing.T) {
+	j := &jobs{}
+
+	failed, err := j.start("test", func() error {
+		return errors.Join(errors.New("first failure"), errors.New("second failure"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := waitForJob(t, j, failed.GetId())
+	if job.GetState() != pb.Job_STATE_FAILED {
+		t.Errorf("expected the job to fail, got %s", job.GetState())
+	}
+	if job.GetError() != "first failure\nsecond failure" {
+		t.Errorf("unexpected error of the job: %q", job.GetError())
+	}
+
+	// Finished jobs do not count towards the limit but make room for new ones.
+	for range maxJobs {
+		started, err := j.start("test", func() error { return nil })
+		if err != nil {
+			t.Fatalf("expected finished jobs not to count: %s", err.Error())
+		}
+		waitForJob(t, j, started.GetId())
+	}
+	if _, found := j.get(failed.GetId()); found {
+		t.Error("expected the oldest finished job to be forgotten")
+	}
+
+	// Running jobs do count.
+	release := make(chan struct{})
+	defer close(release)
+	blocked := func() error {
+		<-release
+		return nil
+	}
+	for range maxJobs {
+		if _, err := j.start("test", blocked); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := j.start("test", blocked); !errors.Is(err, errTooManyJobs) {
+		t.Errorf("expected too many running jobs to be rejected, got %v", err)
+	}
+}
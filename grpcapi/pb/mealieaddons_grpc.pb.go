@@ -0,0 +1,269 @@
+// A tool to export your mealie recipes for offline storage.
+// Copyright (C) 2025  Torsten Long
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: mealieaddons.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MealieAddons_ListFormats_FullMethodName        = "/mealieaddons.v1.MealieAddons/ListFormats"
+	MealieAddons_Export_FullMethodName             = "/mealieaddons.v1.MealieAddons/Export"
+	MealieAddons_TriggerAssignments_FullMethodName = "/mealieaddons.v1.MealieAddons/TriggerAssignments"
+	MealieAddons_GetJobStatus_FullMethodName       = "/mealieaddons.v1.MealieAddons/GetJobStatus"
+)
+
+// MealieAddonsClient is the client API for MealieAddons service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MealieAddons provides the core operations of mealie-addons.
+type MealieAddonsClient interface {
+	// List all formats that documents can be exported to.
+	ListFormats(ctx context.Context, in *ListFormatsRequest, opts ...grpc.CallOption) (*ListFormatsResponse, error)
+	// Export all recipes matching a query to a document. The document is streamed in chunks. The
+	// first chunk also contains the file name and mime type.
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportChunk], error)
+	// Trigger a round of category and tag assignments in the background.
+	TriggerAssignments(ctx context.Context, in *TriggerAssignmentsRequest, opts ...grpc.CallOption) (*Job, error)
+	// Get the status of a job started via this API.
+	GetJobStatus(ctx context.Context, in *GetJobStatusRequest, opts ...grpc.CallOption) (*Job, error)
+}
+
+type mealieAddonsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMealieAddonsClient(cc grpc.ClientConnInterface) MealieAddonsClient {
+	return &mealieAddonsClient{cc}
+}
+
+func (c *mealieAddonsClient) ListFormats(ctx context.Context, in *ListFormatsRequest, opts ...grpc.CallOption) (*ListFormatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListFormatsResponse)
+	err := c.cc.Invoke(ctx, MealieAddons_ListFormats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mealieAddonsClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MealieAddons_ServiceDesc.Streams[0], MealieAddons_Export_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportRequest, ExportChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MealieAddons_ExportClient = grpc.ServerStreamingClient[ExportChunk]
+
+func (c *mealieAddonsClient) TriggerAssignments(ctx context.Context, in *TriggerAssignmentsRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, MealieAddons_TriggerAssignments_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mealieAddonsClient) GetJobStatus(ctx context.Context, in *GetJobStatusRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, MealieAddons_GetJobStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MealieAddonsServer is the server API for MealieAddons service.
+// All implementations must embed UnimplementedMealieAddonsServer
+// for forward compatibility.
+//
+// MealieAddons provides the core operations of mealie-addons.
+type MealieAddonsServer interface {
+	// List all formats that documents can be exported to.
+	ListFormats(context.Context, *ListFormatsRequest) (*ListFormatsResponse, error)
+	// Export all recipes matching a query to a document. The document is streamed in chunks. The
+	// first chunk also contains the file name and mime type.
+	Export(*ExportRequest, grpc.ServerStreamingServer[ExportChunk]) error
+	// Trigger a round of category and tag assignments in the background.
+	TriggerAssignments(context.Context, *TriggerAssignmentsRequest) (*Job, error)
+	// Get the status of a job started via this API.
+	GetJobStatus(context.Context, *GetJobStatusRequest) (*Job, error)
+	mustEmbedUnimplementedMealieAddonsServer()
+}
+
+// UnimplementedMealieAddonsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMealieAddonsServer struct{}
+
+func (UnimplementedMealieAddonsServer) ListFormats(context.Context, *ListFormatsRequest) (*ListFormatsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListFormats not implemented")
+}
+func (UnimplementedMealieAddonsServer) Export(*ExportRequest, grpc.ServerStreamingServer[ExportChunk]) error {
+	return status.Error(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedMealieAddonsServer) TriggerAssignments(context.Context, *TriggerAssignmentsRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method TriggerAssignments not implemented")
+}
+func (UnimplementedMealieAddonsServer) GetJobStatus(context.Context, *GetJobStatusRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetJobStatus not implemented")
+}
+func (UnimplementedMealieAddonsServer) mustEmbedUnimplementedMealieAddonsServer() {}
+func (UnimplementedMealieAddonsServer) testEmbeddedByValue()                      {}
+
+// UnsafeMealieAddonsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MealieAddonsServer will
+// result in compilation errors.
+type UnsafeMealieAddonsServer interface {
+	mustEmbedUnimplementedMealieAddonsServer()
+}
+
+func RegisterMealieAddonsServer(s grpc.ServiceRegistrar, srv MealieAddonsServer) {
+	// If the following call panics, it indicates UnimplementedMealieAddonsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MealieAddons_ServiceDesc, srv)
+}
+
+func _MealieAddons_ListFormats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFormatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MealieAddonsServer).ListFormats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MealieAddons_ListFormats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MealieAddonsServer).ListFormats(ctx, req.(*ListFormatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MealieAddons_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MealieAddonsServer).Export(m, &grpc.GenericServerStream[ExportRequest, ExportChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MealieAddons_ExportServer = grpc.ServerStreamingServer[ExportChunk]
+
+func _MealieAddons_TriggerAssignments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriggerAssignmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MealieAddonsServer).TriggerAssignments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MealieAddons_TriggerAssignments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MealieAddonsServer).TriggerAssignments(ctx, req.(*TriggerAssignmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MealieAddons_GetJobStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MealieAddonsServer).GetJobStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MealieAddons_GetJobStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MealieAddonsServer).GetJobStatus(ctx, req.(*GetJobStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MealieAddons_ServiceDesc is the grpc.ServiceDesc for MealieAddons service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MealieAddons_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mealieaddons.v1.MealieAddons",
+	HandlerType: (*MealieAddonsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListFormats",
+			Handler:    _MealieAddons_ListFormats_Handler,
+		},
+		{
+			MethodName: "TriggerAssignments",
+			Handler:    _MealieAddons_TriggerAssignments_Handler,
+		},
+		{
+			MethodName: "GetJobStatus",
+			Handler:    _MealieAddons_GetJobStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Export",
+			Handler:       _MealieAddons_Export_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "mealieaddons.proto",
+}
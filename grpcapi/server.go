@@ -0,0 +1,323 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package grpcapi contains the gRPC API, which provides the core operations alongside the HTTP API.
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/razziel89/mealie-addons/api"
+	"github.com/razziel89/mealie-addons/assign"
+	"github.com/razziel89/mealie-addons/grpcapi/pb"
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/render"
+	"github.com/razziel89/mealie-addons/requestid"
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+const (
+	// Documents are streamed in chunks of this size.
+	chunkSize = 64 * 1024
+	// The time to wait for a graceful shutdown unless specified otherwise.
+	defaultTimeout = 2 * time.Second
+)
+
+// Server implements the gRPC API.
+type Server struct {
+	pb.UnimplementedMealieAddonsServer
+
+	timeout    time.Duration
+	source     api.RecipeSource
+	generators []api.ResponseGenerator
+	// Protects assignments, which can be replaced while serving.
+	lock        sync.RWMutex
+	assignments assign.Assignments
+	client      assign.Client
+	jobs        jobs
+	token       string
+	auth        api.Auth
+}
+
+// New creates a gRPC API. Exports use the given generators to build documents from recipes
+// retrieved from the source and may take at most the given timeout. Assignments are performed
+// using the given client. Calls have to present the token as a bearer token in their authorization
+// metadata or, if any, the credentials of auth just like requests to the HTTP API.
+func New(
+	timeout time.Duration,
+	source api.RecipeSource,
+	generators []api.ResponseGenerator,
+	assignments assign.Assignments,
+	client assign.Client,
+	token string,
+	auth api.Auth,
+) *Server {
+	return &Server{
+		timeout:     timeout,
+		source:      source,
+		generators:  generators,
+		assignments: assignments,
+		client:      client,
+		token:       token,
+		auth:        auth,
+	}
+}
+
+// SetAssignments replaces the assignments that are triggered via TriggerAssignments, e.g. after
+// the configuration was reloaded.
+func (s *Server) SetAssignments(assignments assign.Assignments) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.assignments = assignments
+}
+
+// ListFormats lists all formats that documents can be exported to.
+func (s *Server) ListFormats(
+	_ context.Context,
+	_ *pb.ListFormatsRequest,
+) (*pb.ListFormatsResponse, error) {
+	formats := make([]*pb.Format, 0, len(s.generators))
+	for _, gen := range s.generators {
+		formats = append(formats, &pb.Format{
+			Name:      gen.CommonName(),
+			Extension: gen.Extension(),
+			MimeType:  gen.MimeType(),
+		})
+	}
+	return &pb.ListFormatsResponse{Formats: formats}, nil
+}
+
+// Export exports all recipes matching a query to a document and streams it in chunks.
+func (s *Server) Export(
+	request *pb.ExportRequest,
+	stream grpc.ServerStreamingServer[pb.ExportChunk],
+) error {
+	var gen api.ResponseGenerator
+	for _, candidate := range s.generators {
+		if candidate.CommonName() == request.GetFormat() {
+			gen = candidate
+		}
+	}
+	if gen == nil {
+		return status.Errorf(codes.InvalidArgument, "unknown format %s", request.GetFormat())
+	}
+
+	ctx, cancel := context.WithTimeout(stream.Context(), s.timeout)
+	defer cancel()
+
+	query := map[string][]string{}
+	for _, param := range request.GetQuery() {
+		query[param.GetKey()] = append(query[param.GetKey()], param.GetValue())
+	}
+
+	ctx = render.WithMetadata(ctx, render.ExtractMetadata(query))
+	emptyResult, err := api.ExtractEmptyResult(query)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.source.ValidateQuery(ctx, query); err != nil {
+		var queryErr *mealieclient.QueryError
+		if errors.As(err, &queryErr) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return status.Errorf(codes.Internal, "failed to validate query: %s", err.Error())
+	}
+
+	export := summary.New()
+	ctx = summary.With(ctx, export)
+	ctx, cleanup := workdir.With(ctx)
+	defer cleanup()
+
+	now := time.Now()
+	recipes, warnings, err := s.source.GetRecipes(ctx, query)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to get recipes: %s", err.Error())
+	}
+	slog.InfoContext(
+		ctx, "retrieved recipes via grpc", "recipes", len(recipes), "mimeType", gen.MimeType(),
+	)
+	export.SetRecipes(len(recipes))
+	if len(recipes) == 0 && emptyResult != api.EmptyResultDocument {
+		slog.InfoContext(ctx, "no recipes matched via grpc", "response", emptyResult)
+		if emptyResult == api.EmptyResultNoContent {
+			return nil
+		}
+		return status.Error(codes.NotFound, "no recipes matched the query")
+	}
+	render.OrderRecipes(recipes, query)
+	ctx = render.WithWarnings(ctx, warnings)
+
+	response, err := gen.Response(ctx, recipes, now)
+	export.Log(ctx, gen.CommonName(), len(response), err)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to generate document: %s", err.Error())
+	}
+
+	chunk := &pb.ExportChunk{Filename: api.Filename(gen, now), MimeType: gen.MimeType()}
+	for start := 0; start < len(response) || start == 0; start += chunkSize {
+		chunk.Data = response[start:min(start+chunkSize, len(response))]
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+		chunk = &pb.ExportChunk{}
+	}
+	slog.InfoContext(ctx, "streamed document via grpc", "bytes", len(response))
+	return nil
+}
+
+// TriggerAssignments triggers a round of assignments in the background.
+func (s *Server) TriggerAssignments(
+	_ context.Context,
+	_ *pb.TriggerAssignmentsRequest,
+) (*pb.Job, error) {
+	s.lock.RLock()
+	assignments := s.assignments
+	s.lock.RUnlock()
+	if !assignments.Configured() {
+		return nil, status.Error(codes.FailedPrecondition, "no assignments configured")
+	}
+	job, err := s.jobs.start("assignment", func() error {
+		return assign.RunOnce(assignments, s.client)
+	})
+	if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return job, nil
+}
+
+// GetJobStatus reports the status of a job.
+func (s *Server) GetJobStatus(_ context.Context, request *pb.GetJobStatusRequest) (*pb.Job, error) {
+	job, found := s.jobs.get(request.GetId())
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "unknown job %s", request.GetId())
+	}
+	return job, nil
+}
+
+// Give every call an ID, or keep the one sent by the client as metadata, and attach it to the
+// call's context, just like the HTTP API does. The ID is sent back as a header.
+func identifyRequest(
+	ctx context.Context, request any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	var sent string
+	if values := metadata.ValueFromIncomingContext(ctx, requestid.Header); len(values) != 0 {
+		sent = values[0]
+	}
+	id := requestid.Use(sent)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestid.Header, id)); err != nil {
+		slog.WarnContext(ctx, "failed to send request id", "error", err)
+	}
+	return handler(requestid.With(ctx, id), request)
+}
+
+// Whether a call presents the token as a bearer token in its authorization metadata or the
+// credentials of auth, if any.
+func (s *Server) authorized(ctx context.Context) bool {
+	header := func(name string) string {
+		if values := metadata.ValueFromIncomingContext(ctx, name); len(values) != 0 {
+			return values[0]
+		}
+		return ""
+	}
+	if s.auth.Enabled() && s.auth.Allows(ctx, header) {
+		return true
+	}
+	presented, found := strings.CutPrefix(header("authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(presented), []byte(s.token)) == 1
+}
+
+// Reject unary calls that do not present valid credentials.
+func (s *Server) authenticate(
+	ctx context.Context, request any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	if !s.authorized(ctx) {
+		slog.WarnContext(ctx, "missing or invalid credentials")
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid credentials")
+	}
+	return handler(ctx, request)
+}
+
+// Reject streaming calls, i.e. exports, that do not present valid credentials.
+func (s *Server) authenticateStream(
+	server any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	if !s.authorized(stream.Context()) {
+		slog.WarnContext(stream.Context(), "missing or invalid credentials")
+		return status.Error(codes.Unauthenticated, "missing or invalid credentials")
+	}
+	return handler(server, stream)
+}
+
+// Serve creates a gRPC server for the API that listens on iface. It returns a function that starts
+// the server in the background and a function that shuts it down within the given timeout.
+func Serve(iface string, server *Server) (func() error, func(time.Duration)) {
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(identifyRequest, server.authenticate),
+		grpc.StreamInterceptor(server.authenticateStream),
+	)
+	pb.RegisterMealieAddonsServer(grpcServer, server)
+
+	runFn := func() error {
+		listener, err := net.Listen("tcp", iface)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %s", iface, err.Error())
+		}
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				slog.Error("failed to serve grpc", "error", err)
+				os.Exit(1)
+			}
+		}()
+		slog.Info("serving grpc", "interface", iface)
+		return nil
+	}
+
+	shutdownFn := func(timeout time.Duration) {
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		slog.Info("shutting down the grpc server", "timeout", timeout.String())
+		stopped := make(chan bool)
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(timeout):
+			grpcServer.Stop()
+		}
+	}
+
+	return runFn, shutdownFn
+}
@@ -0,0 +1,74 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/razziel89/mealie-addons/requestid"
+)
+
+// Log formats supported via MA_LOG_FORMAT.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// Build a handler that writes records of at least the given level in the given format to w.
+func newLogHandler(w io.Writer, level string, format string) (slog.Handler, error) {
+	var leveler slog.Level
+	if level != "" {
+		if err := leveler.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("failed to parse MA_LOG_LEVEL: %s", err.Error())
+		}
+	}
+	opts := &slog.HandlerOptions{Level: leveler}
+	switch strings.ToLower(format) {
+	case "", logFormatText:
+		return slog.NewTextHandler(w, opts), nil
+	case logFormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf(
+			"failed to parse MA_LOG_FORMAT, must be %s or %s but is %s",
+			logFormatText, logFormatJSON, format,
+		)
+	}
+}
+
+// Set up the default logger as configured via MA_LOG_LEVEL and MA_LOG_FORMAT. This has to happen
+// before anything is logged. Messages of dependencies that use the standard library's log package
+// are passed through at info level.
+func setUpLogging() error {
+	handler, err := newLogHandler(os.Stderr, os.Getenv("MA_LOG_LEVEL"), os.Getenv("MA_LOG_FORMAT"))
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(requestid.LogHandler(handler)))
+	return nil
+}
+
+// Log an error and exit.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
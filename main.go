@@ -19,161 +19,873 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"golang.org/x/net/html"
+
+	"github.com/razziel89/mealie-addons/api"
+	"github.com/razziel89/mealie-addons/assign"
+	"github.com/razziel89/mealie-addons/destination"
+	"github.com/razziel89/mealie-addons/grpcapi"
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/media"
+	"github.com/razziel89/mealie-addons/migrate"
+	"github.com/razziel89/mealie-addons/render"
+	"github.com/razziel89/mealie-addons/schedule"
+	"github.com/razziel89/mealie-addons/tracing"
+	"github.com/razziel89/mealie-addons/workdir"
 )
 
+// Time to wait for pending spans to be exported when shutting down.
+const tracingFlushTimeout = 5 * time.Second
+
 // Initialise everything.
 func main() {
 	quit := make(chan bool)
 	var err error
+	migrateDryRun := flag.Bool(
+		"migrate-dry-run", false,
+		"log how persisted state would be migrated to this release, then exit without changes",
+	)
+	checkOnly := flag.Bool(
+		"check-config", false,
+		"validate the configuration, including access to mealie, print a report, then exit",
+	)
+	remarkableCode := flag.String(
+		"register-remarkable", "",
+		"exchange a one-time code of the reMarkable cloud for a device token, print it, then exit",
+	)
+	flag.Parse()
+	if *checkOnly {
+		os.Exit(checkConfig(os.Stdout))
+	}
+	if *remarkableCode != "" {
+		deviceToken, err := destination.RegisterReMarkable(context.Background(), *remarkableCode)
+		if err != nil {
+			fatal("failed to register with the remarkable cloud", "error", err)
+		}
+		fmt.Println(deviceToken)
+		os.Exit(0)
+	}
+
+	// Config file, which may configure logging and tracing, too.
+	configFile := os.Getenv("MA_CONFIG_FILE")
+	fromFile, err := loadConfigFile(configFile)
+	if err != nil {
+		fatal("config file not sane", "error", err)
+	}
+	// Logging.
+	if err := setUpLogging(); err != nil {
+		fatal("logging not sane", "error", err)
+	}
+	if configFile != "" {
+		slog.Info(
+			"loaded config file", "path", configFile, "variables", strings.Join(fromFile, ","),
+		)
+	}
+	// Tracing.
+	shutdownTracing, err := tracing.SetUp(context.Background())
+	if err != nil {
+		fatal("tracing not sane", "error", err)
+	}
 
 	// Config.
 	var cfg config
 	if cfg, err = initConfig(); err != nil {
-		log.Fatalf("config not sane: %s", err.Error())
+		fatal("config not sane", "error", err)
+	}
+	// Persisted state, which is migrated before anything reads it.
+	if err := migrate.Run(cfg.stores(), *migrateDryRun); err != nil {
+		fatal("cannot migrate persisted state", "error", err)
+	}
+	if *migrateDryRun {
+		slog.Info("dry run of migrations finished, exiting")
+		os.Exit(0)
 	}
-	if err := checkForPandoc(); err != nil {
-		log.Fatalf("missing executable: %s", err.Error())
+	if err := checkExecutables(&cfg); err != nil {
+		fatal("missing executable", "error", err)
+	}
+	if cfg.pdfLayout.RunningHeaders && cfg.pdfEngine != render.PDFEngineLuaLaTeX &&
+		cfg.pdfEngine != render.PDFEngineXeLaTeX {
+		slog.Warn("MA_PDF_RUNNING_HEADERS is not supported, ignoring it", "engine", cfg.pdfEngine)
+	}
+	if cfg.imageAction == "embed" {
+		if err := media.CheckForHeifConvert(); err != nil {
+			slog.Warn("heic images cannot be embedded", "error", err)
+		}
+		if err := media.CheckForRsvgConvert(); err != nil {
+			slog.Warn("svg images cannot be embedded in pdf documents", "error", err)
+		}
 	}
 
 	{
 		copyCfg := cfg
 		copyCfg.mealieToken = "***"
-		log.Printf("using config: %+v", copyCfg)
+		if copyCfg.workerToken != "" {
+			copyCfg.workerToken = "***"
+		}
+		if copyCfg.grpcToken != "" {
+			copyCfg.grpcToken = "***"
+		}
+		if copyCfg.debugToken != "" {
+			copyCfg.debugToken = "***"
+		}
+		if copyCfg.adminToken != "" {
+			copyCfg.adminToken = "***"
+		}
+		if copyCfg.auth.Token != "" {
+			copyCfg.auth.Token = "***"
+		}
+		if copyCfg.auth.Password != "" {
+			copyCfg.auth.Password = "***"
+		}
+		if s3 := copyCfg.s3; s3 != nil {
+			masked := *s3
+			masked.SecretKey = "***"
+			copyCfg.s3 = &masked
+		}
+		if webdav := copyCfg.webdav; webdav != nil {
+			copyCfg.webdav = &destination.WebDAV{
+				URL: webdav.URL, User: webdav.User, Password: "***", Path: webdav.Path,
+			}
+		}
+		if copyCfg.dropbox != nil {
+			copyCfg.dropbox = &destination.Dropbox{
+				AppKey: copyCfg.dropbox.AppKey, AppSecret: "***", RefreshToken: "***",
+				Path: copyCfg.dropbox.Path,
+			}
+		}
+		if copyCfg.googleDrive != nil {
+			copyCfg.googleDrive = &destination.GoogleDrive{
+				Credentials: "***", FolderID: copyCfg.googleDrive.FolderID,
+			}
+		}
+		if sftp := copyCfg.sftp; sftp != nil {
+			copyCfg.sftp = &destination.SFTP{
+				Address: sftp.Address, User: sftp.User, Password: "***", PrivateKey: "***",
+				Passphrase: "***", HostKeys: sftp.HostKeys, Path: sftp.Path,
+			}
+		}
+		if ftp := copyCfg.ftp; ftp != nil {
+			copyCfg.ftp = &destination.FTP{
+				Address: ftp.Address, User: ftp.User, Password: "***", Path: ftp.Path,
+			}
+		}
+		if copyCfg.remarkable != nil {
+			copyCfg.remarkable = &destination.ReMarkable{DeviceToken: "***"}
+		}
+		if paperless := copyCfg.paperless; paperless != nil {
+			copyCfg.paperless = &destination.Paperless{
+				URL: paperless.URL, Token: "***", Tags: paperless.Tags,
+				Correspondent: paperless.Correspondent,
+			}
+		}
+		if discord := copyCfg.discord; discord != nil {
+			copyCfg.discord = &destination.Discord{
+				WebhookURL: "***", Announcement: discord.Announcement,
+			}
+		}
+		if slack := copyCfg.slack; slack != nil {
+			copyCfg.slack = &destination.Slack{
+				Token: "***", Channel: slack.Channel, Announcement: slack.Announcement,
+			}
+		}
+		if matrix := copyCfg.matrix; matrix != nil {
+			copyCfg.matrix = &destination.Matrix{
+				Homeserver: matrix.Homeserver, Token: "***", Room: matrix.Room,
+				Announcement: matrix.Announcement,
+			}
+		}
+		if git := copyCfg.git; git != nil {
+			copyCfg.git = &destination.Git{
+				Remote: git.Remote, Branch: git.Branch, Dir: git.Dir,
+				AuthorName: git.AuthorName, AuthorEmail: git.AuthorEmail,
+			}
+			if remote, err := url.Parse(git.Remote); err == nil {
+				copyCfg.git.Remote = remote.Redacted()
+			}
+		}
+		if proxy, err := url.Parse(copyCfg.mealieTransport.Proxy); err == nil {
+			copyCfg.mealieTransport.Proxy = proxy.Redacted()
+		}
+		// Only URLs can contain credentials, files are kept as they are.
+		if target, err := url.Parse(copyCfg.fixes.resultTarget); err == nil && target.Host != "" {
+			copyCfg.fixes.resultTarget = target.Redacted()
+		}
+		slog.Info("using config", "config", fmt.Sprintf("%+v", copyCfg))
 	}
 
-	var limiter chan bool
-	if cfg.retrievalLimit > 0 {
-		log.Printf("retrieving at most %d recipes in parallel", cfg.retrievalLimit)
-		limiter = make(chan bool, cfg.retrievalLimit)
+	if cfg.memoryLimit > 0 {
+		// This takes precedence over the GOMEMLIMIT environment variable.
+		debug.SetMemoryLimit(cfg.memoryLimit)
+		media.SetConcurrencyLimit(cfg.imageLimit)
+		slog.Info("limiting memory", "bytes", cfg.memoryLimit, "parallelImages", cfg.imageLimit)
 	}
 
-	mealie := mealie{url: cfg.mealieRetrievalURL, token: cfg.mealieToken, limiter: limiter}
-	works, try := false, 1
-	var group string
-	for !works && try <= cfg.startupGraceSecs {
-		var err error
-		group, err = mealie.check()
-		if err != nil {
-			log.Printf(
-				"cannot connect to mealie, retrying at most %d times every 1s: %s",
-				cfg.startupGraceSecs-try,
-				err.Error(),
-			)
-			time.Sleep(time.Second)
+	// Single sign-on.
+	if err := cfg.auth.Discover(); err != nil {
+		fatal("failed to set up authentication", "error", err)
+	}
+
+	// Parts of the configuration and the TLS certificate are reloaded on SIGHUP, e.g. after the
+	// certificate has been renewed. Signals are only handled once everything has been set up but
+	// must not terminate the process before that.
+	signalReload := make(chan os.Signal, 1)
+	signal.Notify(signalReload, syscall.SIGHUP)
+
+	// Client addresses for logs and rate limits.
+	if err := api.SetTrustedProxies(cfg.trustedProxies); err != nil {
+		fatal("failed to set trusted proxies", "error", err)
+	}
+
+	// HTTPS.
+	if cfg.tlsCertFile != "" {
+		if err := api.EnableTLS(cfg.tlsCertFile, cfg.tlsKeyFile); err != nil {
+			fatal("failed to set up tls", "error", err)
 		}
-		works = err == nil
-		try++
 	}
-	if !works {
-		log.Fatalf("mealie connection cannot be established")
+
+	var mealie *mealieclient.Client
+	// Why recipes are not updated, empty if they are.
+	noUpdates := ""
+	// Whether mealie could be reached at startup. If not, everything is started degraded.
+	reached := true
+	// The URL of mealie without the group that the token belongs to.
+	mealieBaseURL := cfg.mealieBaseURL
+	if cfg.mode == modeServer {
+		var group string
+		mealie, group, err = connectToMealie(cfg)
+		switch {
+		case err != nil && !cfg.degradedStart:
+			fatal("failed to connect to mealie", "error", err)
+		case err != nil && cfg.fixes.oneShot:
+			// Nothing is served in one-shot mode, which is why there is no point in starting.
+			group = retryMealie(mealie)
+		case err != nil:
+			slog.Warn(
+				"starting degraded, retrying to connect to mealie in the background",
+				"interval", degradedRetryInterval.String(),
+			)
+			reached = false
+		}
+		mealie.SetSeasons(cfg.seasons)
+		mealie.SetDiets(cfg.diets)
+		mealie.SetSavedQueries(cfg.savedQueries)
+		mealie.SetArchiveCategory(cfg.archiveCategory)
+		mealie.SetReadOnly(cfg.readOnly)
+		if reached {
+			verifyPermissions(cfg, mealie)
+		}
+
+		// Nothing that updates recipes is started in read-only mode. Exports keep working.
+		if cfg.readOnly {
+			noUpdates = "read-only mode"
+		}
+		if noUpdates != "" && cfg.fixes.oneShot {
+			fatal("cannot perform fixes", "reason", noUpdates)
+		}
+		if noUpdates != "" && cfg.queryAssignments.Configured() {
+			slog.Warn("not performing query assignments", "reason", noUpdates)
+			cfg.queryAssignments = assign.Assignments{}
+		}
+		if noUpdates != "" && cfg.fixes.requested() {
+			slog.Warn("not performing fixes", "reason", noUpdates)
+			cfg.fixes = fixes{}
+		}
+		if cfg.fixes.oneShot {
+			os.Exit(performFixes(cfg, mealie).exitCode())
+		}
+		if reached {
+			cfg.mealieBaseURL = mealieBaseURL + "/g/" + group
+		}
 	}
 
-	cfg.mealieBaseURL = cfg.mealieBaseURL + "/g/" + group
+	if err := media.SetCache(
+		cfg.mediaCacheSize, cfg.mediaCacheDir, cfg.mediaCacheDirSize,
+	); err != nil {
+		fatal("failed to set up media cache", "error", err)
+	}
+	api.SetBookCache(cfg.bookCacheSize)
+	api.SetMediaKey(cfg.workerToken)
+	render.Language = cfg.language
+	api.DefaultEmptyResult = cfg.emptyResult
 
-	htmlHooks := []func(*html.Node) (*html.Node, error){}
+	htmlHooks := []render.HTMLHook{}
+	pdfHooks := []render.HTMLHook{}
 	switch cfg.imageAction {
 	case "ignore": // No-op.
 	case "remove":
-		log.Println("image tags will be removed from resulting documents")
-		hook := func(htmlInput *html.Node) (*html.Node, error) {
-			return removeAllHTMLElements(htmlInput, "img")
-		}
-		htmlHooks = append(htmlHooks, hook)
+		slog.Info("image tags will be removed from resulting documents")
+		htmlHooks = append(htmlHooks, render.RemoveImages)
 	case "embed":
-		log.Println("image tags will be embedded into resulting documents")
-		retrievalEndpoint := cfg.selfURL + "/media/"
+		slog.Info("image tags will be embedded into resulting documents")
+		retrievalEndpoint := cfg.mediaURL
 		hook := func(htmlInput *html.Node) (*html.Node, error) {
-			return redirectImgSources(htmlInput, "/api/media/recipes/", retrievalEndpoint)
-		}
-		htmlHooks = append(htmlHooks, hook)
-		hook = func(htmlInput *html.Node) (*html.Node, error) {
-			return ensureWebpImagesCanBeReplaced(htmlInput)
+			return render.RewriteImgSources(
+				htmlInput, "/api/media/recipes/",
+				func(path string) string { return retrievalEndpoint + api.SignMediaPath(path) },
+			)
 		}
 		htmlHooks = append(htmlHooks, hook)
+		// Only LaTeX cannot embed webp images. Other formats keep the smaller original files.
+		pdfHooks = append(pdfHooks, render.EnsureWebpImagesCanBeReplaced)
 	}
 
-	updateAttrsHook := func(htmlInput *html.Node) (*html.Node, error) {
-		return updateHTMLAttrs(htmlInput, cfg.htmlAttrsMod, cfg.htmlAttrsRm)
+	htmlAttrs := &htmlAttrRules{}
+	htmlAttrs.set(cfg.htmlAttrsMod, cfg.htmlAttrsRm)
+	htmlHooks = append(htmlHooks, htmlAttrs.update)
+
+	validateLinksHook := func(htmlInput *html.Node) (*html.Node, error) {
+		return render.ValidateInternalLinks(htmlInput, cfg.failOnBrokenLinks)
 	}
-	htmlHooks = append(htmlHooks, updateAttrsHook)
+	htmlHooks = append(htmlHooks, validateLinksHook)
 
-	pandoc := pandoc{options: cfg.pandocFlags, htmlHooks: htmlHooks}
-	err = pandoc.loadFonts(cfg.pandocFontsDir)
+	pandoc := render.NewPandoc(cfg.pandocFlags, htmlHooks)
+	pandoc.AddFormatHooks("pdf", pdfHooks...)
+	pandoc.SetFontSubsetting(cfg.pdfSubsetFonts)
+	pandoc.SetLinting(cfg.lint)
+	pandoc.SetGlyphRejection(cfg.rejectGlyphs)
+	pandoc.SetPDFLayout(cfg.pdfLayout)
+	if err := pandoc.SetPDFEngine(cfg.pdfEngine); err != nil {
+		fatal("failed to set pdf engine", "error", err)
+	}
+	if err := workdir.SetRoot(cfg.workDir); err != nil {
+		fatal("failed to set up working directory", "error", err)
+	}
+	err = pandoc.LoadFonts(cfg.pandocFontsDir)
 	if err != nil {
-		log.Printf("failed to load fonts, skipping: %s", err.Error())
+		slog.Warn("failed to load fonts, skipping", "error", err)
 	}
 
 	// API.
-	startAPIFn, serverShutdown := setUpAPI(
-		cfg.listenInterface,
-		time.Duration(cfg.timeoutSecs)*time.Second,
-		mealie.getRecipes,
-		mealie.getMedia,
-		[]responseGenerator{
-			&markdownGenerator{url: cfg.mealieBaseURL, pandoc: &pandoc},
-			&epubGenerator{url: cfg.mealieBaseURL, pandoc: &pandoc},
-			&pdfGenerator{url: cfg.mealieBaseURL, pandoc: &pandoc},
-			&htmlGenerator{url: cfg.mealieBaseURL, pandoc: &pandoc},
-		},
-	)
+	var startAPIFn func()
+	var quitScheduledExports chan<- bool
+	var quitWatcher chan<- bool
+	var serverShutdown func(time.Duration) error
+	startGRPCFn, grpcShutdown := func() error { return nil }, func(time.Duration) {}
+	var grpcServer *grpcapi.Server
+	if cfg.mode == modeWorker {
+		slog.Info("running in worker mode, only conversions will be performed")
+		startAPIFn, serverShutdown = api.SetUpWorker(
+			cfg.listenInterface,
+			time.Duration(cfg.timeoutSecs)*time.Second,
+			pandoc,
+			cfg.pandocAllowlist,
+			cfg.workerToken,
+			cfg.debugToken,
+			api.ReadinessChecks{"pandoc": pandoc.Ready},
+		)
+	} else {
+		converters := map[string]render.Converter{}
+		for _, format := range knownFormats {
+			spec := cfg.converters[format]
+			converter, err := render.NewConverter(spec, pandoc, htmlHooks, cfg.workerToken)
+			if err != nil {
+				fatal("failed to set up converter", "format", format, "error", err)
+			}
+			if spec.Backend != "" {
+				slog.Info("using converter backend", "backend", spec.Backend, "format", format)
+			}
+			converters[format] = cfg.execHooks.WrapConverter(converter)
+		}
 
-	// Use default timeout for now.
+		var source api.RecipeSource = mealie
+		// Links in documents point to the group of the mealie token, which is unknown until mealie
+		// has been reached. Thus, no documents are generated before that.
+		awaited := &awaitedSource{RecipeSource: mealie}
+		if !reached {
+			source = awaited
+		}
+		if len(cfg.execHooks.PreFetch) != 0 {
+			source = api.WithQueryHook(source, cfg.execHooks.RunPreFetch)
+		}
+
+		url := cfg.mealieBaseURL
+		generators := []api.ResponseGenerator{
+			&render.MarkdownGenerator{
+				URL: url, Converter: converters["markdown"], Difficulty: cfg.showDifficulty,
+				Nutrition: cfg.showNutrition, Allergens: cfg.allergens,
+			},
+			&render.EpubGenerator{
+				URL:        url,
+				Converter:  converters["epub"],
+				Captions:   cfg.imageCaptions,
+				Styles:     cfg.categoryStyles,
+				Difficulty: cfg.showDifficulty,
+				Nutrition:  cfg.showNutrition,
+				Allergens:  cfg.allergens,
+				Metadata:   cfg.metadata,
+			},
+			&render.PDFGenerator{
+				URL:        url,
+				Converter:  converters["pdf"],
+				Captions:   cfg.imageCaptions,
+				Styles:     cfg.categoryStyles,
+				Difficulty: cfg.showDifficulty,
+				Nutrition:  cfg.showNutrition,
+				Allergens:  cfg.allergens,
+				Metadata:   cfg.metadata,
+			},
+			&render.HTMLGenerator{
+				URL:        url,
+				Converter:  converters["html"],
+				Styles:     cfg.categoryStyles,
+				Difficulty: cfg.showDifficulty,
+				Nutrition:  cfg.showNutrition,
+				Allergens:  cfg.allergens,
+			},
+			&render.DocxGenerator{
+				URL:        url,
+				Converter:  converters["docx"],
+				Captions:   cfg.imageCaptions,
+				Styles:     cfg.categoryStyles,
+				Difficulty: cfg.showDifficulty,
+				Nutrition:  cfg.showNutrition,
+				Allergens:  cfg.allergens,
+				Metadata:   cfg.metadata,
+			},
+			&render.OdtGenerator{
+				URL:        url,
+				Converter:  converters["odt"],
+				Captions:   cfg.imageCaptions,
+				Styles:     cfg.categoryStyles,
+				Difficulty: cfg.showDifficulty,
+				Nutrition:  cfg.showNutrition,
+				Allergens:  cfg.allergens,
+				Metadata:   cfg.metadata,
+			},
+			&render.JSONGenerator{},
+			&render.PaprikaGenerator{URL: url, Media: mealie},
+		}
+		if err := render.CheckForEbookConvert(); err == nil {
+			generators = append(generators, &render.Azw3Generator{
+				URL:        url,
+				Converter:  converters["epub"],
+				Captions:   cfg.imageCaptions,
+				Styles:     cfg.categoryStyles,
+				Difficulty: cfg.showDifficulty,
+				Nutrition:  cfg.showNutrition,
+				Allergens:  cfg.allergens,
+				Metadata:   cfg.metadata,
+			})
+		} else {
+			slog.Warn("azw3 documents cannot be generated", "error", err)
+		}
+		generators = append(generators, api.Bundle(generators))
+		// Indices of the library are available in all formats that are built from markdown.
+		for _, gen := range generators {
+			switch gen.(type) {
+			case *render.MarkdownGenerator, *render.EpubGenerator, *render.PDFGenerator,
+				*render.HTMLGenerator, *render.DocxGenerator, *render.OdtGenerator,
+				*render.Azw3Generator:
+				generators = append(generators, api.IndexOnly(gen))
+			}
+		}
+		destinations := []destination.Destination{}
+		if cfg.webdav != nil {
+			destinations = append(destinations, cfg.webdav)
+		}
+		if cfg.s3 != nil {
+			destinations = append(destinations, cfg.s3)
+		}
+		if cfg.dropbox != nil {
+			destinations = append(destinations, cfg.dropbox)
+		}
+		if cfg.googleDrive != nil {
+			destinations = append(destinations, cfg.googleDrive)
+		}
+		if cfg.sftp != nil {
+			destinations = append(destinations, cfg.sftp)
+		}
+		if cfg.ftp != nil {
+			destinations = append(destinations, cfg.ftp)
+		}
+		if cfg.remarkable != nil {
+			destinations = append(destinations, cfg.remarkable)
+		}
+		if cfg.paperless != nil {
+			destinations = append(destinations, cfg.paperless)
+		}
+		if cfg.discord != nil {
+			destinations = append(destinations, cfg.discord)
+		}
+		if cfg.slack != nil {
+			destinations = append(destinations, cfg.slack)
+		}
+		if cfg.matrix != nil {
+			destinations = append(destinations, cfg.matrix)
+		}
+		if cfg.git != nil {
+			destinations = append(destinations, cfg.git)
+		}
+		readinessChecks := api.ReadinessChecks{"mealie": mealie.Ready}
+		if cfg.needsPandoc() {
+			readinessChecks["pandoc"] = pandoc.Ready
+		}
+		startAPIFn, serverShutdown = api.SetUp(
+			cfg.listenInterface,
+			time.Duration(cfg.timeoutSecs)*time.Second,
+			source,
+			generators,
+			time.Duration(cfg.cacheSecs)*time.Second,
+			cfg.pandocAllowlist,
+			cfg.debugToken,
+			destinations,
+			cfg.adminToken,
+			mealie,
+			cfg.presets,
+			cfg.auth,
+			cfg.rateLimit,
+			readinessChecks,
+		)
+		quitScheduledExports, err = schedule.LaunchLoop(
+			cfg.scheduledExports, source, generators, destinations,
+		)
+		if err != nil {
+			fatal("failed to start scheduled exports", "error", err)
+		}
+		quitWatcher, err = schedule.LaunchWatcher(
+			cfg.watch, cfg.presets, source, generators, destinations,
+		)
+		if err != nil {
+			fatal("failed to start watching presets", "error", err)
+		}
+		if cfg.grpcInterface != "" {
+			grpcServer = grpcapi.New(
+				time.Duration(cfg.timeoutSecs)*time.Second,
+				source,
+				generators,
+				cfg.queryAssignments,
+				mealie,
+				cfg.grpcToken,
+				cfg.auth,
+			)
+			startGRPCFn, grpcShutdown = grpcapi.Serve(cfg.grpcInterface, grpcServer)
+		}
+		if !reached {
+			go awaitMealie(cfg, mealie, mealieBaseURL, generators, awaited)
+		}
+	}
+
+	// Stop accepting requests and give running exports, including their pandoc processes, time to
+	// finish. Everything is drained at the same time, so that shutting down takes no longer than
+	// the drain timeout.
+	drainTimeout := time.Duration(cfg.drainTimeoutSecs) * time.Second
 	quitHook := func() error {
-		return serverShutdown(0)
+		slog.Info("draining running exports", "timeout", drainTimeout.String())
+		drained := sync.WaitGroup{}
+		drained.Go(func() { grpcShutdown(drainTimeout) })
+		for _, loop := range []chan<- bool{quitScheduledExports, quitWatcher} {
+			if loop != nil {
+				drained.Go(func() { stopLoop(loop, drainTimeout) })
+			}
+		}
+		err := serverShutdown(drainTimeout)
+		drained.Wait()
+		return err
 	}
 
 	// Allow killing via signals, too. Listen for SIGINT (sent by user) and SIGTERM (sent by OS).
 	signalQuit := make(chan os.Signal, 2) //nolint:mnd
 	signal.Notify(signalQuit, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		for done := false; !done; {
-			// Block until the signal channel has been notified, then call the quit hook. If there
-			// is an error calling the quit hook, do not exit but continue to listen for signals.
-			sig := <-signalQuit
-			log.Printf("caught signal %v", sig)
-			if err := quitHook(); err != nil {
-				log.Printf("error shutting down due to signal: %s", err.Error())
-			} else {
-				done = true
-				quit <- true
-			}
+		// Block until the signal channel has been notified, then call the quit hook. Exports that
+		// are still running once it returns are aborted.
+		sig := <-signalQuit
+		slog.Info("caught signal", "signal", sig.String())
+		if err := quitHook(); err != nil {
+			slog.Warn("aborting exports that did not finish in time", "error", err)
 		}
+		quit <- true
 	}()
 
-	quitAssignmentLoop, err := launchAssignmentLoop(cfg.queryAssignments, &mealie)
-	if err != nil {
-		log.Fatalf("failed to start assignment loop: %s", err.Error())
+	var quitAssignmentLoop chan<- bool
+	if cfg.mode == modeServer {
+		quitAssignmentLoop, err = assign.LaunchLoop(cfg.queryAssignments, mealie)
+		if err != nil {
+			fatal("failed to start assignment loop", "error", err)
+		}
 	}
 
 	// Actually start the API.
 	startAPIFn()
-	if err := healthCheck(cfg.selfURL); err != nil {
+	if err := api.HealthCheck(cfg.selfURL); err != nil {
 		if quitAssignmentLoop != nil {
 			quitAssignmentLoop <- true
 		}
 		if err := serverShutdown(0); err != nil {
-			log.Printf("failed to shut down server: %s", err.Error())
+			slog.Error("failed to shut down server", "error", err)
 		}
-		log.Fatalf("health check failed, cannot reach self via MA_SELF_URL: %s", err.Error())
+		fatal("health check failed, cannot reach self via MA_SELF_URL", "error", err)
 	}
-	// Perform requested fixes.
-	if cfg.fixes.imageReupload {
-		err := reuploadImages(&mealie)
-		if err != nil {
-			log.Fatalf("failed to run image-reupload fix: %s", err.Error())
+	if err := startGRPCFn(); err != nil {
+		if quitAssignmentLoop != nil {
+			quitAssignmentLoop <- true
+		}
+		if err := serverShutdown(0); err != nil {
+			slog.Error("failed to shut down server", "error", err)
 		}
+		fatal("failed to start grpc server", "error", err)
 	}
+	// Perform requested fixes. After starting degraded, that happens once mealie has been reached.
+	if cfg.mode == modeServer && cfg.fixes.requested() && reached {
+		if report := performFixes(cfg, mealie); report.Status == fixStatusFailed {
+			fatal("failed to run fixes, see the fix report")
+		}
+	}
+	reload := &reloader{
+		configFile:         configFile,
+		fromFile:           fromFile,
+		noUpdates:          noUpdates,
+		pandoc:             pandoc,
+		htmlAttrs:          htmlAttrs,
+		mealie:             mealie,
+		grpcServer:         grpcServer,
+		quitAssignmentLoop: quitAssignmentLoop,
+	}
+	go func() {
+		for range signalReload {
+			slog.Info("caught signal, reloading", "signal", syscall.SIGHUP.String())
+			if err := api.ReloadTLS(); err != nil {
+				slog.Error("failed to reload tls certificate, keeping the old one", "error", err)
+			}
+			if err := reload.reload(); err != nil {
+				slog.Error("failed to reload configuration, keeping the old one", "error", err)
+			}
+		}
+	}()
+
 	// Block until we are asked to quit.
 	<-quit
 
-	if quitAssignmentLoop != nil {
-		quitAssignmentLoop <- true
+	reload.stopAssignments()
+	// Send spans that have not been exported yet.
+	ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.WarnContext(ctx, "failed to flush spans", "error", err)
+	}
+}
+
+// Connect to mealie, retrying for as long as the startup grace period lasts. Return the client and
+// the group that the token belongs to. The client is returned even if no connection can be
+// established.
+func connectToMealie(cfg config) (*mealieclient.Client, string, error) {
+	if cfg.retrievalLimit > 0 {
+		slog.Info("limiting parallel retrieval", "recipes", cfg.retrievalLimit)
+	}
+
+	pagination := mealieclient.Pagination{PerPage: cfg.pageSize, MaxPages: cfg.maxPages}
+	mealie := mealieclient.New(
+		cfg.mealieRetrievalURL, cfg.mealieToken, cfg.retrievalLimit, pagination,
+	)
+	mealie.SetRetry(cfg.mealieRetry)
+	if err := mealie.SetTransport(cfg.mealieTransport); err != nil {
+		fatal("failed to configure connection to mealie", "error", err)
+	}
+	if cfg.mealieTransport.InsecureSkipVerify {
+		slog.Warn("not verifying the certificate of mealie, connections can be intercepted")
+	}
+	works, try := false, 1
+	var group string
+	for !works && try <= cfg.startupGraceSecs {
+		var err error
+		group, err = mealie.Check()
+		if err != nil {
+			slog.Warn(
+				"cannot connect to mealie, retrying every 1s",
+				"retriesLeft", cfg.startupGraceSecs-try, "error", err,
+			)
+			time.Sleep(time.Second)
+		}
+		works = err == nil
+		try++
+	}
+	if !works {
+		return mealie, "", errors.New("mealie connection cannot be established")
+	}
+	return mealie, group, nil
+}
+
+// Run the requested fixes, log their results, and report them if requested.
+func performFixes(cfg config, mealie *mealieclient.Client) fixReport {
+	report := runFixes(mealie, cfg.fixes)
+	for _, result := range report.Results {
+		if result.Error != "" {
+			slog.Error(
+				"fix failed", "fix", result.Fix, "recipes", result.Fixed, "error", result.Error,
+			)
+		} else {
+			slog.Info(
+				"fix result", "fix", result.Fix, "status", result.Status, "recipes", result.Fixed,
+			)
+		}
+	}
+	if cfg.fixes.resultTarget != "" {
+		if err := report.send(cfg.fixes.resultTarget); err != nil {
+			slog.Error("failed to report fix results", "error", err)
+		}
+	}
+	return report
+}
+
+// Time between attempts to connect to mealie after the startup grace period has passed.
+const degradedRetryInterval = 5 * time.Second
+
+// Retry connecting to mealie until a connection can be established and return the group that the
+// token belongs to.
+func retryMealie(mealie *mealieclient.Client) string {
+	for {
+		time.Sleep(degradedRetryInterval)
+		group, err := mealie.Check()
+		if err == nil {
+			return group
+		}
+		slog.Warn("cannot connect to mealie, still degraded", "error", err)
+	}
+}
+
+// Determine what the mealie token may do and exit if it cannot even read recipes.
+func verifyPermissions(cfg config, mealie *mealieclient.Client) {
+	permissions, err := mealie.CheckPermissions()
+	if err != nil {
+		fatal("failed to check permissions of mealie token", "error", err)
+	}
+	slog.Info(
+		"checked permissions of mealie token", "readRecipes", permissions.ReadRecipes,
+		"manageOrganisers", permissions.ManageOrganisers,
+	)
+	if !permissions.ReadRecipes {
+		fatal("mealie token cannot read recipes")
+	}
+	if !permissions.ManageOrganisers && cfg.adminToken != "" {
+		slog.Warn("mealie token cannot manage categories and tags, renaming them will fail")
+	}
+}
+
+// Leave degraded mode once mealie can be reached. Until then, the readiness endpoint reports that
+// mealie is unreachable, which keeps orchestrators from routing requests to us without restarting
+// us over and over while mealie is down, e.g. during upgrades. Afterwards, links in documents point
+// to the group of the token and the requested fixes are performed.
+func awaitMealie(
+	cfg config,
+	mealie *mealieclient.Client,
+	baseURL string,
+	generators []api.ResponseGenerator,
+	source *awaitedSource,
+) {
+	group := retryMealie(mealie)
+	slog.Info("connection to mealie established, leaving degraded mode")
+	verifyPermissions(cfg, mealie)
+	setMealieURL(generators, baseURL+"/g/"+group)
+	source.reached.Store(true)
+	if cfg.fixes.requested() {
+		if report := performFixes(cfg, mealie); report.Status == fixStatusFailed {
+			fatal("failed to run fixes, see the fix report")
+		}
+	}
+}
+
+// Point links in documents created by the generators to the given URL of mealie.
+func setMealieURL(generators []api.ResponseGenerator, mealieURL string) {
+	for _, gen := range generators {
+		switch gen := gen.(type) {
+		case *render.MarkdownGenerator:
+			gen.URL = mealieURL
+		case *render.EpubGenerator:
+			gen.URL = mealieURL
+		case *render.PDFGenerator:
+			gen.URL = mealieURL
+		case *render.HTMLGenerator:
+			gen.URL = mealieURL
+		case *render.DocxGenerator:
+			gen.URL = mealieURL
+		case *render.OdtGenerator:
+			gen.URL = mealieURL
+		case *render.Azw3Generator:
+			gen.URL = mealieURL
+		case *render.PaprikaGenerator:
+			gen.URL = mealieURL
+		}
+	}
+}
+
+var errMealieUnreachable = errors.New("mealie unreachable")
+
+// A recipe source that provides no recipes until mealie has been reached. This makes sure that no
+// documents are generated while the generators do not know the URL of mealie yet.
+type awaitedSource struct {
+	api.RecipeSource
+	reached atomic.Bool
+}
+
+func (s *awaitedSource) GetRecipes(
+	ctx context.Context,
+	queryParams map[string][]string,
+) ([]mealieclient.Recipe, []string, error) {
+	if !s.reached.Load() {
+		return nil, nil, errMealieUnreachable
+	}
+	return s.RecipeSource.GetRecipes(ctx, queryParams)
+}
+
+func (s *awaitedSource) GetSummaries(
+	ctx context.Context,
+	query *url.Values,
+) ([]mealieclient.Recipe, error) {
+	if !s.reached.Load() {
+		return nil, errMealieUnreachable
+	}
+	return s.RecipeSource.GetSummaries(ctx, query)
+}
+
+func (s *awaitedSource) SelectSlugs(
+	ctx context.Context,
+	queryParams map[string][]string,
+) ([]mealieclient.Slug, error) {
+	if !s.reached.Load() {
+		return nil, errMealieUnreachable
+	}
+	return s.RecipeSource.SelectSlugs(ctx, queryParams)
+}
+
+func (s *awaitedSource) GetRecipe(ctx context.Context, slug string) (mealieclient.Recipe, error) {
+	if !s.reached.Load() {
+		return mealieclient.Recipe{}, errMealieUnreachable
+	}
+	return s.RecipeSource.GetRecipe(ctx, slug)
+}
+
+func (s *awaitedSource) GetMedia(
+	ctx context.Context,
+	uuid string,
+	filename string,
+	middle string,
+) (mealieclient.MediaDownload, error) {
+	if !s.reached.Load() {
+		return mealieclient.MediaDownload{}, errMealieUnreachable
+	}
+	return s.RecipeSource.GetMedia(ctx, uuid, filename, middle)
+}
+
+// Ask a background loop to stop, which waits for whatever it is running, but for at most timeout.
+func stopLoop(quit chan<- bool, timeout time.Duration) {
+	select {
+	case quit <- true:
+	case <-time.After(timeout):
+		slog.Warn("background export did not finish in time", "timeout", timeout.String())
 	}
 }
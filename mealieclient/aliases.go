@@ -0,0 +1,91 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// Query parameters that are shorthands for parts of mealie's queryFilter. They are translated
+// before the query is forwarded to mealie.
+const (
+	// TagParam keeps only recipes with the given tag, identified by name or slug.
+	TagParam = "tag"
+	// CategoryParam keeps only recipes with the given category, identified by name or slug.
+	CategoryParam = "category"
+	// MinRatingParam keeps only recipes rated at least the given value. It is what `rating>=4`
+	// looks like after the query string has been parsed.
+	MinRatingParam = "rating>"
+	// MaxRatingParam keeps only recipes rated at most the given value. It is what `rating<=2`
+	// looks like after the query string has been parsed.
+	MaxRatingParam = "rating<"
+)
+
+var aliasParams = []string{TagParam, CategoryParam, MinRatingParam, MaxRatingParam}
+
+// Replace all shorthands in the query parameters by a queryFilter that mealie understands. They are
+// combined with each other and with any queryFilter in the query via AND. Organisers match by name
+// or slug, e.g. `tag=vegan` becomes
+// `(tags.name CONTAINS ALL ["vegan"] OR tags.slug CONTAINS ALL ["vegan"])`.
+func expandAliases(queryParams map[string][]string) (map[string][]string, error) {
+	conditions := []string{}
+	for _, key := range []string{TagParam, CategoryParam} {
+		attribute := map[string]string{TagParam: "tags", CategoryParam: "recipeCategory"}[key]
+		for _, value := range queryParams[key] {
+			value = strings.TrimSpace(value)
+			if value == "" || strings.ContainsAny(value, `"'`) {
+				return nil, &QueryError{
+					Param: key, Reason: "must be a non-empty name or slug without quotes",
+				}
+			}
+			conditions = append(conditions, fmt.Sprintf(
+				`(%[1]s.name CONTAINS ALL ["%[2]s"] OR %[1]s.slug CONTAINS ALL ["%[2]s"])`,
+				attribute, value,
+			))
+		}
+	}
+	for _, key := range []string{MinRatingParam, MaxRatingParam} {
+		operator := map[string]string{MinRatingParam: ">=", MaxRatingParam: "<="}[key]
+		for _, value := range queryParams[key] {
+			rating, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || rating < 0 || rating > 5 {
+				return nil, &QueryError{
+					Param:  "rating" + operator,
+					Reason: "must be a number from 0 to 5 but is " + value,
+				}
+			}
+			conditions = append(conditions, fmt.Sprintf("rating %s %g", operator, rating))
+		}
+	}
+	if len(conditions) == 0 {
+		return queryParams, nil
+	}
+
+	expanded := maps.Clone(queryParams)
+	for _, key := range aliasParams {
+		delete(expanded, key)
+	}
+	for _, filter := range queryParams["queryFilter"] {
+		conditions = append(conditions, "("+filter+")")
+	}
+	expanded["queryFilter"] = []string{strings.Join(conditions, " AND ")}
+	return expanded, nil
+}
@@ -0,0 +1,67 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"strings"
+	"unicode"
+)
+
+// IncludeArchivedParam includes recipes in the archive category, which are excluded by default.
+// See SetArchiveCategory.
+const IncludeArchivedParam = "includeArchived"
+
+// SetArchiveCategory sets the name of the category of archived recipes. Recipes in that category
+// are excluded unless requested explicitly, either via IncludeArchivedParam or by selecting that
+// category. An empty name disables archiving.
+func (m *Client) SetArchiveCategory(name string) {
+	m.archiveCategory = name
+}
+
+// An approximation of the slug that mealie derives from a name.
+func slugify(name string) string {
+	slug := strings.Builder{}
+	dash := false
+	for _, char := range strings.ToLower(name) {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			if dash && slug.Len() != 0 {
+				slug.WriteRune('-')
+			}
+			slug.WriteRune(char)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// Exclude the archive category unless the query requests archived recipes explicitly.
+func (m *Client) excludeArchived(queryParams map[string][]string, sel *selection) {
+	if m.archiveCategory == "" || sel.includeArchived {
+		return
+	}
+	for _, value := range queryParams["categories"] {
+		if strings.EqualFold(value, m.archiveCategory) || value == slugify(m.archiveCategory) {
+			return
+		}
+	}
+	sel.excludeCategories = append(
+		sel.excludeCategories, strings.ToLower(collapseWhitespace(m.archiveCategory)),
+	)
+}
@@ -0,0 +1,59 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// The number of exports that may read from mealie concurrently. Modifications need all of them.
+const maxConcurrentReads = 1 << 16
+
+// Coordinate reads and writes of mealie data so that exports see a consistent state. Exports take
+// one unit of the semaphore, modifications take all of them. The semaphore is fair, which is why
+// modifications are not starved by a steady stream of exports.
+type coordinator struct {
+	sem *semaphore.Weighted
+}
+
+func newCoordinator() coordinator {
+	return coordinator{sem: semaphore.NewWeighted(maxConcurrentReads)}
+}
+
+func (c coordinator) acquire(ctx context.Context, weight int64, what string) (func(), error) {
+	if err := c.sem.Acquire(ctx, weight); err != nil {
+		return nil, fmt.Errorf("failed to obtain %s access to mealie data: %s", what, err.Error())
+	}
+	return func() { c.sem.Release(weight) }, nil
+}
+
+// Shared blocks until no modification of mealie data is in progress and prevents any from starting
+// until the returned function has been called. Use it to retrieve a consistent snapshot.
+func (m *Client) Shared(ctx context.Context) (func(), error) {
+	return m.coordinator.acquire(ctx, 1, "shared")
+}
+
+// Exclusive blocks until no other export or modification of mealie data is in progress and
+// prevents any from starting until the returned function has been called. Use it to modify data.
+// Do not call GetRecipes while holding exclusive access.
+func (m *Client) Exclusive(ctx context.Context) (func(), error) {
+	return m.coordinator.acquire(ctx, maxConcurrentReads, "exclusive")
+}
@@ -0,0 +1,219 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DietParam selects only recipes that follow a diet, e.g. "vegan", identified by its name. If it
+// is given several times, recipes have to follow all of the diets.
+const DietParam = "diet"
+
+// Diet describes how to tell whether a recipe follows a diet from its tags, categories, and
+// ingredients. Keywords match the start of any word in an ingredient, ignoring case, see
+// ContainsWordStart.
+type Diet struct {
+	// Recipes with any of these tags follow the diet regardless of their ingredients.
+	Tags []string `json:"tags"`
+	// Recipes with any of these tags or categories do not follow the diet.
+	ExcludeTags       []string `json:"excludeTags"`
+	ExcludeCategories []string `json:"excludeCategories"`
+	// Recipes with an ingredient matching any of these keywords do not follow the diet, e.g.
+	// "chicken" for a vegetarian diet.
+	ExcludeIngredients []string `json:"excludeIngredients"`
+	// Ingredients matching any of these keywords never exclude a recipe, e.g. "peanut butter" for a
+	// vegan diet that excludes "butter".
+	AllowIngredients []string `json:"allowIngredients"`
+}
+
+// Diets map the names of diets to their rules.
+type Diets map[string]Diet
+
+// DefaultDiets are the diets known without any configuration. Their keywords are English.
+var DefaultDiets = Diets{
+	"vegetarian": {
+		Tags:               []string{"vegetarian", "vegan"},
+		ExcludeIngredients: meatKeywords,
+		AllowIngredients:   []string{"vegetarian", "vegan", "meatless", "plant-based"},
+	},
+	"vegan": {
+		Tags: []string{"vegan"},
+		ExcludeIngredients: append(slices.Clone(meatKeywords),
+			"milk", "butter", "cream", "cheese", "yogurt", "yoghurt", "egg", "honey", "ghee",
+			"parmesan", "mozzarella", "ricotta", "feta", "mascarpone", "quark", "mayonnaise",
+		),
+		AllowIngredients: []string{
+			"vegan", "plant-based", "dairy-free", "eggplant", "butternut", "peanut butter",
+			"almond butter", "nut butter", "cocoa butter", "cream of tartar", "coconut milk",
+			"coconut cream", "oat milk", "soy milk", "almond milk", "rice milk",
+		},
+	},
+	"gluten-free": {
+		Tags: []string{"gluten-free", "gluten free"},
+		ExcludeIngredients: []string{
+			"wheat", "flour", "barley", "rye", "spelt", "semolina", "couscous", "bulgur", "pasta",
+			"spaghetti", "noodle", "bread", "panko", "seitan", "soy sauce", "beer", "malt",
+		},
+		AllowIngredients: []string{
+			"gluten-free", "gluten free", "rice flour", "almond flour", "coconut flour",
+			"chickpea flour", "potato flour", "corn flour", "rice noodle", "tamari",
+		},
+	},
+}
+
+var meatKeywords = []string{
+	"meat", "beef", "pork", "veal", "lamb", "mutton", "chicken", "turkey", "duck", "goose",
+	"venison", "bacon", "ham", "sausage", "salami", "prosciutto", "chorizo", "pancetta", "lard",
+	"gelatin", "fish", "salmon", "tuna", "cod", "anchov", "sardine", "shrimp", "prawn", "crab",
+	"lobster", "mussel", "clam", "oyster", "squid", "octopus",
+}
+
+// Validate ensures that every diet has a name and at least one rule, and that no keyword is empty.
+func (d Diets) Validate() error {
+	for name, diet := range d {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("diets need a name")
+		}
+		if len(diet.Tags)+len(diet.ExcludeTags)+len(diet.ExcludeCategories)+
+			len(diet.ExcludeIngredients) == 0 {
+			return fmt.Errorf("diet %s has no rules", name)
+		}
+		all := slices.Concat(
+			diet.Tags, diet.ExcludeTags, diet.ExcludeCategories,
+			diet.ExcludeIngredients, diet.AllowIngredients,
+		)
+		for _, value := range all {
+			if strings.TrimSpace(value) == "" {
+				return fmt.Errorf("diet %s has an empty tag, category, or keyword", name)
+			}
+		}
+	}
+	return nil
+}
+
+// SetDiets determines the diets used to evaluate DietParam. They extend the default diets,
+// replacing those with the same names.
+func (m *Client) SetDiets(diets Diets) {
+	m.diets = make(Diets, len(DefaultDiets)+len(diets))
+	for name, diet := range DefaultDiets {
+		m.diets[name] = diet
+	}
+	for name, diet := range diets {
+		m.diets[strings.ToLower(name)] = diet
+	}
+}
+
+func (m *Client) knownDiets() Diets {
+	if m.diets == nil {
+		return DefaultDiets
+	}
+	return m.diets
+}
+
+// The diets selected by the values of DietParam.
+func (d Diets) rules(names []string) ([]Diet, error) {
+	rules := make([]Diet, 0, len(names))
+	for _, name := range names {
+		found := false
+		for known, diet := range d {
+			if strings.EqualFold(known, name) {
+				rules = append(rules, diet)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown diet %s", name)
+		}
+	}
+	return rules, nil
+}
+
+// Whether the tags and categories of a recipe decide that it follows the diet. Return whether they
+// decide at all and, if so, the decision.
+func (d Diet) decidedByOrganisers(tags []Organiser, categories []Organiser) (bool, bool) {
+	if matchesAny(tags, lower(d.Tags)) {
+		return true, true
+	}
+	if matchesAny(tags, lower(d.ExcludeTags)) ||
+		matchesAny(categories, lower(d.ExcludeCategories)) {
+		return true, false
+	}
+	return false, false
+}
+
+// Whether the recipe follows the diet.
+func (d Diet) follows(recipe *Recipe) bool {
+	if decided, follows := d.decidedByOrganisers(recipe.Tags, recipe.Categories); decided {
+		return follows
+	}
+	for _, ingredient := range recipe.Ingredients {
+		texts := []string{ingredient.Text}
+		if ingredient.Food != nil {
+			texts = append(texts, ingredient.Food.Name)
+		}
+		for _, text := range texts {
+			if matchesKeyword(text, d.ExcludeIngredients) &&
+				!matchesKeyword(text, d.AllowIngredients) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func lower(names []string) []string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, strings.ToLower(collapseWhitespace(name)))
+	}
+	return result
+}
+
+func matchesKeyword(text string, keywords []string) bool {
+	return slices.ContainsFunc(keywords, func(k string) bool { return ContainsWordStart(text, k) })
+}
+
+// ContainsWordStart determines whether the keyword starts any word in the text, ignoring case, e.g.
+// "nut" is contained in "Pine nuts" and "nutmeg" but not in "walnuts". Words are delimited by any
+// character that is neither a letter nor a digit.
+func ContainsWordStart(text string, keyword string) bool {
+	text = strings.ToLower(text)
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	if keyword == "" {
+		return false
+	}
+	for offset := 0; offset < len(text); {
+		idx := strings.Index(text[offset:], keyword)
+		if idx == -1 {
+			return false
+		}
+		idx += offset
+		previous, _ := utf8.DecodeLastRuneInString(text[:idx])
+		if idx == 0 || (!unicode.IsLetter(previous) && !unicode.IsDigit(previous)) {
+			return true
+		}
+		offset = idx + 1
+	}
+	return false
+}
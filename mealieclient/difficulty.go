@@ -0,0 +1,80 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Difficulties range from MinDifficulty to MaxDifficulty.
+const (
+	MinDifficulty = 1
+	MaxDifficulty = 5
+)
+
+// Durations such as "1 hour 30 minutes", "1h30min", or "PT1H30M". Mealie stores times as free text.
+var durationRegex = regexp.MustCompile(
+	`(?i)(\d+(?:[.,]\d+)?)\s*(days?|d|hours?|hrs?|h|minutes?|mins?|m)?\b`,
+)
+
+// Numbers following a unit directly, e.g. in "PT1H30M", are not separated by a word boundary.
+var unitFollowedByNumberRegex = regexp.MustCompile(`([a-zA-Z])(\d)`)
+
+// Minutes needed according to a duration as stored by mealie. Zero if unknown.
+func parseMinutes(duration string) float64 {
+	duration = unitFollowedByNumberRegex.ReplaceAllString(duration, "$1 $2")
+	minutes := 0.0
+	for _, match := range durationRegex.FindAllStringSubmatch(duration, -1) {
+		value, err := strconv.ParseFloat(strings.ReplaceAll(match[1], ",", "."), 64)
+		if err != nil {
+			continue
+		}
+		switch unit := strings.ToLower(match[2]); {
+		case strings.HasPrefix(unit, "d"):
+			minutes += value * 24 * 60 //nolint:mnd
+		case strings.HasPrefix(unit, "h"):
+			minutes += value * 60 //nolint:mnd
+		default:
+			minutes += value
+		}
+	}
+	return minutes
+}
+
+// Map a value to points depending on the number of thresholds it exceeds.
+func points(value float64, thresholds ...float64) int {
+	result := 0
+	for _, threshold := range thresholds {
+		if value > threshold {
+			result++
+		}
+	}
+	return result
+}
+
+// Difficulty estimates how hard a recipe is to cook, ranging from MinDifficulty to MaxDifficulty.
+// Mealie does not track difficulty. Thus, it is derived from the number of ingredients, the number
+// of steps, and the total time.
+func (r *Recipe) Difficulty() int {
+	total := points(float64(len(r.Ingredients)), 5, 10, 15) //nolint:mnd
+	total += points(float64(len(r.Instructions)), 3, 6, 10) //nolint:mnd
+	total += points(parseMinutes(r.TotalTime), 30, 60, 120) //nolint:mnd
+	return min(MinDifficulty+(total+1)/2, MaxDifficulty)    //nolint:mnd
+}
@@ -0,0 +1,228 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// The parser that mealie shall use for ingredients. It works without any external service.
+const ingredientParser = "nlp"
+
+// An ingredient as mealie stores it. Fields that we do not touch are retained as they are.
+type rawIngredient map[string]any
+
+// Structured ingredients have a quantity, unit, or food. Unstructured ones only have a note.
+func (i rawIngredient) structured() bool {
+	quantity, _ := i["quantity"].(float64)
+	return i["unit"] != nil || i["food"] != nil || quantity != 0
+}
+
+// The text of an unstructured ingredient.
+func (i rawIngredient) text() string {
+	for _, key := range []string{"note", "display", "originalText"} {
+		if text, _ := i[key].(string); strings.TrimSpace(text) != "" {
+			return strings.TrimSpace(text)
+		}
+	}
+	return ""
+}
+
+type recipeWithRawIngredients struct {
+	Slug        string          `json:"slug"`
+	Ingredients []rawIngredient `json:"recipeIngredient"`
+	Settings    map[string]any  `json:"settings"`
+}
+
+type ingredientsForParsing struct {
+	Parser      string   `json:"parser"`
+	Ingredients []string `json:"ingredients"`
+}
+
+type parsedIngredient struct {
+	Input      string        `json:"input"`
+	Ingredient rawIngredient `json:"ingredient"`
+}
+
+type ingredientsForPatching struct {
+	Ingredients []rawIngredient `json:"recipeIngredient"`
+	Settings    map[string]any  `json:"settings"`
+}
+
+// Units and foods that have been created while parsing ingredients, by kind and lowercase name.
+// This avoids creating the same one twice when recipes are processed in parallel.
+type createdOrganisers struct {
+	lock sync.Mutex
+	ids  map[string]map[string]any
+}
+
+var created = createdOrganisers{ids: map[string]map[string]any{}}
+
+// ParseIngredients sends the ingredients of a recipe through mealie's ingredient parser and stores
+// the resulting quantities, units, and foods if none of its ingredients has any of them, which is
+// often the case for old imports. Units and foods that mealie does not know yet are created. The
+// original text is retained. It returns whether the recipe was updated.
+func (m *Client) ParseIngredients(ctx context.Context, slug string) (bool, error) {
+	if err := m.refuseWrites("store parsed ingredients"); err != nil {
+		return false, err
+	}
+	var recipe recipeWithRawIngredients
+	if err := m.sendJSON(ctx, "GET", "/api/recipes/"+slug, nil, &recipe); err != nil {
+		return false, err
+	}
+
+	inputs := []string{}
+	indices := []int{}
+	for idx, ingredient := range recipe.Ingredients {
+		if ingredient.structured() {
+			slog.InfoContext(
+				ctx, "skipping ingredient parsing, it is structured already", "slug", slug,
+			)
+			return false, nil
+		}
+		if text := ingredient.text(); text != "" {
+			inputs = append(inputs, text)
+			indices = append(indices, idx)
+		}
+	}
+	if len(inputs) == 0 {
+		return false, nil
+	}
+	slog.InfoContext(ctx, "parsing ingredients", "slug", slug, "ingredients", len(inputs))
+
+	var parsed []parsedIngredient
+	request := ingredientsForParsing{Parser: ingredientParser, Ingredients: inputs}
+	if err := m.sendJSON(ctx, "POST", "/api/parser/ingredients", request, &parsed); err != nil {
+		return false, err
+	}
+	if len(parsed) != len(inputs) {
+		return false, fmt.Errorf(
+			"parser returned %d ingredients for %d inputs", len(parsed), len(inputs),
+		)
+	}
+
+	for pos, result := range parsed {
+		ingredient := recipe.Ingredients[indices[pos]]
+		for _, kind := range []string{"unit", "food"} {
+			organiser, err := m.ensureOrganiser(ctx, kind, result.Ingredient[kind])
+			if err != nil {
+				return false, err
+			}
+			ingredient[kind] = organiser
+		}
+		ingredient["quantity"] = result.Ingredient["quantity"]
+		ingredient["note"] = result.Ingredient["note"]
+		ingredient["originalText"] = inputs[pos]
+	}
+
+	// Mealie shows only the notes of ingredients unless amounts are enabled.
+	if recipe.Settings == nil {
+		recipe.Settings = map[string]any{}
+	}
+	recipe.Settings["disableAmount"] = false
+
+	// Exports shall see the recipe either before or after the update.
+	release, err := m.Exclusive(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	patch := ingredientsForPatching{Ingredients: recipe.Ingredients, Settings: recipe.Settings}
+	if err := m.sendJSON(ctx, "PATCH", "/api/recipes/"+slug, patch, nil); err != nil {
+		return false, err
+	}
+	slog.InfoContext(ctx, "stored parsed ingredients", "slug", slug)
+	return true, nil
+}
+
+// Return a unit or food that mealie knows. The parser returns those it does not know without an ID.
+// Create them in that case. Return nil if there is no unit or food.
+func (m *Client) ensureOrganiser(ctx context.Context, kind string, value any) (any, error) {
+	organiser, _ := value.(map[string]any)
+	name, _ := organiser["name"].(string)
+	if organiser == nil || strings.TrimSpace(name) == "" {
+		return nil, nil
+	}
+	if id, _ := organiser["id"].(string); id != "" {
+		return organiser, nil
+	}
+
+	created.lock.Lock()
+	defer created.lock.Unlock()
+	key := kind + "/" + strings.ToLower(strings.TrimSpace(name))
+	if known, found := created.ids[key]; found {
+		return known, nil
+	}
+	slog.InfoContext(ctx, "creating ingredient data", "kind", kind, "name", name)
+	var result map[string]any
+	payload := map[string]string{"name": strings.TrimSpace(name)}
+	if err := m.sendJSON(ctx, "POST", "/api/"+kind+"s", payload, &result); err != nil {
+		return nil, fmt.Errorf("failed to create %s %s: %s", kind, name, err.Error())
+	}
+	created.ids[key] = result
+	return result, nil
+}
+
+// Send a request with payload as JSON body unless it is nil and decode the JSON response into
+// result unless it is nil.
+func (m *Client) sendJSON(
+	ctx context.Context, method string, path string, payload any, result any,
+) error {
+	var body io.Reader
+	if payload != nil {
+		content, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to convert to json: %s", err.Error())
+		}
+		body = bytes.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, m.url+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to construct request")
+	}
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	m.addAuth(req)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(content))
+	}
+	if result != nil {
+		if err := json.Unmarshal(content, result); err != nil {
+			return fmt.Errorf("failed to parse response: %s", err.Error())
+		}
+	}
+	return nil
+}
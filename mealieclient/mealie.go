@@ -0,0 +1,1063 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package mealieclient contains a client for the parts of the mealie REST API that we use.
+package mealieclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/image/webp"
+
+	"github.com/razziel89/mealie-addons/media"
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/tracing"
+)
+
+func collapseWhitespace(s string) string {
+	return strings.TrimSpace(strings.Join(strings.Fields(s), " "))
+}
+
+// Recipe is a mealie recipe. We only define those fields that we actually want to use.
+type Recipe struct {
+	ID           string        `json:"id"`
+	Slug         string        `json:"slug"`
+	Name         string        `json:"name"`
+	Servings     float32       `json:"recipeServings"`
+	TotalTime    string        `json:"totalTime"`
+	PrepTime     string        `json:"prepTime"`
+	PerformTime  string        `json:"performTime"`
+	Rating       float32       `json:"rating"`
+	Description  string        `json:"description"`
+	OrgURL       string        `json:"orgURL"`
+	Categories   []Organiser   `json:"recipeCategory"`
+	Tags         []Organiser   `json:"tags"`
+	Instructions []Instruction `json:"recipeInstructions"`
+	Ingredients  []Ingredient  `json:"recipeIngredient"`
+	Comments     []Comment     `json:"comments"`
+	Image        string        `json:"image"`
+	UpdatedAt    string        `json:"updatedAt"`
+	Nutrition    Nutrition     `json:"nutrition"`
+}
+
+func (r *Recipe) normalise() {
+	r.ID = collapseWhitespace(r.ID)
+	r.Name = collapseWhitespace(r.Name)
+	r.TotalTime = collapseWhitespace(r.TotalTime)
+	r.PrepTime = collapseWhitespace(r.PrepTime)
+	r.PerformTime = collapseWhitespace(r.PerformTime)
+	r.Description = collapseWhitespace(r.Description)
+	r.OrgURL = collapseWhitespace(r.OrgURL)
+	r.Image = collapseWhitespace(r.Image)
+	for _, category := range r.Categories {
+		category.normalise()
+	}
+	for _, tag := range r.Tags {
+		tag.normalise()
+	}
+	for _, instruction := range r.Instructions {
+		instruction.normalise()
+	}
+	for _, ingredient := range r.Ingredients {
+		ingredient.normalise()
+	}
+	for _, comment := range r.Comments {
+		comment.normalise()
+	}
+	r.Nutrition.normalise()
+}
+
+// Nutrition holds the nutritional values of a single serving of a recipe. Mealie stores them as
+// free text, usually a bare number. Values that are unknown are empty.
+type Nutrition struct {
+	Calories              string `json:"calories"`
+	FatContent            string `json:"fatContent"`
+	SaturatedFatContent   string `json:"saturatedFatContent"`
+	CarbohydrateContent   string `json:"carbohydrateContent"`
+	SugarContent          string `json:"sugarContent"`
+	FiberContent          string `json:"fiberContent"`
+	ProteinContent        string `json:"proteinContent"`
+	SodiumContent         string `json:"sodiumContent"`
+	CholesterolContent    string `json:"cholesterolContent"`
+	TransFatContent       string `json:"transFatContent"`
+	UnsaturatedFatContent string `json:"unsaturatedFatContent"`
+}
+
+func (n *Nutrition) normalise() {
+	n.Calories = collapseWhitespace(n.Calories)
+	n.FatContent = collapseWhitespace(n.FatContent)
+	n.SaturatedFatContent = collapseWhitespace(n.SaturatedFatContent)
+	n.CarbohydrateContent = collapseWhitespace(n.CarbohydrateContent)
+	n.SugarContent = collapseWhitespace(n.SugarContent)
+	n.FiberContent = collapseWhitespace(n.FiberContent)
+	n.ProteinContent = collapseWhitespace(n.ProteinContent)
+	n.SodiumContent = collapseWhitespace(n.SodiumContent)
+	n.CholesterolContent = collapseWhitespace(n.CholesterolContent)
+	n.TransFatContent = collapseWhitespace(n.TransFatContent)
+	n.UnsaturatedFatContent = collapseWhitespace(n.UnsaturatedFatContent)
+}
+
+// Instruction is a single step of a recipe.
+type Instruction struct {
+	Text string `json:"text"`
+}
+
+func (i *Instruction) normalise() {
+	i.Text = collapseWhitespace(i.Text)
+}
+
+// Ingredient is a single ingredient of a recipe as mealie displays it. Only structured ingredients
+// have a food.
+type Ingredient struct {
+	Text string `json:"display"`
+	Food *Food  `json:"food"`
+}
+
+func (i *Ingredient) normalise() {
+	i.Text = collapseWhitespace(i.Text)
+	if i.Food != nil {
+		i.Food.Name = collapseWhitespace(i.Food.Name)
+	}
+}
+
+// Food is what a structured ingredient consists of, e.g. "wheat flour".
+type Food struct {
+	Name string `json:"name"`
+}
+
+// Organiser is a category or a tag.
+type Organiser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+func (o *Organiser) normalise() {
+	o.Name = collapseWhitespace(o.Name)
+}
+
+// Comment is a comment that a user left on a recipe.
+type Comment struct {
+	Text string `json:"text"`
+	User User   `json:"user"`
+}
+
+func (c *Comment) normalise() {
+	c.Text = collapseWhitespace(c.Text)
+	c.User.normalise()
+}
+
+// User is the author of a comment.
+type User struct {
+	Name string `json:"username"`
+}
+
+func (u *User) normalise() {
+	u.Name = collapseWhitespace(u.Name)
+}
+
+type pagedResponse[T any] struct {
+	Items []T `json:"items"`
+	Pages int `json:"total_pages"`
+	Total int `json:"total"`
+}
+
+type userResponse struct {
+	Name      string `json:"username"`
+	Group     string `json:"group"`
+	Household string `json:"household"`
+}
+
+func (u userResponse) String() string {
+	return fmt.Sprintf("%s (group=%s, household=%s)", u.Name, u.Group, u.Household)
+}
+
+// Slug identifies a recipe.
+type Slug struct {
+	ID          string      `json:"id"`
+	Slug        string      `json:"slug"`
+	Name        string      `json:"name"`
+	UpdatedAt   string      `json:"updatedAt"`
+	Categories  []Organiser `json:"recipeCategory"`
+	Tags        []Organiser `json:"tags"`
+	Image       string      `json:"image"`
+	TotalTime   string      `json:"totalTime"`
+	PrepTime    string      `json:"prepTime"`
+	PerformTime string      `json:"performTime"`
+}
+
+// Summary converts the slug into a recipe that lacks all details that slugs do not have, e.g.
+// ingredients and instructions.
+func (s *Slug) Summary() Recipe {
+	recipe := Recipe{
+		ID:          s.ID,
+		Slug:        s.Slug,
+		Name:        s.Name,
+		TotalTime:   s.TotalTime,
+		PrepTime:    s.PrepTime,
+		PerformTime: s.PerformTime,
+		Categories:  s.Categories,
+		Tags:        s.Tags,
+		Image:       s.Image,
+		UpdatedAt:   s.UpdatedAt,
+	}
+	recipe.normalise()
+	return recipe
+}
+
+// Client talks to a mealie instance.
+type Client struct {
+	url         string
+	token       string
+	limiter     chan bool
+	pagination  Pagination
+	coordinator coordinator
+	seasons     Seasons
+	// Nil means that only the default diets are known.
+	diets        Diets
+	savedQueries SavedQueries
+	// Recipes in this category are excluded unless requested explicitly.
+	archiveCategory string
+	// Whether to refuse to modify data in mealie.
+	readOnly bool
+	// What the token may do, nil if unknown. It may be determined while requests are served, e.g.
+	// after starting degraded.
+	permissions atomic.Pointer[Permissions]
+	retry       Retry
+	// Sends all requests to mealie, see SetTransport.
+	httpClient *http.Client
+	// defaultQuery map[string][]string
+}
+
+// DefaultPerPage is the default number of items requested per page from mealie's API.
+const DefaultPerPage = 200
+
+// Pagination determines how paginated endpoints of mealie's API are retrieved.
+type Pagination struct {
+	// PerPage is the number of items requested per page. If not positive, DefaultPerPage is used.
+	PerPage int
+	// MaxPages is the maximum number of pages retrieved per request. If not positive, there is no
+	// limit.
+	MaxPages int
+}
+
+// New creates a client for the mealie instance at url that authenticates with token. If
+// retrievalLimit is positive, at most that many recipes will be retrieved in parallel.
+func New(url string, token string, retrievalLimit int, pagination Pagination) *Client {
+	var limiter chan bool
+	if retrievalLimit > 0 {
+		limiter = make(chan bool, retrievalLimit)
+	}
+	if pagination.PerPage <= 0 {
+		pagination.PerPage = DefaultPerPage
+	}
+	return &Client{
+		url:         url,
+		token:       token,
+		limiter:     limiter,
+		pagination:  pagination,
+		coordinator: newCoordinator(),
+		retry:       DefaultRetry,
+		httpClient:  tracing.HTTPClient,
+	}
+}
+
+// Retrieve all pages of a paginated endpoint of mealie's API. The path is that of the endpoint and
+// "what" describes the retrieved items for logging. Fail if there are more pages than permitted.
+func getPages[T any](
+	ctx context.Context,
+	m *Client,
+	path string,
+	query *url.Values,
+	what string,
+) ([]T, error) {
+	slog.DebugContext(ctx, "getting paginated items", "what", what)
+
+	if query == nil {
+		query = &url.Values{}
+	}
+
+	page := 1
+	lastPage := 1
+	total := 0
+	var items []T
+
+	for page <= lastPage {
+		query.Set("page", fmt.Sprint(page))
+		query.Set("perPage", fmt.Sprint(m.pagination.PerPage))
+
+		var pagedResponse pagedResponse[T]
+
+		url := m.url + path + "?" + query.Encode()
+		slog.DebugContext(ctx, "getting from mealie", "url", url)
+		resp, body, err := m.get(ctx, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		summary.From(ctx).AddFetchedBytes(len(body))
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		}
+		err = json.Unmarshal(body, &pagedResponse)
+		if err != nil {
+			slog.DebugContext(ctx, "failed to parse body", "body", string(body))
+			return nil, err
+		}
+		if page > 1 && pagedResponse.Pages != lastPage {
+			slog.WarnContext(ctx,
+				"number of pages changed during retrieval, so did the data",
+				"what", what, "pagesBefore", lastPage, "pagesAfter", pagedResponse.Pages,
+			)
+		}
+		lastPage = pagedResponse.Pages
+		total = pagedResponse.Total
+		if m.pagination.MaxPages > 0 && lastPage > m.pagination.MaxPages {
+			return nil, fmt.Errorf(
+				"mealie reports %d pages of %s with %d each but at most %d may be retrieved",
+				lastPage, what, m.pagination.PerPage, m.pagination.MaxPages,
+			)
+		}
+		items = append(items, pagedResponse.Items...)
+		slog.DebugContext(ctx,
+			"retrieved page",
+			"what", what, "items", len(pagedResponse.Items), "page", page, "pages", lastPage,
+		)
+		if len(pagedResponse.Items) == 0 && page < lastPage {
+			slog.WarnContext(
+				ctx, "mealie returned an empty page before the last one, stopping", "what", what,
+			)
+			break
+		}
+
+		page++
+	}
+
+	if total > 0 && total != len(items) {
+		slog.WarnContext(ctx,
+			"mealie reported a different number of items than were retrieved",
+			"what", what, "reported", total, "retrieved", len(items),
+		)
+	}
+	slog.InfoContext(ctx, "retrieved items", "what", what, "items", len(items))
+	return items, nil
+}
+
+// GetSlugs retrieves the slugs of all recipes matching the given query.
+func (m *Client) GetSlugs(ctx context.Context, query *url.Values) ([]Slug, error) {
+	return getPages[Slug](ctx, m, "/api/recipes", query, "slugs")
+}
+
+// GetSummaries retrieves all recipes matching the given query. Mealie provides only a summary of
+// each recipe this way, which means that instructions, ingredients, and comments are missing. The
+// summaries are a consistent snapshot, see Shared.
+func (m *Client) GetSummaries(ctx context.Context, query *url.Values) ([]Recipe, error) {
+	release, err := m.Shared(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	summaries, err := getPages[Recipe](ctx, m, "/api/recipes", query, "recipe summaries")
+	for idx := range summaries {
+		summaries[idx].normalise()
+	}
+	return summaries, err
+}
+
+// ErrRecipeNotFound is returned when mealie does not know a recipe.
+var ErrRecipeNotFound = errors.New("recipe not found")
+
+// GetRecipe retrieves the full details of a single recipe. Mealie accepts the ID of a recipe in
+// place of its slug.
+func (m *Client) GetRecipe(ctx context.Context, slug string) (Recipe, error) {
+	var recipe Recipe
+	slog.DebugContext(ctx, "getting from mealie", "url", m.url+"/api/recipes/"+slug)
+	resp, body, err := m.get(ctx, m.url+"/api/recipes/"+slug, nil)
+	if err != nil {
+		return recipe, err
+	}
+	summary.From(ctx).AddFetchedBytes(len(body))
+	if resp.StatusCode == http.StatusNotFound {
+		return recipe, fmt.Errorf("slug %s: %w", slug, ErrRecipeNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return recipe, fmt.Errorf(
+			"slug %s: unexpected status code %d: %s", slug, resp.StatusCode, string(body),
+		)
+	}
+	err = json.Unmarshal(body, &recipe)
+	if err != nil {
+		slog.DebugContext(ctx, "failed to parse body", "body", string(body))
+		return recipe, err
+	}
+	return recipe, err
+}
+
+// SelectSlugs retrieves the slugs of all recipes that GetRecipes would retrieve for the given query
+// parameters. No recipe details are retrieved.
+func (m *Client) SelectSlugs(ctx context.Context, queryParams map[string][]string) ([]Slug, error) {
+	slugs, _, err := m.selectAll(ctx, queryParams)
+	return slugs, err
+}
+
+// Select the slugs of a single query without any saved query. Return the selection, too, since
+// some parts of it can be evaluated only once recipe details are known.
+func (m *Client) selectSlugs(
+	ctx context.Context, queryParams map[string][]string,
+) ([]Slug, selection, error) {
+	queryParams, err := expandAliases(queryParams)
+	if err != nil {
+		return nil, selection{}, err
+	}
+	queryParams, selection, err := splitSelection(queryParams)
+	if err != nil {
+		return nil, selection, err
+	}
+	m.excludeArchived(queryParams, &selection)
+	if selection.dietRules, err = m.knownDiets().rules(selection.diets); err != nil {
+		return nil, selection, err
+	}
+	if selection.season != "" {
+		selection.seasonTags, err = m.seasons.tags(selection.season, time.Now())
+		if err != nil {
+			return nil, selection, err
+		}
+	}
+
+	// Build the raw query string for later use.
+	query := url.Values{}
+	for key, values := range queryParams {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	slog.DebugContext(ctx, "built query string", "query", query.Encode())
+
+	// We start with page 1 and then paginate.
+	slugs, err := m.GetSlugs(ctx, &query)
+	if err != nil {
+		return nil, selection, err
+	}
+	// Avoid retrieving the details of recipes that would be discarded anyway.
+	return selection.apply(ctx, slugs), selection, nil
+}
+
+// Time to wait before retrying recipes that could not be retrieved, giving mealie time to recover.
+const retryBackoff = 2 * time.Second
+
+// GetRecipes retrieves the full details of all recipes matching the given query parameters. The
+// recipes are a consistent snapshot, see Shared. Recipes that are modified, renamed, or deleted in
+// mealie while they are being retrieved are reported via human-readable warnings. Deleted recipes
+// are skipped. See ExcludeTagsParam and related constants for query parameters that are not
+// forwarded to mealie but evaluated by us.
+func (m *Client) GetRecipes(
+	ctx context.Context,
+	queryParams map[string][]string,
+) ([]Recipe, []string, error) {
+	slog.InfoContext(ctx, "retrieving recipes")
+	ctx, span := tracing.Start(ctx, "retrieve recipes")
+	recipes, warnings, err := m.getRecipes(ctx, queryParams)
+	span.SetAttributes(
+		attribute.Int("recipes", len(recipes)), attribute.Int("warnings", len(warnings)),
+	)
+	tracing.End(span, err)
+	return recipes, warnings, err
+}
+
+func (m *Client) getRecipes(
+	ctx context.Context,
+	queryParams map[string][]string,
+) ([]Recipe, []string, error) {
+	// Make sure that no modifications happen while we retrieve recipes. Otherwise, the export might
+	// contain recipes from before and after a round of assignments.
+	release, err := m.Shared(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	// First, we retrieve the recipe slugs. They are a snapshot of the state of the recipes when the
+	// export started. Some parts of the selection can be evaluated only once recipe details are
+	// known.
+	slugs, selections, err := m.selectAll(ctx, queryParams)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Then, we retrieve the information about all the recipes. We send many requests in parallel to
+	// speed up the process.
+	wg := sync.WaitGroup{}
+	wg.Add(len(slugs))
+	recipes := make([]Recipe, len(slugs))
+	found := make([]bool, len(slugs))
+	warnings := make([]string, len(slugs))
+	errs := make([]error, len(slugs))
+	retrieved := atomic.Int64{}
+	summary.From(ctx).Stage("retrieving %d recipes", len(slugs))
+
+	fetch := func(id int) {
+		ctx, span := tracing.Start(
+			ctx, "retrieve recipe", attribute.String("slug", slugs[id].Slug),
+		)
+		recipe, warning, err := m.getSnapshotRecipe(ctx, slugs[id])
+		tracing.End(span, err)
+		if err == nil {
+			recipe.normalise()
+			recipes[id] = recipe
+			found[id] = recipe.ID != ""
+			warnings[id] = warning
+			summary.From(ctx).Stage("retrieved %d/%d recipes", retrieved.Add(1), len(slugs))
+		}
+		errs[id] = err
+	}
+
+	for idx := range slugs {
+		// Avoid loop pointer weirdness.
+		id := idx
+		// Retrieve all recipes in parallel. Let'ssee if this works.
+		go func() {
+			if m.limiter != nil {
+				m.limiter <- true
+			}
+			fetch(id)
+			wg.Done()
+			if m.limiter != nil {
+				<-m.limiter
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Slow mealie instances sporadically fail to answer some requests. Each request is retried
+	// already, see SetRetry. Should mealie have been overwhelmed for longer than that, retry the
+	// recipes that still failed once more, one after the other, to avoid failing the entire export.
+	failed := []int{}
+	for idx, err := range errs {
+		if err != nil {
+			failed = append(failed, idx)
+		}
+	}
+	if len(failed) != 0 {
+		slog.WarnContext(ctx,
+			"retrying failed recipes", "recipes", len(failed), "backoff", retryBackoff.String(),
+		)
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(retryBackoff):
+		}
+	}
+	for _, id := range failed {
+		slog.WarnContext(ctx, "retrying recipe", "recipe", describe(slugs[id]), "error", errs[id])
+		fetch(id)
+	}
+
+	// Drop deleted recipes, recipes that no query selects after all, and empty warnings while
+	// retaining the order. The number of recipes of every query is limited last.
+	result := make([]Recipe, 0, len(recipes))
+	deselected := 0
+	counts := map[*selection]int{}
+	for idx, recipe := range recipes {
+		if !found[idx] {
+			continue
+		}
+		kept := false
+		for _, sel := range selections[idx] {
+			if sel.keeps(&recipe) && (sel.maxRecipes == 0 || counts[sel] < sel.maxRecipes) {
+				counts[sel]++
+				kept = true
+			}
+		}
+		if !kept {
+			deselected++
+			continue
+		}
+		result = append(result, recipe)
+	}
+	if deselected != 0 {
+		slog.InfoContext(
+			ctx, "selected recipes by difficulty, diet, and limit",
+			"selected", len(result), "recipes", len(result)+deselected,
+		)
+	}
+	warnings = slices.DeleteFunc(warnings, func(warning string) bool { return warning == "" })
+	for _, warning := range warnings {
+		slog.WarnContext(ctx, warning)
+	}
+
+	return result, warnings, errors.Join(errs...)
+}
+
+// Retrieve the recipe that a slug from a snapshot refers to and detect whether it has changed since
+// the snapshot was taken. Return a warning describing the change if so. If the recipe has been
+// deleted, return an empty recipe and a warning.
+func (m *Client) getSnapshotRecipe(ctx context.Context, slug Slug) (Recipe, string, error) {
+	recipe, err := m.GetRecipe(ctx, slug.Slug)
+	if errors.Is(err, ErrRecipeNotFound) && slug.ID != "" {
+		// Renaming a recipe changes its slug but not its ID.
+		recipe, err = m.GetRecipe(ctx, slug.ID)
+	}
+	switch {
+	case errors.Is(err, ErrRecipeNotFound):
+		warning := fmt.Sprintf(
+			"recipe %s was deleted during the export and is missing", describe(slug),
+		)
+		return Recipe{}, warning, nil
+	case err != nil:
+		return Recipe{}, "", err
+	case recipe.Slug != slug.Slug:
+		warning := fmt.Sprintf(
+			"recipe %s was renamed to %s during the export", describe(slug), recipe.Name,
+		)
+		return recipe, warning, nil
+	case slug.UpdatedAt != "" && recipe.UpdatedAt != slug.UpdatedAt:
+		warning := fmt.Sprintf(
+			"recipe %s was modified during the export, its latest version is included",
+			describe(slug),
+		)
+		return recipe, warning, nil
+	}
+	return recipe, "", nil
+}
+
+func describe(slug Slug) string {
+	if slug.Name == "" {
+		return slug.Slug
+	}
+	return fmt.Sprintf("%s (%s)", slug.Name, slug.Slug)
+}
+
+// MediaDownload is a media file retrieved from mealie together with its mime type.
+type MediaDownload struct {
+	Content []byte
+	Mime    string
+}
+
+// GetMedia retrieves a media file belonging to a recipe.
+func (m *Client) GetMedia(
+	ctx context.Context,
+	uuid string,
+	filename string,
+	middle string,
+) (MediaDownload, error) {
+	slog.DebugContext(ctx, "retrieving media", "uuid", uuid, "filename", filename)
+
+	var extension string
+	filenameParts := strings.Split(filename, ".")
+	if len(filenameParts) >= 1 {
+		extension = strings.ToLower(filenameParts[len(filenameParts)-1])
+	}
+
+	url := fmt.Sprintf("%s/api/media/recipes/%s/%s/%s", m.url, uuid, middle, filename)
+	resp, content, err := m.get(ctx, url, http.Header{"Accept": {"image/*"}})
+	if err != nil {
+		return MediaDownload{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return MediaDownload{}, fmt.Errorf(
+			"unexpected status code %d: %s", resp.StatusCode, string(content),
+		)
+	}
+
+	data := MediaDownload{
+		Content: content,
+		Mime:    resp.Header.Get("Content-Type"),
+	}
+	var decodeErr error
+	if !strings.HasPrefix(data.Mime, "image/") {
+		slog.DebugContext(ctx, "mealie claims we received no image but we requested one, checking")
+		switch extension {
+		case "jpg":
+			_, decodeErr = jpeg.Decode(bytes.NewReader(data.Content))
+			extension = "jpeg"
+		case "jpeg":
+			_, decodeErr = jpeg.Decode(bytes.NewReader(data.Content))
+		case "webp":
+			_, decodeErr = webp.Decode(bytes.NewReader(data.Content))
+		case "heic", "heif":
+			if !media.IsHEIF(data.Content) {
+				decodeErr = fmt.Errorf("no heif image")
+			}
+		case "svg":
+			if !media.IsSVG(data.Content) {
+				decodeErr = fmt.Errorf("no svg image")
+			}
+		}
+	}
+	data.Mime = "image/" + extension
+	if extension == "svg" {
+		data.Mime = "image/svg+xml"
+	}
+	if decodeErr != nil {
+		return data, fmt.Errorf("failed to verify download as %s", data.Mime)
+	}
+
+	slog.DebugContext(ctx, "successfully retrieved media", "mimeType", data.Mime)
+	return data, nil
+}
+
+// ReuploadImage uploads the original image of a recipe again if mealie does not know that the
+// recipe has an image. It returns whether an upload took place.
+func (m *Client) ReuploadImage(
+	ctx context.Context,
+	slug string,
+) (bool, error) {
+	recipe, err := m.GetRecipe(ctx, slug)
+	if err != nil {
+		return false, err
+	}
+	if recipe.Image != "" {
+		slog.InfoContext(ctx, "skipping reupload of image", "slug", slug)
+		// In this case, the recipe does have an image assigned to it. No reupload is needed, then.
+		return false, nil
+	}
+	slog.InfoContext(ctx, "attempting reupload of image", "slug", slug)
+
+	// Download image first.
+	imageContent, found, err := m.downloadImage(ctx, recipe.ID, "original.webp")
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		// In this case, the recipe really does not have an image assigned to it.
+		slog.InfoContext(ctx, "there is no image", "slug", slug)
+		return false, nil
+	}
+	// In this case, the recipe has an image assigned even though the "image" property is null.
+	slog.InfoContext(ctx, "retrieved image", "slug", slug)
+
+	if err := m.uploadImage(ctx, slug, imageContent); err != nil {
+		return false, err
+	}
+	slog.InfoContext(ctx, "reuploaded image", "slug", slug)
+	return true, nil
+}
+
+// The smaller variants of recipe images that mealie generates on upload and shows as thumbnails.
+var thumbnailVariants = []string{"min-original.webp", "tiny-original.webp"}
+
+// RegenerateThumbnails uploads the original image of a recipe again if any of the smaller variants
+// of it is missing, which results in broken thumbnails. Mealie generates all variants on upload. It
+// returns whether an upload took place.
+func (m *Client) RegenerateThumbnails(
+	ctx context.Context,
+	slug string,
+) (bool, error) {
+	recipe, err := m.GetRecipe(ctx, slug)
+	if err != nil {
+		return false, err
+	}
+	if recipe.Image == "" {
+		// Recipes without images have no thumbnails. See ReuploadImage.
+		return false, nil
+	}
+
+	missing := []string{}
+	for _, variant := range thumbnailVariants {
+		_, found, err := m.downloadImage(ctx, recipe.ID, variant)
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			missing = append(missing, variant)
+		}
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+	slog.InfoContext(
+		ctx, "thumbnails are missing", "slug", slug, "thumbnails", strings.Join(missing, ", "),
+	)
+
+	imageContent, found, err := m.downloadImage(ctx, recipe.ID, "original.webp")
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		slog.InfoContext(ctx, "cannot regenerate thumbnails without an image", "slug", slug)
+		return false, nil
+	}
+	if err := m.uploadImage(ctx, slug, imageContent); err != nil {
+		return false, err
+	}
+	slog.InfoContext(ctx, "regenerated thumbnails", "slug", slug)
+	return true, nil
+}
+
+// Download a variant of the image of a recipe. Also report whether it exists.
+func (m *Client) downloadImage(
+	ctx context.Context,
+	recipeID string,
+	variant string,
+) ([]byte, bool, error) {
+	url := fmt.Sprintf("%s/api/media/recipes/%s/images/%s", m.url, recipeID, variant)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "image/*")
+	m.addAuth(req)
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	imageContent, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf(
+			"unexpected status code %d: %s", resp.StatusCode, string(imageContent),
+		)
+	}
+	return imageContent, true, resp.Body.Close()
+}
+
+// Upload a webp image for a recipe using multipart/form-data. Mealie generates all smaller
+// variants of it.
+func (m *Client) uploadImage(ctx context.Context, slug string, imageContent []byte) error {
+	if err := m.refuseWrites("upload images"); err != nil {
+		return err
+	}
+	// Exports shall see the recipe either before or after the upload.
+	release, err := m.Exclusive(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	// Prepare multipart/form-data input.
+	var uploadBuffer bytes.Buffer
+	multipartWriter := multipart.NewWriter(&uploadBuffer)
+	// Add the image file.
+	imageWriter, err := multipartWriter.CreateFormFile("image", "original.webp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(imageWriter, bytes.NewReader(imageContent))
+	if err != nil {
+		return err
+	}
+	extensionWriter, err := multipartWriter.CreateFormField("extension")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(extensionWriter, strings.NewReader("webp"))
+	if err != nil {
+		return err
+	}
+	// Close the multipart writer. Otherwise, the sent body would be incomplete.
+	err = multipartWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/api/recipes/%s/image", m.url, slug)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, &uploadBuffer)
+	if err != nil {
+		return err
+	}
+	// The content type header will also contain the multipart boundary.
+	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
+	m.addAuth(req)
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf(
+			"unexpected status code %d: %s", resp.StatusCode, string(body),
+		)
+	}
+	return nil
+}
+
+func (m *Client) addAuth(req *http.Request) {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.token))
+}
+
+// Check verifies that mealie can be reached with the configured token and returns the group of
+// the user the token belongs to.
+func (m *Client) Check() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second) //nolint:mnd
+	defer cancel()
+
+	user, err := m.self(ctx)
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "successful login", "user", user.String())
+	return strings.ToLower(user.Group), nil
+}
+
+// Ready verifies that mealie can still be reached with the configured token. Unlike Check, it
+// gives up quickly and logs nothing, which is why it suits frequent readiness probes.
+func (m *Client) Ready() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) //nolint:mnd
+	defer cancel()
+	_, err := m.self(ctx)
+	return err
+}
+
+// Retrieve the user the configured token belongs to.
+func (m *Client) self(ctx context.Context) (user userResponse, err error) {
+	// Augment error no matter which one we get.
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to verify connection to mealie: %s", err.Error())
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", m.url+"/api/users/self", nil)
+	if err != nil {
+		return user, err
+	}
+	m.addAuth(req)
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return user, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+	// Readiness probes retrieve the user all the time, so connections have to be released.
+	if err := resp.Body.Close(); err != nil {
+		return user, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+	err = json.Unmarshal(body, &user)
+	return user, err
+}
+
+// GetOrganisers retrieves all organisers of the given kind, which is "categories" or "tags".
+func (m *Client) GetOrganisers(ctx context.Context, kind string) ([]Organiser, error) {
+	if kind != "categories" && kind != "tags" {
+		return nil, fmt.Errorf("can only get categories or tags for now but not '%s'", kind)
+	}
+	return getPages[Organiser](ctx, m, "/api/organizers/"+kind, nil, kind)
+}
+
+// CreateOrganiser creates a category or tag with the given name and returns it.
+func (m *Client) CreateOrganiser(ctx context.Context, kind string, name string) (Organiser, error) {
+	if kind != "categories" && kind != "tags" {
+		return Organiser{}, fmt.Errorf("can only create categories or tags but not '%s'", kind)
+	}
+	if err := m.refuseWrites("create " + kind); err != nil {
+		return Organiser{}, err
+	}
+	if err := m.requireOrganiserPermission("create " + kind); err != nil {
+		return Organiser{}, err
+	}
+	slog.InfoContext(ctx, "creating organiser", "kind", kind, "name", name)
+	var organiser Organiser
+	payload := map[string]string{"name": name}
+	if err := m.sendJSON(ctx, "POST", "/api/organizers/"+kind, payload, &organiser); err != nil {
+		return Organiser{}, fmt.Errorf("failed to create %s %s: %s", kind, name, err.Error())
+	}
+	return organiser, nil
+}
+
+// DeleteOrganiser deletes the category or tag with the given ID.
+func (m *Client) DeleteOrganiser(ctx context.Context, kind string, id string) error {
+	if kind != "categories" && kind != "tags" {
+		return fmt.Errorf("can only delete categories or tags but not '%s'", kind)
+	}
+	if err := m.refuseWrites("delete " + kind); err != nil {
+		return err
+	}
+	if err := m.requireOrganiserPermission("delete " + kind); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "deleting organiser", "kind", kind, "id", id)
+	if err := m.sendJSON(ctx, "DELETE", "/api/organizers/"+kind+"/"+id, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete %s %s: %s", kind, id, err.Error())
+	}
+	return nil
+}
+
+type recipeForPatchingOrganisers struct {
+	Categories []Organiser `json:"recipeCategory"`
+	Tags       []Organiser `json:"tags"`
+}
+
+// SetOrganisers updates the categories and tags of a recipe to the ones it currently has.
+func (m *Client) SetOrganisers(ctx context.Context, recipe Recipe) error {
+	if err := m.refuseWrites("update organisers"); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "updating organisers", "slug", recipe.Slug)
+
+	converted := recipeForPatchingOrganisers{
+		Categories: recipe.Categories,
+		Tags:       recipe.Tags,
+	}
+	body, err := json.Marshal(converted)
+	if err != nil {
+		return fmt.Errorf("failed to convert organisers to json: %s", err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, "PATCH", m.url+"/api/recipes/"+recipe.Slug, bytes.NewReader(body),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to construct request")
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	m.addAuth(req)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	slog.InfoContext(ctx, "updated organisers", "slug", recipe.Slug)
+	return nil
+}
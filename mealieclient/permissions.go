@@ -0,0 +1,76 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrNotPermitted is returned by methods that need a permission that the token lacks. See
+// CheckPermissions.
+var ErrNotPermitted = errors.New("the mealie token is not permitted")
+
+// Permissions describes what the user that the token belongs to may do in mealie.
+type Permissions struct {
+	// Mealie lets every user update the recipes they can read unless a recipe is locked, which is
+	// only known per recipe. Thus, there is no separate permission to update recipes.
+	ReadRecipes bool
+	// Creating and deleting categories and tags requires the respective permission unless the
+	// user is an admin.
+	ManageOrganisers bool
+}
+
+// The parts of mealie's user that determine permissions.
+type userPermissions struct {
+	Admin       bool `json:"admin"`
+	CanOrganize bool `json:"canOrganize"`
+}
+
+// CheckPermissions determines what the token may do in mealie without modifying anything.
+// Afterwards, methods that need a permission the token lacks return ErrNotPermitted instead of
+// sending requests that would fail.
+func (m *Client) CheckPermissions() (Permissions, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second) //nolint:mnd
+	defer cancel()
+
+	var user userPermissions
+	if err := m.sendJSON(ctx, "GET", "/api/users/self", nil, &user); err != nil {
+		return Permissions{}, fmt.Errorf("failed to retrieve user: %s", err.Error())
+	}
+	permissions := Permissions{ManageOrganisers: user.Admin || user.CanOrganize}
+	err := m.sendJSON(ctx, "GET", "/api/recipes?page=1&perPage=1", nil, nil)
+	if err != nil {
+		slog.WarnContext(ctx, "cannot read recipes", "error", err)
+	}
+	permissions.ReadRecipes = err == nil
+	m.permissions.Store(&permissions)
+	return permissions, nil
+}
+
+// Return an error if the token may not manage organisers. The argument describes the refused
+// modification.
+func (m *Client) requireOrganiserPermission(what string) error {
+	if permissions := m.permissions.Load(); permissions != nil && !permissions.ManageOrganisers {
+		return fmt.Errorf("%w to %s", ErrNotPermitted, what)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// QueryParam selects the recipes of a saved query, identified by its name. If it is given several
+// times, the recipes of all of the queries are exported, each of them once.
+const QueryParam = "q"
+
+// SavedQueries map names to query parameters that select recipes, e.g. "desserts" to
+// {"category": "desserts"}. They support all parameters of the book endpoints except QueryParam.
+type SavedQueries map[string]map[string]string
+
+// Validate ensures that every saved query has a name and consists of known parameters.
+func (s SavedQueries) Validate() error {
+	for name, params := range s {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("saved queries need a name")
+		}
+		for key := range params {
+			if !slices.Contains(mealieParams, key) && !slices.Contains(selectionParams, key) &&
+				!slices.Contains(aliasParams, key) {
+				return fmt.Errorf("saved query %s has unknown parameter %s", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// SetSavedQueries determines the queries that QueryParam selects.
+func (m *Client) SetSavedQueries(queries SavedQueries) {
+	m.savedQueries = queries
+}
+
+// Expand the query parameters into one query per saved query that they select. All other
+// parameters are added to every saved query, replacing those of the same name. Without any saved
+// query, the query parameters are returned as they are.
+func (s SavedQueries) expand(queryParams map[string][]string) ([]map[string][]string, error) {
+	names := queryParams[QueryParam]
+	if len(names) == 0 {
+		return []map[string][]string{queryParams}, nil
+	}
+	others := maps.Clone(queryParams)
+	delete(others, QueryParam)
+	queries := make([]map[string][]string, 0, len(names))
+	for _, name := range names {
+		params, found := s[strings.TrimSpace(name)]
+		if !found {
+			return nil, &QueryError{Param: QueryParam, Reason: "unknown saved query " + name}
+		}
+		query := make(map[string][]string, len(params)+len(others))
+		for key, value := range params {
+			query[key] = []string{value}
+		}
+		maps.Copy(query, others)
+		queries = append(queries, query)
+	}
+	return queries, nil
+}
+
+// Select the slugs of every query that the query parameters stand for and merge them, dropping
+// duplicates while retaining the order. Return, for every slug, the selections of the queries that
+// selected it, which decide whether to keep the recipe once its details are known. Every query has
+// a selection of its own.
+func (m *Client) selectAll(
+	ctx context.Context, queryParams map[string][]string,
+) ([]Slug, [][]*selection, error) {
+	queries, err := m.savedQueries.expand(queryParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	slugs := []Slug{}
+	selections := [][]*selection{}
+	indices := map[string]int{}
+	for _, query := range queries {
+		selected, sel, err := m.selectSlugs(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, slug := range selected {
+			key := slug.ID
+			if key == "" {
+				key = slug.Slug
+			}
+			idx, found := indices[key]
+			if !found {
+				idx = len(slugs)
+				indices[key] = idx
+				slugs = append(slugs, slug)
+				selections = append(selections, nil)
+			}
+			selections[idx] = append(selections[idx], &sel)
+		}
+	}
+	if len(queries) > 1 {
+		slog.InfoContext(
+			ctx, "merged recipes of saved queries", "queries", len(queries), "recipes", len(slugs),
+		)
+	}
+	return slugs, selections, nil
+}
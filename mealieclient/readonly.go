@@ -0,0 +1,41 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is returned by all methods that would modify data in mealie if the client is
+// read-only. See SetReadOnly.
+var ErrReadOnly = errors.New("mealie is accessed read-only")
+
+// SetReadOnly determines whether the client refuses to modify data in mealie. Retrieving recipes,
+// and thus exporting them, is unaffected.
+func (m *Client) SetReadOnly(readOnly bool) {
+	m.readOnly = readOnly
+}
+
+// Return an error if the client is read-only. The argument describes the refused modification.
+func (m *Client) refuseWrites(what string) error {
+	if m.readOnly {
+		return fmt.Errorf("%w, refusing to %s", ErrReadOnly, what)
+	}
+	return nil
+}
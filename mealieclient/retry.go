@@ -0,0 +1,111 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Retry determines how requests that retrieve data from mealie are retried if they fail
+// transiently, e.g. because a connection was reset or mealie was overloaded by many parallel
+// requests.
+type Retry struct {
+	// Attempts is the number of times a request is sent at most. Values below 1 mean once.
+	Attempts int
+	// Backoff is the time to wait before the second attempt, which doubles with every further
+	// attempt. Waits vary by up to half of that so that parallel requests do not retry in lockstep.
+	Backoff time.Duration
+}
+
+// DefaultRetry is how requests are retried unless SetRetry is called.
+var DefaultRetry = Retry{Attempts: 3, Backoff: 500 * time.Millisecond} //nolint:mnd
+
+// SetRetry determines how requests that retrieve slugs, recipes, and media are retried.
+func (m *Client) SetRetry(retry Retry) {
+	m.retry = retry
+}
+
+// Whether a request that failed with the error or status code might succeed if sent again. Mealie
+// and reverse proxies in front of it reply with status codes 5xx while overloaded or restarting.
+func transient(status int, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// Send a GET request to mealie and return the response and its body, which has been read
+// completely. Requests that fail transiently are retried as determined via SetRetry. The status
+// code is not checked otherwise.
+func (m *Client) get(
+	ctx context.Context, url string, header http.Header,
+) (*http.Response, []byte, error) {
+	for attempt := 1; ; attempt++ {
+		resp, body, err := m.getOnce(ctx, url, header)
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		if attempt >= m.retry.Attempts || ctx.Err() != nil || !transient(status, err) {
+			return resp, body, err
+		}
+		backoff := m.retry.Backoff << (attempt - 1)
+		jitter := backoff / 2                       //nolint:mnd
+		wait := backoff - jitter + rand.N(jitter+1) // #nosec:G404
+		slog.WarnContext(ctx,
+			"retrying request to mealie", "url", url, "attempt", attempt+1,
+			"backoff", wait.String(), "status", status, "error", err,
+		)
+		select {
+		case <-ctx.Done():
+			return resp, body, err
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (m *Client) getOnce(
+	ctx context.Context, url string, header http.Header,
+) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	m.addAuth(req)
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	closeErr := resp.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, closeErr
+}
@@ -0,0 +1,80 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SeasonParam selects only recipes with a seasonal tag. With the value SeasonAuto, the tags of all
+// seasons that the current month belongs to are used. Any other value selects a season by the name
+// of its tag.
+const (
+	SeasonParam = "season"
+	SeasonAuto  = "auto"
+)
+
+// Seasons map the names of seasonal tags to the months that belong to the respective season, e.g.
+// "summer" to June, July, and August. Seasons may overlap.
+type Seasons map[string][]time.Month
+
+// Validate ensures that all months exist.
+func (s Seasons) Validate() error {
+	for tag, months := range s {
+		for _, month := range months {
+			if month < time.January || month > time.December {
+				return fmt.Errorf("season %s contains unknown month %d", tag, month)
+			}
+		}
+	}
+	return nil
+}
+
+// SetSeasons determines the seasons used to evaluate SeasonParam.
+func (m *Client) SetSeasons(seasons Seasons) {
+	m.seasons = seasons
+}
+
+// The lower-case names of the tags selected by the value of SeasonParam at the given time.
+func (s Seasons) tags(season string, now time.Time) ([]string, error) {
+	if season != SeasonAuto {
+		for tag := range s {
+			if strings.EqualFold(tag, season) {
+				return []string{strings.ToLower(tag)}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown season %s", season)
+	}
+	tags := []string{}
+	for tag, months := range s {
+		if slices.Contains(months, now.Month()) {
+			tags = append(tags, strings.ToLower(tag))
+		}
+	}
+	if len(tags) == 0 {
+		slog.Info(
+			"no season contains the month, no recipe will be selected",
+			"month", now.Month().String(),
+		)
+	}
+	return tags, nil
+}
@@ -0,0 +1,208 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Query parameters that are not forwarded to mealie. Instead, they select recipes. Most of them are
+// evaluated based on the recipes' slugs before the full details of any recipe are retrieved.
+const (
+	// ExcludeTagsParam excludes recipes with any of the given tags, identified by name or slug.
+	ExcludeTagsParam = "excludeTags"
+	// ExcludeCategoriesParam excludes recipes with any of the given categories, identified by name
+	// or slug.
+	ExcludeCategoriesParam = "excludeCategories"
+	// MaxRecipesParam limits the number of recipes to the given number. It is evaluated after all
+	// other parts of the selection.
+	MaxRecipesParam = "maxRecipes"
+	// MinDifficultyParam excludes recipes that are easier than the given difficulty. It is
+	// evaluated only after recipe details have been retrieved. See Recipe.Difficulty.
+	MinDifficultyParam = "minDifficulty"
+	// MaxDifficultyParam excludes recipes that are harder than the given difficulty. It is
+	// evaluated only after recipe details have been retrieved. See Recipe.Difficulty.
+	MaxDifficultyParam = "maxDifficulty"
+)
+
+// OrderByDifficulty is a value for mealie's orderBy query parameter that mealie does not know. We
+// sort by difficulty ourselves after recipe details have been retrieved.
+const OrderByDifficulty = "difficulty"
+
+var selectionParams = []string{
+	ExcludeTagsParam, ExcludeCategoriesParam, MaxRecipesParam,
+	MinDifficultyParam, MaxDifficultyParam, SeasonParam, IncludeArchivedParam, DietParam,
+}
+
+// The selection of slugs requested via the query parameters above.
+type selection struct {
+	excludeTags       []string
+	excludeCategories []string
+	maxRecipes        int
+	minDifficulty     int
+	maxDifficulty     int
+	season            string
+	// Determined from the season by the client since it requires configuration.
+	seasonTags      []string
+	includeArchived bool
+	diets           []string
+	// Determined from the diets by the client since they may be configured.
+	dietRules []Diet
+}
+
+// Split query parameters into those that shall be forwarded to mealie and the selection.
+func splitSelection(queryParams map[string][]string) (map[string][]string, selection, error) {
+	forwarded := make(map[string][]string, len(queryParams))
+	sel := selection{}
+	for key, values := range queryParams {
+		if !slices.Contains(selectionParams, key) {
+			forwarded[key] = values
+		}
+	}
+	for _, value := range queryParams[ExcludeTagsParam] {
+		sel.excludeTags = append(sel.excludeTags, strings.ToLower(strings.TrimSpace(value)))
+	}
+	for _, value := range queryParams[ExcludeCategoriesParam] {
+		sel.excludeCategories = append(
+			sel.excludeCategories, strings.ToLower(strings.TrimSpace(value)),
+		)
+	}
+	if values := queryParams[MaxRecipesParam]; len(values) != 0 {
+		maxRecipes, err := strconv.Atoi(values[len(values)-1])
+		if err != nil || maxRecipes <= 0 {
+			return nil, sel, &QueryError{
+				Param:  MaxRecipesParam,
+				Reason: "must be a positive number but is " + values[len(values)-1],
+			}
+		}
+		sel.maxRecipes = maxRecipes
+	}
+	if values := queryParams[SeasonParam]; len(values) != 0 {
+		sel.season = strings.TrimSpace(values[len(values)-1])
+	}
+	for _, value := range queryParams[DietParam] {
+		sel.diets = append(sel.diets, strings.TrimSpace(value))
+	}
+	if values := queryParams[IncludeArchivedParam]; len(values) != 0 {
+		include, err := strconv.ParseBool(values[len(values)-1])
+		if err != nil {
+			return nil, sel, &QueryError{
+				Param:  IncludeArchivedParam,
+				Reason: "must be true or false but is " + values[len(values)-1],
+			}
+		}
+		sel.includeArchived = include
+	}
+	var err error
+	sel.minDifficulty, err = difficultyParam(queryParams, MinDifficultyParam, MinDifficulty)
+	if err != nil {
+		return nil, sel, err
+	}
+	sel.maxDifficulty, err = difficultyParam(queryParams, MaxDifficultyParam, MaxDifficulty)
+	if err != nil {
+		return nil, sel, err
+	}
+	if slices.Contains(forwarded["orderBy"], OrderByDifficulty) {
+		delete(forwarded, "orderBy")
+		delete(forwarded, "orderDirection")
+	}
+	return forwarded, sel, nil
+}
+
+// Parse a difficulty from the query parameters, falling back to the default if it is missing.
+func difficultyParam(queryParams map[string][]string, key string, fallback int) (int, error) {
+	values := queryParams[key]
+	if len(values) == 0 {
+		return fallback, nil
+	}
+	difficulty, err := strconv.Atoi(values[len(values)-1])
+	if err != nil || difficulty < MinDifficulty || difficulty > MaxDifficulty {
+		return 0, &QueryError{
+			Param: key,
+			Reason: fmt.Sprintf(
+				"must be a number from %d to %d but is %s",
+				MinDifficulty, MaxDifficulty, values[len(values)-1],
+			),
+		}
+	}
+	return difficulty, nil
+}
+
+func matchesAny(organisers []Organiser, names []string) bool {
+	for _, org := range organisers {
+		name := strings.ToLower(collapseWhitespace(org.Name))
+		if slices.Contains(names, name) || slices.Contains(names, strings.ToLower(org.Slug)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether parts of the selection can only be evaluated once recipe details are known.
+func (s selection) needsDetails() bool {
+	return s.minDifficulty > MinDifficulty || s.maxDifficulty < MaxDifficulty ||
+		len(s.dietRules) != 0
+}
+
+// Apply the selection to the slugs, retaining their order. The number of recipes is limited only if
+// no part of the selection needs recipe details. Otherwise, see limitRecipes.
+func (s selection) apply(ctx context.Context, slugs []Slug) []Slug {
+	truncate := s.maxRecipes > 0 && !s.needsDetails()
+	selected := make([]Slug, 0, len(slugs))
+	for _, slug := range slugs {
+		if truncate && len(selected) >= s.maxRecipes {
+			break
+		}
+		if matchesAny(slug.Tags, s.excludeTags) ||
+			matchesAny(slug.Categories, s.excludeCategories) {
+			continue
+		}
+		if s.season != "" && !matchesAny(slug.Tags, s.seasonTags) {
+			continue
+		}
+		excluded := slices.ContainsFunc(s.dietRules, func(diet Diet) bool {
+			decided, follows := diet.decidedByOrganisers(slug.Tags, slug.Categories)
+			return decided && !follows
+		})
+		if excluded {
+			continue
+		}
+		selected = append(selected, slug)
+	}
+	if len(selected) != len(slugs) {
+		slog.InfoContext(
+			ctx, "selected recipes before retrieval",
+			"selected", len(selected), "recipes", len(slugs),
+		)
+	}
+	return selected
+}
+
+// Whether the recipe is kept by those parts of the selection that need its details.
+func (s selection) keeps(recipe *Recipe) bool {
+	difficulty := recipe.Difficulty()
+	if difficulty < s.minDifficulty || difficulty > s.maxDifficulty {
+		return false
+	}
+	return !slices.ContainsFunc(s.dietRules, func(d Diet) bool { return !d.follows(recipe) })
+}
@@ -0,0 +1,98 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/razziel89/mealie-addons/tracing"
+)
+
+// Transport determines how the client connects to mealie. The zero value connects like any other
+// HTTP client, i.e. it trusts the certificate authorities of the system and uses the proxy that the
+// environment variables HTTP_PROXY, HTTPS_PROXY, and NO_PROXY specify, if any.
+type Transport struct {
+	// CAFile is a PEM file with certificates of authorities that are trusted in addition to those
+	// of the system, e.g. the internal authority of a self-hosted setup.
+	CAFile string
+	// Proxy is the URL of a proxy through which all requests are sent, overriding the environment.
+	Proxy string
+	// InsecureSkipVerify disables verification of the certificate that mealie presents. This makes
+	// connections vulnerable to interception and is meant for testing only.
+	InsecureSkipVerify bool
+}
+
+// HTTPClient builds an HTTP client that connects as the transport specifies. Its requests are
+// recorded as spans like those of tracing.HTTPClient.
+func (t Transport) HTTPClient() (*http.Client, error) {
+	if t == (Transport{}) {
+		return tracing.HTTPClient, nil
+	}
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("default transport cannot be customised")
+	}
+	transport = transport.Clone()
+	if t.Proxy != "" {
+		proxy, err := url.Parse(t.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy url: %s", err.Error())
+		}
+		if proxy.Scheme == "" || proxy.Host == "" {
+			return nil, fmt.Errorf("proxy url %s lacks a scheme or host", t.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if t.CAFile != "" || t.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: t.InsecureSkipVerify, // #nosec:G402
+			MinVersion:         tls.VersionTLS12,
+		}
+	}
+	if t.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		content, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %s", err.Error())
+		}
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("ca file %s contains no pem certificates", t.CAFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return tracing.NewHTTPClient(transport), nil
+}
+
+// SetTransport determines how the client connects to mealie. Call it before sending any requests.
+func (m *Client) SetTransport(transport Transport) error {
+	client, err := transport.HTTPClient()
+	if err != nil {
+		return err
+	}
+	m.httpClient = client
+	return nil
+}
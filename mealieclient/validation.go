@@ -0,0 +1,165 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mealieclient
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters that mealie understands when listing recipes. All of them are forwarded.
+var mealieParams = []string{
+	"search", "orderBy", "orderByNullPosition", "orderDirection", "queryFilter", "paginationSeed",
+	"cookbook", "categories", "tags", "tools", "foods", "households",
+	"requireAllCategories", "requireAllTags", "requireAllTools", "requireAllFoods",
+}
+
+// QueryError describes why a query parameter is invalid. Queries with such parameters cannot
+// succeed, which is why they are rejected before anything is retrieved.
+type QueryError struct {
+	Param  string
+	Reason string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query parameter %s: %s", e.Param, e.Reason)
+}
+
+// ValidateQuery verifies that all query parameters are understood and well-formed. Categories and
+// tags have to exist in mealie, identified by their IDs or slugs. Errors caused by the query are of
+// type *QueryError, other errors mean that the query could not be validated.
+func (m *Client) ValidateQuery(ctx context.Context, queryParams map[string][]string) error {
+	for key := range queryParams {
+		if !slices.Contains(mealieParams, key) && !slices.Contains(selectionParams, key) &&
+			!slices.Contains(aliasParams, key) && key != QueryParam {
+			return &QueryError{Param: key, Reason: "unknown parameter"}
+		}
+	}
+	queries, err := m.savedQueries.expand(queryParams)
+	if err != nil {
+		return err
+	}
+	for _, query := range queries {
+		if err := m.validateQuery(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate a single query without any saved query.
+func (m *Client) validateQuery(ctx context.Context, queryParams map[string][]string) error {
+	queryParams, err := expandAliases(queryParams)
+	if err != nil {
+		return err
+	}
+
+	_, selection, err := splitSelection(queryParams)
+	if err != nil {
+		return err
+	}
+	if selection.season != "" {
+		if _, err := m.seasons.tags(selection.season, time.Now()); err != nil {
+			return &QueryError{Param: SeasonParam, Reason: err.Error()}
+		}
+	}
+	if _, err := m.knownDiets().rules(selection.diets); err != nil {
+		return &QueryError{Param: DietParam, Reason: err.Error()}
+	}
+
+	for _, value := range queryParams["orderDirection"] {
+		if value != "asc" && value != "desc" {
+			return &QueryError{
+				Param: "orderDirection", Reason: "must be asc or desc but is " + value,
+			}
+		}
+	}
+	for _, key := range []string{
+		"requireAllCategories", "requireAllTags", "requireAllTools", "requireAllFoods",
+	} {
+		for _, value := range queryParams[key] {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return &QueryError{Param: key, Reason: "must be true or false but is " + value}
+			}
+		}
+	}
+	for _, value := range queryParams["queryFilter"] {
+		if err := validateQueryFilter(value); err != nil {
+			return &QueryError{Param: "queryFilter", Reason: err.Error()}
+		}
+	}
+
+	for _, kind := range []string{"categories", "tags"} {
+		if len(queryParams[kind]) == 0 {
+			continue
+		}
+		organisers, err := m.GetOrganisers(ctx, kind)
+		if err != nil {
+			return fmt.Errorf("failed to get %s for validation: %s", kind, err.Error())
+		}
+		for _, value := range queryParams[kind] {
+			known := slices.ContainsFunc(organisers, func(org Organiser) bool {
+				return org.ID == value || org.Slug == value
+			})
+			if !known {
+				return &QueryError{Param: kind, Reason: "no ID or slug of any of them: " + value}
+			}
+		}
+	}
+	return nil
+}
+
+// Perform basic checks of the syntax of mealie's query filters, e.g.
+// `tags.name CONTAINS ALL ["Easy", "Vegan"] AND (rating > 3 OR dateAdded > "2024-01-01")`. Quotes
+// and brackets have to be balanced. Brackets within quoted strings are ignored.
+func validateQueryFilter(filter string) error {
+	if strings.TrimSpace(filter) == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	closing := map[rune]rune{'(': ')', '[': ']'}
+	open := []rune{}
+	var quote rune
+	for pos, char := range filter {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '(' || char == '[':
+			open = append(open, char)
+		case char == ')' || char == ']':
+			if len(open) == 0 || closing[open[len(open)-1]] != char {
+				return fmt.Errorf("unexpected %c at position %d", char, pos+1)
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if quote != 0 {
+		return fmt.Errorf("unterminated quoted string")
+	}
+	if len(open) != 0 {
+		return fmt.Errorf("missing %c", closing[open[len(open)-1]])
+	}
+	return nil
+}
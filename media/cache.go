@@ -0,0 +1,279 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package media
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/razziel89/mealie-addons/migrate"
+)
+
+// Caches the results of image conversions. Nil means that nothing is cached.
+var cache *resultCache
+
+// The layout of cache directories. Increment it and add a migration to CacheStore whenever the
+// layout changes.
+const cacheVersion = 1
+
+// CacheStore describes a cache directory as persisted state so that it can be migrated.
+func CacheStore(dir string) migrate.Store {
+	return migrate.Store{Name: "media cache", Path: dir, Dir: true, Version: cacheVersion}
+}
+
+// SetCache caches the results of image conversions such that images that are requested
+// repeatedly, e.g. by several exports, are converted only once. Results are kept in memory up to
+// maxBytes, evicting the least recently used ones first. If dir is not empty, results are also
+// stored there, which makes them survive restarts. Files in dir take up to maxDiskBytes, removing
+// the least recently used ones first, or are never removed if maxDiskBytes is not positive. A
+// non-positive maxBytes and an empty dir disable caching. Call this before processing any image.
+func SetCache(maxBytes int64, dir string, maxDiskBytes int64) error {
+	if maxBytes <= 0 && dir == "" {
+		cache = nil
+		return nil
+	}
+	newCache := &resultCache{
+		maxBytes:     maxBytes,
+		dir:          dir,
+		maxDiskBytes: maxDiskBytes,
+		entries:      map[string]*list.Element{},
+		order:        list.New(),
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil { //nolint:mnd
+			return fmt.Errorf("failed to create cache directory %s: %s", dir, err.Error())
+		}
+		// Temporary files are leftovers of an instance that crashed while storing results.
+		leftovers, err := filepath.Glob(filepath.Join(dir, "*"+cacheTempSuffix+"*"))
+		if err != nil {
+			return fmt.Errorf("failed to list cache directory %s: %s", dir, err.Error())
+		}
+		for _, leftover := range leftovers {
+			_ = os.Remove(leftover)
+		}
+		newCache.pruning = true
+		newCache.pruneDisk()
+	}
+	cache = newCache
+	return nil
+}
+
+// Results are written to files with this suffix first and renamed once complete.
+const cacheTempSuffix = ".tmp"
+
+// Once the cache directory exceeds its limit, files are removed until it takes up at most this
+// fraction of it. That way, it is not pruned again right away.
+const cachePruneTarget = 0.9
+
+// The result of converting an image.
+type cachedResult struct {
+	content []byte
+	mime    string
+}
+
+type cacheEntry struct {
+	key    string
+	result cachedResult
+}
+
+type resultCache struct {
+	lock     sync.Mutex
+	maxBytes int64
+	bytes    int64
+	dir      string
+	entries  map[string]*list.Element
+	// The most recently used entry is at the front.
+	order *list.List
+	// How many bytes the files in dir may take up and, approximately, do take up.
+	maxDiskBytes int64
+	diskBytes    int64
+	// Set while files are removed from dir, which happens outside of the lock.
+	pruning bool
+}
+
+// Identify a conversion by what is done and by a hash of its input.
+func cacheKey(operation string, content []byte, mime string) string {
+	hash := sha256.New()
+	hash.Write([]byte(operation + "\x00" + mime + "\x00"))
+	hash.Write(content)
+	return operation + "-" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// Retrieve a result from memory or, failing that, from disk. Files are read without holding the
+// lock so that other images need not wait for the disk.
+func (c *resultCache) get(key string) (cachedResult, bool) {
+	if c == nil {
+		return cachedResult{}, false
+	}
+	c.lock.Lock()
+	if element, found := c.entries[key]; found {
+		c.order.MoveToFront(element)
+		result := element.Value.(*cacheEntry).result //nolint:forcetypeassert
+		c.lock.Unlock()
+		return result, true
+	}
+	c.lock.Unlock()
+	if c.dir == "" {
+		return cachedResult{}, false
+	}
+	// Files contain the mime type followed by a newline and the content.
+	path := filepath.Join(c.dir, key)
+	stored, err := os.ReadFile(path) // #nosec:G304
+	if err != nil {
+		return cachedResult{}, false
+	}
+	mime, content, found := bytes.Cut(stored, []byte("\n"))
+	if !found {
+		return cachedResult{}, false
+	}
+	// The modification time tells which files were used least recently when pruning.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	result := cachedResult{content: content, mime: string(mime)}
+	c.lock.Lock()
+	c.remember(key, result)
+	c.lock.Unlock()
+	return result, true
+}
+
+// Store a result in memory and on disk. Files are written without holding the lock.
+func (c *resultCache) put(key string, result cachedResult) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	_, found := c.entries[key]
+	c.remember(key, result)
+	c.lock.Unlock()
+	if found || c.dir == "" {
+		return
+	}
+	// Write atomically via a temporary file of its own so that concurrent readers never see
+	// partial files and concurrent writers of the same result do not interfere.
+	stored := append([]byte(result.mime+"\n"), result.content...)
+	if err := writeAtomically(filepath.Join(c.dir, key), stored); err != nil {
+		slog.Warn("failed to store conversion result on disk", "error", err)
+		return
+	}
+
+	c.lock.Lock()
+	c.diskBytes += int64(len(stored))
+	prune := c.maxDiskBytes > 0 && c.diskBytes > c.maxDiskBytes && !c.pruning
+	c.pruning = c.pruning || prune
+	c.lock.Unlock()
+	if prune {
+		c.pruneDisk()
+	}
+}
+
+// Write content to a temporary file next to path and rename it to path once complete.
+func writeAtomically(path string, content []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+cacheTempSuffix+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	err = errors.Join(err, file.Close())
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+	return err
+}
+
+// Whether a file in the cache directory holds a result, as opposed to, e.g., the version of the
+// layout or a result that is still being written.
+func isCachedResult(name string) bool {
+	_, hash, found := strings.Cut(name, "-")
+	return found && !strings.Contains(name, cacheTempSuffix) && len(hash) >= sha256.Size*2
+}
+
+// Remove the least recently used files from the cache directory until it takes up less than its
+// limit and determine how much it takes up. The caller has to set pruning, which is reset once
+// done. Files are removed without holding the lock.
+func (c *resultCache) pruneDisk() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		slog.Warn("failed to list cache directory", "path", c.dir, "error", err)
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	total := int64(0)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !isCachedResult(entry.Name()) {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	if c.maxDiskBytes > 0 && total > c.maxDiskBytes {
+		slices.SortFunc(files, func(a, b os.FileInfo) int {
+			return a.ModTime().Compare(b.ModTime())
+		})
+		target := int64(float64(c.maxDiskBytes) * cachePruneTarget)
+		removed := 0
+		for _, file := range files {
+			if total <= target {
+				break
+			}
+			if err := os.Remove(filepath.Join(c.dir, file.Name())); err != nil {
+				slog.Warn("failed to remove cached result", "file", file.Name(), "error", err)
+				continue
+			}
+			total -= file.Size()
+			removed++
+		}
+		slog.Info("pruned cache directory", "path", c.dir, "removed", removed, "bytes", total)
+	}
+
+	c.lock.Lock()
+	c.diskBytes = total
+	c.pruning = false
+	c.lock.Unlock()
+}
+
+// Keep a result in memory, evicting the least recently used ones if there is not enough space.
+// Results larger than the whole cache and results that are kept already are not kept again. Call
+// with the lock held.
+func (c *resultCache) remember(key string, result cachedResult) {
+	size := int64(len(result.content))
+	if _, found := c.entries[key]; found || size > c.maxBytes {
+		return
+	}
+	for c.bytes+size > c.maxBytes {
+		oldest := c.order.Back()
+		entry := c.order.Remove(oldest).(*cacheEntry) //nolint:forcetypeassert
+		delete(c.entries, entry.key)
+		c.bytes -= int64(len(entry.result.content))
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, result: result})
+	c.bytes += size
+}
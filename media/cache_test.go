@@ -0,0 +1,137 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package media
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResultCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	if err := SetCache(10, "", 0); err != nil {
+		t.Fatalf("SetCache() failed: %s", err.Error())
+	}
+	defer func() { cache = nil }()
+
+	cache.put("a", cachedResult{content: []byte("aaaa"), mime: "image/png"})
+	cache.put("b", cachedResult{content: []byte("bbbb"), mime: "image/png"})
+	// Using a makes b the least recently used entry.
+	if _, found := cache.get("a"); !found {
+		t.Fatalf("a should be cached")
+	}
+	cache.put("c", cachedResult{content: []byte("cccc"), mime: "image/png"})
+	if _, found := cache.get("b"); found {
+		t.Errorf("b should have been evicted")
+	}
+	if _, found := cache.get("a"); !found {
+		t.Errorf("a should still be cached")
+	}
+	cache.put("d", cachedResult{content: []byte("too large for the cache"), mime: "image/png"})
+	if _, found := cache.get("d"); found {
+		t.Errorf("d is larger than the cache and should not be cached")
+	}
+}
+
+func TestResultCacheSurvivesRestartsOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	if err := SetCache(0, dir, 0); err != nil {
+		t.Fatalf("SetCache() failed: %s", err.Error())
+	}
+	defer func() { cache = nil }()
+
+	cache.put("key", cachedResult{content: []byte("line 1\nline 2"), mime: "image/jpeg"})
+	if err := SetCache(0, dir, 0); err != nil {
+		t.Fatalf("SetCache() failed: %s", err.Error())
+	}
+	result, found := cache.get("key")
+	if !found || string(result.content) != "line 1\nline 2" || result.mime != "image/jpeg" {
+		t.Errorf("get() = %+v, %v, want the stored result", result, found)
+	}
+}
+
+func TestPrepareUsesCache(t *testing.T) {
+	if err := SetCache(1<<20, "", 0); err != nil {
+		t.Fatalf("SetCache() failed: %s", err.Error())
+	}
+	defer func() { cache = nil }()
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatalf("failed to encode png: %s", err.Error())
+	}
+	// Pretend that an image has been converted before.
+	key := cacheKey("prepare", []byte("webp"), "image/webp")
+	cache.put(key, cachedResult{content: buf.Bytes(), mime: "image/jpeg"})
+
+	content, mime, err := Prepare(context.Background(), []byte("webp"), "image/webp")
+	if err != nil || mime != "image/jpeg" || !bytes.Equal(content, buf.Bytes()) {
+		t.Errorf("Prepare() = %d bytes, %s, %v, want the cached result", len(content), mime, err)
+	}
+}
+
+func TestResultCachePrunesDisk(t *testing.T) {
+	dir := t.TempDir()
+	// Each stored result takes up 100 bytes.
+	content := bytes.Repeat([]byte("x"), 100-len("image/png\n"))
+	if err := SetCache(0, dir, 250); err != nil {
+		t.Fatalf("SetCache() failed: %s", err.Error())
+	}
+	defer func() { cache = nil }()
+
+	keys := []string{}
+	for idx := range 3 {
+		key := cacheKey("prepare", []byte{byte(idx)}, "image/webp")
+		keys = append(keys, key)
+		cache.put(key, cachedResult{content: content, mime: "image/png"})
+		// Make sure that modification times differ.
+		past := time.Now().Add(time.Duration(idx-10) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, key), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The third result exceeds the limit, which leaves room for two results only.
+	if _, err := os.Stat(filepath.Join(dir, keys[0])); !os.IsNotExist(err) {
+		t.Errorf("expected the least recently used result to be removed")
+	}
+	for _, key := range keys[1:] {
+		if _, found := cache.get(key); !found {
+			t.Errorf("expected result %s to be kept", key)
+		}
+	}
+
+	// The version of the layout is never removed.
+	if err := os.WriteFile(filepath.Join(dir, "VERSION"), []byte("1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCache(0, dir, 1); err != nil {
+		t.Fatalf("SetCache() failed: %s", err.Error())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "VERSION" {
+		t.Errorf("unexpected files after pruning: %v", entries)
+	}
+}
@@ -0,0 +1,202 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// Values of the EXIF orientation tag. They describe how the stored image has to be transformed to
+// be displayed upright.
+const (
+	orientationNormal     = 1
+	orientationMirror     = 2
+	orientationRotate180  = 3
+	orientationFlip       = 4
+	orientationTranspose  = 5
+	orientationRotate90   = 6
+	orientationTransverse = 7
+	orientationRotate270  = 8
+)
+
+const (
+	orientationTag = 0x0112
+	ifdEntryLen    = 12
+	tiffHeaderLen  = 8
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// Find the raw EXIF data, starting with the TIFF header, in a JPEG, PNG, or WebP image. Return nil
+// if there is none.
+func findExif(content []byte, mime string) []byte {
+	switch mime {
+	case "image/jpeg":
+		return findExifJPEG(content)
+	case "image/png":
+		return findExifPNG(content)
+	case "image/webp":
+		return findExifWebP(content)
+	}
+	return nil
+}
+
+// JPEG files consist of segments, each starting with a marker and a big endian length. EXIF data is
+// stored in an APP1 segment that comes before the start of the scan.
+func findExifJPEG(content []byte) []byte {
+	if !bytes.HasPrefix(content, []byte{0xff, 0xd8}) {
+		return nil
+	}
+	for pos := 2; pos+4 <= len(content); { //nolint:mnd
+		marker := content[pos+1]
+		if content[pos] != 0xff || marker == 0xda {
+			return nil
+		}
+		// The length includes its own two bytes, so anything shorter is broken.
+		end := pos + 2 + int(binary.BigEndian.Uint16(content[pos+2:])) //nolint:mnd
+		if end < pos+4 || end > len(content) {
+			return nil
+		}
+		payload := content[pos+4 : end]
+		if marker == 0xe1 && bytes.HasPrefix(payload, exifHeader) {
+			return payload[len(exifHeader):]
+		}
+		pos = end
+	}
+	return nil
+}
+
+// PNG files consist of chunks after an 8 byte signature. Each chunk comprises a big endian length,
+// a type, the data, and a checksum.
+func findExifPNG(content []byte) []byte {
+	for pos := 8; pos+12 <= len(content); { //nolint:mnd
+		start := pos + 8                                           //nolint:mnd
+		end := start + int(binary.BigEndian.Uint32(content[pos:])) // #nosec:G115
+		if end < start || end+4 > len(content) {
+			return nil
+		}
+		if string(content[pos+4:start]) == "eXIf" {
+			return content[start:end]
+		}
+		pos = end + 4 //nolint:mnd
+	}
+	return nil
+}
+
+// WebP files are RIFF containers with a 12 byte header. Each chunk comprises a type, a little
+// endian length, and the data padded to an even length.
+func findExifWebP(content []byte) []byte {
+	if len(content) < 12 || string(content[8:12]) != "WEBP" { //nolint:mnd
+		return nil
+	}
+	for pos := 12; pos+8 <= len(content); { //nolint:mnd
+		start := pos + 8                                                //nolint:mnd
+		end := start + int(binary.LittleEndian.Uint32(content[pos+4:])) // #nosec:G115
+		if end < start || end > len(content) {
+			return nil
+		}
+		if string(content[pos:pos+4]) == "EXIF" {
+			// Some encoders keep the header used in JPEG files.
+			return bytes.TrimPrefix(content[start:end], exifHeader)
+		}
+		pos = end + (end-start)%2 //nolint:mnd
+	}
+	return nil
+}
+
+// Extract the orientation from raw EXIF data. It is stored in the first IFD of the TIFF structure.
+// Return orientationNormal if it cannot be determined.
+func orientation(exif []byte) int {
+	if len(exif) < tiffHeaderLen {
+		return orientationNormal
+	}
+	var order binary.ByteOrder
+	switch string(exif[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return orientationNormal
+	}
+	ifd := int(order.Uint32(exif[4:])) // #nosec:G115
+	if ifd < tiffHeaderLen || ifd+2 > len(exif) {
+		return orientationNormal
+	}
+	for idx := range int(order.Uint16(exif[ifd:])) {
+		entry := ifd + 2 + idx*ifdEntryLen
+		if entry+ifdEntryLen > len(exif) {
+			break
+		}
+		if order.Uint16(exif[entry:]) == orientationTag {
+			value := int(order.Uint16(exif[entry+8:]))
+			if value < orientationNormal || value > orientationRotate270 {
+				return orientationNormal
+			}
+			return value
+		}
+	}
+	return orientationNormal
+}
+
+// Transform the image such that it is displayed upright when its EXIF orientation is ignored.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation == orientationNormal {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	// Transposing orientations swap width and height.
+	dstWidth, dstHeight := width, height
+	if orientation >= orientationTranspose {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := range height {
+		for x := range width {
+			var dx, dy int
+			switch orientation {
+			case orientationMirror:
+				dx, dy = width-1-x, y
+			case orientationRotate180:
+				dx, dy = width-1-x, height-1-y
+			case orientationFlip:
+				dx, dy = x, height-1-y
+			case orientationTranspose:
+				dx, dy = y, x
+			case orientationRotate90:
+				dx, dy = height-1-y, x
+			case orientationTransverse:
+				dx, dy = height-1-y, width-1-x
+			case orientationRotate270:
+				dx, dy = y, width-1-x
+			}
+			srcOffset, dstOffset := src.PixOffset(x, y), dst.PixOffset(dx, dy)
+			copy(dst.Pix[dstOffset:dstOffset+4], src.Pix[srcOffset:srcOffset+4])
+		}
+	}
+	return dst
+}
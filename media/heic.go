@@ -0,0 +1,96 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// Brands in the ftyp box of ISO base media files that identify HEIF images.
+var heifBrands = []string{"heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1"}
+
+// IsHEIF determines whether content is a HEIF image, which includes HEIC images.
+func IsHEIF(content []byte) bool {
+	if len(content) < 12 || string(content[4:8]) != "ftyp" { //nolint:mnd
+		return false
+	}
+	return slices.Contains(heifBrands, string(content[8:12]))
+}
+
+// CheckForHeifConvert verifies that the heif-convert executable can be found. It is needed only to
+// embed HEIC images.
+func CheckForHeifConvert() error {
+	_, err := exec.LookPath("heif-convert")
+	if err != nil {
+		return fmt.Errorf("failed to find heif-convert in path: %s", err.Error())
+	}
+	return nil
+}
+
+// Convert a HEIF image to JPEG via heif-convert. It applies all transformations stored in the
+// image, e.g. rotations, and removes the orientation from any EXIF data it copies to the output.
+func heifToJPEG(ctx context.Context, content []byte) ([]byte, error) {
+	if err := CheckForHeifConvert(); err != nil {
+		return nil, err
+	}
+
+	tmpdir, err := os.MkdirTemp(workdir.Dir(ctx), workdir.Prefix+"heif-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %s", err.Error())
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			slog.WarnContext(
+				ctx, "failed to remove temporary directory", "path", tmpdir, "error", err,
+			)
+		}
+	}()
+	input := filepath.Join(tmpdir, "image.heic")
+	output := filepath.Join(tmpdir, "image.jpeg")
+	if err := os.WriteFile(input, content, 0o600); err != nil { //nolint:mnd
+		return nil, fmt.Errorf("failed to write heif image: %s", err.Error())
+	}
+
+	cmd := exec.CommandContext(ctx, "heif-convert", input, output)
+	stderr := strings.Builder{}
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(
+			"failed to run heif-convert: %s, stderr: %s", err.Error(), stderr.String(),
+		)
+	}
+
+	converted, err := os.ReadFile(output) // #nosec:G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read converted image: %s", err.Error())
+	}
+	if !bytes.HasPrefix(converted, []byte{0xff, 0xd8}) {
+		return nil, fmt.Errorf("heif-convert did not produce a jpeg image")
+	}
+	return converted, nil
+}
@@ -0,0 +1,50 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package media
+
+import (
+	"context"
+	"fmt"
+)
+
+// Limits how many images are processed at the same time. Nil means no limit.
+var limiter chan struct{}
+
+// SetConcurrencyLimit limits how many images are processed at the same time. Processing an image
+// needs memory proportional to its decoded size, which can easily be hundreds of megabytes for
+// large photos. A non-positive limit removes the limit. Call this before processing any image.
+func SetConcurrencyLimit(limit int) {
+	if limit <= 0 {
+		limiter = nil
+		return
+	}
+	limiter = make(chan struct{}, limit)
+}
+
+// Wait until an image may be processed. Call the returned function once done.
+func acquire(ctx context.Context) (func(), error) {
+	if limiter == nil {
+		return func() {}, nil
+	}
+	select {
+	case limiter <- struct{}{}:
+		return func() { <-limiter }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to wait for image processing: %s", ctx.Err().Error())
+	}
+}
@@ -0,0 +1,124 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package media prepares images retrieved from mealie for embedding into documents.
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+
+	"golang.org/x/image/webp"
+)
+
+// Quality used when re-encoding JPEG images.
+const jpegQuality = 90
+
+// Prepare converts an image with the given mime type such that it can be embedded in all supported
+// document types. WebP and HEIC images are converted to JPEG because LaTeX does not understand
+// them. HEIC images need heif-convert to be installed. Images containing EXIF metadata are rotated
+// according to their EXIF orientation and re-encoded without any metadata, which also removes
+// sensitive data such as GPS coordinates. Other media are returned as they are. Return the content
+// and mime type of the prepared media.
+func Prepare(ctx context.Context, content []byte, mime string) ([]byte, string, error) {
+	return cachedPrepare(ctx, "prepare", content, mime, false)
+}
+
+// PrepareKeepingWebP is like Prepare but keeps WebP images without EXIF metadata as they are. Use
+// it for document types that understand WebP images, e.g. HTML and EPUB, to keep smaller files.
+func PrepareKeepingWebP(ctx context.Context, content []byte, mime string) ([]byte, string, error) {
+	return cachedPrepare(ctx, "prepare-keep-webp", content, mime, true)
+}
+
+func cachedPrepare(
+	ctx context.Context, op string, content []byte, mime string, keepWebP bool,
+) ([]byte, string, error) {
+	key := cacheKey(op, content, mime)
+	if result, found := cache.get(key); found {
+		return result.content, result.mime, nil
+	}
+	prepared, preparedMime, err := prepare(ctx, content, mime, keepWebP)
+	if err != nil {
+		return nil, "", err
+	}
+	// Media that are returned as they are need not be cached.
+	if preparedMime != mime || !bytes.Equal(prepared, content) {
+		cache.put(key, cachedResult{content: prepared, mime: preparedMime})
+	}
+	return prepared, preparedMime, nil
+}
+
+func prepare(
+	ctx context.Context, content []byte, mime string, keepWebP bool,
+) ([]byte, string, error) {
+	release, err := acquire(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+
+	if mime == "image/heic" || mime == "image/heif" || IsHEIF(content) {
+		slog.DebugContext(ctx, "converting heif to jpeg")
+		converted, err := heifToJPEG(ctx, content)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to convert heif image: %s", err.Error())
+		}
+		content, mime = converted, "image/jpeg"
+	}
+
+	exif := findExif(content, mime)
+	// WebP images cannot be re-encoded as such. Thus, they are converted if metadata have to go.
+	if (mime != "image/webp" || keepWebP) && exif == nil {
+		return content, mime, nil
+	}
+
+	var img image.Image
+	switch mime {
+	case "image/webp":
+		slog.DebugContext(ctx, "converting webp to jpeg")
+		img, err = webp.Decode(bytes.NewReader(content))
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(content))
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode %s image: %s", mime, err.Error())
+	}
+
+	if exif != nil {
+		slog.DebugContext(ctx, "stripping exif metadata", "mimeType", mime)
+		img = applyOrientation(img, orientation(exif))
+	}
+
+	buf := bytes.Buffer{}
+	if mime == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		mime = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode %s image: %s", mime, err.Error())
+	}
+	return buf.Bytes(), mime, nil
+}
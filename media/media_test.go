@@ -0,0 +1,175 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package media
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os/exec"
+	"testing"
+)
+
+func heifHeader(brand string) []byte {
+	return append([]byte{0, 0, 0, 24, 'f', 't', 'y', 'p'}, []byte(brand+"\x00\x00\x00\x00")...)
+}
+
+func TestIsHEIF(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    bool
+	}{
+		{"heic", heifHeader("heic"), true},
+		{"heif", heifHeader("mif1"), true},
+		{"mp4", heifHeader("isom"), false},
+		{"jpeg", []byte{0xff, 0xd8, 0xff, 0xe0, 0, 0, 0, 0, 0, 0, 0, 0}, false},
+		{"short", []byte("ftyp"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsHEIF(test.content); got != test.want {
+				t.Errorf("IsHEIF() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsSVG(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"plain", `<svg xmlns="http://www.w3.org/2000/svg"></svg>`, true},
+		{
+			"prolog",
+			"\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- made by hand -->\n" +
+				`<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "svg11.dtd" [<!ENTITY a "b">]>` +
+				"\n<svg>",
+			true,
+		},
+		{"html", `<html><body><svg></svg><script>alert(1)</script></body></html>`, false},
+		{"comment", `<!-- <svg> --><html></html>`, false},
+		{"other element", `<svgfoo></svgfoo>`, false},
+		{"unterminated", `<?xml version="1.0"`, false},
+		{"empty", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsSVG([]byte(test.content)); got != test.want {
+				t.Errorf("IsSVG() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPrepareHEIFWithoutHeifConvert(t *testing.T) {
+	if _, err := exec.LookPath("heif-convert"); err == nil {
+		t.Skip("heif-convert is installed")
+	}
+	_, _, err := Prepare(context.Background(), heifHeader("heic"), "image/heic")
+	if err == nil {
+		t.Fatal("expected an error without heif-convert")
+	}
+}
+
+func TestPrepareHEIFWithHeifConvert(t *testing.T) {
+	if _, err := exec.LookPath("heif-convert"); err != nil {
+		t.Skip("heif-convert is not installed")
+	}
+	// Broken images must be reported as errors instead of being passed on.
+	_, _, err := Prepare(context.Background(), heifHeader("heic"), "image/heic")
+	if err == nil {
+		t.Fatal("expected an error for a broken heic image")
+	}
+}
+
+func TestPrepareKeepsImagesWithoutExif(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 4, 2)), nil); err != nil {
+		t.Fatal(err)
+	}
+	content, mime, err := Prepare(context.Background(), buf.Bytes(), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mime != "image/jpeg" || !bytes.Equal(content, buf.Bytes()) {
+		t.Error("image without exif data was modified")
+	}
+}
+
+func TestPrepareKeepingWebPKeepsWebPImagesWithoutExif(t *testing.T) {
+	// A RIFF container without any chunks is enough since the image is not decoded.
+	webp := []byte("RIFF\x04\x00\x00\x00WEBP")
+	content, mime, err := PrepareKeepingWebP(context.Background(), webp, "image/webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mime != "image/webp" || !bytes.Equal(content, webp) {
+		t.Error("webp image without exif data was modified")
+	}
+	if _, _, err := Prepare(context.Background(), webp, "image/webp"); err == nil {
+		t.Error("expected an error when converting a broken webp image")
+	}
+}
+
+func TestFindExifRejectsTooShortJPEGSegments(t *testing.T) {
+	for _, length := range []byte{0, 1} {
+		content := []byte{0xff, 0xd8, 0xff, 0xe1, 0, length, 0, 0, 0, 0}
+		if findExif(content, "image/jpeg") != nil {
+			t.Errorf("found exif data in a segment of length %d", length)
+		}
+	}
+}
+
+func TestPrepareAppliesOrientation(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.White)
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// A big endian TIFF structure with a single IFD entry that sets the orientation to 6.
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01" +
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00\x00\x00")
+	segment := append([]byte{0xff, 0xe1, 0, byte(len(exif) + 2)}, exif...)
+	content := append(append(append([]byte{}, encoded[:2]...), segment...), encoded[2:]...)
+
+	prepared, mime, err := Prepare(context.Background(), content, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mime != "image/jpeg" {
+		t.Errorf("unexpected mime type %s", mime)
+	}
+	if findExif(prepared, mime) != nil {
+		t.Error("exif data was not removed")
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(prepared))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := decoded.Bounds().Size(); size != image.Pt(2, 4) {
+		t.Errorf("image was not rotated, size is %v", size)
+	}
+}
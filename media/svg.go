@@ -0,0 +1,109 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// IsSVG determines whether content is an SVG image, i.e. whether its root element is svg.
+func IsSVG(content []byte) bool {
+	rest := bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	// The root element may be preceded by an XML declaration, comments, and a doctype, which may
+	// declare entities in brackets.
+	for {
+		rest = bytes.TrimLeft(rest, " \t\r\n")
+		var end string
+		switch {
+		case bytes.HasPrefix(rest, []byte("<?")):
+			end = "?>"
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			end = "-->"
+		case hasPrefixFold(rest, "<!doctype"):
+			end = ">"
+			if bracket := bytes.IndexByte(rest, '['); bracket != -1 &&
+				bracket < bytes.IndexByte(rest, '>') {
+				end = "]>"
+			}
+		default:
+			return hasPrefixFold(rest, "<svg") && len(rest) > 4 &&
+				strings.ContainsRune(" \t\r\n/>", rune(rest[4]))
+		}
+		idx := bytes.Index(rest, []byte(end))
+		if idx == -1 {
+			return false
+		}
+		rest = rest[idx+len(end):]
+	}
+}
+
+func hasPrefixFold(content []byte, prefix string) bool {
+	return len(content) >= len(prefix) && strings.EqualFold(string(content[:len(prefix)]), prefix)
+}
+
+// CheckForRsvgConvert verifies that the rsvg-convert executable can be found. It is needed only to
+// embed SVG images in PDF documents.
+func CheckForRsvgConvert() error {
+	_, err := exec.LookPath("rsvg-convert")
+	if err != nil {
+		return fmt.Errorf("failed to find rsvg-convert in path: %s", err.Error())
+	}
+	return nil
+}
+
+// RasterizeSVG converts an SVG image to PNG via rsvg-convert. LaTeX cannot embed SVG images.
+func RasterizeSVG(ctx context.Context, content []byte) ([]byte, error) {
+	key := cacheKey("rasterize", content, "image/svg+xml")
+	if result, found := cache.get(key); found {
+		return result.content, nil
+	}
+	content, err := rasterizeSVG(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	cache.put(key, cachedResult{content: content, mime: "image/png"})
+	return content, nil
+}
+
+func rasterizeSVG(ctx context.Context, content []byte) ([]byte, error) {
+	if err := CheckForRsvgConvert(); err != nil {
+		return nil, err
+	}
+	release, err := acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	cmd := exec.CommandContext(ctx, "rsvg-convert", "--format=png")
+	cmd.Stdin = bytes.NewReader(content)
+	stdout := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	stderr := strings.Builder{}
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf(
+			"failed to run rsvg-convert: %s, stderr: %s", err.Error(), stderr.String(),
+		)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,178 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package migrate keeps persisted state readable across releases. Every store of persisted state,
+// e.g. a cache directory, records the version of its layout. At start-up, stores written by older
+// releases are migrated step by step and stores written by newer releases are rejected, so that an
+// upgrade or a downgrade never silently corrupts or discards state.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The file inside store directories that records their version.
+const versionFile = "VERSION"
+
+// Migration converts a store from version From to version From+1. Migrations may be interrupted,
+// e.g. by a crash, in which case they are run again at the next start-up. Thus, they have to
+// either be idempotent or replace files atomically.
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(path string) error
+}
+
+// Store is persisted state whose layout is versioned, either a directory or a single file.
+// Directories record their version in a file inside them, single files in a file next to them
+// with the extension ".version". Stores without such a record have version 1, the layout of
+// releases before versions were recorded.
+type Store struct {
+	Name string
+	Path string
+	Dir  bool
+	// The version that the running release reads and writes.
+	Version    int
+	Migrations []Migration
+}
+
+func (s Store) versionPath() string {
+	if s.Dir {
+		return filepath.Join(s.Path, versionFile)
+	}
+	return s.Path + ".version"
+}
+
+// Determine the version of the store as it is. Stores that do not exist yet have the current
+// version.
+func (s Store) storedVersion() (int, error) {
+	content, err := os.ReadFile(s.versionPath())
+	if err == nil {
+		version, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil || version < 1 {
+			return 0, fmt.Errorf("bad version record %s of %s", s.versionPath(), s.Name)
+		}
+		return version, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("failed to read version of %s: %s", s.Name, err.Error())
+	}
+	if _, err := os.Stat(s.Path); errors.Is(err, os.ErrNotExist) {
+		return s.Version, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to access %s at %s: %s", s.Name, s.Path, err.Error())
+	}
+	return 1, nil
+}
+
+// Determine the migrations that bring the store from its stored version to the current one.
+func (s Store) plan() ([]Migration, error) {
+	stored, err := s.storedVersion()
+	if err != nil {
+		return nil, err
+	}
+	if stored > s.Version {
+		return nil, fmt.Errorf(
+			"%s at %s has version %d, which only newer releases can read, this one reads %d",
+			s.Name, s.Path, stored, s.Version,
+		)
+	}
+	steps := make([]Migration, 0, s.Version-stored)
+	for version := stored; version < s.Version; version++ {
+		found := false
+		for _, migration := range s.Migrations {
+			if migration.From == version {
+				steps = append(steps, migration)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no migration of %s from version %d", s.Name, version)
+		}
+	}
+	return steps, nil
+}
+
+// Record the version of the store, replacing the record atomically.
+func (s Store) record(version int) error {
+	if s.Dir {
+		if err := os.MkdirAll(s.Path, 0o700); err != nil { //nolint:mnd
+			return fmt.Errorf("failed to create %s at %s: %s", s.Name, s.Path, err.Error())
+		}
+	}
+	path := s.versionPath()
+	err := os.WriteFile(path+".tmp", []byte(strconv.Itoa(version)+"\n"), 0o600) //nolint:mnd
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record version of %s: %s", s.Name, err.Error())
+	}
+	return nil
+}
+
+// Run migrates all stores to their current versions and records those. Nothing is changed if any
+// store cannot be migrated, e.g. because a newer release wrote it. In a dry run, migrations are
+// only logged.
+func Run(stores []Store, dryRun bool) error {
+	plans := make([][]Migration, 0, len(stores))
+	for _, store := range stores {
+		steps, err := store.plan()
+		if err != nil {
+			return err
+		}
+		plans = append(plans, steps)
+	}
+
+	for idx, store := range stores {
+		for _, step := range plans[idx] {
+			attrs := []any{
+				"store", store.Name, "path", store.Path,
+				"from", step.From, "to", step.From + 1, "migration", step.Description,
+			}
+			if dryRun {
+				slog.Info("would migrate persisted state", attrs...)
+				continue
+			}
+			slog.Info("migrating persisted state", attrs...)
+			if err := step.Apply(store.Path); err != nil {
+				return fmt.Errorf(
+					"failed to migrate %s from version %d: %s", store.Name, step.From, err.Error(),
+				)
+			}
+			if err := store.record(step.From + 1); err != nil {
+				return err
+			}
+		}
+		if len(plans[idx]) == 0 {
+			slog.Debug("persisted state is up to date", "store", store.Name, "path", store.Path)
+		}
+		if !dryRun {
+			if err := store.record(store.Version); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
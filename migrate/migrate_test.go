@@ -0,0 +1,128 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A store of a single file whose migrations append lines to it.
+func appendingStore(path string, version int) Store {
+	appendLine := func(line string) func(string) error {
+		return func(path string) error {
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				return err
+			}
+			_, err = file.WriteString(line + "\n")
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		}
+	}
+	return Store{
+		Name: "test", Path: path, Version: version,
+		Migrations: []Migration{
+			{From: 1, Description: "to 2", Apply: appendLine("2")},
+			{From: 2, Description: "to 3", Apply: appendLine("3")},
+		},
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestRunMigratesUnversionedStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	if err := os.WriteFile(path, []byte("1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := appendingStore(path, 3)
+
+	if err := Run([]Store{store}, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "1\n" {
+		t.Errorf("dry run changed the store: %q", got)
+	}
+	if _, err := os.Stat(path + ".version"); !os.IsNotExist(err) {
+		t.Errorf("dry run recorded a version")
+	}
+
+	if err := Run([]Store{store}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "1\n2\n3\n" {
+		t.Errorf("wrong migrations applied: %q", got)
+	}
+	if got := readFile(t, path+".version"); strings.TrimSpace(got) != "3" {
+		t.Errorf("wrong version recorded: %q", got)
+	}
+
+	if err := Run([]Store{store}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "1\n2\n3\n" {
+		t.Errorf("migrations applied twice: %q", got)
+	}
+}
+
+func TestRunRejectsNewerStores(t *testing.T) {
+	dir := t.TempDir()
+	older := appendingStore(filepath.Join(dir, "older"), 3)
+	newer := appendingStore(filepath.Join(dir, "newer"), 1)
+	for _, path := range []string{older.Path, newer.Path} {
+		if err := os.WriteFile(path, []byte("1\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(newer.Path+".version", []byte("2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Run([]Store{older, newer}, false)
+
+	if err == nil || !strings.Contains(err.Error(), "only newer releases can read") {
+		t.Fatalf("expected an error about a newer release but got %v", err)
+	}
+	if got := readFile(t, older.Path); got != "1\n" {
+		t.Errorf("store changed although another cannot be migrated: %q", got)
+	}
+}
+
+func TestRunRecordsVersionOfNewStores(t *testing.T) {
+	store := Store{Name: "test", Path: filepath.Join(t.TempDir(), "cache"), Dir: true, Version: 4}
+
+	if err := Run([]Store{store}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, filepath.Join(store.Path, versionFile)); strings.TrimSpace(got) != "4" {
+		t.Errorf("wrong version recorded: %q", got)
+	}
+}
@@ -0,0 +1,146 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"golang.org/x/net/html"
+
+	"github.com/razziel89/mealie-addons/assign"
+	"github.com/razziel89/mealie-addons/grpcapi"
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/render"
+)
+
+// Rules to modify and remove HTML attributes that can be replaced while documents are generated.
+type htmlAttrRules struct {
+	lock sync.RWMutex
+	mod  map[string]map[string]string
+	rm   map[string]map[string]string
+}
+
+func (h *htmlAttrRules) set(mod map[string]map[string]string, rm map[string]map[string]string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.mod, h.rm = mod, rm
+}
+
+// Apply the current rules to a document. This is an HTML hook.
+func (h *htmlAttrRules) update(htmlInput *html.Node) (*html.Node, error) {
+	h.lock.RLock()
+	mod, rm := h.mod, h.rm
+	h.lock.RUnlock()
+	return render.UpdateHTMLAttrs(htmlInput, mod, rm)
+}
+
+// Everything that is affected when the configuration is reloaded on SIGHUP.
+type reloader struct {
+	// Reloads must neither overlap with each other nor with stopping the assignment loop.
+	lock       sync.Mutex
+	configFile string
+	// The environment variables that were set from the config file, which are unset before it is
+	// read again so that changes to it take effect.
+	fromFile []string
+	// Why recipes are not updated, empty if they are.
+	noUpdates string
+	pandoc    *render.Pandoc
+	htmlAttrs *htmlAttrRules
+	// The following are only set in server mode. The gRPC server is nil if it is disabled.
+	mealie             *mealieclient.Client
+	grpcServer         *grpcapi.Server
+	quitAssignmentLoop chan<- bool
+}
+
+// Read the config file and environment variables again and apply the parts of the configuration
+// that can be changed without a restart, namely pandoc flags, HTML attribute rules, fonts, and
+// query assignments. Changes to anything else are ignored. Exports that are already running are
+// not affected. On error, the previous configuration is kept.
+func (r *reloader) reload() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	previous := map[string]string{}
+	for _, name := range r.fromFile {
+		previous[name] = os.Getenv(name)
+		if err := os.Unsetenv(name); err != nil {
+			return fmt.Errorf("failed to unset %s: %s", name, err.Error())
+		}
+	}
+	fromFile, err := loadConfigFile(r.configFile)
+	var cfg config
+	if err == nil {
+		cfg, err = initConfig()
+	}
+	if err != nil {
+		for _, name := range fromFile {
+			_ = os.Unsetenv(name)
+		}
+		for name, value := range previous {
+			_ = os.Setenv(name, value)
+		}
+		return err
+	}
+	r.fromFile = fromFile
+
+	r.pandoc.SetOptions(cfg.pandocFlags)
+	r.htmlAttrs.set(cfg.htmlAttrsMod, cfg.htmlAttrsRm)
+	if err := r.pandoc.LoadFonts(cfg.pandocFontsDir); err != nil {
+		slog.Warn("failed to reload fonts, keeping the previous ones", "error", err)
+	}
+	if r.mealie != nil {
+		if err := r.restartAssignments(cfg.queryAssignments); err != nil {
+			return err
+		}
+	}
+	slog.Info("reloaded configuration")
+	return nil
+}
+
+// Restart the assignment loop with new assignments, which performs a round right away.
+func (r *reloader) restartAssignments(assignments assign.Assignments) error {
+	if r.noUpdates != "" && assignments.Configured() {
+		slog.Warn("not performing query assignments", "reason", r.noUpdates)
+		assignments = assign.Assignments{}
+	}
+	if r.quitAssignmentLoop != nil {
+		r.quitAssignmentLoop <- true
+	}
+	quit, err := assign.LaunchLoop(assignments, r.mealie)
+	r.quitAssignmentLoop = quit
+	if err != nil {
+		return fmt.Errorf("failed to restart assignment loop: %s", err.Error())
+	}
+	if r.grpcServer != nil {
+		r.grpcServer.SetAssignments(assignments)
+	}
+	return nil
+}
+
+// Stop the assignment loop, if it is running.
+func (r *reloader) stopAssignments() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.quitAssignmentLoop != nil {
+		r.quitAssignmentLoop <- true
+		r.quitAssignmentLoop = nil
+	}
+}
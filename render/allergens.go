@@ -0,0 +1,141 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Allergens map the names of allergens, e.g. "gluten", to keywords that identify foods containing
+// them, e.g. "wheat" and "barley". A keyword matches the start of any word in the name of a food,
+// ignoring case, see mealieclient.ContainsWordStart.
+type Allergens map[string][]string
+
+// Validate ensures that every allergen has keywords and that none of them is empty.
+func (a Allergens) Validate() error {
+	for name, keywords := range a {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("allergens need a name")
+		}
+		if len(keywords) == 0 {
+			return fmt.Errorf("allergen %s has no keywords", name)
+		}
+		for _, keyword := range keywords {
+			if strings.TrimSpace(keyword) == "" {
+				return fmt.Errorf("allergen %s has an empty keyword", name)
+			}
+		}
+	}
+	return nil
+}
+
+// The names of all allergens in the order in which they are listed.
+func (a Allergens) names() []string {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	SortNames(names)
+	return names
+}
+
+// Whether the food contains the allergen, i.e. whether any keyword starts a word in its name.
+func (a Allergens) in(allergen string, food string) bool {
+	for _, keyword := range a[allergen] {
+		if mealieclient.ContainsWordStart(food, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Determine the allergens that every recipe contains, keyed by recipe ID. Only structured
+// ingredients are considered since only they name their food. Recipes without allergens are left
+// out.
+func (a Allergens) contained(recipes []mealieclient.Recipe) map[string][]string {
+	if len(a) == 0 {
+		return nil
+	}
+	names := a.names()
+	result := map[string][]string{}
+	for _, recipe := range recipes {
+		found := []string{}
+		for _, name := range names {
+			for _, ingredient := range recipe.Ingredients {
+				if ingredient.Food != nil && a.in(name, ingredient.Food.Name) {
+					found = append(found, name)
+					break
+				}
+			}
+		}
+		if len(found) != 0 {
+			result[recipe.ID] = found
+		}
+	}
+	return result
+}
+
+// Render a warning badge for every allergen, each linking to its entry in the allergen index
+// unless there is none. Colors are not supported in PDF documents, but the text is.
+func allergenBadges(allergens []string, anchors *anchors, noIndex bool) string {
+	badges := make([]string, 0, len(allergens))
+	for _, allergen := range allergens {
+		badge := fmt.Sprintf(
+			`<span style="color: white; background-color: #c62828; padding: 0 0.3em;">%s</span>`,
+			html.EscapeString(allergen),
+		)
+		badges = append(badges, indexLink(badge, anchors.allergen(allergen), noIndex))
+	}
+	return fmt.Sprintf("**Contains**: %s\n", strings.Join(badges, " "))
+}
+
+// Build an index listing the recipes that contain each allergen. Empty if no recipe contains any.
+func allergenIndex(
+	recipes []mealieclient.Recipe,
+	allergens Allergens,
+	contained map[string][]string,
+	anchors *anchors,
+) []string {
+	if len(contained) == 0 {
+		return nil
+	}
+	result := []string{"# Allergens"}
+	for _, allergen := range allergens.names() {
+		result = append(
+			result,
+			fmt.Sprintf("\n## <a name=\"%s\"></a> %s\n", anchors.allergen(allergen), allergen),
+		)
+		listed := false
+		for _, recipe := range recipes {
+			if slices.Contains(contained[recipe.ID], allergen) {
+				link := fmt.Sprintf("- [%s](#%s)", recipe.Name, anchors.recipe(&recipe))
+				result = append(result, link)
+				listed = true
+			}
+		}
+		if !listed {
+			result = append(result, "No recipe contains this allergen.")
+		}
+	}
+	return append(result, "\n"+`<div style="page-break-before: always;"></div>`+"\n")
+}
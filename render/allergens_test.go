@@ -0,0 +1,84 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+func TestAllergensContained(t *testing.T) {
+	allergens := Allergens{"nuts": {"walnut", "Hasel"}, "eggs": {"egg"}}
+	ingredients := func(foods ...string) []mealieclient.Ingredient {
+		result := []mealieclient.Ingredient{{Text: "a pinch of eggplant"}}
+		for _, food := range foods {
+			result = append(result, mealieclient.Ingredient{Food: &mealieclient.Food{Name: food}})
+		}
+		return result
+	}
+	recipes := []mealieclient.Recipe{
+		{ID: "1", Ingredients: ingredients("Walnuts", "chicken eggs")},
+		{ID: "2", Ingredients: ingredients("gemahlene Haselnüsse")},
+		{ID: "3", Ingredients: ingredients("nutmeg", "veggies")},
+	}
+	contained := allergens.contained(recipes)
+
+	if got := strings.Join(contained["1"], ","); got != "eggs,nuts" {
+		t.Errorf("wrong allergens for recipe 1: %s", got)
+	}
+	if got := strings.Join(contained["2"], ","); got != "nuts" {
+		t.Errorf("wrong allergens for recipe 2: %s", got)
+	}
+	if _, found := contained["3"]; found {
+		t.Errorf("keywords matched in the middle of words: %v", contained["3"])
+	}
+}
+
+func TestBuildMarkdownAllergens(t *testing.T) {
+	recipes := []mealieclient.Recipe{
+		{
+			ID: "1", Name: "Omelette", Slug: "omelette",
+			Ingredients: []mealieclient.Ingredient{{Food: &mealieclient.Food{Name: "egg"}}},
+		},
+		{ID: "2", Name: "Bread", Slug: "bread"},
+	}
+	opts := MarkdownOptions{Allergens: Allergens{"eggs": {"egg"}, "nuts": {"nut"}}}
+	markdown := BuildMarkdown(recipes, "https://mealie", opts)
+
+	for _, expected := range []string{
+		`**Contains**: [<span`, `>eggs</span>](#allergen-eggs)`,
+		"# Allergens", "## <a name=\"allergen-eggs\"></a> eggs\n\n- [Omelette](#recipe-omelette)",
+		"## <a name=\"allergen-nuts\"></a> nuts\n\nNo recipe contains this allergen.",
+	} {
+		if !strings.Contains(markdown, expected) {
+			t.Errorf("missing %q:\n%s", expected, markdown)
+		}
+	}
+	if strings.Count(markdown, "**Contains**") != 1 {
+		t.Errorf("recipes without allergens have badges:\n%s", markdown)
+	}
+
+	opts.Presentation = presentationFrom(WithSingleRecipe(context.Background(), "Omelette"))
+	markdown = BuildMarkdown(recipes[:1], "https://mealie", opts)
+	if strings.Contains(markdown, "#allergen-") || strings.Contains(markdown, "# Allergens") {
+		t.Errorf("single recipe links to an allergen index:\n%s", markdown)
+	}
+}
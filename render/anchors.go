@@ -0,0 +1,91 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Used for items whose names contain neither letters nor digits.
+const unnamedAnchor = "unnamed"
+
+type anchorKey struct {
+	prefix string
+	key    string
+}
+
+// anchors assigns every recipe, tag, and category a unique anchor in a document. Anchors are
+// derived from names but disambiguated with a counter, so duplicate names and names that slugify to
+// the same string still link to the right item.
+type anchors struct {
+	used     map[string]bool
+	assigned map[anchorKey]string
+}
+
+func newAnchors() *anchors {
+	return &anchors{used: map[string]bool{}, assigned: map[anchorKey]string{}}
+}
+
+// Get the anchor for the item with the given prefix and key, e.g. a recipe ID. A new anchor is
+// derived from the name if the item does not have one yet.
+func (a *anchors) get(prefix string, key string, name string) string {
+	if anchor, found := a.assigned[anchorKey{prefix, key}]; found {
+		return anchor
+	}
+	slug := slugify(name)
+	if slug == "" {
+		slug = unnamedAnchor
+	}
+	base := prefix + "-" + slug
+	anchor := base
+	for idx := 2; a.used[anchor]; idx++ {
+		anchor = fmt.Sprintf("%s-%d", base, idx)
+	}
+	a.used[anchor] = true
+	a.assigned[anchorKey{prefix, key}] = anchor
+	return anchor
+}
+
+func (a *anchors) recipe(recipe *mealieclient.Recipe) string {
+	return a.get("recipe", recipe.ID, recipe.Name)
+}
+
+func (a *anchors) tag(name string) string {
+	return a.get("tag", name, name)
+}
+
+func (a *anchors) category(name string) string {
+	return a.get("category", name, name)
+}
+
+func (a *anchors) allergen(name string) string {
+	return a.get("allergen", name, name)
+}
+
+// Convert a name to lower case and replace every run of characters that are neither letters nor
+// digits by a single hyphen. Letters outside of ASCII are kept.
+func slugify(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
@@ -0,0 +1,134 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// Azw3Generator generates AZW3 documents, which Kindle e-readers understand. It converts an EPUB
+// document via calibre's ebook-convert executable, which retains the table of contents.
+type Azw3Generator struct {
+	URL string
+	// Converter has to support conversions to EPUB.
+	Converter Converter
+	// Captions renders captions below images.
+	Captions bool
+	// Styles highlight categories and the recipes in them.
+	Styles CategoryStyles
+	// Difficulty shows the estimated difficulty of every recipe.
+	Difficulty bool
+	// Nutrition shows nutritional values per serving and their averages per category.
+	Nutrition bool
+	// Allergens are highlighted in every recipe containing them.
+	Allergens Allergens
+	// Metadata are the defaults for the metadata of every document. Keywords and the language are
+	// determined automatically.
+	Metadata Metadata
+}
+
+// CheckForEbookConvert verifies that calibre's ebook-convert executable can be found. It is needed
+// only to generate AZW3 documents.
+func CheckForEbookConvert() error {
+	_, err := exec.LookPath("ebook-convert")
+	if err != nil {
+		return fmt.Errorf("failed to find ebook-convert in path: %s", err.Error())
+	}
+	return nil
+}
+
+// CommonName is the name of the format.
+func (g *Azw3Generator) CommonName() string {
+	return "azw3"
+}
+
+// Extension is the file extension of the format.
+func (g *Azw3Generator) Extension() string {
+	return "azw3"
+}
+
+// MimeType is the mime type of the format.
+func (g *Azw3Generator) MimeType() string {
+	return "application/vnd.amazon.mobi8-ebook"
+}
+
+// Response generates a document containing all recipes.
+func (g *Azw3Generator) Response(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+) ([]byte, error) {
+	opts := MarkdownOptions{
+		Captions:     g.Captions,
+		Styles:       g.Styles,
+		Difficulty:   g.Difficulty,
+		Nutrition:    g.Nutrition,
+		Allergens:    g.Allergens,
+		Warnings:     warningsFrom(ctx),
+		Presentation: presentationFrom(ctx),
+	}
+	ctx = WithMetadata(ctx, documentMetadata(ctx, g.Metadata, recipes))
+	epub, err := g.Converter.Convert(
+		ctx, BuildMarkdown(recipes, g.URL, opts), "epub", documentTitle(ctx, timestamp),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ebookConvert(ctx, epub)
+}
+
+// Convert an EPUB document to AZW3 via ebook-convert, which only works on files.
+func ebookConvert(ctx context.Context, epub []byte) ([]byte, error) {
+	tmpdir, err := os.MkdirTemp(workdir.Dir(ctx), workdir.Prefix+"azw3-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %s", err.Error())
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			slog.WarnContext(
+				ctx, "failed to remove temporary directory", "path", tmpdir, "error", err,
+			)
+		}
+	}()
+	input := filepath.Join(tmpdir, "recipes.epub")
+	output := filepath.Join(tmpdir, "recipes.azw3")
+	err = os.WriteFile(input, epub, 0o600) //nolint:mnd
+	if err != nil {
+		return nil, fmt.Errorf("failed to write epub document: %s", err.Error())
+	}
+
+	summary.From(ctx).Stage("running ebook-convert")
+	start := time.Now()
+	_, errMsg, err := runExe(ctx, "ebook-convert", []string{input, output}, nil, nil, tmpdir)
+	summary.From(ctx).AddPass("ebook-convert", time.Since(start))
+	if err != nil {
+		logStderr(ctx, "ebook-convert", errMsg, err)
+		return nil, fmt.Errorf("failed to convert to azw3: %s", err.Error())
+	}
+	return os.ReadFile(output) // #nosec:G304
+}
@@ -0,0 +1,73 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"slices"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Language determines the collation rules used to sort recipes, tags, and categories by name. The
+// default uses the root collation order, which already sorts accented letters next to their base
+// letters.
+var Language = language.Und
+
+// Collators are not safe for concurrent use. Thus, we create a new one for every sort.
+func newCollator() *collate.Collator {
+	return collate.New(Language)
+}
+
+// SortNames sorts names according to the collation rules of the configured language.
+func SortNames(names []string) {
+	newCollator().SortStrings(names)
+}
+
+// SortOrganisers sorts tags or categories by name according to the collation rules of the
+// configured language.
+func SortOrganisers(organisers []mealieclient.Organiser) {
+	collator := newCollator()
+	slices.SortStableFunc(organisers, func(a, b mealieclient.Organiser) int {
+		return collator.CompareString(a.Name, b.Name)
+	})
+}
+
+// OrderRecipes sorts recipes if the query asks mealie to order them by name or by difficulty.
+// Names are sorted according to the collation rules of the configured language. Mealie's database
+// does not know about the language and would, for example, sort "Äpfel" after "Zwiebeln". Mealie
+// does not know about difficulties at all, see mealieclient.OrderByDifficulty.
+func OrderRecipes(recipes []mealieclient.Recipe, query map[string][]string) {
+	sign := 1
+	if slices.Contains(query["orderDirection"], "desc") {
+		sign = -1
+	}
+	switch {
+	case slices.Contains(query["orderBy"], "name"):
+		collator := newCollator()
+		slices.SortStableFunc(recipes, func(a, b mealieclient.Recipe) int {
+			return sign * collator.CompareString(a.Name, b.Name)
+		})
+	case slices.Contains(query["orderBy"], mealieclient.OrderByDifficulty):
+		slices.SortStableFunc(recipes, func(a, b mealieclient.Recipe) int {
+			return sign * (a.Difficulty() - b.Difficulty())
+		})
+	}
+}
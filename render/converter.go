@@ -0,0 +1,184 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// HTMLHook modifies an intermediate HTML document before it is converted further.
+type HTMLHook func(*html.Node) (*html.Node, error)
+
+// Converter converts markdown input into the output format toFormat.
+type Converter interface {
+	Convert(
+		ctx context.Context,
+		markdownInput string,
+		toFormat string,
+		title string,
+	) ([]byte, error)
+}
+
+// FileConverter is a Converter that can also write converted documents to files directly. That way,
+// large documents need not be kept in memory as a whole.
+type FileConverter interface {
+	Converter
+	ConvertToFile(
+		ctx context.Context,
+		markdownInput string,
+		toFormat string,
+		title string,
+		path string,
+	) error
+}
+
+// Convert markdown input and write the result to the file at path. Converters that cannot write
+// files directly keep the result in memory before writing it.
+func convertToFile(
+	ctx context.Context,
+	converter Converter,
+	markdownInput string,
+	toFormat string,
+	title string,
+	path string,
+) error {
+	if fileConverter, ok := converter.(FileConverter); ok {
+		return fileConverter.ConvertToFile(ctx, markdownInput, toFormat, title, path)
+	}
+	converted, err := converter.Convert(ctx, markdownInput, toFormat, title)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, converted, 0o600); err != nil { //nolint:mnd
+		return fmt.Errorf("failed to write %s: %s", path, err.Error())
+	}
+	return nil
+}
+
+type pandocFlagsKey struct{}
+
+// WithPandocFlags returns a context that makes pandoc-based converters use additional flags for the
+// conversion. Flags may use the same prefixes as the options passed to NewPandoc. Validate them
+// using ValidatePandocFlags first.
+func WithPandocFlags(ctx context.Context, flags []string) context.Context {
+	return context.WithValue(ctx, pandocFlagsKey{}, flags)
+}
+
+func pandocFlagsFrom(ctx context.Context) []string {
+	flags, _ := ctx.Value(pandocFlagsKey{}).([]string)
+	return flags
+}
+
+// ValidatePandocFlags ensures that only flags whose names are in the allowlist are used. The name
+// of a flag is everything before the first equals sign, ignoring any prefix.
+func ValidatePandocFlags(flags []string, allowlist []string) error {
+	for _, flag := range flags {
+		name := flag
+		for _, prefix := range []string{"@first:", "@last:"} {
+			name = strings.TrimPrefix(name, prefix)
+		}
+		name, _, _ = strings.Cut(name, "=")
+		if !slices.Contains(allowlist, name) {
+			return fmt.Errorf("pandoc flag %s is not allowed", name)
+		}
+	}
+	return nil
+}
+
+// Hooks that are run on the intermediate HTML document only when converting to specific formats.
+var filetypeHooks = map[string]HTMLHook{
+	"markdown_github": RemoveImages,
+	// LaTeX cannot embed SVG images.
+	"pdf": RasterizeSvgImages,
+	// Typst cannot retrieve images via HTTP.
+	"typst": RemoveImages,
+}
+
+// Names of the supported converter backends.
+const (
+	BackendPandoc = "pandoc"
+	BackendNative = "native"
+	BackendTypst  = "typst"
+	BackendHTTP   = "http"
+)
+
+// ConverterSpec declares which backend shall be used to convert to a format. An empty backend means
+// pandoc. The URL and URLs are used only by the http backend.
+type ConverterSpec struct {
+	Backend string   `json:"backend"`
+	URL     string   `json:"url"`
+	URLs    []string `json:"urls"`
+}
+
+// Validate checks that the backend is known, that it supports the format with the given common
+// name, and that all settings it needs are present.
+func (s ConverterSpec) Validate(format string) error {
+	switch s.Backend {
+	case "", BackendPandoc:
+	case BackendNative:
+		if format != "html" {
+			return fmt.Errorf("backend %s supports only html but not %s", s.Backend, format)
+		}
+	case BackendTypst:
+		if format != "pdf" {
+			return fmt.Errorf("backend %s supports only pdf but not %s", s.Backend, format)
+		}
+	case BackendHTTP:
+		if s.URL == "" && len(s.URLs) == 0 {
+			return fmt.Errorf("backend %s requires a url or urls", s.Backend)
+		}
+	default:
+		return fmt.Errorf("unknown converter backend %s", s.Backend)
+	}
+	return nil
+}
+
+// NeedsPandoc returns whether the backend requires the pandoc executable.
+func (s ConverterSpec) NeedsPandoc() bool {
+	return s.Backend == "" || s.Backend == BackendPandoc || s.Backend == BackendTypst
+}
+
+// NewConverter builds the converter declared by the spec. All backends that need pandoc share the
+// given pandoc converter. The native backend runs the given hooks on every HTML document. The http
+// backend presents the token, if any, to conversion services.
+func NewConverter(
+	spec ConverterSpec, pandoc *Pandoc, htmlHooks []HTMLHook, token string,
+) (Converter, error) {
+	switch spec.Backend {
+	case "", BackendPandoc:
+		return pandoc, nil
+	case BackendNative:
+		return NewNative(htmlHooks), nil
+	case BackendTypst:
+		return NewTypst(pandoc), nil
+	case BackendHTTP:
+		urls := append([]string{}, spec.URLs...)
+		if spec.URL != "" {
+			urls = append(urls, spec.URL)
+		}
+		return NewRemote(urls, token), nil
+	default:
+		return nil, fmt.Errorf("unknown converter backend %s", spec.Backend)
+	}
+}
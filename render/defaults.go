@@ -0,0 +1,130 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+)
+
+// A pandoc defaults file that holds all options we set ourselves, see
+// https://pandoc.org/MANUAL.html#defaults-files. Options given by users are passed as command-line
+// arguments before it, which is why they cannot override its options.
+type pandocDefaults struct {
+	From            string            `yaml:"from"`
+	To              string            `yaml:"to"`
+	Verbosity       string            `yaml:"verbosity"`
+	Standalone      bool              `yaml:"standalone,omitempty"`
+	EmbedResources  bool              `yaml:"embed-resources,omitempty"`
+	TableOfContents bool              `yaml:"table-of-contents,omitempty"`
+	NumberSections  bool              `yaml:"number-sections,omitempty"`
+	EpubTitlePage   *bool             `yaml:"epub-title-page,omitempty"`
+	PDFEngine       string            `yaml:"pdf-engine,omitempty"`
+	PDFEngineOpts   []string          `yaml:"pdf-engine-opts,omitempty"`
+	Metadata        map[string]string `yaml:"metadata,omitempty"`
+	Variables       map[string]any    `yaml:"variables,omitempty"`
+}
+
+// Defaults of the first pass, which converts markdown to HTML.
+func firstPassDefaults(metadata map[string]string) pandocDefaults {
+	return pandocDefaults{From: "markdown", To: "html5", Verbosity: "INFO", Metadata: metadata}
+}
+
+// Defaults of the second pass, which converts HTML to the desired format. Variables of specific
+// formats, e.g. fonts of PDF documents, are added by the caller.
+func lastPassDefaults(toFormat string, metadata map[string]string) pandocDefaults {
+	epubTitlePage := false
+	return pandocDefaults{
+		From:            "html",
+		To:              toFormat,
+		Verbosity:       "INFO",
+		Standalone:      true,
+		EmbedResources:  true,
+		TableOfContents: true,
+		EpubTitlePage:   &epubTitlePage,
+		Metadata:        metadata,
+		Variables:       map[string]any{"geometry": "margin=2cm"},
+	}
+}
+
+// Write the defaults to a new file in dir, or the system's temporary directory if dir is empty.
+// Return the path of the file and its content.
+func (d pandocDefaults) write(dir string) (string, []byte, error) {
+	content, err := yaml.Marshal(d)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode pandoc defaults: %s", err.Error())
+	}
+	file, err := os.CreateTemp(dir, "pandoc-defaults-*.yaml")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create pandoc defaults file: %s", err.Error())
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", nil, fmt.Errorf("failed to write pandoc defaults file: %s", err.Error())
+	}
+	return file.Name(), content, nil
+}
+
+// What pandoc received for the passes of the most recent conversion to each format, keyed by
+// format and then by pass.
+var recentPasses = struct {
+	lock     sync.Mutex
+	byFormat map[string]map[int][]byte
+}{byFormat: map[string]map[int][]byte{}}
+
+// Remember what pandoc received for a pass of a conversion to a format.
+func recordPass(toFormat string, pass int, args []string, defaults []byte) {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "# pandoc pass %d/2, arguments: %s\n", pass, strings.Join(args, " "))
+	buf.Write(defaults)
+
+	recentPasses.lock.Lock()
+	defer recentPasses.lock.Unlock()
+	if recentPasses.byFormat[toFormat] == nil {
+		recentPasses.byFormat[toFormat] = map[int][]byte{}
+	}
+	recentPasses.byFormat[toFormat][pass] = buf.Bytes()
+}
+
+// RecentPandocDefaults returns the defaults files that pandoc received for both passes of the most
+// recent conversion to the given format as a single YAML stream. A comment before each of them
+// lists the command-line arguments of the pass. Return false if there was no such conversion.
+func RecentPandocDefaults(toFormat string) ([]byte, bool) {
+	recentPasses.lock.Lock()
+	defer recentPasses.lock.Unlock()
+	passes, found := recentPasses.byFormat[toFormat]
+	if !found {
+		return nil, false
+	}
+	docs := [][]byte{}
+	for _, pass := range []int{1, 2} {
+		if content, found := passes[pass]; found {
+			docs = append(docs, content)
+		}
+	}
+	return bytes.Join(docs, []byte("---\n")), true
+}
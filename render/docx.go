@@ -0,0 +1,98 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// DocxGenerator generates DOCX documents, which can be edited with word processors.
+type DocxGenerator struct {
+	URL       string
+	Converter Converter
+	// Captions renders captions below images.
+	Captions bool
+	// Styles highlight categories and the recipes in them.
+	Styles CategoryStyles
+	// Difficulty shows the estimated difficulty of every recipe.
+	Difficulty bool
+	// Nutrition shows nutritional values per serving and their averages per category.
+	Nutrition bool
+	// Allergens are highlighted in every recipe containing them.
+	Allergens Allergens
+	// Metadata are the defaults for the metadata of every document. Keywords and the language are
+	// determined automatically.
+	Metadata Metadata
+}
+
+// CommonName is the name of the format.
+func (g *DocxGenerator) CommonName() string {
+	return "docx"
+}
+
+// Extension is the file extension of the format.
+func (g *DocxGenerator) Extension() string {
+	return "docx"
+}
+
+// MimeType is the mime type of the format.
+func (g *DocxGenerator) MimeType() string {
+	return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+}
+
+// Response generates a document containing all recipes.
+func (g *DocxGenerator) Response(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+) ([]byte, error) {
+	ctx, markdown := g.markdown(ctx, recipes)
+	return g.Converter.Convert(ctx, markdown, "docx", documentTitle(ctx, timestamp))
+}
+
+// ResponseFile generates the same document as Response and writes it to the file at path.
+func (g *DocxGenerator) ResponseFile(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+	path string,
+) error {
+	ctx, markdown := g.markdown(ctx, recipes)
+	return convertToFile(ctx, g.Converter, markdown, "docx", documentTitle(ctx, timestamp), path)
+}
+
+// Build the input for the converter and a context that carries the metadata of the document.
+func (g *DocxGenerator) markdown(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+) (context.Context, string) {
+	opts := MarkdownOptions{
+		Captions:     g.Captions,
+		Styles:       g.Styles,
+		Difficulty:   g.Difficulty,
+		Nutrition:    g.Nutrition,
+		Allergens:    g.Allergens,
+		Warnings:     warningsFrom(ctx),
+		Presentation: presentationFrom(ctx),
+	}
+	ctx = WithMetadata(ctx, documentMetadata(ctx, g.Metadata, recipes))
+	return ctx, BuildMarkdown(recipes, g.URL, opts)
+}
@@ -0,0 +1,99 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Records what it is asked to convert instead of converting anything.
+type recordingConverter struct {
+	markdown string
+	format   string
+	title    string
+	metadata Metadata
+}
+
+func (c *recordingConverter) Convert(
+	ctx context.Context,
+	markdownInput string,
+	toFormat string,
+	title string,
+) ([]byte, error) {
+	c.markdown, c.format, c.title, c.metadata = markdownInput, toFormat, title, metadataFrom(ctx)
+	return []byte("converted"), nil
+}
+
+func TestDocxGeneratorFormat(t *testing.T) {
+	gen := &DocxGenerator{}
+	if got := gen.CommonName(); got != "docx" {
+		t.Errorf("CommonName() = %s, want docx", got)
+	}
+	if got := gen.Extension(); got != "docx" {
+		t.Errorf("Extension() = %s, want docx", got)
+	}
+	want := "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	if got := gen.MimeType(); got != want {
+		t.Errorf("MimeType() = %s, want %s", got, want)
+	}
+}
+
+func TestDocxGeneratorResponse(t *testing.T) {
+	converter := &recordingConverter{}
+	gen := &DocxGenerator{
+		URL:       "http://mealie",
+		Converter: converter,
+		Metadata:  Metadata{Author: "Default", Subject: "Recipes"},
+	}
+	recipes := []mealieclient.Recipe{
+		{ID: "1", Name: "Apple Pie", Categories: []mealieclient.Organiser{{Name: "Dessert"}}},
+		{ID: "2", Name: "Soup", Categories: []mealieclient.Organiser{{Name: "Starter"}}},
+	}
+	timestamp := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ctx := WithMetadata(context.Background(), Metadata{Author: "Query"})
+
+	response, err := gen.Response(ctx, recipes, timestamp)
+	if err != nil {
+		t.Fatalf("Response() failed: %s", err.Error())
+	}
+	if string(response) != "converted" {
+		t.Errorf("Response() = %s, want the converted document", string(response))
+	}
+	if converter.format != "docx" {
+		t.Errorf("converted to %s, want docx", converter.format)
+	}
+	if converter.title != BuildTitle(timestamp) {
+		t.Errorf("title is %s, want %s", converter.title, BuildTitle(timestamp))
+	}
+	for _, recipe := range recipes {
+		if !strings.Contains(converter.markdown, recipe.Name) {
+			t.Errorf("document lacks recipe %s", recipe.Name)
+		}
+	}
+	if converter.metadata.Author != "Query" || converter.metadata.Subject != "Recipes" {
+		t.Errorf("unexpected author and subject in metadata: %+v", converter.metadata)
+	}
+	if strings.Join(converter.metadata.Keywords, ",") != "Dessert,Starter" {
+		t.Errorf("unexpected keywords in metadata: %v", converter.metadata.Keywords)
+	}
+}
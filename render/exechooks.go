@@ -0,0 +1,126 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Stages of the export pipeline that external scripts can hook into.
+const (
+	StagePreFetch     = "pre-fetch"
+	StagePostMarkdown = "post-markdown"
+	StagePostRender   = "post-render"
+)
+
+// ExecHooks are commands, i.e. an executable followed by its arguments, that are run at specific
+// stages of the export pipeline. Each command receives intermediate data on stdin and has to write
+// the data that shall be used instead to stdout. The pre-fetch hook receives the query parameters
+// as a JSON object mapping names to lists of values before any recipes are retrieved. The
+// post-markdown hook receives the markdown document before it is converted. The post-render hook
+// receives the converted document. Commands can find the stage in the MA_HOOK_STAGE and the format
+// in the MA_HOOK_FORMAT environment variable.
+type ExecHooks struct {
+	PreFetch     []string `json:"pre-fetch"`
+	PostMarkdown []string `json:"post-markdown"`
+	PostRender   []string `json:"post-render"`
+}
+
+// Run the command for a stage on the input and return its output. Without a command, the input is
+// returned unchanged.
+func runExecHook(
+	ctx context.Context,
+	command []string,
+	stage string,
+	format string,
+	input []byte,
+) ([]byte, error) {
+	if len(command) == 0 {
+		return input, nil
+	}
+	env := append(os.Environ(), "MA_HOOK_STAGE="+stage, "MA_HOOK_FORMAT="+format)
+	output, errMsg, err := runExe(ctx, command[0], command[1:], env, input, "")
+	logStderr(ctx, stage+" hook", errMsg, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s hook: %s", stage, err.Error())
+	}
+	return output, nil
+}
+
+// RunPreFetch lets the pre-fetch hook modify the query parameters used to retrieve recipes.
+func (h ExecHooks) RunPreFetch(
+	ctx context.Context,
+	query map[string][]string,
+) (map[string][]string, error) {
+	if len(h.PreFetch) == 0 {
+		return query, nil
+	}
+	input, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert query to json: %s", err.Error())
+	}
+	output, err := runExecHook(ctx, h.PreFetch, StagePreFetch, "", input)
+	if err != nil {
+		return nil, err
+	}
+	modified := map[string][]string{}
+	err = json.Unmarshal(output, &modified)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to parse output of %s hook as the expected JSON: %s",
+			StagePreFetch, err.Error(),
+		)
+	}
+	return modified, nil
+}
+
+// WrapConverter returns a converter that runs the post-markdown hook before and the post-render
+// hook after the conversion performed by the given converter.
+func (h ExecHooks) WrapConverter(converter Converter) Converter {
+	if len(h.PostMarkdown) == 0 && len(h.PostRender) == 0 {
+		return converter
+	}
+	return &hookedConverter{converter: converter, hooks: h}
+}
+
+type hookedConverter struct {
+	converter Converter
+	hooks     ExecHooks
+}
+
+func (c *hookedConverter) Convert(
+	ctx context.Context,
+	markdownInput string,
+	toFormat string,
+	title string,
+) ([]byte, error) {
+	markdown, err := runExecHook(
+		ctx, c.hooks.PostMarkdown, StagePostMarkdown, toFormat, []byte(markdownInput),
+	)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := c.converter.Convert(ctx, string(markdown), toFormat, title)
+	if err != nil {
+		return nil, err
+	}
+	return runExecHook(ctx, c.hooks.PostRender, StagePostRender, toFormat, converted)
+}
@@ -0,0 +1,316 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// A range of code points, both ends inclusive.
+type runeRange struct {
+	first rune
+	last  rune
+}
+
+// The code points that a font has glyphs for, sorted by code point.
+type glyphCoverage []runeRange
+
+func (g glyphCoverage) covers(r rune) bool {
+	idx := sort.Search(len(g), func(idx int) bool { return g[idx].last >= r })
+	return idx < len(g) && g[idx].first <= r
+}
+
+// Determine the code points that a TrueType font has glyphs for by parsing its cmap table. Only
+// the unicode subtables in formats 4 and 12 are considered, which are the ones used in practice.
+func parseGlyphCoverage(font []byte) (glyphCoverage, error) {
+	if len(font) < 12 { //nolint:mnd
+		return nil, fmt.Errorf("font file too short")
+	}
+	var cmap []byte
+	numTables := int(binary.BigEndian.Uint16(font[4:]))
+	for idx := range numTables {
+		record := 12 + idx*16 //nolint:mnd
+		if record+16 > len(font) {
+			break
+		}
+		if string(font[record:record+4]) == "cmap" {
+			offset := int(binary.BigEndian.Uint32(font[record+8:]))  // #nosec:G115
+			length := int(binary.BigEndian.Uint32(font[record+12:])) // #nosec:G115
+			if offset < 0 || length < 0 || offset+length > len(font) {
+				return nil, fmt.Errorf("cmap table out of bounds")
+			}
+			cmap = font[offset : offset+length]
+		}
+	}
+	if len(cmap) < 4 { //nolint:mnd
+		return nil, fmt.Errorf("no cmap table found")
+	}
+
+	coverage := glyphCoverage{}
+	numSubtables := int(binary.BigEndian.Uint16(cmap[2:]))
+	for idx := range numSubtables {
+		record := 4 + idx*8 //nolint:mnd
+		if record+8 > len(cmap) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(cmap[record:])
+		encoding := binary.BigEndian.Uint16(cmap[record+2:])
+		isUnicode := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		offset := int(binary.BigEndian.Uint32(cmap[record+4:])) // #nosec:G115
+		if !isUnicode || offset < 0 || offset+2 > len(cmap) {
+			continue
+		}
+		subtable := cmap[offset:]
+		switch binary.BigEndian.Uint16(subtable) {
+		case 4: //nolint:mnd
+			coverage = append(coverage, parseCmapFormat4(subtable)...)
+		case 12: //nolint:mnd
+			coverage = append(coverage, parseCmapFormat12(subtable)...)
+		}
+	}
+	if len(coverage) == 0 {
+		return nil, fmt.Errorf("no supported unicode cmap subtable found")
+	}
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i].first < coverage[j].first })
+	// Merge overlapping ranges so that lookups can use a binary search.
+	merged := glyphCoverage{coverage[0]}
+	for _, current := range coverage[1:] {
+		last := &merged[len(merged)-1]
+		if current.first <= last.last+1 {
+			last.last = max(last.last, current.last)
+		} else {
+			merged = append(merged, current)
+		}
+	}
+	return merged, nil
+}
+
+// Segments in format 4 map ranges of code points to glyphs either via a delta or via an array of
+// glyph IDs. Code points mapped to glyph 0 have no glyph.
+func parseCmapFormat4(subtable []byte) glyphCoverage {
+	if len(subtable) < 14 { //nolint:mnd
+		return nil
+	}
+	segCount := int(binary.BigEndian.Uint16(subtable[6:])) / 2 //nolint:mnd
+	endCodes := 14                                             //nolint:mnd
+	startCodes := endCodes + 2*segCount + 2                    //nolint:mnd
+	idDeltas := startCodes + 2*segCount                        //nolint:mnd
+	idRangeOffsets := idDeltas + 2*segCount                    //nolint:mnd
+	if idRangeOffsets+2*segCount > len(subtable) {
+		return nil
+	}
+	u16 := func(pos int) int {
+		if pos+2 > len(subtable) {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(subtable[pos:]))
+	}
+
+	coverage := glyphCoverage{}
+	for seg := range segCount {
+		first, last := u16(startCodes+2*seg), u16(endCodes+2*seg)
+		delta, rangeOffset := u16(idDeltas+2*seg), u16(idRangeOffsets+2*seg)
+		if first == 0xffff { //nolint:mnd
+			continue
+		}
+		if rangeOffset == 0 {
+			for code := first; code <= last; code++ {
+				if (code+delta)&0xffff != 0 {
+					coverage = append(coverage, runeRange{rune(code), rune(code)})
+				}
+			}
+			continue
+		}
+		for code := first; code <= last; code++ {
+			glyph := u16(idRangeOffsets + 2*seg + rangeOffset + 2*(code-first))
+			if glyph != 0 && (glyph+delta)&0xffff != 0 {
+				coverage = append(coverage, runeRange{rune(code), rune(code)})
+			}
+		}
+	}
+	return coverage
+}
+
+// Format 12 maps groups of code points to consecutive glyphs.
+func parseCmapFormat12(subtable []byte) glyphCoverage {
+	if len(subtable) < 16 { //nolint:mnd
+		return nil
+	}
+	numGroups := int(binary.BigEndian.Uint32(subtable[12:])) // #nosec:G115
+	coverage := glyphCoverage{}
+	for idx := range numGroups {
+		group := 16 + idx*12 //nolint:mnd
+		if group+12 > len(subtable) {
+			break
+		}
+		first := rune(binary.BigEndian.Uint32(subtable[group:]))  // #nosec:G115
+		last := rune(binary.BigEndian.Uint32(subtable[group+4:])) // #nosec:G115
+		if binary.BigEndian.Uint32(subtable[group+8:]) == 0 {
+			// The first code point maps to the glyph used for missing characters.
+			first++
+		}
+		if first <= last {
+			coverage = append(coverage, runeRange{first, last})
+		}
+	}
+	return coverage
+}
+
+// Find all characters in text that none of the fonts has a glyph for. Whitespace and control
+// characters are ignored.
+func missingGlyphs(text string, fonts []glyphCoverage) []rune {
+	missing := map[rune]bool{}
+	for _, char := range text {
+		if unicode.IsSpace(char) || unicode.IsControl(char) || missing[char] {
+			continue
+		}
+		if !slices.ContainsFunc(fonts, func(font glyphCoverage) bool { return font.covers(char) }) {
+			missing[char] = true
+		}
+	}
+	result := make([]rune, 0, len(missing))
+	for char := range missing {
+		result = append(result, char)
+	}
+	slices.Sort(result)
+	return result
+}
+
+func describeRunes(runes []rune) string {
+	descriptions := make([]string, 0, len(runes))
+	for _, char := range runes {
+		descriptions = append(descriptions, fmt.Sprintf("%q (U+%04X)", char, char))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+var baseFontRegex = regexp.MustCompile(`/BaseFont\s*/([^\s/<>\[\]()]+)`)
+
+// EmbeddedFonts lists the names of all fonts used in a PDF document. Font dictionaries are often
+// stored in compressed object streams, which is why all compressed streams are searched, too.
+// Subset fonts are marked as such.
+func EmbeddedFonts(pdf []byte) []string {
+	sources := [][]byte{pdf}
+	for rest := pdf; ; {
+		start := bytes.Index(rest, []byte("stream"))
+		if start == -1 {
+			break
+		}
+		rest = bytes.TrimLeft(rest[start+len("stream"):], "\r\n")
+		end := bytes.Index(rest, []byte("endstream"))
+		if end == -1 {
+			break
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(rest[:end]))
+		if err == nil {
+			// Errors are expected for streams that are not compressed or truncated.
+			decompressed, _ := io.ReadAll(reader)
+			sources = append(sources, decompressed)
+		}
+		rest = rest[end:]
+	}
+
+	fonts := []string{}
+	for _, source := range sources {
+		for _, match := range baseFontRegex.FindAllSubmatch(source, -1) {
+			name := string(match[1])
+			// Subset fonts are prefixed by six upper case letters and a plus sign.
+			if len(name) > 7 && name[6] == '+' && strings.ToUpper(name[:6]) == name[:6] {
+				name = name[7:] + " (subset)"
+			}
+			if !slices.Contains(fonts, name) {
+				fonts = append(fonts, name)
+			}
+		}
+	}
+	slices.Sort(fonts)
+	return fonts
+}
+
+// CheckForGhostscript verifies that the gs executable can be found. It is needed only for font
+// subsetting.
+func CheckForGhostscript() error {
+	_, err := exec.LookPath("gs")
+	if err != nil {
+		return fmt.Errorf("failed to find gs in path: %s", err.Error())
+	}
+	return nil
+}
+
+// Subset all fonts embedded in a PDF document via ghostscript, which reduces its size. Temporary
+// files are created in a new directory in tmpRoot.
+func subsetFonts(ctx context.Context, pdf []byte, tmpRoot string) ([]byte, error) {
+	tmpdir, err := os.MkdirTemp(tmpRoot, workdir.Prefix+"subset-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %s", err.Error())
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			slog.WarnContext(
+				ctx, "failed to remove temporary directory", "path", tmpdir, "error", err,
+			)
+		}
+	}()
+	input := filepath.Join(tmpdir, "input.pdf")
+	output := filepath.Join(tmpdir, "output.pdf")
+	if err := os.WriteFile(input, pdf, 0o600); err != nil { //nolint:mnd
+		return nil, fmt.Errorf("failed to write pdf: %s", err.Error())
+	}
+
+	if err := subsetFontFile(ctx, input, output); err != nil {
+		return nil, err
+	}
+
+	subset, err := os.ReadFile(output) // #nosec:G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subset pdf: %s", err.Error())
+	}
+	slog.InfoContext(ctx, "subset fonts in pdf", "bytesBefore", len(pdf), "bytesAfter", len(subset))
+	return subset, nil
+}
+
+// Subset all fonts embedded in the PDF document at input via ghostscript and write the result to
+// output.
+func subsetFontFile(ctx context.Context, input string, output string) error {
+	args := []string{
+		"-q", "-dNOPAUSE", "-dBATCH", "-dSAFER",
+		"-sDEVICE=pdfwrite", "-dSubsetFonts=true", "-dEmbedAllFonts=true",
+		"-sOutputFile=" + output, input,
+	}
+	_, errMsg, err := runExe(ctx, "gs", args, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to run gs: %s, stderr: %s", err.Error(), errMsg)
+	}
+	return nil
+}
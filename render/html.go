@@ -0,0 +1,418 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// HTMLGenerator generates HTML documents.
+type HTMLGenerator struct {
+	URL       string
+	Converter Converter
+	// Styles highlight categories and the recipes in them.
+	Styles CategoryStyles
+	// Difficulty shows the estimated difficulty of every recipe.
+	Difficulty bool
+	// Nutrition shows nutritional values per serving and their averages per category.
+	Nutrition bool
+	// Allergens are highlighted in every recipe containing them.
+	Allergens Allergens
+}
+
+// CommonName is the name of the format.
+func (g *HTMLGenerator) CommonName() string {
+	return "html"
+}
+
+// Extension is the file extension of the format.
+func (g *HTMLGenerator) Extension() string {
+	return "html"
+}
+
+// MimeType is the mime type of the format.
+func (g *HTMLGenerator) MimeType() string {
+	return "text/html"
+}
+
+// Response generates a document containing all recipes.
+func (g *HTMLGenerator) Response(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+) ([]byte, error) {
+	ctx, markdown := g.markdown(ctx, recipes)
+	return g.Converter.Convert(ctx, markdown, "html", documentTitle(ctx, timestamp))
+}
+
+// ResponseFile generates the same document as Response and writes it to the file at path.
+func (g *HTMLGenerator) ResponseFile(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+	path string,
+) error {
+	ctx, markdown := g.markdown(ctx, recipes)
+	return convertToFile(ctx, g.Converter, markdown, "html", documentTitle(ctx, timestamp), path)
+}
+
+// Build the input for the converter. The context is returned unchanged.
+func (g *HTMLGenerator) markdown(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+) (context.Context, string) {
+	opts := MarkdownOptions{
+		Styles: g.Styles, Difficulty: g.Difficulty, Nutrition: g.Nutrition,
+		Allergens:    g.Allergens,
+		Warnings:     warningsFrom(ctx),
+		Presentation: presentationFrom(ctx),
+	}
+	return ctx, BuildMarkdown(recipes, g.URL, opts)
+}
+
+// RemoveAllHTMLElements removes all elements of the given type from the document.
+func RemoveAllHTMLElements(root *html.Node, element string) (*html.Node, error) {
+	nodesAtCurrentLevel := []*html.Node{root}
+	nodesAtNextLevel := []*html.Node{}
+	numRemoved := 0
+
+	for len(nodesAtCurrentLevel) != 0 {
+		for _, current := range nodesAtCurrentLevel {
+			child := current.FirstChild
+			for child != nil {
+				next := child.NextSibling
+				if child.Type == html.ElementNode && child.Data == element {
+					numRemoved++
+					current.RemoveChild(child)
+				} else {
+					nodesAtNextLevel = append(nodesAtNextLevel, child)
+				}
+				child = next
+			}
+		}
+		nodesAtCurrentLevel = nodesAtNextLevel
+		nodesAtNextLevel = []*html.Node{}
+	}
+
+	slog.Debug("removed html nodes", "nodes", numRemoved, "element", element)
+	return root, nil
+}
+
+// RemoveImages removes all images from the document, including the captions of figures.
+func RemoveImages(root *html.Node) (*html.Node, error) {
+	root, err := RemoveAllHTMLElements(root, "img")
+	if err != nil {
+		return nil, err
+	}
+	return RemoveAllHTMLElements(root, "figcaption")
+}
+
+// RedirectImgSources replaces the prefix of the sources of all images with a new prefix.
+func RedirectImgSources(root *html.Node, prefix string, newPrefix string) (*html.Node, error) {
+	return RewriteImgSources(root, prefix, func(rest string) string { return newPrefix + rest })
+}
+
+// RewriteImgSources replaces the sources of all images that start with prefix by the result of
+// rewrite, which receives the remainder of the source after the prefix.
+func RewriteImgSources(
+	root *html.Node, prefix string, rewrite func(string) string,
+) (*html.Node, error) {
+	element := "img"
+	key := "src"
+
+	nodesAtCurrentLevel := []*html.Node{root}
+	nodesAtNextLevel := []*html.Node{}
+	numReplaced := 0
+	numKept := 0
+
+	for len(nodesAtCurrentLevel) != 0 {
+		for _, current := range nodesAtCurrentLevel {
+			child := current.FirstChild
+			for child != nil {
+				next := child.NextSibling
+				nodesAtNextLevel = append(nodesAtNextLevel, child)
+				if child.Type == html.ElementNode && child.Data == element {
+					replaced := false
+					for idx := range child.Attr {
+						attr := &child.Attr[idx]
+						if attr.Key == key && strings.HasPrefix(attr.Val, prefix) {
+							attr.Val = rewrite(strings.TrimPrefix(attr.Val, prefix))
+							replaced = true
+						}
+					}
+					if replaced {
+						numReplaced++
+					} else {
+						numKept++
+					}
+				}
+				child = next
+			}
+		}
+		nodesAtCurrentLevel = nodesAtNextLevel
+		nodesAtNextLevel = []*html.Node{}
+	}
+
+	slog.Debug(
+		"redirected html nodes", "nodes", numReplaced, "kept", numKept, "element", element,
+	)
+
+	return root, nil
+}
+
+// Extensions of images that not all document types support. They are converted to jpeg on
+// retrieval if their source carries a ".jpeg" suffix. HEIC images are always converted.
+var replaceableImageExtensions = []string{".webp", ".heic", ".heif"}
+
+// IsReplaceableImage determines whether the image at src has to be converted to jpeg on retrieval.
+func IsReplaceableImage(src string) bool {
+	for _, extension := range replaceableImageExtensions {
+		if strings.HasSuffix(strings.ToLower(src), extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureWebpImagesCanBeReplaced marks webp and heic image sources so that they are converted to
+// jpeg on retrieval. This is needed only for PDF documents because LaTeX does not understand webp
+// images. Only the sources are modified, the alt text in particular is retained.
+func EnsureWebpImagesCanBeReplaced(root *html.Node) (*html.Node, error) {
+	element := "img"
+	key := "src"
+
+	nodesAtCurrentLevel := []*html.Node{root}
+	nodesAtNextLevel := []*html.Node{}
+	numReplaced := 0
+
+	for len(nodesAtCurrentLevel) != 0 {
+		for _, current := range nodesAtCurrentLevel {
+			child := current.FirstChild
+			for child != nil {
+				next := child.NextSibling
+				nodesAtNextLevel = append(nodesAtNextLevel, child)
+				if child.Type == html.ElementNode && child.Data == element {
+					replaced := false
+					for idx := range child.Attr {
+						attr := &child.Attr[idx]
+						if attr.Key == key && IsReplaceableImage(attr.Val) {
+							attr.Val += ".jpeg"
+							replaced = true
+						}
+					}
+					if replaced {
+						numReplaced++
+					}
+				}
+				child = next
+			}
+		}
+		nodesAtCurrentLevel = nodesAtNextLevel
+		nodesAtNextLevel = []*html.Node{}
+	}
+
+	slog.Debug("redirected webp or heic images", "images", numReplaced)
+	return root, nil
+}
+
+// RasterizeSvgImages marks SVG image sources so that they are converted to png on retrieval. This
+// is needed for PDF documents because LaTeX does not understand SVG images.
+func RasterizeSvgImages(root *html.Node) (*html.Node, error) {
+	element := "img"
+	key := "src"
+
+	nodesAtCurrentLevel := []*html.Node{root}
+	nodesAtNextLevel := []*html.Node{}
+	numReplaced := 0
+
+	for len(nodesAtCurrentLevel) != 0 {
+		for _, current := range nodesAtCurrentLevel {
+			child := current.FirstChild
+			for child != nil {
+				next := child.NextSibling
+				nodesAtNextLevel = append(nodesAtNextLevel, child)
+				if child.Type == html.ElementNode && child.Data == element {
+					for idx := range child.Attr {
+						attr := &child.Attr[idx]
+						if attr.Key == key && strings.HasSuffix(strings.ToLower(attr.Val), ".svg") {
+							attr.Val += ".png"
+							numReplaced++
+						}
+					}
+				}
+				child = next
+			}
+		}
+		nodesAtCurrentLevel = nodesAtNextLevel
+		nodesAtNextLevel = []*html.Node{}
+	}
+
+	slog.Debug("redirected svg images", "images", numReplaced)
+	return root, nil
+}
+
+// UpdateHTMLAttrs sets the attributes in mapMod and removes the attributes in mapRm for all
+// elements. Both maps map element names to attributes.
+func UpdateHTMLAttrs(
+	root *html.Node,
+	mapMod map[string]map[string]string,
+	mapRm map[string]map[string]string,
+) (*html.Node, error) {
+	nodesAtCurrentLevel := []*html.Node{root}
+	nodesAtNextLevel := []*html.Node{}
+	numMod := 0
+	numRm := 0
+
+	for len(nodesAtCurrentLevel) != 0 {
+		for _, current := range nodesAtCurrentLevel {
+			child := current.FirstChild
+			for child != nil {
+				next := child.NextSibling
+				nodesAtNextLevel = append(nodesAtNextLevel, child)
+				if child.Type == html.ElementNode {
+					mod, found := mapMod[child.Data]
+					if found {
+						didModify := map[string]bool{}
+						for idx := range child.Attr {
+							attr := &child.Attr[idx]
+							if newVal, found := mod[attr.Key]; found {
+								didModify[attr.Key] = true
+								attr.Val = newVal
+								slog.Debug(
+									"setting html attribute", "element", child.Data,
+									"key", attr.Key, "value", newVal, "previous", attr.Val,
+								)
+								numMod++
+							}
+						}
+						for key, val := range mod {
+							if _, found := didModify[key]; !found {
+								slog.Debug(
+									"adding html attribute", "element", child.Data,
+									"key", key, "value", val,
+								)
+								child.Attr = append(child.Attr, html.Attribute{Key: key, Val: val})
+							}
+						}
+					}
+					rm, found := mapRm[child.Data]
+					if found {
+						newAttrs := make([]html.Attribute, 0, len(child.Attr))
+						for _, attr := range child.Attr {
+							if _, found := rm[attr.Key]; !found {
+								newAttrs = append(newAttrs, attr)
+							} else {
+								numRm++
+								slog.Debug(
+									"removing html attribute", "element", child.Data,
+									"key", attr.Key, "previous", attr.Val,
+								)
+							}
+						}
+						child.Attr = newAttrs
+					}
+				}
+				child = next
+			}
+		}
+		nodesAtCurrentLevel = nodesAtNextLevel
+		nodesAtNextLevel = []*html.Node{}
+	}
+
+	slog.Debug("updated html attributes", "modified", numMod, "removed", numRm)
+
+	return root, nil
+}
+
+// ParseHTMLAttrs parses an HTML snippet into a map from element names to their attributes.
+func ParseHTMLAttrs(htmlInput string) (map[string]map[string]string, error) {
+	result := map[string]map[string]string{}
+	if htmlInput == "" {
+		return result, nil
+	}
+
+	root, err := html.Parse(strings.NewReader(htmlInput))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML input: %s", err.Error())
+	}
+
+	nodesAtCurrentLevel := []*html.Node{root}
+	nodesAtNextLevel := []*html.Node{}
+
+	for len(nodesAtCurrentLevel) != 0 {
+		for _, current := range nodesAtCurrentLevel {
+			child := current.FirstChild
+			for child != nil {
+				nodesAtNextLevel = append(nodesAtNextLevel, child)
+				if child.Type == html.ElementNode {
+					elementResult, found := result[child.Data]
+					if !found {
+						elementResult = map[string]string{}
+					}
+					for _, attr := range child.Attr {
+						elementResult[attr.Key] = attr.Val
+					}
+					result[child.Data] = elementResult
+				}
+				child = child.NextSibling
+			}
+		}
+		nodesAtCurrentLevel = nodesAtNextLevel
+		nodesAtNextLevel = []*html.Node{}
+	}
+
+	numElems := len(result)
+	numAttrs := 0
+	for _, elementResult := range result {
+		numAttrs += len(elementResult)
+	}
+
+	keys := []string{}
+	for key := range result {
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		if len(result[key]) == 0 {
+			delete(result, key)
+		}
+	}
+
+	slog.Debug("parsed html", "elements", numElems, "attributes", numAttrs)
+	return result, nil
+}
+
+// Count the images that remain in the document.
+func countImages(node *html.Node) int {
+	count := 0
+	if node.Type == html.ElementNode && node.Data == "img" {
+		count++
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		count += countImages(child)
+	}
+	return count
+}
@@ -0,0 +1,82 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// WithIndexOnly returns a context that makes generators list recipes with their categories, tags,
+// and times and a link to mealie, but without any further details. That is a printable table of
+// contents of the library. Only the name, slug, categories, tags, and times of recipes are used.
+func WithIndexOnly(ctx context.Context) context.Context {
+	presentation := presentationFrom(ctx)
+	presentation.indexOnly = true
+	return WithPresentation(ctx, presentation)
+}
+
+// Build a markdown document that lists recipes, one list item per recipe. The url is that of the
+// mealie instance.
+func indexMarkdown(recipes []mealieclient.Recipe, url string, opts MarkdownOptions) string {
+	result := make([]string, 0, len(recipes)+2) //nolint:mnd
+	result = append(result, opts.Presentation.frontMatter()...)
+	result = append(result, "# Recipes\n")
+	for _, recipe := range recipes {
+		categoryNames := make([]string, 0, len(recipe.Categories))
+		categories := make([]string, 0, len(recipe.Categories))
+		for _, category := range recipe.Categories {
+			categoryNames = append(categoryNames, category.Name)
+			categories = append(categories, opts.Styles.category(category.Name, category.Name))
+		}
+		tags := make([]string, 0, len(recipe.Tags))
+		for _, tag := range recipe.Tags {
+			tags = append(tags, tag.Name)
+		}
+
+		item := fmt.Sprintf(
+			"- [%s](%s/r/%s)", opts.Styles.recipe(recipe.Name, categoryNames), url, recipe.Slug,
+		)
+		details := []string{}
+		for _, entry := range []struct{ label, value string }{
+			{"Total time", recipe.TotalTime},
+			{"Prep time", recipe.PrepTime},
+			{"Cook time", recipe.PerformTime},
+		} {
+			if entry.value != "" {
+				details = append(details, fmt.Sprintf("%s: %s", entry.label, entry.value))
+			}
+		}
+		if len(categories) > 0 {
+			details = append(details, "Categories: "+strings.Join(categories, ", "))
+		}
+		if len(tags) > 0 {
+			details = append(details, "Tags: "+strings.Join(tags, ", "))
+		}
+		if len(details) > 0 {
+			item += "\\\n  " + strings.Join(details, "; ")
+		}
+		result = append(result, item)
+	}
+	result = append(result, "")
+	result = append(result, warningsAppendix(opts.Warnings)...)
+	return strings.Join(result, "\n")
+}
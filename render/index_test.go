@@ -0,0 +1,49 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+func TestBuildMarkdownIndexOnly(t *testing.T) {
+	recipes := []mealieclient.Recipe{
+		{
+			ID: "1", Name: "Apple Pie", Slug: "apple-pie", TotalTime: "1 hour",
+			Categories:   []mealieclient.Organiser{{Name: "Dessert"}},
+			Instructions: []mealieclient.Instruction{{Text: "Bake"}},
+		},
+		{ID: "2", Name: "Bread", Slug: "bread"},
+	}
+	ctx := WithIndexOnly(context.Background())
+	opts := MarkdownOptions{Presentation: presentationFrom(ctx)}
+	markdown := BuildMarkdown(recipes, "https://mealie", opts)
+
+	expected := "- [Apple Pie](https://mealie/r/apple-pie)\\\n" +
+		"  Total time: 1 hour; Categories: Dessert"
+	if !strings.Contains(markdown, expected) || !strings.Contains(markdown, "- [Bread]") {
+		t.Errorf("index does not list all recipes:\n%s", markdown)
+	}
+	if strings.Contains(markdown, "Bake") || strings.Contains(markdown, "# Tags") {
+		t.Errorf("index contains details:\n%s", markdown)
+	}
+}
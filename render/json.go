@@ -0,0 +1,63 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// JSONGenerator generates a JSON array of all recipes. It does not need a converter, which lets
+// other tools consume recipes retrieved from mealie without any conversion.
+type JSONGenerator struct{}
+
+// CommonName is the name of the format.
+func (g *JSONGenerator) CommonName() string {
+	return "json"
+}
+
+// Extension is the file extension of the format.
+func (g *JSONGenerator) Extension() string {
+	return "json"
+}
+
+// MimeType is the mime type of the format.
+func (g *JSONGenerator) MimeType() string {
+	return "application/json"
+}
+
+// Response generates a JSON array containing all recipes. Recipes use the same field names as
+// mealie's API.
+func (g *JSONGenerator) Response(
+	_ context.Context,
+	recipes []mealieclient.Recipe,
+	_ time.Time,
+) ([]byte, error) {
+	if recipes == nil {
+		recipes = []mealieclient.Recipe{}
+	}
+	response, err := json.MarshalIndent(recipes, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert recipes to json: %s", err.Error())
+	}
+	return response, nil
+}
@@ -0,0 +1,56 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+func TestJSONGeneratorResponse(t *testing.T) {
+	recipes := []mealieclient.Recipe{
+		{
+			ID:           "1",
+			Name:         "Apple Pie",
+			Tags:         []mealieclient.Organiser{{Name: "Sweet"}},
+			Ingredients:  []mealieclient.Ingredient{{Text: "Apples"}},
+			Instructions: []mealieclient.Instruction{{Text: "Bake"}},
+		},
+	}
+	response, err := (&JSONGenerator{}).Response(context.Background(), recipes, time.Now())
+	if err != nil {
+		t.Fatalf("Response() failed: %s", err.Error())
+	}
+	var parsed []mealieclient.Recipe
+	if err := json.Unmarshal(response, &parsed); err != nil {
+		t.Fatalf("Response() is no valid json: %s", err.Error())
+	}
+	if len(parsed) != 1 || parsed[0].Name != "Apple Pie" || parsed[0].Tags[0].Name != "Sweet" ||
+		parsed[0].Ingredients[0].Text != "Apples" || parsed[0].Instructions[0].Text != "Bake" {
+		t.Errorf("unexpected recipes after round trip: %+v", parsed)
+	}
+
+	response, err = (&JSONGenerator{}).Response(context.Background(), nil, time.Now())
+	if err != nil || string(response) != "[]" {
+		t.Errorf("Response() = %s, %v, want an empty array", string(response), err)
+	}
+}
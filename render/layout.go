@@ -0,0 +1,93 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import "strings"
+
+// Engines that pandoc can use to generate PDF documents. Each of them is also the name of its
+// executable. LaTeX-based engines support all layouts but are slow and big. Typst is much faster
+// but does not support running headers. The HTML-based engines support neither running headers nor
+// chapter numbers and are meant as fallbacks.
+const (
+	PDFEngineLuaLaTeX    = "lualatex"
+	PDFEngineXeLaTeX     = "xelatex"
+	PDFEngineTypst       = "typst"
+	PDFEngineWeasyPrint  = "weasyprint"
+	PDFEngineWkHTMLToPDF = "wkhtmltopdf"
+)
+
+// PDFEngines are all supported PDF engines in the order in which they are preferred.
+var PDFEngines = []string{
+	PDFEngineLuaLaTeX, PDFEngineXeLaTeX, PDFEngineTypst, PDFEngineWeasyPrint, PDFEngineWkHTMLToPDF,
+}
+
+// DefaultPDFEngine is the PDF engine used unless another one is set.
+const DefaultPDFEngine = PDFEngineLuaLaTeX
+
+// PDFLayout modifies the layout of PDF documents.
+type PDFLayout struct {
+	// ChapterNumbers numbers all chapters and recipes.
+	ChapterNumbers bool
+	// RunningHeaders shows the current chapter and recipe at the top and the page number at the
+	// bottom of every page. Only LaTeX-based engines support it.
+	RunningHeaders bool
+}
+
+// LaTeX code that sets up running headers. Top-level headings become sections and recipes become
+// subsections.
+var runningHeaderLatex = []string{
+	`\usepackage{fancyhdr}`,
+	`\pagestyle{fancy}`,
+	`\fancyhf{}`,
+	`\fancyhead[L]{\leftmark}`,
+	`\fancyhead[R]{\rightmark}`,
+	`\fancyfoot[C]{\thepage}`,
+}
+
+// LaTeX code that determines the text of running headers. It has to come after the page style has
+// been set since fancyhdr overwrites it.
+var (
+	numberedMarksLatex = []string{
+		`\renewcommand{\sectionmark}[1]{\markboth{Chapter \thesection\ --- #1}{}}`,
+		`\renewcommand{\subsectionmark}[1]{\markright{\thesubsection\ #1}}`,
+	}
+	unnumberedMarksLatex = []string{
+		`\renewcommand{\sectionmark}[1]{\markboth{#1}{}}`,
+		`\renewcommand{\subsectionmark}[1]{\markright{#1}}`,
+	}
+)
+
+// Implement the layout in the defaults of the final pandoc conversion to PDF via the given engine.
+func (l PDFLayout) applyTo(defaults *pandocDefaults, engine string) {
+	if l.ChapterNumbers && (isLaTeX(engine) || engine == PDFEngineTypst) {
+		defaults.NumberSections = true
+	}
+	if l.RunningHeaders && isLaTeX(engine) {
+		latex := append([]string{}, runningHeaderLatex...)
+		if l.ChapterNumbers {
+			latex = append(latex, numberedMarksLatex...)
+		} else {
+			latex = append(latex, unnumberedMarksLatex...)
+		}
+		defaults.Variables["header-includes"] = strings.Join(latex, "\n")
+	}
+}
+
+func isLaTeX(engine string) bool {
+	return engine == PDFEngineLuaLaTeX || engine == PDFEngineXeLaTeX
+}
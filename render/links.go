@@ -0,0 +1,95 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Prefixes of the anchors that the generated documents link to internally.
+var internalAnchorPrefixes = []string{"recipe-", "tag-", "category-"}
+
+// ValidateInternalLinks checks that all internal links to recipes, tags, and categories point to an
+// anchor that exists in the document. Broken links are logged. If fail is set, they are also
+// reported as an error.
+func ValidateInternalLinks(root *html.Node, fail bool) (*html.Node, error) {
+	anchors := map[string]bool{}
+	targets := []string{}
+
+	nodesAtCurrentLevel := []*html.Node{root}
+	nodesAtNextLevel := []*html.Node{}
+
+	for len(nodesAtCurrentLevel) != 0 {
+		for _, current := range nodesAtCurrentLevel {
+			child := current.FirstChild
+			for child != nil {
+				nodesAtNextLevel = append(nodesAtNextLevel, child)
+				if child.Type == html.ElementNode {
+					for _, attr := range child.Attr {
+						switch {
+						case attr.Key == "id" || (attr.Key == "name" && child.Data == "a"):
+							anchors[attr.Val] = true
+						case attr.Key == "href" && child.Data == "a":
+							if target, found := strings.CutPrefix(attr.Val, "#"); found {
+								targets = append(targets, target)
+							}
+						}
+					}
+				}
+				child = child.NextSibling
+			}
+		}
+		nodesAtCurrentLevel = nodesAtNextLevel
+		nodesAtNextLevel = []*html.Node{}
+	}
+
+	numInternal := 0
+	broken := []string{}
+	for _, target := range targets {
+		if !isInternalAnchor(target) {
+			continue
+		}
+		numInternal++
+		if anchors[target] {
+			continue
+		}
+		slog.Warn("broken internal link", "target", "#"+target)
+		broken = append(broken, target)
+	}
+
+	slog.Info("validated internal links", "links", numInternal, "broken", len(broken))
+	if fail && len(broken) != 0 {
+		return nil, fmt.Errorf(
+			"found %d broken internal links: #%s", len(broken), strings.Join(broken, ", #"),
+		)
+	}
+	return root, nil
+}
+
+func isInternalAnchor(target string) bool {
+	for _, prefix := range internalAnchorPrefixes {
+		if strings.HasPrefix(target, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+
+	"github.com/razziel89/mealie-addons/summary"
+)
+
+const (
+	// At most this many lint warnings are reported per document. Content with systematic issues
+	// would otherwise flood logs and job records.
+	maxLintWarnings = 50
+	// Headings longer than this many characters usually contain whole paragraphs by accident,
+	// which break the layout of tables of contents.
+	maxHeadingRunes = 100
+)
+
+var (
+	// Opening, closing and self-closing raw HTML tags. Autolinks such as <https://example.com> do
+	// not match since tag names have to be followed by whitespace, a slash, or the end of the tag.
+	rawTagRegex = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9-]*)(?:\s[^<>]*?)?(/?)>`)
+	// Code spans, whose content is never interpreted as HTML.
+	codeSpanRegex = regexp.MustCompile("(`+)[^`]*?`+")
+	// Elements that never have closing tags.
+	voidElements = []string{
+		"area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source",
+		"track", "wbr",
+	}
+)
+
+// An open raw HTML tag and where it was opened.
+type openTag struct {
+	name     string
+	location string
+}
+
+// Lint the markdown input of pandoc for issues that otherwise surface as cryptic pandoc or LaTeX
+// failures or as broken layouts: unclosed raw HTML tags, overly long headings and, for PDF
+// documents, characters that LaTeX engines choke on. Every warning names where the issue is.
+func lintMarkdown(markdown string, toFormat string) []string {
+	warnings := []string{}
+	open := []openTag{}
+	heading := ""
+	fence := ""
+
+	for idx, line := range strings.Split(markdown, "\n") {
+		location := fmt.Sprintf("line %d", idx+1)
+		if heading != "" {
+			location = fmt.Sprintf("line %d below heading %q", idx+1, heading)
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+
+		if toFormat == "pdf" {
+			if suspicious := suspiciousRunes(line); len(suspicious) != 0 {
+				warnings = append(warnings, fmt.Sprintf(
+					"%s contains characters that pdf engines cannot typeset: %s",
+					location, describeRunes(suspicious),
+				))
+			}
+		}
+
+		if level := len(trimmed) - len(strings.TrimLeft(trimmed, "#")); level > 0 && level <= 6 &&
+			(len(trimmed) == level || trimmed[level] == ' ') {
+			text := strings.TrimSpace(rawTagRegex.ReplaceAllString(trimmed[level:], ""))
+			if length := utf8.RuneCountInString(text); length > maxHeadingRunes {
+				warnings = append(warnings, fmt.Sprintf(
+					"line %d has a heading with %d characters, more than %d: %q",
+					idx+1, length, maxHeadingRunes, truncate(text, maxHeadingRunes),
+				))
+			}
+			heading = truncate(text, 40) //nolint:mnd
+		}
+
+		line = codeSpanRegex.ReplaceAllString(line, "")
+		for _, match := range rawTagRegex.FindAllStringSubmatch(line, -1) {
+			name := strings.ToLower(match[2])
+			closing, selfClosing := match[1] == "/", match[3] == "/"
+			switch {
+			case slices.Contains(voidElements, name) || selfClosing:
+			case !closing:
+				open = append(open, openTag{name: name, location: location})
+			default:
+				innermost := -1
+				for pos := len(open) - 1; pos >= 0; pos-- {
+					if open[pos].name == name {
+						innermost = pos
+						break
+					}
+				}
+				if innermost < 0 {
+					warnings = append(warnings, fmt.Sprintf(
+						"%s closes raw html tag <%s> that is not open", location, name,
+					))
+					continue
+				}
+				for _, tag := range open[innermost+1:] {
+					warnings = append(warnings, unclosedTag(tag))
+				}
+				open = open[:innermost]
+			}
+		}
+	}
+	for _, tag := range open {
+		warnings = append(warnings, unclosedTag(tag))
+	}
+	return warnings
+}
+
+func unclosedTag(tag openTag) string {
+	return fmt.Sprintf("%s opens raw html tag <%s> that is never closed", tag.location, tag.name)
+}
+
+// Characters that LaTeX engines either reject or silently drop: control characters, byte order
+// marks, replacement characters left behind by broken encodings, and private use characters.
+func suspiciousRunes(text string) []rune {
+	suspicious := []rune{}
+	for _, char := range text {
+		if (unicode.IsControl(char) && char != '\t' && char != '\r') ||
+			char == '\uFEFF' || char == utf8.RuneError || unicode.Is(unicode.Co, char) {
+			if !slices.Contains(suspicious, char) {
+				suspicious = append(suspicious, char)
+			}
+		}
+	}
+	return suspicious
+}
+
+// Shorten text to at most the given number of characters.
+func truncate(text string, runes int) string {
+	if utf8.RuneCountInString(text) <= runes {
+		return text
+	}
+	return string([]rune(text)[:runes-1]) + "…"
+}
+
+// Lint the intermediate HTML document for images without alt text, which e-readers and screen
+// readers cannot describe.
+func lintHTML(node *html.Node) []string {
+	warnings := []string{}
+	if node.Type == html.ElementNode && node.Data == "img" {
+		alt, src := "", ""
+		for _, attr := range node.Attr {
+			switch attr.Key {
+			case "alt":
+				alt = attr.Val
+			case "src":
+				src = attr.Val
+			}
+		}
+		if strings.TrimSpace(alt) == "" {
+			warnings = append(warnings, fmt.Sprintf(
+				"image %s lacks an alt text", truncate(src, 80), //nolint:mnd
+			))
+		}
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		warnings = append(warnings, lintHTML(child)...)
+	}
+	return warnings
+}
+
+// Log lint warnings and record them for the export, at most maxLintWarnings of them.
+func reportLintWarnings(ctx context.Context, warnings []string) {
+	if len(warnings) > maxLintWarnings {
+		omitted := len(warnings) - maxLintWarnings
+		warnings = append(warnings[:maxLintWarnings:maxLintWarnings], fmt.Sprintf(
+			"and %d more", omitted,
+		))
+	}
+	for _, warning := range warnings {
+		slog.WarnContext(ctx, "lint warning", "warning", warning)
+	}
+	summary.From(ctx).AddLintWarnings(warnings...)
+}
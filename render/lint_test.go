@@ -0,0 +1,79 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestLintMarkdown(t *testing.T) {
+	markdown := strings.Join([]string{
+		`## <a name="recipe-soup"></a> Soup`,
+		`Visit <https://example.com> or write to <a@example.com>.`,
+		`<div style="color: red">Hot<br></div>`,
+		"Use `<span>` for inline text.",
+		"```",
+		"<div>",
+		"```",
+		`<span>unclosed`,
+		`stray</p>`,
+		"## " + strings.Repeat("long ", 30),
+		"Odd \x01 character",
+	}, "\n")
+
+	warnings := lintMarkdown(markdown, "pdf")
+
+	expected := []string{
+		`line 9 below heading "Soup" closes raw html tag <p> that is not open`,
+		`line 10 has a heading with 149 characters, more than 100`,
+		`line 11 below heading "long long long long long long long long…" contains characters ` +
+			`that pdf engines cannot typeset: '\x01' (U+0001)`,
+		`line 8 below heading "Soup" opens raw html tag <span> that is never closed`,
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("expected %d warnings but got %d: %q", len(expected), len(warnings), warnings)
+	}
+	for idx, warning := range warnings {
+		if !strings.HasPrefix(warning, expected[idx]) {
+			t.Errorf("expected warning starting with %q but got %q", expected[idx], warning)
+		}
+	}
+
+	if warnings := lintMarkdown("Odd \x01 character", "epub"); len(warnings) != 0 {
+		t.Errorf("characters were checked for epub: %q", warnings)
+	}
+}
+
+func TestLintHTML(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(
+		`<p><img src="a.webp" alt="Soup"><img src="b.webp" alt=" "><img src="c.webp"></p>`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := lintHTML(root)
+
+	expected := "image b.webp lacks an alt text,image c.webp lacks an alt text"
+	if got := strings.Join(warnings, ","); got != expected {
+		t.Errorf("expected %q but got %q", expected, got)
+	}
+}
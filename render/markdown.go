@@ -0,0 +1,423 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// MarkdownGenerator generates Markdown documents.
+type MarkdownGenerator struct {
+	URL       string
+	Converter Converter
+	// Difficulty shows the estimated difficulty of every recipe.
+	Difficulty bool
+	// Nutrition shows nutritional values per serving and their averages per category.
+	Nutrition bool
+	// Allergens are highlighted in every recipe containing them.
+	Allergens Allergens
+}
+
+// CommonName is the name of the format.
+func (g *MarkdownGenerator) CommonName() string {
+	return "markdown"
+}
+
+// Extension is the file extension of the format.
+func (g *MarkdownGenerator) Extension() string {
+	return "md"
+}
+
+// MimeType is the mime type of the format.
+func (g *MarkdownGenerator) MimeType() string {
+	return "text/markdown"
+}
+
+// Response generates a document containing all recipes.
+func (g *MarkdownGenerator) Response(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+) ([]byte, error) {
+	opts := MarkdownOptions{
+		Difficulty:   g.Difficulty,
+		Nutrition:    g.Nutrition,
+		Allergens:    g.Allergens,
+		Warnings:     warningsFrom(ctx),
+		Presentation: presentationFrom(ctx),
+	}
+	return g.Converter.Convert(
+		ctx, BuildMarkdown(recipes, g.URL, opts), "markdown_github", documentTitle(ctx, timestamp),
+	)
+}
+
+// BuildTitle builds the title of an export generated at the given time.
+func BuildTitle(timestamp time.Time) string {
+	return fmt.Sprintf("Exported Recipes @ %s", timestamp.Format(time.RFC3339))
+}
+
+type warningsKey struct{}
+
+// WithWarnings returns a context that makes generators list the given warnings in an appendix of
+// the generated document.
+func WithWarnings(ctx context.Context, warnings []string) context.Context {
+	return context.WithValue(ctx, warningsKey{}, warnings)
+}
+
+func warningsFrom(ctx context.Context) []string {
+	warnings, _ := ctx.Value(warningsKey{}).([]string)
+	return warnings
+}
+
+// MarkdownOptions modify the documents built by BuildMarkdown.
+type MarkdownOptions struct {
+	// Captions renders images as figures with a caption below them.
+	Captions bool
+	// Styles highlight categories and the recipes in them.
+	Styles CategoryStyles
+	// Difficulty shows the estimated difficulty of every recipe.
+	Difficulty bool
+	// Allergens are highlighted in every recipe containing them and listed in an index.
+	Allergens Allergens
+	// Nutrition shows the nutritional values per serving of every recipe and their averages per
+	// category in an appendix.
+	Nutrition bool
+	// Warnings are listed in an appendix if there are any.
+	Warnings []string
+	// Presentation adds a cover and a dedication and changes how recipes are shown.
+	Presentation Presentation
+}
+
+// BuildMarkdown builds a markdown document containing all recipes as well as indices of all tags
+// and categories. The url is that of the mealie instance. The presentation may limit the document
+// to a single recipe or to a list of recipes without details.
+func BuildMarkdown(recipes []mealieclient.Recipe, url string, opts MarkdownOptions) string {
+	if len(recipes) == 0 {
+		return emptyMarkdown(opts)
+	}
+	if opts.Presentation.indexOnly {
+		return indexMarkdown(recipes, url, opts)
+	}
+
+	// Extract all known categories and tags to build the index at the end.
+	tags := map[string]bool{}
+	categories := map[string]bool{}
+	for _, recipe := range recipes {
+		for _, tag := range recipe.Tags {
+			tags[tag.Name] = true
+		}
+		for _, category := range recipe.Categories {
+			categories[category.Name] = true
+		}
+	}
+	slog.Debug("collected organisers", "tags", len(tags), "categories", len(categories))
+
+	// Sort tags and categories for easier processing down the line.
+	// Tags.
+	sortedTags := make([]string, 0, len(tags))
+	for tag := range tags {
+		sortedTags = append(sortedTags, tag)
+	}
+	SortNames(sortedTags)
+	// Categories.
+	sortedCategories := make([]string, 0, len(categories))
+	for category := range categories {
+		sortedCategories = append(sortedCategories, category)
+	}
+	SortNames(sortedCategories)
+
+	// Extract all tags and categories for each recipe. That makes it very easy to build the indices
+	// down the line.
+	// Tags.
+	tagsPerRecipe := map[string][]string{}
+	for _, recipe := range recipes {
+		tags := make([]string, 0, len(recipe.Tags))
+		for _, tag := range recipe.Tags {
+			tags = append(tags, tag.Name)
+		}
+		tagsPerRecipe[recipe.ID] = tags
+	}
+	// Categories.
+	categoriesPerRecipe := map[string][]string{}
+	for _, recipe := range recipes {
+		categories := make([]string, 0, len(recipe.Categories))
+		for _, category := range recipe.Categories {
+			categories = append(categories, category.Name)
+		}
+		categoriesPerRecipe[recipe.ID] = categories
+	}
+
+	// Assign anchors in the order in which items appear in the document.
+	anchors := newAnchors()
+	for _, recipe := range recipes {
+		anchors.recipe(&recipe)
+	}
+	for _, tag := range sortedTags {
+		anchors.tag(tag)
+	}
+	for _, category := range sortedCategories {
+		anchors.category(category)
+	}
+	contained := opts.Allergens.contained(recipes)
+	if len(contained) != 0 {
+		for _, allergen := range opts.Allergens.names() {
+			anchors.allergen(allergen)
+		}
+	}
+
+	// A single recipe needs no navigation.
+	if opts.Presentation.singleRecipe && len(recipes) == 1 {
+		result := recipeToMarkdown(&recipes[0], url, opts, anchors)
+		return strings.Join(append(result, warningsAppendix(opts.Warnings)...), "\n")
+	}
+
+	result := make([]string, 0, 2*(len(recipes)+1)) //nolint:mnd
+	result = append(result, opts.Presentation.frontMatter()...)
+
+	// Recipes.
+	result = append(result, "# Recipes")
+	for _, recipe := range recipes {
+		result = append(result, fmt.Sprintf("- [%s](#%s)", recipe.Name, anchors.recipe(&recipe)))
+	}
+	result = append(result, "\n"+`<div style="page-break-before: always;"></div>`+"\n")
+	for _, recipe := range recipes {
+		result = append(result, recipeToMarkdown(&recipe, url, opts, anchors)...)
+	}
+
+	// Tags index.
+	tagsIndex := make([]string, 0, len(recipes))
+	tagsIndex = append(tagsIndex, `# Tags`)
+	for _, tag := range sortedTags {
+		tagsIndex = append(
+			tagsIndex,
+			fmt.Sprintf("\n## <a name=\"%s\"></a> %s\n", anchors.tag(tag), tag),
+		)
+		for _, recipe := range recipes {
+			if slices.Contains(tagsPerRecipe[recipe.ID], tag) {
+				link := fmt.Sprintf("- [%s](#%s)", recipe.Name, anchors.recipe(&recipe))
+				tagsIndex = append(tagsIndex, link)
+			}
+		}
+	}
+	tagsIndex = append(tagsIndex, "\n"+`<div style="page-break-before: always;"></div>`+"\n")
+	result = append(result, tagsIndex...)
+
+	// Categories index.
+	categoriesIndex := make([]string, 0, len(recipes))
+	categoriesIndex = append(categoriesIndex, `# Categories`)
+	for _, category := range sortedCategories {
+		categoriesIndex = append(
+			categoriesIndex,
+			fmt.Sprintf(
+				"\n## <a name=\"%s\"></a> %s\n",
+				anchors.category(category), opts.Styles.category(category, category),
+			),
+		)
+		for _, recipe := range recipes {
+			if slices.Contains(categoriesPerRecipe[recipe.ID], category) {
+				link := fmt.Sprintf("- [%s](#%s)", recipe.Name, anchors.recipe(&recipe))
+				categoriesIndex = append(categoriesIndex, link)
+			}
+		}
+	}
+	categoriesIndex = append(
+		categoriesIndex,
+		"\n"+`<div style="page-break-before: always;"></div>`+"\n",
+	)
+	result = append(result, categoriesIndex...)
+	result = append(result, allergenIndex(recipes, opts.Allergens, contained, anchors)...)
+
+	if opts.Nutrition {
+		result = append(result, nutritionAppendix(recipes, sortedCategories)...)
+	}
+	result = append(result, warningsAppendix(opts.Warnings)...)
+	return strings.Join(result, "\n")
+}
+
+// Build an appendix listing the warnings. Empty if there are none.
+func warningsAppendix(warnings []string) []string {
+	if len(warnings) == 0 {
+		return nil
+	}
+	result := []string{"# Warnings\n"}
+	for _, warning := range warnings {
+		result = append(result, "- "+warning)
+	}
+	return result
+}
+
+// Build a document explaining that no recipes matched, which is friendlier than an empty index.
+func emptyMarkdown(opts MarkdownOptions) string {
+	result := []string{
+		"# No Recipes\n",
+		"No recipes matched your query.",
+		"Try removing some filters or check their spelling.\n",
+	}
+	result = append(result, warningsAppendix(opts.Warnings)...)
+	return strings.Join(result, "\n")
+}
+
+// Link a name to its entry in an index unless there is no index.
+func indexLink(name string, anchor string, noIndex bool) string {
+	if noIndex {
+		return name
+	}
+	return fmt.Sprintf("[%s](#%s)", name, anchor)
+}
+
+// Markdown images as used in instructions, e.g. ![alt](src "title").
+var markdownImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\(\s*([^)\s]+)(?:\s+"[^"]*")?\s*\)`)
+
+// Build an image tag with the given alt text. If captions is set, the image is wrapped in a figure
+// that uses the alt text as caption.
+func imageToHTML(src string, alt string, extraAttrs string, captions bool) string {
+	img := fmt.Sprintf(
+		`<img src="%s" alt="%s"%s>`, html.EscapeString(src), html.EscapeString(alt), extraAttrs,
+	)
+	if !captions {
+		return img
+	}
+	return fmt.Sprintf(
+		"<figure>%s<figcaption>%s</figcaption></figure>", img, html.EscapeString(alt),
+	)
+}
+
+// Ensure that every image in an instruction has an alt text derived from the recipe name and the
+// step number. Images are converted to HTML so that they can be captioned.
+func labelInstructionImages(text string, recipeName string, step int, captions bool) string {
+	return markdownImageRegex.ReplaceAllStringFunc(text, func(image string) string {
+		match := markdownImageRegex.FindStringSubmatch(image)
+		alt := strings.TrimSpace(match[1])
+		if alt == "" {
+			alt = fmt.Sprintf("%s, step %d", recipeName, step)
+		}
+		return imageToHTML(match[2], alt, "", captions)
+	})
+}
+
+func recipeToMarkdown(
+	recipe *mealieclient.Recipe,
+	url string,
+	opts MarkdownOptions,
+	anchors *anchors,
+) []string {
+	result := []string{}
+
+	categoryNames := make([]string, 0, len(recipe.Categories))
+	for _, category := range recipe.Categories {
+		categoryNames = append(categoryNames, category.Name)
+	}
+
+	heading := fmt.Sprintf(`## <a name="%s"></a> %s
+
+Total time: %s
+`, anchors.recipe(recipe), opts.Styles.recipe(recipe.Name, categoryNames), recipe.TotalTime)
+	result = append(result, heading)
+	if opts.Difficulty {
+		result = append(
+			result,
+			fmt.Sprintf("Difficulty: %d/%d\n", recipe.Difficulty(), mealieclient.MaxDifficulty),
+		)
+	}
+	// Documents with a single recipe have neither a list of recipes nor indices to link to.
+	single := opts.Presentation.singleRecipe
+	if allergens := opts.Allergens.contained([]mealieclient.Recipe{*recipe}); len(allergens) != 0 {
+		result = append(result, allergenBadges(allergens[recipe.ID], anchors, single))
+	}
+	if len(recipe.Description) > 0 {
+		result = append(result, fmt.Sprintf("%s\n", recipe.Description))
+	}
+	if len(recipe.Image) != 0 {
+		src := fmt.Sprintf("/api/media/recipes/%s/images/original.webp", recipe.ID)
+		size := ` height="150"`
+		if opts.Presentation.LargePhotos {
+			size = ` width="100%"`
+		}
+		result = append(result, imageToHTML(src, recipe.Name, size, opts.Captions)+"\n")
+	}
+	goTo := "- **Go to**: [Recipes](#recipes), [Tags](#tags), [Categories](#categories), "
+	if single {
+		goTo = "- **Go to**: "
+	}
+	result = append(
+		result,
+		goTo+fmt.Sprintf("[Original](%s), ", recipe.OrgURL)+
+			fmt.Sprintf("[Mealie](%s/r/%s)", url, recipe.Slug),
+	)
+
+	if len(recipe.Categories) > 0 {
+		categories := make([]string, 0, len(recipe.Categories))
+		for _, category := range recipe.Categories {
+			categories = append(
+				categories,
+				opts.Styles.category(category.Name, indexLink(
+					category.Name, anchors.category(category.Name), single,
+				)),
+			)
+		}
+		categoriesStr := fmt.Sprintf("- **Categories**: %s", strings.Join(categories, ", "))
+		result = append(result, categoriesStr)
+	}
+
+	if len(recipe.Tags) > 0 {
+		tags := make([]string, 0, len(recipe.Tags))
+		for _, tag := range recipe.Tags {
+			tags = append(tags, indexLink(tag.Name, anchors.tag(tag.Name), single))
+		}
+		tagsStr := fmt.Sprintf("- **Tags**: %s", strings.Join(tags, ", "))
+		result = append(result, tagsStr)
+	}
+
+	if len(recipe.Ingredients) > 0 {
+		result = append(result, "- **Ingredients**:")
+		for _, tmp := range recipe.Ingredients {
+			result = append(result, fmt.Sprintf("    - %s", tmp.Text))
+		}
+	}
+
+	if len(recipe.Instructions) > 0 {
+		result = append(result, "- **Instructions**:")
+		for idx, tmp := range recipe.Instructions {
+			text := labelInstructionImages(tmp.Text, recipe.Name, idx+1, opts.Captions)
+			result = append(result, fmt.Sprintf("    - %s", text))
+		}
+	}
+
+	if len(recipe.Comments) > 0 && !opts.Presentation.HideComments {
+		result = append(result, "- **Comments**:")
+		for _, tmp := range recipe.Comments {
+			result = append(result, fmt.Sprintf("    - %s: %s", tmp.User.Name, tmp.Text))
+		}
+	}
+
+	if opts.Nutrition {
+		result = append(result, nutritionTable(recipe)...)
+	}
+
+	result = append(result, "\n"+`<div style="page-break-before: always;"></div>`+"\n")
+	return result
+}
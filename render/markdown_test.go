@@ -15,35 +15,20 @@ You should have received a copy of the GNU General Public License
 along with this program.  If not, see <https://www.gnu.org/licenses/>.
 */
 
-// Package main contains the server code.
-package main
+package render
 
 import (
-	"context"
-	"time"
+	"strings"
+	"testing"
 )
 
-type pdfGenerator struct {
-	url    string
-	pandoc *pandoc
-}
-
-func (g *pdfGenerator) commonName() string {
-	return "pdf"
-}
-
-func (g *pdfGenerator) extension() string {
-	return "pdf"
-}
-
-func (g *pdfGenerator) mimeType() string {
-	return "application/pdf"
-}
+func TestLabelInstructionImagesEscapesSources(t *testing.T) {
+	text := `Mix. ![](https://example.com/a.webp?x=1&y="onerror="alert(1))`
+	labelled := labelInstructionImages(text, "Apple Pie", 2, false)
 
-func (g *pdfGenerator) response(
-	ctx context.Context,
-	recipes []recipe,
-	timestamp time.Time,
-) ([]byte, error) {
-	return g.pandoc.run(ctx, buildMarkdown(recipes, g.url), "pdf", buildTitle(timestamp), nil)
+	want := `<img src="https://example.com/a.webp?x=1&amp;y=&#34;onerror=&#34;alert(1" ` +
+		`alt="Apple Pie, step 2">`
+	if !strings.Contains(labelled, want) {
+		t.Errorf("image source was not escaped:\n%s", labelled)
+	}
 }
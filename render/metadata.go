@@ -0,0 +1,120 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// Query parameters that set metadata. They are meant for us and not for mealie.
+const (
+	AuthorParam  = "author"
+	SubjectParam = "subject"
+)
+
+// Metadata describes a document. It is shown by library software and e-readers.
+type Metadata struct {
+	Author   string   `json:"author,omitempty"`
+	Subject  string   `json:"subject,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Language string   `json:"language,omitempty"`
+}
+
+type metadataKey struct{}
+
+// WithMetadata returns a context that makes pandoc-based converters set the given metadata.
+func WithMetadata(ctx context.Context, metadata Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+func metadataFrom(ctx context.Context) Metadata {
+	metadata, _ := ctx.Value(metadataKey{}).(Metadata)
+	return metadata
+}
+
+// ExtractMetadata removes the query parameters that set metadata from the query and returns the
+// metadata they set.
+func ExtractMetadata(query map[string][]string) Metadata {
+	var metadata Metadata
+	if values := query[AuthorParam]; len(values) != 0 {
+		metadata.Author = values[0]
+	}
+	if values := query[SubjectParam]; len(values) != 0 {
+		metadata.Subject = values[0]
+	}
+	delete(query, AuthorParam)
+	delete(query, SubjectParam)
+	return metadata
+}
+
+// Determine the metadata of a document containing the given recipes. Metadata in the context
+// override the defaults. Keywords are the categories of all recipes.
+func documentMetadata(
+	ctx context.Context,
+	defaults Metadata,
+	recipes []mealieclient.Recipe,
+) Metadata {
+	metadata := defaults
+	fromCtx := metadataFrom(ctx)
+	if fromCtx.Author != "" {
+		metadata.Author = fromCtx.Author
+	}
+	if fromCtx.Subject != "" {
+		metadata.Subject = fromCtx.Subject
+	}
+
+	categories := map[string]bool{}
+	for _, recipe := range recipes {
+		for _, category := range recipe.Categories {
+			categories[category.Name] = true
+		}
+	}
+	metadata.Keywords = make([]string, 0, len(categories))
+	for category := range categories {
+		metadata.Keywords = append(metadata.Keywords, category)
+	}
+	SortNames(metadata.Keywords)
+
+	if Language != language.Und {
+		metadata.Language = Language.String()
+	}
+	return metadata
+}
+
+// The metadata as pandoc's metadata fields.
+func (m Metadata) pandocMetadata() map[string]string {
+	fields := map[string]string{}
+	if m.Author != "" {
+		fields["author"] = m.Author
+	}
+	if m.Subject != "" {
+		fields["subject"] = m.Subject
+	}
+	if len(m.Keywords) != 0 {
+		fields["keywords"] = strings.Join(m.Keywords, ", ")
+	}
+	if m.Language != "" {
+		fields["lang"] = m.Language
+	}
+	return fields
+}
@@ -0,0 +1,113 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"golang.org/x/net/html"
+
+	"github.com/razziel89/mealie-addons/summary"
+)
+
+var nativeTemplate = template.Must(template.New("native").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{.Body}}
+</body>
+</html>
+`))
+
+// Native is a Converter that converts markdown to HTML without any external executables. It
+// supports only HTML output.
+type Native struct {
+	markdown  goldmark.Markdown
+	htmlHooks []HTMLHook
+}
+
+// NewNative creates a native converter that runs the given hooks on every HTML document.
+func NewNative(htmlHooks []HTMLHook) *Native {
+	// Recipes are rendered using raw HTML elements, e.g. for anchors. Thus, we have to keep them.
+	// Headings need IDs since the generated documents link to some of them.
+	markdown := goldmark.New(
+		goldmark.WithExtensions(extension.Table),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+	return &Native{markdown: markdown, htmlHooks: htmlHooks}
+}
+
+// Convert converts markdown input to a standalone HTML document.
+func (n *Native) Convert(
+	ctx context.Context,
+	markdownInput string,
+	toFormat string,
+	title string,
+) ([]byte, error) {
+	if toFormat != "html" {
+		return nil, fmt.Errorf("native converter cannot convert to %s", toFormat)
+	}
+
+	body := bytes.Buffer{}
+	err := n.markdown.Convert([]byte(markdownInput), &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert markdown to html: %s", err.Error())
+	}
+
+	document := bytes.Buffer{}
+	err = nativeTemplate.Execute(&document, struct {
+		Title string
+		Body  template.HTML
+	}{
+		Title: title,
+		Body:  template.HTML(body.String()), //#nosec:G203
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build html document: %s", err.Error())
+	}
+
+	root, err := html.Parse(&document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated html: %s", err.Error())
+	}
+	for idx, hook := range n.htmlHooks {
+		root, err = hook(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run %d'nth html hook: %s", idx+1, err.Error())
+		}
+	}
+	summary.From(ctx).AddImages(countImages(root))
+	buf := bytes.Buffer{}
+	err = html.Render(&buf, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render HTML output: %s", err.Error())
+	}
+	return buf.Bytes(), nil
+}
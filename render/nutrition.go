@@ -0,0 +1,179 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// A nutrient as shown in nutrition tables. Bare numbers are shown with the unit.
+type nutrient struct {
+	label string
+	unit  string
+	value func(n *mealieclient.Nutrition) string
+}
+
+// Nutrients in the order in which they are shown.
+var nutrients = []nutrient{
+	{"Calories", "kcal", func(n *mealieclient.Nutrition) string { return n.Calories }},
+	{"Fat", "g", func(n *mealieclient.Nutrition) string { return n.FatContent }},
+	{"Saturated fat", "g", func(n *mealieclient.Nutrition) string { return n.SaturatedFatContent }},
+	{"Trans fat", "g", func(n *mealieclient.Nutrition) string { return n.TransFatContent }},
+	{
+		"Unsaturated fat", "g",
+		func(n *mealieclient.Nutrition) string { return n.UnsaturatedFatContent },
+	},
+	{"Carbohydrates", "g", func(n *mealieclient.Nutrition) string { return n.CarbohydrateContent }},
+	{"Sugar", "g", func(n *mealieclient.Nutrition) string { return n.SugarContent }},
+	{"Fiber", "g", func(n *mealieclient.Nutrition) string { return n.FiberContent }},
+	{"Protein", "g", func(n *mealieclient.Nutrition) string { return n.ProteinContent }},
+	{"Sodium", "mg", func(n *mealieclient.Nutrition) string { return n.SodiumContent }},
+	{"Cholesterol", "mg", func(n *mealieclient.Nutrition) string { return n.CholesterolContent }},
+}
+
+// Nutrients averaged in the nutrition appendix. Indices into nutrients.
+var summarisedNutrients = []int{0, 1, 5, 8}
+
+// Parse the amount of a nutrient, e.g. "350", "350 kcal" or "12,5g". Report false if the value
+// does not start with a number.
+func nutrientAmount(value string) (float64, bool) {
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != ','
+	})
+	if end == -1 {
+		end = len(value)
+	}
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(value[:end], ",", "."), 64)
+	return amount, err == nil
+}
+
+// Format an amount followed by a unit.
+func formatAmount(amount float64, unit string) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64) + " " + unit
+}
+
+// Format the value of a nutrient. Bare numbers get the nutrient's unit. Anything else, e.g. a
+// value with a unit of its own, is shown as it is.
+func (n nutrient) format(value string) string {
+	if amount, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64); err == nil {
+		return formatAmount(amount, n.unit)
+	}
+	return value
+}
+
+// Build a table with the nutritional values per serving of a recipe. Nutrients without a value
+// are left out. Empty if no value is known at all.
+func nutritionTable(recipe *mealieclient.Recipe) []string {
+	rows := []string{}
+	for _, nutrient := range nutrients {
+		if value := nutrient.value(&recipe.Nutrition); value != "" {
+			rows = append(rows, fmt.Sprintf("| %s | %s |", nutrient.label, nutrient.format(value)))
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	result := []string{"", "| Nutrition per serving | |", "|:--|--:|"}
+	return append(result, rows...)
+}
+
+// Build an appendix with the average nutritional values per serving of the recipes in each
+// category. Recipes without a value for a nutrient do not count towards its average, which is why
+// the number of recipes with any values is shown, too. Empty if no recipe has any values.
+func nutritionAppendix(recipes []mealieclient.Recipe, categories []string) []string {
+	type totals struct {
+		recipes int
+		known   int
+		sums    []float64
+		counts  []int
+	}
+	newTotals := func() *totals {
+		return &totals{
+			sums:   make([]float64, len(summarisedNutrients)),
+			counts: make([]int, len(summarisedNutrients)),
+		}
+	}
+	add := func(t *totals, recipe *mealieclient.Recipe) {
+		t.recipes++
+		if len(nutritionTable(recipe)) > 0 {
+			t.known++
+		}
+		for idx, nutrientIdx := range summarisedNutrients {
+			value := nutrients[nutrientIdx].value(&recipe.Nutrition)
+			if amount, ok := nutrientAmount(value); ok {
+				t.sums[idx] += amount
+				t.counts[idx]++
+			}
+		}
+	}
+
+	all := newTotals()
+	perCategory := map[string]*totals{}
+	for _, category := range categories {
+		perCategory[category] = newTotals()
+	}
+	for idx := range recipes {
+		add(all, &recipes[idx])
+		for _, category := range recipes[idx].Categories {
+			if t, found := perCategory[category.Name]; found {
+				add(t, &recipes[idx])
+			}
+		}
+	}
+	if all.known == 0 {
+		return nil
+	}
+
+	header := "| Category | Recipes with data |"
+	alignment := "|:--|--:|"
+	for _, nutrientIdx := range summarisedNutrients {
+		header += fmt.Sprintf(" %s |", nutrients[nutrientIdx].label)
+		alignment += "--:|"
+	}
+	row := func(name string, t *totals) string {
+		line := fmt.Sprintf("| %s | %d of %d |", name, t.known, t.recipes)
+		for idx, nutrientIdx := range summarisedNutrients {
+			if t.counts[idx] == 0 {
+				line += " – |"
+				continue
+			}
+			average := math.Round(10*t.sums[idx]/float64(t.counts[idx])) / 10 //nolint:mnd
+			line += fmt.Sprintf(" %s |", formatAmount(average, nutrients[nutrientIdx].unit))
+		}
+		return line
+	}
+
+	result := []string{
+		"# Nutrition\n",
+		"Average nutritional values per serving.",
+		"Recipes without a value for a nutrient do not count towards its average.\n",
+		header,
+		alignment,
+	}
+	for _, category := range categories {
+		result = append(result, row(category, perCategory[category]))
+	}
+	result = append(result, row("All recipes", all))
+	return append(result, "\n"+`<div style="page-break-before: always;"></div>`+"\n")
+}
@@ -0,0 +1,67 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+func TestBuildMarkdownNutrition(t *testing.T) {
+	recipes := []mealieclient.Recipe{
+		{
+			ID: "1", Name: "Apple Pie", Slug: "apple-pie",
+			Categories: []mealieclient.Organiser{{Name: "Dessert"}},
+			Nutrition:  mealieclient.Nutrition{Calories: "300", ProteinContent: "4,5"},
+		},
+		{
+			ID: "2", Name: "Crumble", Slug: "crumble",
+			Categories: []mealieclient.Organiser{{Name: "Dessert"}},
+			Nutrition:  mealieclient.Nutrition{Calories: "401 kcal", FatContent: "about 20g"},
+		},
+		{
+			ID: "3", Name: "Bread", Slug: "bread",
+			Categories: []mealieclient.Organiser{{Name: "Baking"}},
+		},
+	}
+	markdown := BuildMarkdown(recipes, "https://mealie", MarkdownOptions{Nutrition: true})
+
+	for _, expected := range []string{
+		"| Calories | 300 kcal |",
+		"| Protein | 4.5 g |",
+		"| Calories | 401 kcal |",
+		"| Fat | about 20g |",
+		"| Baking | 0 of 1 | – | – | – | – |",
+		"| Dessert | 2 of 2 | 350.5 kcal | – | – | 4.5 g |",
+		"| All recipes | 2 of 3 | 350.5 kcal | – | – | 4.5 g |",
+	} {
+		if !strings.Contains(markdown, expected) {
+			t.Errorf("missing %q:\n%s", expected, markdown)
+		}
+	}
+	if strings.Count(markdown, "Nutrition per serving") != 2 {
+		t.Errorf("recipes without nutritional values have a table:\n%s", markdown)
+	}
+
+	markdown = BuildMarkdown(recipes[2:], "https://mealie", MarkdownOptions{Nutrition: true})
+	if strings.Contains(markdown, "Nutrition") {
+		t.Errorf("nutrition shown although no values are known:\n%s", markdown)
+	}
+}
@@ -0,0 +1,48 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+func TestOdtGenerator(t *testing.T) {
+	converter := &recordingConverter{}
+	gen := &OdtGenerator{Converter: converter}
+	if got := gen.CommonName(); got != "odt" {
+		t.Errorf("CommonName() = %s, want odt", got)
+	}
+	if got := gen.Extension(); got != "odt" {
+		t.Errorf("Extension() = %s, want odt", got)
+	}
+	if got := gen.MimeType(); got != "application/vnd.oasis.opendocument.text" {
+		t.Errorf("MimeType() = %s, want application/vnd.oasis.opendocument.text", got)
+	}
+
+	recipes := []mealieclient.Recipe{{ID: "1", Name: "Apple Pie"}}
+	if _, err := gen.Response(context.Background(), recipes, time.Now()); err != nil {
+		t.Fatalf("Response() failed: %s", err.Error())
+	}
+	if converter.format != "odt" {
+		t.Errorf("converted to %s, want odt", converter.format)
+	}
+}
@@ -0,0 +1,759 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package render converts recipes into the supported output formats.
+package render
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/net/html"
+
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/tracing"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// Default fonts used if no fonts are provided. They are the Go fonts, which cover the Latin, Greek,
+// and Cyrillic scripts, in regular, bold, and italic styles. See fonts/README for their licence.
+//
+//go:embed fonts/*.ttf
+var defaultFonts embed.FS
+
+// A file that holds a style of main.ttf and the fontspec option that selects it.
+type fontStyle struct {
+	file   string
+	option string
+}
+
+// Files in font directories that hold the bold and italic styles of main.ttf. Without them, LaTeX
+// engines fake bold text and cannot render italic text at all.
+var mainFontStyles = []fontStyle{
+	{"main-bold.ttf", "BoldFont"},
+	{"main-italic.ttf", "ItalicFont"},
+	{"main-bolditalic.ttf", "BoldItalicFont"},
+}
+
+// Call an executable with arguments and return stdout and stderr. Specify the executable via
+// "exe"", the arguments via "args", additional environment variables in the form "key=value" via
+// "env", standard input via "stdin", and the working directory via "dir". An empty directory means
+// the current working directory. The command will be cancelled automatically when the context
+// expires.
+func runExe(
+	ctx context.Context, exe string, args []string, env []string, stdin []byte, dir string,
+) ([]byte, string, error) {
+	stdout := bytes.Buffer{}
+	errMsg, err := runExeTo(ctx, exe, args, env, stdin, dir, &stdout)
+	return stdout.Bytes(), errMsg, err
+}
+
+// Like runExe but write stdout to the given writer instead of keeping it in memory.
+func runExeTo(
+	ctx context.Context,
+	exe string,
+	args []string,
+	env []string,
+	stdin []byte,
+	dir string,
+	stdout io.Writer,
+) (string, error) {
+	slog.DebugContext(ctx, "running executable", "exe", exe, "args", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Env = env
+	cmd.Dir = dir
+
+	cmd.Stdin = bytes.NewReader(stdin)
+
+	cmd.Stdout = stdout
+	stderr := strings.Builder{}
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	return stderr.String(), err
+}
+
+// Log what an executable wrote to stderr. That is mostly chatter, e.g. due to pandoc's --verbose
+// flag, which is why it is logged at debug level unless the executable failed.
+func logStderr(ctx context.Context, exe string, errMsg string, err error) {
+	if errMsg == "" {
+		return
+	}
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "stderr of executable", "exe", exe, "stderr", errMsg)
+}
+
+// Pandoc is a Converter that uses the pandoc executable.
+type Pandoc struct {
+	// Protects options and fonts, which can be reloaded while conversions are running. Every
+	// conversion uses a copy of the converter taken when it starts.
+	lock      *sync.RWMutex
+	options   []string
+	fonts     pandocFonts
+	htmlHooks []HTMLHook
+	// Hooks that are run after htmlHooks only when converting to specific formats.
+	formatHooks map[string][]HTMLHook
+	// The directory that the default fonts were extracted to, if any, which is reused on reload.
+	defaultFontDir string
+	subsetFonts    bool
+	lint           bool
+	// Whether PDF documents with characters that no font has glyphs for are rejected.
+	rejectMissingGlyphs bool
+	pdfLayout           PDFLayout
+	pdfEngine           string
+}
+
+// The fonts that pandoc uses.
+type pandocFonts struct {
+	// The file name of the main font and the file names of fallback fonts in brackets, which is
+	// how LaTeX engines tell file names from font names.
+	mainFont      string
+	fallbackFonts []string
+	// Fontspec options that select the styles of the main font, see mainFontStyles.
+	mainFontOptions []string
+	// The directory that holds all font files, which are linked into the directory that every
+	// conversion runs in. Empty if no fonts were loaded.
+	fontDir   string
+	fontFiles []string
+	// The glyphs provided by the main and fallback fonts. Nil if unknown.
+	coverage []glyphCoverage
+}
+
+// NewPandoc creates a pandoc converter that passes the given options to pandoc and that runs the
+// given hooks on every intermediate HTML document.
+func NewPandoc(options []string, htmlHooks []HTMLHook) *Pandoc {
+	return &Pandoc{
+		lock:        &sync.RWMutex{},
+		options:     options,
+		htmlHooks:   htmlHooks,
+		formatHooks: map[string][]HTMLHook{},
+	}
+}
+
+// SetOptions replaces the options passed to pandoc. Conversions that are already running keep
+// using the previous ones.
+func (p *Pandoc) SetOptions(options []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.options = options
+}
+
+// Return a copy of the converter that is not affected by reloading options or fonts.
+func (p *Pandoc) current() *Pandoc {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	run := *p
+	return &run
+}
+
+// AddFormatHooks registers hooks that are run on the intermediate HTML document after all other
+// hooks, but only when converting to the given format.
+func (p *Pandoc) AddFormatHooks(toFormat string, hooks ...HTMLHook) {
+	p.formatHooks[toFormat] = append(p.formatHooks[toFormat], hooks...)
+}
+
+// SetFontSubsetting determines whether fonts embedded in PDF documents are subset via ghostscript.
+func (p *Pandoc) SetFontSubsetting(enabled bool) {
+	p.subsetFonts = enabled
+}
+
+// SetGlyphRejection determines whether PDF documents containing characters that none of the loaded
+// fonts have glyphs for are rejected. Otherwise, such characters are reported as warnings and
+// rendered as boxes.
+func (p *Pandoc) SetGlyphRejection(enabled bool) {
+	p.rejectMissingGlyphs = enabled
+}
+
+// SetLinting determines whether the markdown input and the intermediate HTML document are checked
+// for content issues, e.g. unclosed raw HTML tags. Issues are logged and recorded for the export
+// as warnings but never fail it.
+func (p *Pandoc) SetLinting(enabled bool) {
+	p.lint = enabled
+}
+
+// SetPDFLayout determines the layout of PDF documents. It has no effect on other formats.
+func (p *Pandoc) SetPDFLayout(layout PDFLayout) {
+	p.pdfLayout = layout
+}
+
+// SetPDFEngine determines the engine that generates PDF documents, one of PDFEngines. By default,
+// DefaultPDFEngine is used.
+func (p *Pandoc) SetPDFEngine(engine string) error {
+	if !slices.Contains(PDFEngines, engine) {
+		return fmt.Errorf("unknown pdf engine %s, use one of %v", engine, PDFEngines)
+	}
+	p.pdfEngine = engine
+	return nil
+}
+
+// Select the engine for the final pandoc conversion to PDF and apply the layout.
+func (p *Pandoc) applyPDFEngine(defaults *pandocDefaults) {
+	engine := p.pdfEngine
+	if engine == "" {
+		engine = DefaultPDFEngine
+	}
+	defaults.PDFEngine = engine
+	if engine == PDFEngineTypst && p.fonts.fontDir != "" {
+		// Fonts are linked into the directory pandoc runs in but typst does not look there.
+		defaults.PDFEngineOpts = append(defaults.PDFEngineOpts, "--font-path="+p.fonts.fontDir)
+	}
+	p.pdfLayout.applyTo(defaults, engine)
+}
+
+// Create a private directory for a single conversion that pandoc runs in and link all fonts into
+// it. That way, concurrent conversions never share any files. Return the directory and a function
+// that removes it.
+func (p *Pandoc) newRunDir(ctx context.Context) (string, func(), error) {
+	dir, err := os.MkdirTemp(workdir.Dir(ctx), workdir.Prefix+"pandoc-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %s", err.Error())
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			slog.WarnContext(
+				ctx, "failed to remove temporary directory", "path", dir, "error", err,
+			)
+		}
+	}
+	for _, name := range p.fonts.fontFiles {
+		err := linkFile(filepath.Join(p.fonts.fontDir, name), filepath.Join(dir, name))
+		if err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("failed to link font %s: %s", name, err.Error())
+		}
+	}
+	return dir, cleanup, nil
+}
+
+// LoadFonts makes all TrueType fonts in dir available to pandoc. A font called main.ttf is used as
+// the main font, the files in mainFontStyles as its styles, all others as fallback fonts. If all
+// fonts can be parsed, characters in PDF documents that none of them has glyphs for are reported,
+// see SetGlyphRejection. If there are no fonts in dir, default fonts embedded in the binary are
+// used. Fonts are never modified, so dir may be shared. It can be called again to reload the fonts,
+// which keeps the previous ones on error. Conversions that are already running keep using the
+// previous fonts.
+func (p *Pandoc) LoadFonts(dir string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path of %s: %s", dir, err.Error())
+	}
+
+	content, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list directory %s: %s", dir, err.Error())
+	}
+	filtered := make([]string, 0, len(content))
+	files := make([]string, 0, len(content))
+	coverage := make([]glyphCoverage, 0, len(content))
+	canCheckGlyphs := true
+	fonts := pandocFonts{}
+	styles := []string{}
+	for _, file := range content {
+		isRelevant := false
+		isStyle := slices.ContainsFunc(mainFontStyles, func(style fontStyle) bool {
+			return style.file == file.Name()
+		})
+		if file.Name() == "main.ttf" {
+			fonts.mainFont = file.Name()
+			isRelevant = true
+		} else if isStyle {
+			styles = append(styles, file.Name())
+			isRelevant = true
+		} else if strings.HasSuffix(file.Name(), ".ttf") {
+			filtered = append(filtered, fmt.Sprintf("[%s]", file.Name()))
+			isRelevant = true
+		}
+		if isRelevant && canCheckGlyphs {
+			fontCoverage, err := readGlyphCoverage(filepath.Join(dir, file.Name()))
+			if err != nil {
+				slog.Warn(
+					"cannot check glyphs, failed to parse font", "font", file.Name(), "error", err,
+				)
+				canCheckGlyphs = false
+			}
+			coverage = append(coverage, fontCoverage)
+		}
+		if isRelevant {
+			files = append(files, file.Name())
+		}
+	}
+	if fonts.mainFont != "" {
+		fonts.mainFontOptions = styleOptions(styles)
+	} else {
+		// Without a main font, its styles are fallback fonts like any other.
+		for _, style := range styles {
+			filtered = append(filtered, fmt.Sprintf("[%s]", style))
+		}
+	}
+	slices.Sort(filtered)
+	if len(filtered) != 0 {
+		fonts.fallbackFonts = filtered
+	}
+	if fonts.mainFont == "" && len(filtered) == 0 {
+		slog.Info("no fonts found, using default fonts", "path", dir)
+		return p.useDefaultFonts()
+	}
+	fonts.fontDir, fonts.fontFiles = dir, files
+	if canCheckGlyphs && fonts.mainFont != "" {
+		fonts.coverage = coverage
+	}
+	p.fonts = fonts
+	return nil
+}
+
+// Return the fontspec options that select the styles of the main font in the given files.
+func styleOptions(files []string) []string {
+	options := []string{}
+	for _, style := range mainFontStyles {
+		if slices.Contains(files, style.file) {
+			options = append(options, style.option+"="+style.file)
+		}
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// Extract the default fonts into a temporary directory, unless that happened before, and use them.
+// Glyphs are not checked since users cannot do anything about the default fonts lacking some,
+// other than providing fonts of their own. Such characters are rendered as boxes instead.
+func (p *Pandoc) useDefaultFonts() error {
+	entries, err := defaultFonts.ReadDir("fonts")
+	if err != nil {
+		return fmt.Errorf("failed to list default fonts: %s", err.Error())
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	if p.defaultFontDir == "" {
+		dir, err := os.MkdirTemp(workdir.Root(), workdir.Prefix+"fonts-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %s", err.Error())
+		}
+		for _, name := range files {
+			font, err := defaultFonts.ReadFile("fonts/" + name)
+			if err != nil {
+				return fmt.Errorf("failed to read default font %s: %s", name, err.Error())
+			}
+			err = os.WriteFile(filepath.Join(dir, name), font, 0o600) //nolint:mnd
+			if err != nil {
+				return fmt.Errorf("failed to extract default font %s: %s", name, err.Error())
+			}
+		}
+		p.defaultFontDir = dir
+	}
+	p.fonts = pandocFonts{
+		mainFont:        "main.ttf",
+		mainFontOptions: styleOptions(files),
+		fontDir:         p.defaultFontDir,
+		fontFiles:       files,
+	}
+	return nil
+}
+
+func readGlyphCoverage(path string) (glyphCoverage, error) {
+	font, err := os.ReadFile(path) // #nosec:G304
+	if err != nil {
+		return nil, err
+	}
+	return parseGlyphCoverage(font)
+}
+
+// Link a file to another path or, where links are not possible, e.g. on Windows without the
+// respective privilege, copy it.
+func linkFile(source string, destination string) error {
+	if err := os.Symlink(source, destination); err == nil {
+		return nil
+	}
+	return copyFile(source, destination)
+}
+
+func copyFile(source string, destination string) error {
+	data, err := os.ReadFile(source) //#nosec:G304
+	if err != nil {
+		return fmt.Errorf("failed to read source file %s: %s", source, err.Error())
+	}
+	err = os.WriteFile(destination, data, 0o600) //nolint:mnd
+	if err != nil {
+		return fmt.Errorf("failed to write destination file %s: %s", destination, err.Error())
+	}
+	return nil
+}
+
+// CheckForPandoc verifies that the pandoc executable can be run.
+func CheckForPandoc() error {
+	_, err := exec.LookPath("pandoc")
+	if err != nil {
+		return fmt.Errorf("failed to find pandoc in path: %s", err.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) //nolint:mnd
+	defer cancel()
+	output, _, err := runExe(
+		ctx,
+		"pandoc",
+		[]string{"--version"},
+		nil,
+		nil,
+		"",
+	)
+	if err != nil {
+		return fmt.Errorf("failed to run pandoc --version: %s", err.Error())
+	}
+	slog.InfoContext(
+		ctx, "pandoc version information", "version", strings.TrimSpace(string(output)),
+	)
+	return nil
+}
+
+// Ready verifies that conversions can run, i.e. that the pandoc executable can be found and that
+// the fonts it uses are loaded and still exist. Unlike CheckForPandoc, it does not run pandoc.
+func (p *Pandoc) Ready() error {
+	if _, err := exec.LookPath("pandoc"); err != nil {
+		return fmt.Errorf("failed to find pandoc in path: %s", err.Error())
+	}
+	fonts := p.current().fonts
+	if fonts.fontDir == "" {
+		return fmt.Errorf("no fonts loaded")
+	}
+	for _, file := range fonts.fontFiles {
+		if _, err := os.Stat(filepath.Join(fonts.fontDir, file)); err != nil {
+			return fmt.Errorf("failed to find font %s: %s", file, err.Error())
+		}
+	}
+	return nil
+}
+
+// ProbePDFEngine determines the engine that generates PDF documents. If engine is not empty, it is
+// used even if its executable cannot be found. Otherwise, the first engine in PDFEngines whose
+// executable can be found is used. Without any, DefaultPDFEngine is used and PDF documents cannot
+// be generated.
+func ProbePDFEngine(engine string) string {
+	if engine != "" {
+		if _, err := exec.LookPath(engine); err != nil {
+			slog.Warn("pdf documents cannot be generated, failed to find engine", "engine", engine)
+		}
+		return engine
+	}
+	for _, candidate := range PDFEngines {
+		if _, err := exec.LookPath(candidate); err == nil {
+			slog.Info("generating pdf documents", "engine", candidate)
+			return candidate
+		}
+	}
+	slog.Warn(
+		"pdf documents cannot be generated, failed to find any engine",
+		"engines", strings.Join(PDFEngines, ", "),
+	)
+	return DefaultPDFEngine
+}
+
+// Convert converts markdown input to the desired format via pandoc. We convert twice for anything
+// that isn't HTML. The reason is that links in the document are broken unless we first convert to
+// HTML, but if we do that, they work also for other formats. No clue why that is.
+func (p *Pandoc) Convert(
+	ctx context.Context,
+	markdownInput string,
+	toFormat string,
+	title string,
+) ([]byte, error) {
+	p = p.current()
+	dir, cleanup, err := p.newRunDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	intermediate, last, err := p.prepare(ctx, dir, markdownInput, toFormat, title)
+	if err != nil {
+		return nil, err
+	}
+	output := bytes.Buffer{}
+	if err := p.finish(ctx, dir, intermediate, last, toFormat, &output); err != nil {
+		return nil, err
+	}
+	converted := output.Bytes()
+
+	if toFormat == "pdf" {
+		if p.subsetFonts {
+			summary.From(ctx).Stage("subsetting fonts via ghostscript")
+			start := time.Now()
+			converted, err = subsetFonts(ctx, converted, workdir.Dir(ctx))
+			summary.From(ctx).AddPass("ghostscript", time.Since(start))
+			if err != nil {
+				return nil, err
+			}
+		}
+		slog.DebugContext(
+			ctx, "fonts in pdf", "fonts", strings.Join(EmbeddedFonts(converted), ", "),
+		)
+	}
+	return converted, nil
+}
+
+// ConvertToFile is like Convert but writes the converted document to the file at path. The
+// document is never kept in memory as a whole, which matters for large documents with many images.
+func (p *Pandoc) ConvertToFile(
+	ctx context.Context,
+	markdownInput string,
+	toFormat string,
+	title string,
+	path string,
+) error {
+	p = p.current()
+	dir, cleanup, err := p.newRunDir(ctx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	intermediate, last, err := p.prepare(ctx, dir, markdownInput, toFormat, title)
+	if err != nil {
+		return err
+	}
+
+	// Ghostscript reads the document from a file anyway.
+	subset := toFormat == "pdf" && p.subsetFonts
+	target := path
+	if subset {
+		target = path + ".unsubset"
+		defer func() {
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				slog.WarnContext(
+					ctx, "failed to remove temporary file", "path", target, "error", err,
+				)
+			}
+		}()
+	}
+	file, err := os.Create(target) // #nosec:G304
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %s", target, err.Error())
+	}
+	err = p.finish(ctx, dir, intermediate, last, toFormat, file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write %s: %s", target, closeErr.Error())
+	}
+	if err != nil || !subset {
+		return err
+	}
+
+	summary.From(ctx).Stage("subsetting fonts via ghostscript")
+	start := time.Now()
+	err = subsetFontFile(ctx, target, path)
+	summary.From(ctx).AddPass("ghostscript", time.Since(start))
+	return err
+}
+
+// A pass of pandoc: the command-line arguments given by users and the defaults file with all other
+// options.
+type pandocPass struct {
+	userArgs []string
+	defaults pandocDefaults
+}
+
+// Run a pass of pandoc in dir for a conversion to the given format, reading input from stdin and
+// writing the result to stdout. Return what pandoc wrote to stderr.
+func (p *Pandoc) runPass(
+	ctx context.Context,
+	dir string,
+	toFormat string,
+	number int,
+	pass pandocPass,
+	stdin []byte,
+	stdout io.Writer,
+) (string, error) {
+	path, content, err := pass.defaults.write(dir)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := os.Remove(path); err != nil {
+			slog.WarnContext(
+				ctx, "failed to remove pandoc defaults file", "path", path, "error", err,
+			)
+		}
+	}()
+	args := append(slices.Clone(pass.userArgs), "--defaults="+path, "--output=-", "-")
+	recordPass(toFormat, number, args, content)
+	return runExeTo(ctx, "pandoc", args, nil, stdin, dir, stdout)
+}
+
+// Run the second pass of pandoc in dir that converts the intermediate HTML document to the desired
+// format and write the result to output.
+func (p *Pandoc) finish(
+	ctx context.Context,
+	dir string,
+	intermediate []byte,
+	last pandocPass,
+	toFormat string,
+	output io.Writer,
+) error {
+	summary.From(ctx).Stage("running pandoc pass 2/2 (%s)", toFormat)
+	ctx, span := tracing.Start(ctx, "pandoc pass 2/2", attribute.String("format", toFormat))
+	start := time.Now()
+	errMsg, err := p.runPass(ctx, dir, toFormat, 2, last, intermediate, output) //nolint:mnd
+	summary.From(ctx).AddPass("pandoc-"+toFormat, time.Since(start))
+	logStderr(ctx, "pandoc", errMsg, err)
+	tracing.End(span, err)
+	return err
+}
+
+// Run the first pass of pandoc in dir that converts the markdown input to an intermediate HTML
+// document and run all hooks on it. Return that document and the second pass.
+func (p *Pandoc) prepare(
+	ctx context.Context,
+	dir string,
+	markdownInput string,
+	toFormat string,
+	title string,
+) ([]byte, pandocPass, error) {
+	lintWarnings := []string{}
+	if toFormat == "pdf" && p.fonts.coverage != nil {
+		if missing := missingGlyphs(markdownInput, p.fonts.coverage); len(missing) != 0 {
+			msg := fmt.Sprintf(
+				"main.ttf and all fallback fonts lack glyphs for characters used by recipes: %s",
+				describeRunes(missing),
+			)
+			if p.rejectMissingGlyphs {
+				return nil, pandocPass{}, fmt.Errorf("%s", msg)
+			}
+			lintWarnings = append(lintWarnings, msg)
+		}
+	}
+	if p.lint {
+		lintWarnings = append(lintWarnings, lintMarkdown(markdownInput, toFormat)...)
+	}
+
+	metadata := metadataFrom(ctx).pandocMetadata()
+	metadata["title"] = title
+	metadata["pagetitle"] = title
+	options := append(append([]string{}, p.options...), pandocFlagsFrom(ctx)...)
+	alwaysUserArgs := []string{}
+	for _, arg := range options {
+		if !strings.HasPrefix(arg, "@first:") && !strings.HasPrefix(arg, "@last:") {
+			alwaysUserArgs = append(alwaysUserArgs, arg)
+		}
+	}
+
+	// Convert to HTML first. Somehow, internal links are broken without doing so.
+	first := pandocPass{
+		userArgs: append([]string{}, alwaysUserArgs...),
+		defaults: firstPassDefaults(metadata),
+	}
+	for _, arg := range options {
+		if rest, found := strings.CutPrefix(arg, "@first:"); found {
+			first.userArgs = append(first.userArgs, rest)
+		}
+	}
+
+	summary.From(ctx).Stage("running pandoc pass 1/2 (html)")
+	spanCtx, span := tracing.Start(ctx, "pandoc pass 1/2", attribute.String("format", toFormat))
+	start := time.Now()
+	stdout := bytes.Buffer{}
+	errMsg, err := p.runPass(spanCtx, dir, toFormat, 1, first, []byte(markdownInput), &stdout)
+	htmlIntermediate := stdout.Bytes()
+	summary.From(ctx).AddPass("pandoc-html", time.Since(start))
+	logStderr(ctx, "pandoc", errMsg, err)
+	tracing.End(span, err)
+	if err != nil {
+		// Warnings may well explain why pandoc failed.
+		reportLintWarnings(ctx, lintWarnings)
+		return nil, pandocPass{}, err
+	}
+
+	root, err := html.Parse(bytes.NewReader(htmlIntermediate))
+	if err != nil {
+		return nil, pandocPass{}, fmt.Errorf("failed to parse generated html: %s", err.Error())
+	}
+	for idx, hook := range p.htmlHooks {
+		root, err = hook(root)
+		if err != nil {
+			return nil, pandocPass{}, fmt.Errorf(
+				"failed to run %d'nth html hook: %s", idx+1, err.Error(),
+			)
+		}
+	}
+	for idx, hook := range p.formatHooks[toFormat] {
+		root, err = hook(root)
+		if err != nil {
+			return nil, pandocPass{}, fmt.Errorf(
+				"failed to run %d'nth %s html hook: %s", idx+1, toFormat, err.Error(),
+			)
+		}
+	}
+	if filetypeHook := filetypeHooks[toFormat]; filetypeHook != nil {
+		root, err = filetypeHook(root)
+		if err != nil {
+			return nil, pandocPass{}, fmt.Errorf(
+				"failed to run filetype html hook: %s", err.Error(),
+			)
+		}
+	}
+	if p.lint {
+		lintWarnings = append(lintWarnings, lintHTML(root)...)
+	}
+	reportLintWarnings(ctx, lintWarnings)
+	summary.From(ctx).AddImages(countImages(root))
+	buf := bytes.Buffer{}
+	err = html.Render(&buf, root)
+	if err != nil {
+		return nil, pandocPass{}, fmt.Errorf("failed to render HTML output: %s", err.Error())
+	}
+	htmlIntermediate = buf.Bytes()
+
+	// Convert again, but to the desired format.
+	last := pandocPass{
+		userArgs: append([]string{}, alwaysUserArgs...),
+		defaults: lastPassDefaults(toFormat, metadata),
+	}
+	for _, arg := range options {
+		if rest, found := strings.CutPrefix(arg, "@last:"); found {
+			last.userArgs = append(last.userArgs, rest)
+		}
+	}
+	if p.fonts.mainFont != "" {
+		last.defaults.Variables["mainfont"] = p.fonts.mainFont
+	}
+	if p.fonts.mainFontOptions != nil {
+		last.defaults.Variables["mainfontoptions"] = p.fonts.mainFontOptions
+	}
+	if p.fonts.fallbackFonts != nil {
+		last.defaults.Variables["mainfontfallback"] = p.fonts.fallbackFonts
+	}
+	if toFormat == "pdf" {
+		p.applyPDFEngine(&last.defaults)
+	}
+
+	return htmlIntermediate, last, nil
+}
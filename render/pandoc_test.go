@@ -0,0 +1,179 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+func TestNewRunDirIsPrivate(t *testing.T) {
+	if err := workdir.SetRoot(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	pandoc := NewPandoc(nil, nil)
+	// An empty directory makes pandoc fall back to the default fonts.
+	if err := pandoc.LoadFonts(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	first, cleanupFirst, err := pandoc.newRunDir(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, cleanupSecond, err := pandoc.newRunDir(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupSecond()
+
+	if first == second || filepath.Dir(first) != workdir.Root() {
+		t.Errorf("run directories are not private to conversions: %s, %s", first, second)
+	}
+	for _, dir := range []string{first, second} {
+		if _, err := os.Stat(filepath.Join(dir, pandoc.fonts.mainFont)); err != nil {
+			t.Errorf("main font missing from %s: %s", dir, err.Error())
+		}
+	}
+	cleanupFirst()
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("run directory %s was not removed", first)
+	}
+	if _, err := os.Stat(filepath.Join(second, pandoc.fonts.mainFont)); err != nil {
+		t.Errorf("removing one run directory affected another: %s", err.Error())
+	}
+}
+
+func TestReloadDoesNotAffectRunningConversions(t *testing.T) {
+	if err := workdir.SetRoot(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	pandoc := NewPandoc(nil, nil)
+	if err := pandoc.LoadFonts(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	run := pandoc.current()
+
+	pandoc.SetOptions([]string{"--toc"})
+	if run.options != nil {
+		t.Errorf("options of a running conversion changed to %v", run.options)
+	}
+	if len(pandoc.current().options) != 1 {
+		t.Errorf("options were not reloaded: %v", pandoc.current().options)
+	}
+
+	if err := pandoc.LoadFonts(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error loading fonts from a missing directory")
+	}
+	if pandoc.current().fonts.fontDir != run.fonts.fontDir {
+		t.Error("fonts were not kept after failing to reload them")
+	}
+	// The default fonts are not extracted again.
+	if err := pandoc.LoadFonts(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if pandoc.current().fonts.fontDir != run.fonts.fontDir {
+		t.Errorf("default fonts were extracted again to %s", pandoc.current().fonts.fontDir)
+	}
+}
+
+func TestReadyRequiresPandocAndFonts(t *testing.T) {
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	pandoc := NewPandoc(nil, nil)
+	if err := pandoc.Ready(); err == nil {
+		t.Error("expected not to be ready without pandoc")
+	}
+
+	fakePandoc := filepath.Join(bin, "pandoc")
+	if err := os.WriteFile(fakePandoc, []byte("#!/bin/sh\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := pandoc.Ready(); err == nil {
+		t.Error("expected not to be ready without fonts")
+	}
+
+	fontDir := t.TempDir()
+	font, err := defaultFonts.ReadFile("fonts/main.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fontDir, "main.ttf"), font, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := pandoc.LoadFonts(fontDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := pandoc.Ready(); err != nil {
+		t.Errorf("expected to be ready: %s", err.Error())
+	}
+
+	if err := os.Remove(filepath.Join(fontDir, "main.ttf")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pandoc.Ready(); err == nil {
+		t.Error("expected not to be ready after fonts were removed")
+	}
+}
+
+func TestFontStyles(t *testing.T) {
+	if err := workdir.SetRoot(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	pandoc := NewPandoc(nil, nil)
+	if err := pandoc.LoadFonts(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defaults := pandoc.current().fonts
+	if len(defaults.mainFontOptions) != len(mainFontStyles) {
+		t.Errorf("default fonts lack styles: %v", defaults.mainFontOptions)
+	}
+	// Characters that the default fonts lack are rendered as boxes instead of failing exports.
+	if defaults.coverage != nil {
+		t.Error("expected glyphs not to be checked for the default fonts")
+	}
+
+	fontDir := t.TempDir()
+	for _, name := range []string{"main.ttf", "main-bold.ttf"} {
+		font, err := defaultFonts.ReadFile("fonts/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(fontDir, name), font, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pandoc.LoadFonts(fontDir); err != nil {
+		t.Fatal(err)
+	}
+	fonts := pandoc.current().fonts
+	if !slices.Equal(fonts.mainFontOptions, []string{"BoldFont=main-bold.ttf"}) {
+		t.Errorf("unexpected options of the main font: %v", fonts.mainFontOptions)
+	}
+	if fonts.fallbackFonts != nil {
+		t.Errorf("styles of the main font used as fallback fonts: %v", fonts.fallbackFonts)
+	}
+	if len(fonts.fontFiles) != 2 {
+		t.Errorf("not all fonts are linked into run directories: %v", fonts.fontFiles)
+	}
+}
@@ -0,0 +1,238 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+	"github.com/razziel89/mealie-addons/media"
+	"github.com/razziel89/mealie-addons/summary"
+)
+
+// MediaSource provides media files belonging to recipes.
+type MediaSource interface {
+	GetMedia(
+		ctx context.Context,
+		uuid string,
+		filename string,
+		middle string,
+	) (mealieclient.MediaDownload, error)
+}
+
+// PaprikaGenerator generates a .paprikarecipes archive that can be imported into Paprika. It does
+// not need a converter. If Media is set, recipe images are retrieved and embedded.
+type PaprikaGenerator struct {
+	URL   string
+	Media MediaSource
+}
+
+// A single recipe as Paprika stores it. Paprika identifies recipes by their uid and detects changes
+// via their hash.
+type paprikaRecipe struct {
+	UID         string   `json:"uid"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Ingredients string   `json:"ingredients"`
+	Directions  string   `json:"directions"`
+	Notes       string   `json:"notes"`
+	Servings    string   `json:"servings"`
+	PrepTime    string   `json:"prep_time"`
+	CookTime    string   `json:"cook_time"`
+	TotalTime   string   `json:"total_time"`
+	Rating      int      `json:"rating"`
+	Categories  []string `json:"categories"`
+	Source      string   `json:"source"`
+	SourceURL   string   `json:"source_url"`
+	Created     string   `json:"created"`
+	Photo       string   `json:"photo"`
+	PhotoData   string   `json:"photo_data"`
+	PhotoHash   string   `json:"photo_hash"`
+	Hash        string   `json:"hash"`
+}
+
+// CommonName is the name of the format.
+func (g *PaprikaGenerator) CommonName() string {
+	return "paprika"
+}
+
+// Extension is the file extension of the format.
+func (g *PaprikaGenerator) Extension() string {
+	return "paprikarecipes"
+}
+
+// MimeType is the mime type of the format.
+func (g *PaprikaGenerator) MimeType() string {
+	return "application/zip"
+}
+
+// Response generates a zip archive containing one gzip-compressed JSON file per recipe, which is
+// the format Paprika uses to exchange recipes. Paprika has no tags, which is why both categories
+// and tags become Paprika categories.
+func (g *PaprikaGenerator) Response(
+	ctx context.Context,
+	recipes []mealieclient.Recipe,
+	timestamp time.Time,
+) ([]byte, error) {
+	archive := bytes.Buffer{}
+	writer := zip.NewWriter(&archive)
+	names := map[string]int{}
+	for _, recipe := range recipes {
+		converted := g.convert(ctx, recipe, timestamp)
+
+		content, err := json.Marshal(converted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s to json: %s", recipe.Name, err.Error())
+		}
+		compressed := bytes.Buffer{}
+		compressor := gzip.NewWriter(&compressed)
+		if _, err := compressor.Write(content); err != nil {
+			return nil, fmt.Errorf("failed to compress %s: %s", recipe.Name, err.Error())
+		}
+		if err := compressor.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress %s: %s", recipe.Name, err.Error())
+		}
+
+		// Entry names have to be unique within the archive but recipe names need not be.
+		name := strings.ReplaceAll(recipe.Name, "/", "-")
+		if name == "" {
+			name = recipe.Slug
+		}
+		names[name]++
+		if names[name] > 1 {
+			name = fmt.Sprintf("%s (%d)", name, names[name])
+		}
+		entry, err := writer.Create(name + ".paprikarecipe")
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to archive: %s", recipe.Name, err.Error())
+		}
+		if _, err := entry.Write(compressed.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to add %s to archive: %s", recipe.Name, err.Error())
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalise archive: %s", err.Error())
+	}
+	return archive.Bytes(), nil
+}
+
+func (g *PaprikaGenerator) convert(
+	ctx context.Context,
+	recipe mealieclient.Recipe,
+	timestamp time.Time,
+) paprikaRecipe {
+	ingredients := make([]string, 0, len(recipe.Ingredients))
+	for _, ingredient := range recipe.Ingredients {
+		ingredients = append(ingredients, ingredient.Text)
+	}
+	directions := make([]string, 0, len(recipe.Instructions))
+	for _, instruction := range recipe.Instructions {
+		directions = append(directions, instruction.Text)
+	}
+	notes := make([]string, 0, len(recipe.Comments))
+	for _, comment := range recipe.Comments {
+		notes = append(notes, fmt.Sprintf("%s: %s", comment.User.Name, comment.Text))
+	}
+	categories := make([]string, 0, len(recipe.Categories)+len(recipe.Tags))
+	for _, category := range recipe.Categories {
+		categories = append(categories, category.Name)
+	}
+	for _, tag := range recipe.Tags {
+		categories = append(categories, tag.Name)
+	}
+	servings := ""
+	if recipe.Servings > 0 {
+		servings = fmt.Sprint(recipe.Servings)
+	}
+
+	converted := paprikaRecipe{
+		UID:         strings.ToUpper(recipe.ID),
+		Name:        recipe.Name,
+		Description: recipe.Description,
+		Ingredients: strings.Join(ingredients, "\n"),
+		Directions:  strings.Join(directions, "\n\n"),
+		Notes:       strings.Join(notes, "\n\n"),
+		Servings:    servings,
+		PrepTime:    recipe.PrepTime,
+		CookTime:    recipe.PerformTime,
+		TotalTime:   recipe.TotalTime,
+		Rating:      int(math.Round(float64(recipe.Rating))),
+		Categories:  categories,
+		Source:      "Mealie",
+		SourceURL:   recipe.OrgURL,
+		Created:     timestamp.Format(time.DateTime),
+	}
+	if g.URL != "" && converted.SourceURL == "" {
+		converted.SourceURL = fmt.Sprintf("%s/r/%s", g.URL, recipe.Slug)
+	}
+
+	if g.Media != nil && recipe.Image != "" {
+		photo, extension, err := g.photo(ctx, recipe)
+		if err == nil {
+			photoHash := sha256.Sum256(photo)
+			converted.Photo = converted.UID + "." + extension
+			converted.PhotoData = base64.StdEncoding.EncodeToString(photo)
+			converted.PhotoHash = strings.ToUpper(hex.EncodeToString(photoHash[:]))
+			summary.From(ctx).AddImages(1)
+		} else {
+			slog.WarnContext(ctx, "skipping image", "recipe", recipe.Name, "error", err)
+		}
+	}
+
+	// The hash covers everything but itself so that Paprika notices changed recipes.
+	content, _ := json.Marshal(converted)
+	hash := sha256.Sum256(content)
+	converted.Hash = strings.ToUpper(hex.EncodeToString(hash[:]))
+	return converted
+}
+
+// Retrieve the image of a recipe as JPEG or PNG, which are the formats Paprika understands. Return
+// the image and its file extension.
+func (g *PaprikaGenerator) photo(
+	ctx context.Context,
+	recipe mealieclient.Recipe,
+) ([]byte, string, error) {
+	download, err := g.Media.GetMedia(ctx, recipe.ID, "original.webp", "images")
+	if err != nil {
+		return nil, "", err
+	}
+	content, mime, err := media.Prepare(ctx, download.Content, download.Mime)
+	if err != nil {
+		return nil, "", err
+	}
+	switch mime {
+	case "image/jpeg":
+		return content, "jpg", nil
+	case "image/png":
+		return content, "png", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported image type %s", mime)
+	}
+}
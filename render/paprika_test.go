@@ -0,0 +1,119 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+// A MediaSource that knows a single PNG image for every recipe with an ID other than "missing".
+type fakeMediaSource struct{}
+
+func (fakeMediaSource) GetMedia(
+	_ context.Context,
+	uuid string,
+	_ string,
+	_ string,
+) (mealieclient.MediaDownload, error) {
+	if uuid == "missing" {
+		return mealieclient.MediaDownload{}, fmt.Errorf("not found")
+	}
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		return mealieclient.MediaDownload{}, err
+	}
+	return mealieclient.MediaDownload{Content: buf.Bytes(), Mime: "image/png"}, nil
+}
+
+func TestPaprikaGeneratorResponse(t *testing.T) {
+	recipes := []mealieclient.Recipe{
+		{
+			ID:           "abc",
+			Slug:         "pie",
+			Name:         "Pie",
+			Rating:       4,
+			Image:        "yes",
+			Categories:   []mealieclient.Organiser{{Name: "Dessert"}},
+			Tags:         []mealieclient.Organiser{{Name: "Sweet"}},
+			Ingredients:  []mealieclient.Ingredient{{Text: "Apples"}, {Text: "Flour"}},
+			Instructions: []mealieclient.Instruction{{Text: "Mix"}, {Text: "Bake"}},
+		},
+		{ID: "missing", Slug: "pie-2", Name: "Pie", Image: "yes"},
+	}
+	gen := &PaprikaGenerator{URL: "http://mealie", Media: fakeMediaSource{}}
+	response, err := gen.Response(context.Background(), recipes, time.Now())
+	if err != nil {
+		t.Fatalf("Response() failed: %s", err.Error())
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(response), int64(len(response)))
+	if err != nil {
+		t.Fatalf("Response() is no valid zip archive: %s", err.Error())
+	}
+	if len(archive.File) != 2 || archive.File[0].Name != "Pie.paprikarecipe" ||
+		archive.File[1].Name != "Pie (2).paprikarecipe" {
+		t.Fatalf("unexpected archive entries: %+v", archive.File)
+	}
+
+	parsed := make([]paprikaRecipe, 0, len(archive.File))
+	for _, file := range archive.File {
+		entry, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %s", file.Name, err.Error())
+		}
+		decompressor, err := gzip.NewReader(entry)
+		if err != nil {
+			t.Fatalf("%s is not gzip-compressed: %s", file.Name, err.Error())
+		}
+		content, err := io.ReadAll(decompressor)
+		if err != nil {
+			t.Fatalf("failed to decompress %s: %s", file.Name, err.Error())
+		}
+		var recipe paprikaRecipe
+		if err := json.Unmarshal(content, &recipe); err != nil {
+			t.Fatalf("%s is no valid json: %s", file.Name, err.Error())
+		}
+		parsed = append(parsed, recipe)
+	}
+
+	first := parsed[0]
+	if first.UID != "ABC" || first.Ingredients != "Apples\nFlour" ||
+		first.Directions != "Mix\n\nBake" || first.Rating != 4 ||
+		fmt.Sprint(first.Categories) != "[Dessert Sweet]" ||
+		first.SourceURL != "http://mealie/r/pie" || first.Hash == "" {
+		t.Errorf("unexpected first recipe: %+v", first)
+	}
+	if first.Photo != "ABC.png" || first.PhotoData == "" || first.PhotoHash == "" {
+		t.Errorf("first recipe lacks its photo: %+v", first)
+	}
+	if parsed[1].Photo != "" || parsed[1].PhotoData != "" {
+		t.Errorf("second recipe should have no photo: %+v", parsed[1])
+	}
+}
@@ -0,0 +1,97 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Presentation changes how recipes are presented, e.g. in a cookbook that is given as a gift.
+type Presentation struct {
+	// Title replaces the default title mentioning the time of the export.
+	Title string `json:"title"`
+	// Dedication is shown on its own page before the list of recipes. It is markdown.
+	Dedication string `json:"dedication"`
+	// Cover is the URL of an image that is shown on the first page.
+	Cover string `json:"cover"`
+	// HideComments omits the comments of all recipes.
+	HideComments bool `json:"hide-comments"`
+	// LargePhotos shows the photo of each recipe across the full width of the page.
+	LargePhotos bool `json:"large-photos"`
+
+	// Whether the document contains a single recipe only, see WithSingleRecipe.
+	singleRecipe bool
+	// Whether the document lists recipes without their details, see WithIndexOnly.
+	indexOnly bool
+}
+
+type presentationKey struct{}
+
+// WithPresentation returns a context that makes generators present recipes as described.
+func WithPresentation(ctx context.Context, presentation Presentation) context.Context {
+	return context.WithValue(ctx, presentationKey{}, presentation)
+}
+
+func presentationFrom(ctx context.Context) Presentation {
+	presentation, _ := ctx.Value(presentationKey{}).(Presentation)
+	return presentation
+}
+
+// WithSingleRecipe returns a context that makes generators lay out a document containing only the
+// recipe with the given name, e.g. to share it with somebody. The document is titled after the
+// recipe and contains neither a list of recipes, nor indices, nor a cover, nor a dedication.
+func WithSingleRecipe(ctx context.Context, name string) context.Context {
+	presentation := presentationFrom(ctx)
+	return WithPresentation(ctx, Presentation{
+		Title:        name,
+		HideComments: presentation.HideComments,
+		LargePhotos:  presentation.LargePhotos,
+		singleRecipe: true,
+	})
+}
+
+// The title of a document generated at the given time. It can be overridden via the presentation.
+func documentTitle(ctx context.Context, timestamp time.Time) string {
+	if title := presentationFrom(ctx).Title; title != "" {
+		return title
+	}
+	return BuildTitle(timestamp)
+}
+
+// The pages shown before the list of recipes, i.e. the cover and the dedication. Empty if there are
+// none.
+func (p Presentation) frontMatter() []string {
+	result := []string{}
+	if p.Cover != "" {
+		result = append(
+			result,
+			imageToHTML(p.Cover, p.Title, ` width="100%"`, false)+"\n",
+			`<div style="page-break-before: always;"></div>`+"\n",
+		)
+	}
+	if dedication := strings.TrimSpace(p.Dedication); dedication != "" {
+		result = append(
+			result,
+			dedication+"\n",
+			`<div style="page-break-before: always;"></div>`+"\n",
+		)
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/razziel89/mealie-addons/mealieclient"
+)
+
+func TestBuildMarkdownSingleRecipe(t *testing.T) {
+	recipes := []mealieclient.Recipe{
+		{
+			ID: "1", Name: "Apple Pie", Slug: "apple-pie",
+			Tags: []mealieclient.Organiser{{Name: "Sweet"}},
+		},
+	}
+	presentation := Presentation{Dedication: "For you", Title: "Book"}
+	ctx := WithPresentation(context.Background(), presentation)
+	ctx = WithSingleRecipe(ctx, "Apple Pie")
+	opts := MarkdownOptions{Presentation: presentationFrom(ctx)}
+	markdown := BuildMarkdown(recipes, "https://mealie", opts)
+
+	for _, unwanted := range []string{"# Recipes", "# Tags", "# Categories", "(#", "For you"} {
+		if strings.Contains(markdown, unwanted) {
+			t.Errorf("single recipe document contains %q:\n%s", unwanted, markdown)
+		}
+	}
+	if !strings.Contains(markdown, "Apple Pie") || !strings.Contains(markdown, "**Tags**: Sweet") {
+		t.Errorf("single recipe document lacks the recipe:\n%s", markdown)
+	}
+	if title := documentTitle(ctx, time.Time{}); title != "Apple Pie" {
+		t.Errorf("documentTitle() = %s, want the name of the recipe", title)
+	}
+}
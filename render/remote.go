@@ -0,0 +1,121 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+)
+
+// ConversionRequest is what the remote converter sends to a conversion service.
+type ConversionRequest struct {
+	Markdown string   `json:"markdown"`
+	Format   string   `json:"format"`
+	Title    string   `json:"title"`
+	Flags    []string `json:"flags,omitempty"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// Remote is a Converter that lets an external service perform the conversion. It sends a
+// ConversionRequest as JSON via POST to the service and expects the converted document as the
+// response body. If there are several instances of the service, requests are distributed among them
+// in turn. If an instance cannot be reached or fails with a server error, the next one is tried.
+// The token, if any, is presented as a bearer token, which instances in worker mode require.
+type Remote struct {
+	urls  []string
+	token string
+	next  atomic.Uint64
+}
+
+// NewRemote creates a remote converter that talks to the conversion services at urls.
+func NewRemote(urls []string, token string) *Remote {
+	return &Remote{urls: urls, token: token}
+}
+
+// Convert lets the remote service convert markdown input to the desired format.
+func (r *Remote) Convert(
+	ctx context.Context,
+	markdownInput string,
+	toFormat string,
+	title string,
+) ([]byte, error) {
+	body, err := json.Marshal(
+		ConversionRequest{
+			Markdown: markdownInput,
+			Format:   toFormat,
+			Title:    title,
+			Flags:    pandocFlagsFrom(ctx),
+			Metadata: metadataFrom(ctx),
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert conversion request to json: %s", err.Error())
+	}
+
+	var errs []error
+	start := r.next.Add(1)
+	for idx := range uint64(len(r.urls)) {
+		url := r.urls[(start+idx)%uint64(len(r.urls))]
+		converted, retry, err := r.convertVia(ctx, url, body)
+		if !retry || ctx.Err() != nil {
+			return converted, err
+		}
+		slog.WarnContext(
+			ctx, "conversion service failed, trying next one", "url", url, "error", err,
+		)
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Let the service at url perform the conversion. Also report whether it makes sense to retry the
+// conversion with another service, which is the case if this one could not be reached or failed
+// with a server error.
+func (r *Remote) convertVia(ctx context.Context, url string, body []byte) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to construct request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to execute request: %s", err.Error())
+	}
+	defer func() { _ = resp.Body.Close() }()
+	converted, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode >= http.StatusInternalServerError, fmt.Errorf(
+			"unexpected status code %d: %s", resp.StatusCode, string(converted),
+		)
+	}
+	return converted, false, nil
+}
@@ -0,0 +1,73 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoteFailsOverOnServerErrors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("converted"))
+	}))
+	defer working.Close()
+
+	remote := NewRemote([]string{failing.URL, working.URL}, "token")
+	// Requests are distributed in turn, so each service is asked first once.
+	for range 2 {
+		converted, err := remote.Convert(context.Background(), "# Title", "html", "Title")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(converted) != "converted" {
+			t.Errorf("unexpected conversion result %q", converted)
+		}
+	}
+}
+
+func TestRemoteDoesNotFailOverOnClientErrors(t *testing.T) {
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+	asked := false
+	other := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		asked = true
+	}))
+	defer other.Close()
+
+	// The counter starts at one, which is why the rejecting service is asked first.
+	remote := NewRemote([]string{other.URL, rejecting.URL}, "")
+	if _, err := remote.Convert(context.Background(), "# Title", "html", "Title"); err == nil {
+		t.Error("expected an error for a rejected conversion")
+	}
+	if asked {
+		t.Error("another service was asked after a client error")
+	}
+}
@@ -0,0 +1,71 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+)
+
+// CategoryStyle determines how a category is highlighted in documents. The icon is shown in front
+// of the category's name and in front of the names of all recipes in the category. The color is
+// used for the category's name. Colors are not supported in PDF documents.
+type CategoryStyle struct {
+	Color string `json:"color"`
+	Icon  string `json:"icon"`
+}
+
+// CategoryStyles map category names to their styles.
+type CategoryStyles map[string]CategoryStyle
+
+// Colors are used in style attributes. Thus, we accept only hex codes and CSS color names.
+var colorRegex = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[a-zA-Z]+)$`)
+
+// Validate ensures that the color can be used safely.
+func (s CategoryStyle) Validate() error {
+	if s.Color != "" && !colorRegex.MatchString(s.Color) {
+		return fmt.Errorf("color %s is neither a hex code nor a color name", s.Color)
+	}
+	return nil
+}
+
+// Decorate the given text, which refers to the category, with the category's icon and color.
+func (s CategoryStyles) category(name string, text string) string {
+	style, found := s[name]
+	if !found {
+		return text
+	}
+	if style.Icon != "" {
+		text = html.EscapeString(style.Icon) + " " + text
+	}
+	if style.Color != "" {
+		text = fmt.Sprintf(`<span style="color: %s;">%s</span>`, style.Color, text)
+	}
+	return text
+}
+
+// Decorate the name of a recipe with the icons of all its categories.
+func (s CategoryStyles) recipe(name string, categories []string) string {
+	for idx := len(categories) - 1; idx >= 0; idx-- {
+		if icon := s[categories[idx]].Icon; icon != "" {
+			name = html.EscapeString(icon) + " " + name
+		}
+	}
+	return name
+}
@@ -0,0 +1,102 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package render
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// Typst is a Converter that uses pandoc to convert to typst markup and then the typst executable to
+// generate a PDF. It supports only PDF output. Images are not supported.
+type Typst struct {
+	pandoc *Pandoc
+}
+
+// NewTypst creates a typst converter that uses the given pandoc converter to generate typst markup.
+func NewTypst(pandoc *Pandoc) *Typst {
+	return &Typst{pandoc: pandoc}
+}
+
+// CheckForTypst verifies that the typst executable can be found.
+func CheckForTypst() error {
+	_, err := exec.LookPath("typst")
+	if err != nil {
+		return fmt.Errorf("failed to find typst in path: %s", err.Error())
+	}
+	return nil
+}
+
+// Convert converts markdown input to a PDF via typst.
+func (t *Typst) Convert(
+	ctx context.Context,
+	markdownInput string,
+	toFormat string,
+	title string,
+) ([]byte, error) {
+	if toFormat != "pdf" {
+		return nil, fmt.Errorf("typst converter cannot convert to %s", toFormat)
+	}
+
+	markup, err := t.pandoc.Convert(ctx, markdownInput, "typst", title)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert to typst markup: %s", err.Error())
+	}
+
+	tmpdir, err := os.MkdirTemp(workdir.Dir(ctx), workdir.Prefix+"typst-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %s", err.Error())
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			slog.WarnContext(
+				ctx, "failed to remove temporary directory", "path", tmpdir, "error", err,
+			)
+		}
+	}()
+	input := filepath.Join(tmpdir, "recipes.typ")
+	output := filepath.Join(tmpdir, "recipes.pdf")
+	err = os.WriteFile(input, markup, 0o600) //nolint:mnd
+	if err != nil {
+		return nil, fmt.Errorf("failed to write typst markup: %s", err.Error())
+	}
+
+	args := []string{"compile"}
+	if fontDir := t.pandoc.current().fonts.fontDir; fontDir != "" {
+		// Typst does not know about the fonts loaded for pandoc unless told where they are.
+		args = append(args, "--font-path", fontDir)
+	}
+	args = append(args, input, output)
+	summary.From(ctx).Stage("running typst")
+	start := time.Now()
+	_, errMsg, err := runExe(ctx, "typst", args, nil, nil, "")
+	summary.From(ctx).AddPass("typst", time.Since(start))
+	logStderr(ctx, "typst", errMsg, err)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(output) //#nosec:G304
+}
@@ -0,0 +1,86 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package requestid correlates everything that happens while serving a request, in particular log
+// messages of concurrent exports.
+package requestid
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+// Header is the HTTP header that carries request IDs. IDs sent by clients, e.g. reverse proxies,
+// are used as they are. Otherwise, a new one is generated. Either way, it is sent back.
+const Header = "X-Request-ID"
+
+// Longer IDs sent by clients are replaced.
+const maxLength = 128
+
+type contextKey struct{}
+
+// With returns a context that carries the request ID.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// From returns the request ID that ctx carries or the empty string if there is none.
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Use returns id if it can be used as a request ID and a new one otherwise. IDs have to consist of
+// printable ASCII characters, which keeps clients from tampering with log lines.
+func Use(id string) string {
+	if id == "" || len(id) > maxLength {
+		return uuid.New().String()
+	}
+	for _, char := range id {
+		if char < ' ' || char > '~' {
+			return uuid.New().String()
+		}
+	}
+	return id
+}
+
+// LogHandler adds the request ID of the context passed to the slog.*Context functions to every
+// message as the attribute requestId.
+func LogHandler(handler slog.Handler) slog.Handler {
+	return logHandler{handler}
+}
+
+type logHandler struct {
+	slog.Handler
+}
+
+func (h logHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := From(ctx); id != "" {
+		record.AddAttrs(slog.String("requestId", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,165 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shorthands for common schedules.
+var cronShorthands = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// The furthest into the future to look for the next matching time. Every valid schedule matches
+// at least once within that time, e.g. on the 29th of February.
+const maxLookahead = 8 * 366 * 24 * time.Hour
+
+// Schedule determines the times at which something happens. It follows the cron format of five
+// space-separated fields: minute, hour, day of month, month, and day of week. Each field is a
+// comma-separated list of values, ranges like 1-5, or *, each optionally followed by a step like
+// /15. Sunday is day 0 or 7. As with cron, a time matches if either the day of month or the day of
+// week matches if both are restricted.
+type Schedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	// Whether the day of month or the day of week is restricted, i.e., not *.
+	daysRestricted, weekdaysRestricted bool
+}
+
+// ParseSchedule parses a schedule in the cron format. The shorthands @hourly, @daily, @weekly,
+// @monthly, and @yearly are supported, too.
+func ParseSchedule(spec string) (Schedule, error) {
+	if full, found := cronShorthands[strings.TrimSpace(spec)]; found {
+		spec = full
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 { //nolint:mnd
+		return Schedule{}, fmt.Errorf("schedule %q does not have 5 fields", spec)
+	}
+
+	var schedule Schedule
+	var err error
+	parsers := []struct {
+		target   *map[int]bool
+		min, max int
+		name     string
+	}{
+		{&schedule.minutes, 0, 59, "minute"},
+		{&schedule.hours, 0, 23, "hour"},
+		{&schedule.days, 1, 31, "day of month"},
+		{&schedule.months, 1, 12, "month"},
+		{&schedule.weekdays, 0, 7, "day of week"},
+	}
+	for idx, parser := range parsers {
+		*parser.target, err = parseField(fields[idx], parser.min, parser.max)
+		if err != nil {
+			return Schedule{}, fmt.Errorf(
+				"failed to parse %s of %q: %s", parser.name, spec, err.Error(),
+			)
+		}
+	}
+	// Sunday may be given as 7, too.
+	if schedule.weekdays[7] {
+		schedule.weekdays[0] = true
+	}
+	schedule.daysRestricted = fields[2] != "*"
+	schedule.weekdaysRestricted = fields[4] != "*"
+	return schedule, nil
+}
+
+func parseField(field string, minValue, maxValue int) (map[int]bool, error) {
+	values := map[int]bool{}
+	for part := range strings.SplitSeq(field, ",") {
+		valueRange, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %s", stepStr)
+			}
+		}
+
+		start, end := minValue, maxValue
+		if valueRange != "*" {
+			startStr, endStr, isRange := strings.Cut(valueRange, "-")
+			var err error
+			start, err = strconv.Atoi(startStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %s", startStr)
+			}
+			end = start
+			if isRange {
+				end, err = strconv.Atoi(endStr)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %s", endStr)
+				}
+			} else if hasStep {
+				// Like with cron, 5/15 means starting at 5 every 15.
+				end = maxValue
+			}
+		}
+		if start < minValue || end > maxValue || start > end {
+			return nil, fmt.Errorf("%s is not within %d-%d", part, minValue, maxValue)
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+func (s Schedule) matchesDay(t time.Time) bool {
+	day := s.days[t.Day()]
+	weekday := s.weekdays[int(t.Weekday())]
+	if s.daysRestricted && s.weekdaysRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// Next returns the first time strictly after the given one that the schedule matches. It returns
+// the zero time if there is none, e.g. for the 31st of February.
+func (s Schedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	end := after.Add(maxLookahead)
+	for t.Before(end) {
+		switch {
+		case !s.months[int(t.Month())]:
+			// Skip to the first minute of the next month.
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
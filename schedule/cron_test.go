@@ -0,0 +1,64 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday.
+	start := time.Date(2025, time.January, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, time.January, 1, 10, 31, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2025, time.January, 2, 3, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, time.January, 1, 10, 45, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.Date(2025, time.January, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2025, time.January, 1, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// Either the day of month or the day of week has to match.
+		{"0 0 15 * 5", time.Date(2025, time.January, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(test.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule() failed: %s", err.Error())
+			}
+			if got := schedule.Next(start); !got.Equal(test.want) {
+				t.Errorf("Next() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleRejectsInvalidSpecs(t *testing.T) {
+	invalid := []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *"}
+	for _, spec := range invalid {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) should have failed", spec)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package schedule exports recipes to a directory according to schedules.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/razziel89/mealie-addons/api"
+	"github.com/razziel89/mealie-addons/destination"
+	"github.com/razziel89/mealie-addons/render"
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// Export exports all recipes matching the query params in the given formats whenever the schedule
+// matches. The formats are the names of the book endpoints, e.g. pdf. Disabled exports are skipped.
+type Export struct {
+	Schedule string            `json:"schedule"`
+	Formats  []string          `json:"formats"`
+	Params   map[string]string `json:"params"`
+	Disabled bool              `json:"disabled"`
+}
+
+// Exports is the full configuration of scheduled exports. All documents are written to the
+// directory if it is set. Further destinations are configured elsewhere.
+type Exports struct {
+	Dir         string   `json:"dir"`
+	TimeoutSecs int      `json:"timeout-secs"`
+	Exports     []Export `json:"exports"`
+}
+
+// Configured determines whether any exports are enabled.
+func (e *Exports) Configured() bool {
+	for _, export := range e.Exports {
+		if !export.Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// An enabled export with its parsed schedule and generators.
+type scheduledExport struct {
+	index      int
+	schedule   Schedule
+	generators []api.ResponseGenerator
+	query      map[string][]string
+}
+
+// Determine when the next export is due after now. Schedules that never match again, e.g. for the
+// 30th of February, are skipped. Return the zero time if no export will ever run again.
+func nextRun(scheduled []scheduledExport, now time.Time) time.Time {
+	next := time.Time{}
+	for _, export := range scheduled {
+		candidate := export.schedule.Next(now)
+		if !candidate.IsZero() && (next.IsZero() || candidate.Before(next)) {
+			next = candidate
+		}
+	}
+	return next
+}
+
+// LaunchLoop starts exporting in the background. Send to the returned channel to stop it. If no
+// exports are enabled, no loop is started and the channel is nil. Every format has to be provided
+// by one of the generators. Documents are put into all destinations, including the directory.
+func LaunchLoop(
+	exports Exports,
+	source api.RecipeSource,
+	generators []api.ResponseGenerator,
+	destinations []destination.Destination,
+) (chan<- bool, error) {
+	if !exports.Configured() {
+		return nil, nil
+	}
+	if exports.Dir != "" {
+		dir := &destination.Directory{Path: exports.Dir}
+		destinations = append([]destination.Destination{dir}, destinations...)
+	}
+	if len(destinations) == 0 {
+		return nil, fmt.Errorf("scheduled exports need a directory or another destination")
+	}
+	byName := make(map[string]api.ResponseGenerator, len(generators))
+	for _, gen := range generators {
+		byName[gen.CommonName()] = gen
+	}
+
+	scheduled := []scheduledExport{}
+	for idx, export := range exports.Exports {
+		if export.Disabled {
+			continue
+		}
+		schedule, err := ParseSchedule(export.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse schedule of export %d: %s", idx+1, err.Error())
+		}
+		current := scheduledExport{index: idx + 1, schedule: schedule, query: map[string][]string{}}
+		for key, value := range export.Params {
+			current.query[key] = []string{value}
+		}
+		for _, format := range export.Formats {
+			gen, found := byName[format]
+			if !found {
+				return nil, fmt.Errorf("unknown format %s for export %d", format, idx+1)
+			}
+			// Documents may be split per recipe. That is not for mealie to know.
+			split := map[string][]string{api.SplitParam: current.query[api.SplitParam]}
+			gen, err := api.ExtractSplit(gen, split)
+			if err != nil {
+				return nil, fmt.Errorf("failed to set up export %d: %s", idx+1, err.Error())
+			}
+			current.generators = append(current.generators, gen)
+		}
+		delete(current.query, api.SplitParam)
+		scheduled = append(scheduled, current)
+	}
+
+	timeout := time.Duration(exports.TimeoutSecs) * time.Second
+	quit := make(chan bool)
+
+	go func() {
+		for {
+			// Exports that run at the same time run one after the other.
+			now := time.Now()
+			next := nextRun(scheduled, now)
+			if next.IsZero() {
+				slog.Info("no scheduled export will ever run again, stopping")
+				<-quit
+				return
+			}
+			slog.Info("next scheduled export", "at", next.Format(time.RFC3339))
+
+			select {
+			case <-quit:
+				return
+			case <-time.After(time.Until(next)):
+				for _, export := range scheduled {
+					if export.schedule.Next(now).Equal(next) {
+						run(export, source, destinations, timeout, next)
+					}
+				}
+			}
+		}
+	}()
+
+	return quit, nil
+}
+
+// Run a single export. Recipes are retrieved once for all formats. Failures are logged.
+func run(
+	export scheduledExport,
+	source api.RecipeSource,
+	destinations []destination.Destination,
+	timeout time.Duration,
+	timestamp time.Time,
+) {
+	slog.Info("running scheduled export", "export", export.index)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	query := copyQuery(export.query)
+	ctx = render.WithMetadata(ctx, render.ExtractMetadata(query))
+
+	recipes, warnings, err := source.GetRecipes(ctx, query)
+	if err != nil {
+		slog.ErrorContext(ctx, "skipping scheduled export", "export", export.index, "error", err)
+		return
+	}
+	render.OrderRecipes(recipes, query)
+	ctx = render.WithWarnings(ctx, warnings)
+
+	for _, gen := range export.generators {
+		stats := summary.New()
+		stats.SetRecipes(len(recipes))
+		genCtx, cleanup := workdir.With(summary.With(ctx, stats))
+		document, err := gen.Response(genCtx, recipes, timestamp)
+		cleanup()
+		if err == nil {
+			err = putEverywhere(ctx, destinations, api.Filename(gen, timestamp), document)
+		}
+		stats.Log(ctx, gen.CommonName(), len(document), err)
+		if err != nil {
+			slog.ErrorContext(ctx,
+				"failed to store scheduled export",
+				"export", export.index, "format", gen.CommonName(), "error", err,
+			)
+		}
+	}
+}
+
+// Query parameters must not be shared between runs since they are modified.
+func copyQuery(query map[string][]string) map[string][]string {
+	result := make(map[string][]string, len(query))
+	for key, values := range query {
+		result[key] = append([]string{}, values...)
+	}
+	return result
+}
+
+// Put a document into all destinations. A destination that fails does not keep the document from
+// being put into the others. Destinations that do not support the type of document are skipped.
+func putEverywhere(
+	ctx context.Context,
+	destinations []destination.Destination,
+	filename string,
+	document []byte,
+) error {
+	errs := []error{}
+	for _, dest := range destinations {
+		err := dest.Put(ctx, filename, document)
+		if errors.Is(err, destination.ErrUnsupported) {
+			slog.DebugContext(ctx, "skipping destination", "destination", dest.Name(), "error", err)
+			continue
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,52 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRunSkipsImpossibleSchedules(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 10, 30, 0, 0, time.UTC)
+	daily := time.Date(2025, time.January, 2, 3, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		specs []string
+		want  time.Time
+	}{
+		{"impossible first", []string{"0 0 30 2 *", "0 3 * * *"}, daily},
+		{"impossible last", []string{"0 3 * * *", "0 0 30 2 *"}, daily},
+		{"only impossible", []string{"0 0 30 2 *", "0 0 31 4 *"}, time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheduled := []scheduledExport{}
+			for idx, spec := range test.specs {
+				schedule, err := ParseSchedule(spec)
+				if err != nil {
+					t.Fatalf("ParseSchedule() failed: %s", err.Error())
+				}
+				scheduled = append(scheduled, scheduledExport{index: idx + 1, schedule: schedule})
+			}
+			if got := nextRun(scheduled, start); !got.Equal(test.want) {
+				t.Errorf("nextRun() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
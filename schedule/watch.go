@@ -0,0 +1,186 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/razziel89/mealie-addons/api"
+	"github.com/razziel89/mealie-addons/destination"
+	"github.com/razziel89/mealie-addons/render"
+	"github.com/razziel89/mealie-addons/summary"
+	"github.com/razziel89/mealie-addons/workdir"
+)
+
+// Watch re-renders presets whenever any of the recipes matching them is added, removed, or updated.
+// Mealie is polled for changes every interval.
+type Watch struct {
+	IntervalSecs int      `json:"interval-secs"`
+	TimeoutSecs  int      `json:"timeout-secs"`
+	Presets      []string `json:"presets"`
+}
+
+// Configured determines whether any presets are watched.
+func (w *Watch) Configured() bool {
+	return len(w.Presets) != 0
+}
+
+// A watched preset with the version of its recipes that has been rendered last.
+type watchedPreset struct {
+	name         string
+	generator    api.ResponseGenerator
+	params       url.Values
+	query        map[string][]string
+	presentation render.Presentation
+	version      string
+}
+
+// LaunchWatcher starts watching presets in the background. Send to the returned channel to stop it.
+// If no presets are watched, no watcher is started and the channel is nil. All presets are rendered
+// right away and whenever their recipes change. Documents are put into all destinations and cached
+// for downloads of the presets.
+func LaunchWatcher(
+	watch Watch,
+	presets map[string]api.Preset,
+	source api.RecipeSource,
+	generators []api.ResponseGenerator,
+	destinations []destination.Destination,
+) (chan<- bool, error) {
+	if !watch.Configured() {
+		return nil, nil
+	}
+	if len(destinations) == 0 && !api.CachesBooks() {
+		return nil, fmt.Errorf("watching presets needs a destination or the book cache")
+	}
+	byName := make(map[string]api.ResponseGenerator, len(generators))
+	for _, gen := range generators {
+		byName[gen.CommonName()] = gen
+	}
+
+	watched := make([]*watchedPreset, 0, len(watch.Presets))
+	for _, name := range watch.Presets {
+		preset, found := presets[name]
+		if !found {
+			return nil, fmt.Errorf("unknown preset %s to watch", name)
+		}
+		gen, found := byName[preset.Format]
+		if !found {
+			return nil, fmt.Errorf("unknown format %s for preset %s", preset.Format, name)
+		}
+		current := &watchedPreset{
+			name: name, params: url.Values{}, presentation: preset.Presentation,
+		}
+		for key, value := range preset.Params {
+			current.params.Set(key, value)
+		}
+		current.query = copyQuery(current.params)
+		// Documents may be split per recipe. That is not for mealie to know.
+		gen, err := api.ExtractSplit(gen, current.query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up watching preset %s: %s", name, err.Error())
+		}
+		current.generator = gen
+		watched = append(watched, current)
+	}
+
+	interval := time.Duration(watch.IntervalSecs) * time.Second
+	timeout := time.Duration(watch.TimeoutSecs) * time.Second
+	quit := make(chan bool)
+
+	go func() {
+		for {
+			for _, preset := range watched {
+				preset.refresh(source, destinations, timeout)
+			}
+			select {
+			case <-quit:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+
+	return quit, nil
+}
+
+// Render the preset if its recipes changed since it was rendered last. Failures are logged and the
+// preset is rendered again the next time.
+func (p *watchedPreset) refresh(
+	source api.RecipeSource,
+	destinations []destination.Destination,
+	timeout time.Duration,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	query := copyQuery(p.query)
+	ctx = render.WithMetadata(ctx, render.ExtractMetadata(query))
+	ctx = render.WithPresentation(ctx, p.presentation)
+
+	slugs, err := source.SelectSlugs(ctx, copyQuery(query))
+	if err != nil {
+		slog.ErrorContext(
+			ctx, "failed to check watched preset for changes", "preset", p.name, "error", err,
+		)
+		return
+	}
+	version := api.RecipesVersion(slugs)
+	if version == p.version {
+		return
+	}
+	if len(slugs) == 0 {
+		slog.InfoContext(ctx, "not rendering watched preset, no recipes match", "preset", p.name)
+		p.version = version
+		return
+	}
+	slog.InfoContext(ctx,
+		"rendering watched preset, its recipes changed", "preset", p.name, "recipes", len(slugs),
+	)
+
+	timestamp := time.Now()
+	recipes, warnings, err := api.GetRecipes(ctx, source, p.generator, query)
+	if err != nil {
+		slog.ErrorContext(
+			ctx, "failed to get recipes of watched preset", "preset", p.name, "error", err,
+		)
+		return
+	}
+	render.OrderRecipes(recipes, query)
+	ctx = render.WithWarnings(ctx, warnings)
+
+	stats := summary.New()
+	stats.SetRecipes(len(recipes))
+	gen := p.generator
+	genCtx, cleanup := workdir.With(summary.With(ctx, stats))
+	defer cleanup()
+	document, err := gen.Response(genCtx, recipes, timestamp)
+	if err == nil {
+		err = putEverywhere(ctx, destinations, api.Filename(gen, timestamp), document)
+	}
+	stats.Log(ctx, gen.CommonName(), len(document), err)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to store watched preset", "preset", p.name, "error", err)
+		return
+	}
+	api.CacheBook("/preset/"+p.name, p.params, slugs, document)
+	p.version = version
+}
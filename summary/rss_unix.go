@@ -1,3 +1,5 @@
+//go:build unix
+
 /* A tool to export your mealie recipes for offline storage.
 Copyright (C) 2025  Torsten Long
 
@@ -15,35 +17,22 @@ You should have received a copy of the GNU General Public License
 along with this program.  If not, see <https://www.gnu.org/licenses/>.
 */
 
-// Package main contains the server code.
-package main
+package summary
 
 import (
-	"context"
-	"time"
+	"runtime"
+	"syscall"
 )
 
-type epubGenerator struct {
-	url    string
-	pandoc *pandoc
-}
-
-func (g *epubGenerator) commonName() string {
-	return "epub"
-}
-
-func (g *epubGenerator) extension() string {
-	return "epub"
-}
-
-func (g *epubGenerator) mimeType() string {
-	return "application/epub+zip"
-}
-
-func (g *epubGenerator) response(
-	ctx context.Context,
-	recipes []recipe,
-	timestamp time.Time,
-) ([]byte, error) {
-	return g.pandoc.run(ctx, buildMarkdown(recipes, g.url), "epub", buildTitle(timestamp), nil)
+// The peak resident set size of this process in bytes, or zero if unknown.
+func peakRSS() int64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	// Only macOS reports bytes, other systems report kilobytes.
+	if runtime.GOOS == "darwin" {
+		return int64(usage.Maxrss) //nolint:unconvert
+	}
+	return int64(usage.Maxrss) * 1024 //nolint:mnd,unconvert
 }
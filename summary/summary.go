@@ -0,0 +1,182 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package summary collects statistics about individual exports and logs them as a single line.
+package summary
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pass is a single step of the conversion performed by an external executable.
+type Pass struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Export collects statistics about a single export. All methods may be called concurrently and
+// on a nil Export, in which case they do nothing. That way, code that contributes statistics need
+// not care whether they are being collected.
+type Export struct {
+	lock         sync.Mutex
+	start        time.Time
+	recipes      int
+	images       int
+	fetchedBytes int
+	passes       []Pass
+	lintWarnings []string
+	// Called whenever the stage changes.
+	observer func(stage string)
+}
+
+// New starts collecting statistics about an export that starts now.
+func New() *Export {
+	return &Export{start: time.Now()}
+}
+
+type exportKey struct{}
+
+// With returns a context that makes code contribute statistics to the given export.
+func With(ctx context.Context, export *Export) context.Context {
+	return context.WithValue(ctx, exportKey{}, export)
+}
+
+// From returns the export that the context collects statistics for, or nil.
+func From(ctx context.Context) *Export {
+	export, _ := ctx.Value(exportKey{}).(*Export)
+	return export
+}
+
+// SetRecipes records the number of exported recipes.
+func (e *Export) SetRecipes(recipes int) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.recipes = recipes
+}
+
+// AddImages records images embedded in the document.
+func (e *Export) AddImages(images int) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.images += images
+}
+
+// AddFetchedBytes records bytes retrieved from mealie.
+func (e *Export) AddFetchedBytes(fetchedBytes int) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.fetchedBytes += fetchedBytes
+}
+
+// AddPass records a step of the conversion.
+func (e *Export) AddPass(name string, duration time.Duration) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.passes = append(e.passes, Pass{Name: name, Duration: duration})
+}
+
+// AddLintWarnings records issues with the content of the document, e.g. unclosed HTML tags.
+func (e *Export) AddLintWarnings(warnings ...string) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.lintWarnings = append(e.lintWarnings, warnings...)
+}
+
+// LintWarnings returns a copy of all recorded lint warnings.
+func (e *Export) LintWarnings() []string {
+	if e == nil {
+		return nil
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return slices.Clone(e.lintWarnings)
+}
+
+// Observe makes the export report every stage it enters to fn, e.g. to show progress to users.
+func (e *Export) Observe(fn func(stage string)) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.observer = fn
+}
+
+// Stage reports the stage that the export has entered, e.g. "retrieved 120/300 recipes".
+func (e *Export) Stage(format string, args ...any) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	observer := e.observer
+	e.lock.Unlock()
+	if observer != nil {
+		observer(fmt.Sprintf(format, args...))
+	}
+}
+
+// Log logs all statistics as a single record with one attribute per statistic, which is easy to
+// parse and to compare across releases.
+func (e *Export) Log(ctx context.Context, format string, outputBytes int, err error) {
+	if e == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	passes := make([]string, 0, len(e.passes))
+	for _, pass := range e.passes {
+		passes = append(passes, fmt.Sprintf("%s:%.3fs", pass.Name, pass.Duration.Seconds()))
+	}
+	if len(passes) == 0 {
+		passes = append(passes, "none")
+	}
+	slog.InfoContext(
+		ctx, "export summary",
+		"format", format,
+		"success", err == nil,
+		"recipes", e.recipes,
+		"images", e.images,
+		"fetchedBytes", e.fetchedBytes,
+		"outputBytes", outputBytes,
+		"lintWarnings", len(e.lintWarnings),
+		"passes", strings.Join(passes, ","),
+		"total", fmt.Sprintf("%.3fs", time.Since(e.start).Seconds()),
+		"peakRssBytes", peakRSS(),
+	)
+}
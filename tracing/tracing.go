@@ -0,0 +1,124 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package tracing records what exports spend their time on as OpenTelemetry spans.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// The name of the instrumentation, which all spans carry.
+	instrumentation = "github.com/razziel89/mealie-addons"
+	// The service name used unless OTEL_SERVICE_NAME is set.
+	serviceName = "mealie-addons"
+)
+
+// SetUp exports spans via OTLP over HTTP if OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set. The exporter is configured via the standard OTEL_*
+// environment variables. Otherwise, spans are discarded at hardly any cost. It returns a function
+// that flushes all pending spans and stops exporting.
+func SetUp(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" &&
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return noop, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return noop, fmt.Errorf("failed to set up otlp exporter: %s", err.Error())
+	}
+	// Detectors that come later take precedence, i.e. OTEL_SERVICE_NAME beats the default.
+	res, err := resource.New(
+		ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return noop, fmt.Errorf("failed to describe resource: %s", err.Error())
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter), sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// Start starts a span that is a child of the span in ctx, if any. End it via End.
+func Start(
+	ctx context.Context, name string, attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends a span and marks it as failed if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// HTTPClient is an HTTP client whose requests are recorded as spans. The trace context is passed
+// along so that the server can contribute spans of its own.
+var HTTPClient = NewHTTPClient(http.DefaultTransport)
+
+// NewHTTPClient creates an HTTP client that sends requests via the transport and records them as
+// spans like HTTPClient does.
+func NewHTTPClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{Transport: otelhttp.NewTransport(transport)}
+}
+
+// Handler wraps a handler so that every request it serves is recorded as a span, continuing the
+// trace of the client if there is one. Spans are named after the method only unless the handler
+// names the route via Route.
+func Handler(handler http.Handler) http.Handler {
+	return otelhttp.NewHandler(
+		handler, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method }),
+	)
+}
+
+// Route names the span of the request in ctx after the route that served it, e.g. "/recipe/:slug".
+// Routes have far fewer distinct values than paths, which keeps spans easy to aggregate.
+func Route(ctx context.Context, method string, route string) {
+	if route == "" {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	span.SetName(method + " " + route)
+	span.SetAttributes(attribute.String("http.route", route))
+}
@@ -0,0 +1,128 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package workdir provides every export with a temporary directory of its own for fonts,
+// intermediate resources, and auxiliary files of external executables. The directory is removed
+// once the export has finished, whether it succeeded, failed, or was cancelled. Directories left
+// behind by a crash are removed at start-up.
+package workdir
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Prefix of all temporary files and directories. Entries in the root with this prefix are removed
+// at start-up.
+const Prefix = "mealie-addons-"
+
+// The directory that all temporary files and directories are created in, see SetRoot.
+var root = os.TempDir()
+
+// SetRoot makes all temporary files and directories be created in dir, which is created if it does
+// not exist. Entries left behind by an instance that did not shut down cleanly are removed, which
+// is why no two instances running at the same time may share dir. Call it before any temporary
+// file is created.
+func SetRoot(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path of %s: %s", dir, err.Error())
+	}
+	err = os.MkdirAll(dir, 0o700) //nolint:mnd
+	if err != nil {
+		return fmt.Errorf("failed to create working directory %s: %s", dir, err.Error())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list working directory %s: %s", dir, err.Error())
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), Prefix) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		slog.Info("removing leftover temporary file", "path", path)
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove leftover %s: %s", path, err.Error())
+		}
+	}
+	root = dir
+	return nil
+}
+
+// Root returns the directory that all temporary files and directories are created in.
+func Root() string {
+	return root
+}
+
+// The working directory of an export, created when it is first needed.
+type exportDir struct {
+	lock sync.Mutex
+	path string
+	done bool
+}
+
+type exportDirKey struct{}
+
+// With returns a context whose exports get a working directory of their own and a function that
+// removes it. Call that function once the export has finished, e.g. via defer.
+func With(ctx context.Context) (context.Context, func()) {
+	dir := &exportDir{}
+	cleanup := func() {
+		dir.lock.Lock()
+		defer dir.lock.Unlock()
+		dir.done = true
+		if dir.path == "" {
+			return
+		}
+		if err := os.RemoveAll(dir.path); err != nil {
+			slog.WarnContext(
+				ctx, "failed to remove working directory", "path", dir.path, "error", err,
+			)
+		}
+	}
+	return context.WithValue(ctx, exportDirKey{}, dir), cleanup
+}
+
+// Dir returns the working directory of the export that the context belongs to, creating it if
+// needed. Outside of exports or if the directory cannot be created, it returns the root.
+func Dir(ctx context.Context) string {
+	dir, _ := ctx.Value(exportDirKey{}).(*exportDir)
+	if dir == nil {
+		return root
+	}
+	dir.lock.Lock()
+	defer dir.lock.Unlock()
+	if dir.done {
+		// Whatever is created after the export has finished has to be removed by its creator.
+		return root
+	}
+	if dir.path == "" {
+		path, err := os.MkdirTemp(root, Prefix+"export-")
+		if err != nil {
+			slog.WarnContext(ctx, "failed to create working directory", "error", err)
+			return root
+		}
+		dir.path = path
+	}
+	return dir.path
+}
@@ -0,0 +1,74 @@
+/* A tool to export your mealie recipes for offline storage.
+Copyright (C) 2025  Torsten Long
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package workdir
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetRootRemovesLeftovers(t *testing.T) {
+	dir := t.TempDir()
+	leftover := filepath.Join(dir, Prefix+"export-123")
+	unrelated := filepath.Join(dir, "notes.txt")
+	if err := os.MkdirAll(filepath.Join(leftover, "aux"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(unrelated, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetRoot(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("leftover %s was not removed", leftover)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("unrelated file was removed: %s", err.Error())
+	}
+}
+
+func TestExportDirIsRemoved(t *testing.T) {
+	if err := SetRoot(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if dir := Dir(context.Background()); dir != Root() {
+		t.Errorf("expected the root outside of exports but got %s", dir)
+	}
+
+	ctx, cleanup := With(context.Background())
+	dir := Dir(ctx)
+	if dir == Root() || filepath.Dir(dir) != Root() || Dir(ctx) != dir {
+		t.Fatalf("expected a single directory in the root but got %s", dir)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "aux"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("directory %s was not removed", dir)
+	}
+	if after := Dir(ctx); after != Root() {
+		t.Errorf("expected the root after the export finished but got %s", after)
+	}
+}